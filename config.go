@@ -1,16 +1,26 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+
 	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v2"
 
+	"go-users-example/domain/users"
 	"go-users-example/infra/logger"
+	"go-users-example/infra/signer"
+	"go-users-example/transport/grpc"
 	"go-users-example/transport/http"
 )
 
 // Config will hold all the based the configuration for the app
 type Config struct {
 	HTTP   http.Config   `env:"HTTP"`
+	GRPC   grpc.Config   `env:"GRPC"`
 	Logger logger.Config `env:"LOG"`
+	Users  users.Config  `env:"USERS"`
+	Signer signer.Config `env:"SIGNER"`
 }
 
 // Load will retrieve the configuration from different sources by order of priority `flag > ENV > file`
@@ -21,3 +31,48 @@ func Load() *Config {
 
 	return c
 }
+
+// LoadRules will retrieve the validation rules of the users by order of priority `ENV > file > default`.
+// the rules of a field replace the lower priority ones of the same field, the file can be written in yaml or json
+func LoadRules(c users.Config) (users.Rules, error) {
+	rules := users.DefaultRules()
+
+	if c.RulesFile != "" {
+		data, err := ioutil.ReadFile(c.RulesFile)
+		if err != nil {
+			return users.Rules{}, fmt.Errorf("can't read rules file: %w", err)
+		}
+		var file users.Rules
+		if err := yaml.UnmarshalStrict(data, &file); err != nil {
+			return users.Rules{}, fmt.Errorf("can't parse rules file: %w", err)
+		}
+		for field, rule := range file.Fields {
+			rules.Fields[field] = rule
+		}
+	}
+
+	if c.Rules != "" {
+		var env users.Rules
+		if err := yaml.UnmarshalStrict([]byte(c.Rules), &env); err != nil {
+			return users.Rules{}, fmt.Errorf("can't parse rules: %w", err)
+		}
+		for field, rule := range env.Fields {
+			rules.Fields[field] = rule
+		}
+	}
+
+	return rules, nil
+}
+
+// LoadAttributeSchema will retrieve the JSON Schema of the custom attributes of the users from its file,
+// no custom attribute is accepted if there is no file
+func LoadAttributeSchema(c users.Config) (*users.AttributeSchema, error) {
+	var data []byte
+	if c.AttributeSchemaFile != "" {
+		var err error
+		if data, err = ioutil.ReadFile(c.AttributeSchemaFile); err != nil {
+			return nil, fmt.Errorf("can't read attribute schema file: %w", err)
+		}
+	}
+	return users.CompileAttributeSchema(data)
+}
@@ -0,0 +1,35 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	apiKeyPrefix = "uak_"
+	apiKeySize   = 32
+)
+
+// newAPIKey will generate a new random api key, the prefix allow to recognise the key (in logs, secret scanners, etc.)
+func newAPIKey() (string, error) {
+	raw := make([]byte, apiKeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("can't generate api key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(raw), nil
+}
+
+// hashAPIKey will return the representation of the key to store.
+// Note: unlike password, the key is random with enough entropy, so a fast hash is enough and allow a direct lookup
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// isAPIKey will check if the raw value looks like an api key
+func isAPIKey(key string) bool {
+	return strings.HasPrefix(key, apiKeyPrefix) && len(key) == len(apiKeyPrefix)+2*apiKeySize
+}
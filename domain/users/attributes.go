@@ -0,0 +1,241 @@
+package users
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// CodeInvalidAttribute is returned when a custom attribute doesn't match its schema
+const CodeInvalidAttribute = "invalid_attribute"
+
+// defaultAttributeSchema is used when no schema is configured, it doesn't define any custom attribute
+const defaultAttributeSchema = `{"type": "object", "properties": {}}`
+
+// attributeTypes are the json types a custom attribute can have
+var attributeTypes = map[string]bool{"string": true, "number": true, "integer": true, "boolean": true}
+
+// attributeName is the format of the names of the custom attributes, they are used as search parameters
+var attributeName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// attributeSchemaURL is the location of the schema in the compiler, it is never loaded
+const attributeSchemaURL = "attributes.json"
+
+// AttributeSchema is the JSON Schema of the custom attributes of the users.
+// the schema must describe an object whose properties are the attributes, each one with a scalar type
+type AttributeSchema struct {
+	raw      json.RawMessage
+	schema   *jsonschema.Schema
+	types    map[string]string
+	required []string
+}
+
+// CompileAttributeSchema will check and compile the JSON Schema of the attributes, the default schema is used if empty
+func CompileAttributeSchema(raw []byte) (*AttributeSchema, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		raw = []byte(defaultAttributeSchema)
+	}
+	var def struct {
+		Type       string `json:"type"`
+		Properties map[string]struct {
+			Type interface{} `json:"type"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, fmt.Errorf("can't parse attribute schema: %w", err)
+	}
+	if def.Type != "object" {
+		return nil, errors.New("attribute schema should be of type object")
+	}
+
+	s := &AttributeSchema{raw: raw, types: make(map[string]string, len(def.Properties)), required: def.Required}
+	for name, prop := range def.Properties {
+		if !attributeName.MatchString(name) {
+			return nil, fmt.Errorf("invalid attribute name %q, it should match %s", name, attributeName)
+		}
+		typ, _ := prop.Type.(string)
+		if !attributeTypes[typ] {
+			return nil, fmt.Errorf("attribute %q should have a string, number, integer or boolean type", name)
+		}
+		s.types[name] = typ
+	}
+	for _, name := range s.required {
+		if _, ok := s.types[name]; !ok {
+			return nil, fmt.Errorf("required attribute %q isn't defined", name)
+		}
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("can't load %s: the attribute schema can't reference other schemas", url)
+	}
+	if err := compiler.AddResource(attributeSchemaURL, bytes.NewReader(raw)); err != nil {
+		return nil, fmt.Errorf("can't parse attribute schema: %w", err)
+	}
+	schema, err := compiler.Compile(attributeSchemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("can't compile attribute schema: %w", err)
+	}
+	s.schema = schema
+	return s, nil
+}
+
+// Raw returns the JSON Schema as it has been configured
+func (s *AttributeSchema) Raw() json.RawMessage {
+	return s.raw
+}
+
+// validate will check the attributes against the schema
+func (s *AttributeSchema) validate(attrs map[string]interface{}) []FieldViolation {
+	var violations []FieldViolation
+	for _, name := range sortedAttributeNames(attrs) {
+		if _, ok := s.types[name]; !ok {
+			violations = append(violations, FieldViolation{
+				Field:   attributeField(name),
+				Code:    CodeUnknown,
+				Message: fmt.Sprintf("attribute %s isn't defined", name),
+			})
+		}
+	}
+	for _, name := range s.required {
+		if attrs[name] == nil {
+			violations = append(violations, FieldViolation{
+				Field:   attributeField(name),
+				Code:    CodeRequired,
+				Message: fmt.Sprintf("attribute %s is required", name),
+			})
+		}
+	}
+	if len(violations) > 0 {
+		return violations
+	}
+
+	err := s.schema.Validate(normalizeAttributes(attrs))
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return nil
+	}
+	for _, leaf := range ValidationLeaves(verr) {
+		keyword := leaf.KeywordLocation[strings.LastIndex(leaf.KeywordLocation, "/")+1:]
+		// the required attributes are checked above, with a violation by attribute
+		if keyword == "required" {
+			continue
+		}
+		field := "attributes" + strings.ReplaceAll(leaf.InstanceLocation, "/", ".")
+		violations = append(violations, FieldViolation{
+			Field:   field,
+			Code:    CodeInvalidAttribute,
+			Message: fmt.Sprintf("%s: %s", field, leaf.Message),
+			Params:  map[string]interface{}{"keyword": keyword},
+		})
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Field < violations[j].Field })
+	return violations
+}
+
+// value will parse the raw value of a search parameter according to the type of the attribute
+func (s *AttributeSchema) value(name, raw string) (interface{}, *FieldViolation) {
+	typ, ok := s.types[name]
+	if !ok {
+		return nil, &FieldViolation{Field: attributeField(name), Code: CodeUnknown, Message: fmt.Sprintf("attribute %s isn't defined", name)}
+	}
+	var (
+		v   interface{}
+		err error
+	)
+	switch typ {
+	case "string":
+		v = raw
+	case "integer":
+		var i int64
+		i, err = strconv.ParseInt(raw, 10, 64)
+		v = float64(i)
+	case "number":
+		v, err = strconv.ParseFloat(raw, 64)
+	case "boolean":
+		v, err = strconv.ParseBool(raw)
+	}
+	if err != nil {
+		return nil, &FieldViolation{
+			Field:   attributeField(name),
+			Code:    CodeInvalidFormat,
+			Message: fmt.Sprintf("attribute %s should be a %s", name, typ),
+			Params:  map[string]interface{}{"type": typ},
+		}
+	}
+	return v, nil
+}
+
+// normalizeAttributes returns the attributes as decoded from json (float64 numbers, ...) without the null values,
+// the form which is validated and stored
+func normalizeAttributes(attrs map[string]interface{}) map[string]interface{} {
+	if len(attrs) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return attrs
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return attrs
+	}
+	for name, v := range normalized {
+		if v == nil {
+			delete(normalized, name)
+		}
+	}
+	return normalized
+}
+
+// normalizeAttributeChanges returns the changes of the attributes in their stored form, a null value removes the attribute
+func normalizeAttributeChanges(changes map[string]interface{}) map[string]interface{} {
+	if len(changes) == 0 {
+		return nil
+	}
+	normalized := normalizeAttributes(changes)
+	if normalized == nil {
+		normalized = make(map[string]interface{}, len(changes))
+	}
+	for name, v := range changes {
+		if v == nil {
+			normalized[name] = nil
+		}
+	}
+	return normalized
+}
+
+// ValidationLeaves returns the innermost errors of a JSON Schema validation, the ones reporting the actual violations
+func ValidationLeaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, ValidationLeaves(cause)...)
+	}
+	return leaves
+}
+
+func sortedAttributeNames(attrs map[string]interface{}) []string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func attributeField(name string) string {
+	return "attributes." + name
+}
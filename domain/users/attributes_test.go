@@ -0,0 +1,89 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testAttributeSchema = `{
+	"type": "object",
+	"properties": {
+		"department": {"type": "string", "enum": ["sales", "legal"]},
+		"birthday": {"type": "string", "format": "date"},
+		"level": {"type": "integer", "minimum": 1},
+		"remote": {"type": "boolean"}
+	},
+	"required": ["department"]
+}`
+
+func TestCompileAttributeSchema_Invalid(t *testing.T) {
+	for name, raw := range map[string]string{
+		"not json":           `{`,
+		"not an object":      `{"type": "string"}`,
+		"invalid name":       `{"type": "object", "properties": {"Department": {"type": "string"}}}`,
+		"not a scalar":       `{"type": "object", "properties": {"tags": {"type": "array"}}}`,
+		"missing type":       `{"type": "object", "properties": {"level": {"minimum": 1}}}`,
+		"undefined required": `{"type": "object", "properties": {}, "required": ["level"]}`,
+		"invalid schema":     `{"type": "object", "properties": {"level": {"type": "integer", "minimum": "one"}}}`,
+		"remote reference":   `{"type": "object", "properties": {"level": {"type": "integer", "$ref": "https://example.com/level.json"}}}`,
+	} {
+		_, err := CompileAttributeSchema([]byte(raw))
+		require.Error(t, err, name)
+	}
+}
+
+func TestAttributeSchema_Validate(t *testing.T) {
+	s, err := CompileAttributeSchema([]byte(testAttributeSchema))
+	require.NoError(t, err)
+
+	require.Empty(t, s.validate(map[string]interface{}{"department": "sales", "birthday": "1990-02-21", "level": 2, "remote": true}))
+	require.Empty(t, s.validate(map[string]interface{}{"department": "legal"}))
+
+	for name, tc := range map[string]struct {
+		attrs map[string]interface{}
+		field string
+		code  string
+	}{
+		"unknown":          {attrs: map[string]interface{}{"department": "sales", "phone": "+33"}, field: "attributes.phone", code: CodeUnknown},
+		"missing required": {attrs: map[string]interface{}{"level": 2}, field: "attributes.department", code: CodeRequired},
+		"null required":    {attrs: map[string]interface{}{"department": nil}, field: "attributes.department", code: CodeRequired},
+		"wrong type":       {attrs: map[string]interface{}{"department": "sales", "level": "2"}, field: "attributes.level", code: CodeInvalidAttribute},
+		"not an integer":   {attrs: map[string]interface{}{"department": "sales", "level": 2.5}, field: "attributes.level", code: CodeInvalidAttribute},
+		"below minimum":    {attrs: map[string]interface{}{"department": "sales", "level": 0}, field: "attributes.level", code: CodeInvalidAttribute},
+		"invalid date":     {attrs: map[string]interface{}{"department": "sales", "birthday": "21/02/1990"}, field: "attributes.birthday", code: CodeInvalidAttribute},
+		"not in enum":      {attrs: map[string]interface{}{"department": "it"}, field: "attributes.department", code: CodeInvalidAttribute},
+	} {
+		violations := s.validate(tc.attrs)
+		require.Len(t, violations, 1, name)
+		require.Equal(t, tc.field, violations[0].Field, name)
+		require.Equal(t, tc.code, violations[0].Code, name)
+	}
+}
+
+func TestAttributeSchema_Value(t *testing.T) {
+	s, err := CompileAttributeSchema([]byte(testAttributeSchema))
+	require.NoError(t, err)
+
+	for raw, expected := range map[[2]string]interface{}{
+		{"department", "sales"}: "sales",
+		{"level", "3"}:          float64(3),
+		{"remote", "true"}:      true,
+	} {
+		v, violation := s.value(raw[0], raw[1])
+		require.Nil(t, violation, raw)
+		require.Equal(t, expected, v, raw)
+	}
+
+	_, violation := s.value("level", "three")
+	require.Equal(t, CodeInvalidFormat, violation.Code)
+	_, violation = s.value("phone", "+33")
+	require.Equal(t, CodeUnknown, violation.Code)
+}
+
+func TestCompileAttributeSchema_Default(t *testing.T) {
+	s, err := CompileAttributeSchema(nil)
+	require.NoError(t, err)
+	require.Empty(t, s.validate(nil))
+	require.Len(t, s.validate(map[string]interface{}{"department": "sales"}), 1)
+}
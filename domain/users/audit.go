@@ -0,0 +1,57 @@
+package users
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// redactedFields are the fields which are audited without their values
+var redactedFields = map[string]bool{"password": true}
+
+const redactedValue = "[redacted]"
+
+// diffUsers will compute the field level changes between two state of a user, nil means the user doesn't exist.
+// fields are named by their json name
+func diffUsers(before, after *User) []FieldChange {
+	var changes []FieldChange
+	t := reflect.TypeOf(User{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		b, a := fieldValue(before, i), fieldValue(after, i)
+		if b == a {
+			continue
+		}
+		if redactedFields[name] {
+			b, a = redact(b), redact(a)
+		}
+		changes = append(changes, FieldChange{Field: name, Before: b, After: a})
+	}
+	return changes
+}
+
+func fieldValue(usr *User, i int) string {
+	if usr == nil {
+		return ""
+	}
+	v := reflect.ValueOf(*usr).Field(i)
+	if v.IsZero() {
+		return ""
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	data, _ := json.Marshal(v.Interface())
+	return string(data)
+}
+
+func redact(v string) string {
+	if v == "" {
+		return ""
+	}
+	return redactedValue
+}
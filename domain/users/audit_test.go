@@ -0,0 +1,27 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffUsers(t *testing.T) {
+	before := &User{ID: "id", FirstName: "bob", Email: "bob@test.com", Password: "hash1"}
+	after := &User{ID: "id", FirstName: "bob", Email: "bobby@test.com", Password: "hash2", Country: "FR"}
+
+	require.Equal(t, []FieldChange{
+		{Field: "password", Before: redactedValue, After: redactedValue},
+		{Field: "email", Before: "bob@test.com", After: "bobby@test.com"},
+		{Field: "country", After: "FR"},
+	}, diffUsers(before, after))
+
+	require.Equal(t, []FieldChange{
+		{Field: "id", Before: "id"},
+		{Field: "first_name", Before: "bob"},
+		{Field: "password", Before: redactedValue},
+		{Field: "email", Before: "bob@test.com"},
+	}, diffUsers(before, nil))
+
+	require.Empty(t, diffUsers(before, before))
+}
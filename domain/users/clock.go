@@ -0,0 +1,9 @@
+package users
+
+import "time"
+
+// Clock will give the current time, it allows usecases to be deterministic in tests
+type Clock func() time.Time
+
+// SystemClock is the Clock based on the system time
+var SystemClock Clock = time.Now
@@ -0,0 +1,50 @@
+package users
+
+import "time"
+
+// Config will hold users domain specific configuration
+type Config struct {
+	// RestoreWindow is the duration during which a deleted user can still be restored before being purged
+	RestoreWindow time.Duration `env:"USERS_RESTORE_WINDOW" env-default:"720h"`
+	// PurgeInterval is the duration between two runs of the purge of expired deleted users
+	PurgeInterval time.Duration `env:"USERS_PURGE_INTERVAL" env-default:"1h"`
+	// MFAIssuer is the name displayed in the authenticator apps of the users
+	MFAIssuer string `env:"USERS_MFA_ISSUER" env-default:"go-users-example"`
+	// MFAChallengeTTL is the duration a user has to provide its second factor after a successful password check
+	MFAChallengeTTL time.Duration `env:"USERS_MFA_CHALLENGE_TTL" env-default:"5m"`
+	// EmailProviderRules enable the provider specific canonicalization of the emails (dots and plus tags of gmail, ...)
+	EmailProviderRules bool `env:"USERS_EMAIL_PROVIDER_RULES" env-default:"false"`
+	// EmailBlocklistFile is the path of a file listing one disposable domain per line, their emails are refused
+	EmailBlocklistFile string `env:"USERS_EMAIL_BLOCKLIST_FILE"`
+	// NameScripts are the unicode scripts (Latin, Greek, Han, ...) allowed in the names, all the scripts are allowed if empty
+	NameScripts []string `env:"USERS_NAME_SCRIPTS" env-separator:","`
+	// ReservedNickNames are the nicknames which can't be used in addition of the default ones (admin, support, ...)
+	ReservedNickNames []string `env:"USERS_RESERVED_NICKNAMES" env-separator:","`
+	// RulesFile is the path of the yaml or json file of the validation rules of the fields, the default rules are used if empty
+	RulesFile string `env:"USERS_RULES_FILE"`
+	// Rules are the validation rules of the fields in yaml or json, they override the ones of the RulesFile
+	Rules string `env:"USERS_RULES"`
+	// PhoneCodeTTL is the duration a user has to provide the verification code sent to its phone
+	PhoneCodeTTL time.Duration `env:"USERS_PHONE_CODE_TTL" env-default:"10m"`
+	// PhoneResendCooldown is the duration to wait before sending another code to the phone of a user
+	PhoneResendCooldown time.Duration `env:"USERS_PHONE_RESEND_COOLDOWN" env-default:"1m"`
+	// PhoneMaxDailyCodes is the number of codes which can be sent to a user in 24h, no limit if zero
+	PhoneMaxDailyCodes int `env:"USERS_PHONE_MAX_DAILY_CODES" env-default:"5"`
+	// AttributeSchemaFile is the path of the JSON Schema of the custom attributes, no custom attribute is accepted if empty
+	AttributeSchemaFile string `env:"USERS_ATTRIBUTE_SCHEMA_FILE"`
+	// OIDCIssuer is the public url of the server, it identifies the issuer of the OpenID Connect tokens
+	OIDCIssuer string `env:"USERS_OIDC_ISSUER" env-default:"http://localhost:8080"`
+	// OIDCCodeTTL is the duration a client has to exchange an authorization code
+	OIDCCodeTTL time.Duration `env:"USERS_OIDC_CODE_TTL" env-default:"1m"`
+	// OIDCTokenTTL is the lifetime of the access and id tokens
+	OIDCTokenTTL time.Duration `env:"USERS_OIDC_TOKEN_TTL" env-default:"15m"`
+	// OIDCRefreshTokenTTL is the lifetime of a refresh token, each rotation issue a token with a new lifetime
+	OIDCRefreshTokenTTL time.Duration `env:"USERS_OIDC_REFRESH_TOKEN_TTL" env-default:"720h"`
+	// ImportWorkers is the number of rows of an import created concurrently, the hash of the passwords is slow
+	ImportWorkers int `env:"USERS_IMPORT_WORKERS" env-default:"4"`
+	// Admins are the ids of the users whose api keys can be granted the users:admin scope, the emails aren't used as
+	// they are chosen by the users
+	Admins []string `env:"USERS_ADMINS" env-separator:","`
+	// BatchMaxSize is the maximum number of operations of a batch
+	BatchMaxSize int `env:"USERS_BATCH_MAX_SIZE" env-default:"100"`
+}
@@ -0,0 +1,62 @@
+package users
+
+import "context"
+
+type contextKey string
+
+const requestInfoContextKey contextKey = "request_info"
+
+// AnonymousActor is the actor of the requests which aren't authenticated
+const AnonymousActor = "anonymous"
+
+// RequestInfo hold the origin of a request, it is attached to the ChangeEvent to trace who did the change
+type RequestInfo struct {
+	Actor     string `json:"actor"`
+	RequestID string `json:"request_id"`
+	SourceIP  string `json:"source_ip"`
+}
+
+// WithRequestInfo will attach the origin of the request to the context
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoContextKey, info)
+}
+
+// RequestInfoFromContext will return the origin of the request, the actor is anonymous if nothing was attached
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, ok := ctx.Value(requestInfoContextKey).(RequestInfo)
+	if !ok || info.Actor == "" {
+		info.Actor = AnonymousActor
+	}
+	return info
+}
+
+const pendingEffectsContextKey contextKey = "pending_effects"
+
+// pendingEffects are the side effects of the changes of an atomic batch, run once all its changes are committed
+type pendingEffects struct {
+	effects []func()
+}
+
+// withPendingEffects will hold back the side effects of the changes made with the context until run is called
+func withPendingEffects(ctx context.Context) (context.Context, *pendingEffects) {
+	pending := &pendingEffects{}
+	return context.WithValue(ctx, pendingEffectsContextKey, pending), pending
+}
+
+// run will start the held back side effects in the background
+func (p *pendingEffects) run() {
+	for _, effect := range p.effects {
+		go effect()
+	}
+	p.effects = nil
+}
+
+// afterCommit will start the side effect of a change, ex: its notification, in the background once the change is
+// committed: right away, or at the end of the atomic batch which the context belongs to. A reverted change has no effect
+func afterCommit(ctx context.Context, effect func()) {
+	if pending, ok := ctx.Value(pendingEffectsContextKey).(*pendingEffects); ok {
+		pending.effects = append(pending.effects, effect)
+		return
+	}
+	go effect()
+}
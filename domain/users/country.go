@@ -0,0 +1,87 @@
+package users
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// Country is an ISO 3166-1 country
+type Country struct {
+	Alpha2  string `json:"alpha2"`
+	Alpha3  string `json:"alpha3"`
+	Numeric string `json:"numeric"`
+	Name    string `json:"name"`
+}
+
+// countryIndex allow to find a country by its alpha-2, alpha-3 or english name
+var countryIndex = func() map[string]Country {
+	idx := make(map[string]Country, 3*len(countries))
+	for _, c := range countries {
+		idx[countryKey(c.Alpha2)] = c
+		idx[countryKey(c.Alpha3)] = c
+		idx[countryKey(c.Name)] = c
+	}
+	return idx
+}()
+
+func countryKey(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer("&", "and", "’", "'").Replace(s)
+}
+
+// LookupCountry will find the country by its alpha-2 code, its alpha-3 code or its english name, case insensitive
+func LookupCountry(s string) (Country, bool) {
+	c, ok := countryIndex[countryKey(s)]
+	return c, ok
+}
+
+// normalizeCountry returns the alpha-2 code of the country, unknown countries are kept as is
+func normalizeCountry(s string) string {
+	if c, ok := LookupCountry(s); ok {
+		return c.Alpha2
+	}
+	return s
+}
+
+// countryLanguages are the languages in which the country names can be given, english is the default
+var countryLanguages = language.NewMatcher(append([]language.Tag{language.English}, display.Supported.Tags()...))
+
+// CountryNames give the names of the countries in the language preferred by a reader
+type CountryNames struct {
+	lang  language.Tag
+	namer display.Namer
+}
+
+// NewCountryNames will select the supported language matching best the priority list (ex: "fr-CH, fr;q=0.9, en;q=0.8")
+func NewCountryNames(languages string) *CountryNames {
+	tags, _, _ := language.ParseAcceptLanguage(languages)
+	_, i, _ := countryLanguages.Match(tags...)
+	lang := language.English
+	if i > 0 {
+		lang = display.Supported.Tags()[i-1]
+	}
+	return &CountryNames{lang: lang, namer: display.Regions(lang)}
+}
+
+// Language returns the language of the names
+func (n *CountryNames) Language() string {
+	return n.lang.String()
+}
+
+// Name returns the name of the country identified by its alpha-2 code, empty if the country is unknown
+func (n *CountryNames) Name(alpha2 string) string {
+	c, ok := LookupCountry(alpha2)
+	if !ok {
+		return ""
+	}
+	region, err := language.ParseRegion(c.Alpha2)
+	if err != nil {
+		return c.Name
+	}
+	if name := n.namer.Name(region); name != "" {
+		return name
+	}
+	return c.Name
+}
@@ -0,0 +1,54 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupCountry(t *testing.T) {
+	require.Len(t, countries, 249)
+	for _, s := range []string{"FR", "fr", "FRA", "france", " France "} {
+		c, ok := LookupCountry(s)
+		require.True(t, ok, s)
+		require.Equal(t, "FR", c.Alpha2, s)
+		require.Equal(t, "250", c.Numeric, s)
+	}
+	c, ok := LookupCountry("Bosnia and Herzegovina")
+	require.True(t, ok)
+	require.Equal(t, "BIH", c.Alpha3)
+	// the names are the ISO 3166-1 english short names
+	for alpha2, name := range map[string]string{"VN": "Viet Nam", "US": "United States of America", "CI": "Côte d'Ivoire"} {
+		c, ok := LookupCountry(name)
+		require.True(t, ok, name)
+		require.Equal(t, alpha2, c.Alpha2, name)
+		require.Equal(t, name, c.Name, name)
+	}
+
+	for _, s := range []string{"", "XX", "YU", "Gondor"} {
+		_, ok := LookupCountry(s)
+		require.False(t, ok, s)
+	}
+}
+
+func TestValidateCountry(t *testing.T) {
+	require.Nil(t, validateCountry(""))
+	require.Nil(t, validateCountry("deu"))
+	require.Equal(t, CodeUnknown, validateCountry("Gondor").Code)
+}
+
+func TestCountryNames(t *testing.T) {
+	for languages, expected := range map[string][2]string{
+		"":                             {"en", "Germany"},
+		"fr-CH, fr;q=0.9, en;q=0.8":    {"fr", "Allemagne"},
+		"de":                           {"de", "Deutschland"},
+		"es-419":                       {"es", "Alemania"},
+		"tlh, en;q=0.5":                {"en", "Germany"},
+		"not a language priority list": {"en", "Germany"},
+	} {
+		names := NewCountryNames(languages)
+		require.Equal(t, expected[0], names.Language()[:2], languages)
+		require.Equal(t, expected[1], names.Name("DE"), languages)
+	}
+	require.Empty(t, NewCountryNames("fr").Name("Gondor"))
+}
@@ -0,0 +1,210 @@
+package users
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Email limits defined by RFC 5321
+const (
+	maxEmailLocalLen  = 64
+	maxEmailDomainLen = 253
+	maxEmailLabelLen  = 63
+)
+
+// Email is a parsed email address, the domain is always lowercased and in its ascii (punycode) form
+type Email struct {
+	Local  string
+	Domain string
+}
+
+// String implements fmt.Stringer, it returns the normalized address
+func (e Email) String() string {
+	return e.Local + "@" + e.Domain
+}
+
+// emailIDNA convert the internationalized domains to their ascii form, as a browser would do
+var emailIDNA = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.Transitional(false))
+
+// ParseEmail will parse a bare RFC 5322 address (no display name nor comments) and normalize its domain
+func ParseEmail(raw string) (Email, error) {
+	if strings.Count(raw, "@") == 0 {
+		return Email{}, errors.New("an email should contains one '@'")
+	}
+	addr, err := mail.ParseAddress(raw)
+	if err != nil || addr.Name != "" || addr.Address != unquoteLocal(raw) {
+		return Email{}, errors.New("an email should be a valid address")
+	}
+
+	at := strings.LastIndex(addr.Address, "@")
+	local, domain := raw[:strings.LastIndex(raw, "@")], addr.Address[at+1:]
+	if len(local) > maxEmailLocalLen {
+		return Email{}, fmt.Errorf("an email local part should have a len less than %d", maxEmailLocalLen)
+	}
+
+	domain, err = emailIDNA.ToASCII(domain)
+	if err != nil {
+		return Email{}, errors.New("an email should have a valid domain")
+	}
+	if err := validateEmailDomain(domain); err != nil {
+		return Email{}, err
+	}
+
+	return Email{Local: local, Domain: domain}, nil
+}
+
+// unquoteLocal returns the address as net/mail return it, with the quotes of the local part removed
+func unquoteLocal(raw string) string {
+	at := strings.LastIndex(raw, "@")
+	local := raw[:at]
+	if len(local) < 2 || local[0] != '"' || local[len(local)-1] != '"' {
+		return raw
+	}
+	unquoted := strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(local[1 : len(local)-1])
+	return unquoted + raw[at:]
+}
+
+func validateEmailDomain(domain string) error {
+	if len(domain) > maxEmailDomainLen {
+		return fmt.Errorf("an email domain should have a len less than %d", maxEmailDomainLen)
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return errors.New("an email should contains at least one '.' on host part")
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > maxEmailLabelLen || label[0] == '-' || label[len(label)-1] == '-' {
+			return errors.New("an email should have a valid domain")
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return errors.New("an email should have a valid domain")
+			}
+		}
+	}
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return errors.New("an email should have a valid domain")
+	}
+	return nil
+}
+
+// providerRule rewrite the local part of the addresses of a provider which ignore some of its characters
+type providerRule struct {
+	// domain is the canonical domain of the provider
+	domain      string
+	ignoreDots  bool
+	ignoreAfter string
+}
+
+// emailProviders list the providers known to deliver several local parts to the same mailbox
+var emailProviders = map[string]providerRule{
+	"gmail.com":      {domain: "gmail.com", ignoreDots: true, ignoreAfter: "+"},
+	"googlemail.com": {domain: "gmail.com", ignoreDots: true, ignoreAfter: "+"},
+	"outlook.com":    {domain: "outlook.com", ignoreAfter: "+"},
+	"hotmail.com":    {domain: "hotmail.com", ignoreAfter: "+"},
+	"icloud.com":     {domain: "icloud.com", ignoreAfter: "+"},
+	"fastmail.com":   {domain: "fastmail.com", ignoreAfter: "+"},
+}
+
+// EmailPolicy define which emails are accepted and how two emails are considered as the same mailbox
+type EmailPolicy struct {
+	providerRules bool
+	disposable    map[string]bool
+}
+
+// NewEmailPolicy will create the policy from the configuration, the disposable domains file is loaded if provided
+func NewEmailPolicy(c Config) (*EmailPolicy, error) {
+	p := &EmailPolicy{providerRules: c.EmailProviderRules, disposable: map[string]bool{}}
+	if c.EmailBlocklistFile == "" {
+		return p, nil
+	}
+
+	f, err := os.Open(c.EmailBlocklistFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't open email blocklist: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domain, err := emailIDNA.ToASCII(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid domain %q in email blocklist: %w", line, err)
+		}
+		p.disposable[domain] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read email blocklist: %w", err)
+	}
+	return p, nil
+}
+
+// Canonical returns the key identifying the mailbox of the email, two emails with the same key can't be used by two users
+func (p *EmailPolicy) Canonical(e Email) string {
+	rule, ok := emailProviders[e.Domain]
+	if !p.providerRules || !ok {
+		return e.String()
+	}
+	local := strings.ToLower(e.Local)
+	if rule.ignoreAfter != "" {
+		local = strings.SplitN(local, rule.ignoreAfter, 2)[0]
+	}
+	if rule.ignoreDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + rule.domain
+}
+
+// validate will check the email format and that its domain isn't a disposable one
+func (p *EmailPolicy) validate(email string) *FieldViolation {
+	if v := validateEmail(email); v != nil {
+		return v
+	}
+	e, _ := ParseEmail(email)
+	if p.isDisposable(e.Domain) {
+		return &FieldViolation{Field: "email", Code: CodeDisposable, Message: "an email should not use a disposable domain"}
+	}
+	return nil
+}
+
+// isDisposable will check the domain and its parents against the blocklist
+func (p *EmailPolicy) isDisposable(domain string) bool {
+	for {
+		if p.disposable[domain] {
+			return true
+		}
+		i := strings.Index(domain, ".")
+		if i < 0 {
+			return false
+		}
+		domain = domain[i+1:]
+	}
+}
+
+// normalizeEmail returns the normalized address of the email and its canonical key
+func (p *EmailPolicy) normalizeEmail(email string) (string, string, error) {
+	e, err := ParseEmail(email)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", err.Error(), ErrInvalidUser)
+	}
+	return e.String(), p.Canonical(e), nil
+}
+
+// lookupEmail returns the email as it is stored to search it, invalid emails are kept as is and won't match any user
+func lookupEmail(email string) string {
+	e, err := ParseEmail(email)
+	if err != nil {
+		return email
+	}
+	return e.String()
+}
@@ -0,0 +1,76 @@
+package users
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEmail(t *testing.T) {
+	for raw, expected := range map[string]string{
+		"bob@mail.example.co.uk":    "bob@mail.example.co.uk",
+		"Bob.Smith@Example.COM":     "Bob.Smith@example.com",
+		"bob+tag@example.com":       "bob+tag@example.com",
+		"o'brien@example.ie":        "o'brien@example.ie",
+		`"bob smith"@example.com`:   `"bob smith"@example.com`,
+		`"bob@home"@example.com`:    `"bob@home"@example.com`,
+		"bob@bücher.example":        "bob@xn--bcher-kva.example",
+		"bob@xn--bcher-kva.example": "bob@xn--bcher-kva.example",
+		"bob@mail-1.example.com":    "bob@mail-1.example.com",
+		"δοκιμή@παράδειγμα.δοκιμή":  "δοκιμή@xn--hxajbheg2az3al.xn--jxalpdlp",
+	} {
+		e, err := ParseEmail(raw)
+		require.NoError(t, err, raw)
+		require.Equal(t, expected, e.String(), raw)
+	}
+
+	for _, raw := range []string{
+		"",
+		"bob",
+		"bob@localhost",
+		"bob@@example.com",
+		"bob@example..com",
+		".bob@example.com",
+		"bob.@example.com",
+		"bob..smith@example.com",
+		"bob smith@example.com",
+		" bob@example.com",
+		"Bob <bob@example.com>",
+		"bob@example.com (Bob)",
+		"bob@-example.com",
+		"bob@example-.com",
+		"bob@example.123",
+		"bob@exa_mple.com",
+		"bob@[127.0.0.1]",
+		strings.Repeat("b", 65) + "@example.com",
+		"bob@" + strings.Repeat("a", 64) + ".com",
+	} {
+		_, err := ParseEmail(raw)
+		require.Error(t, err, raw)
+	}
+}
+
+func TestEmailPolicy_Canonical(t *testing.T) {
+	for raw, expected := range map[string][2]string{
+		"Bob.Smith@Example.com":      {"Bob.Smith@example.com", "Bob.Smith@example.com"},
+		"Bob.Smith+news@gmail.com":   {"Bob.Smith+news@gmail.com", "bobsmith@gmail.com"},
+		"bob.smith@googlemail.com":   {"bob.smith@googlemail.com", "bobsmith@gmail.com"},
+		"bob.smith+news@outlook.com": {"bob.smith+news@outlook.com", "bob.smith@outlook.com"},
+		"bob.smith+news@example.com": {"bob.smith+news@example.com", "bob.smith+news@example.com"},
+	} {
+		e, err := ParseEmail(raw)
+		require.NoError(t, err, raw)
+		require.Equal(t, expected[0], (&EmailPolicy{}).Canonical(e), raw)
+		require.Equal(t, expected[1], (&EmailPolicy{providerRules: true}).Canonical(e), raw)
+	}
+}
+
+func TestEmailPolicy_Disposable(t *testing.T) {
+	p := &EmailPolicy{disposable: map[string]bool{"mailinator.com": true}}
+	require.Nil(t, p.validate("bob@example.com"))
+	require.Nil(t, p.validate("bob@notmailinator.com"))
+	require.Equal(t, CodeDisposable, p.validate("bob@mailinator.com").Code)
+	require.Equal(t, CodeDisposable, p.validate("bob@eu.Mailinator.com").Code)
+	require.Equal(t, CodeInvalidFormat, p.validate("bob").Code)
+}
@@ -0,0 +1,9 @@
+package users
+
+// TOTPCode expose the TOTP computation to generate valid codes in tests
+var TOTPCode = func(secret string, step int64) (string, error) {
+	return totpCode(secret, step)
+}
+
+// TOTPStep expose the TOTP time step computation
+var TOTPStep = totpStep
@@ -0,0 +1,154 @@
+package users
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrInvalidImport is returned if the format of an import isn't supported or its csv header isn't valid
+	ErrInvalidImport = errors.New("provided import isn't valid")
+	// ErrImportJobNotFound is returned if no import job match the provided id
+	ErrImportJobNotFound = errors.New("import job not found")
+	// ErrImportNotResumable is returned when resuming an import job which didn't fail
+	ErrImportNotResumable = errors.New("import job can't be resumed")
+)
+
+const (
+	// maxImportLineSize is the size limit of a ndjson line
+	maxImportLineSize = 1 << 20
+	// maxImportErrors is the number of row errors kept on a job, the others are only counted
+	maxImportErrors = 1000
+)
+
+// importColumns are the csv columns, named after the json fields of CreateReq. The attributes are a json object
+var importColumns = map[string]func(req *CreateReq, value string) error{
+	"first_name": func(req *CreateReq, value string) error { req.FirstName = value; return nil },
+	"last_name":  func(req *CreateReq, value string) error { req.LastName = value; return nil },
+	"nick_name":  func(req *CreateReq, value string) error { req.NickName = value; return nil },
+	"email":      func(req *CreateReq, value string) error { req.Email = value; return nil },
+	"country":    func(req *CreateReq, value string) error { req.Country = value; return nil },
+	"password":   func(req *CreateReq, value string) error { req.RawPassword = value; return nil },
+	"phone":      func(req *CreateReq, value string) error { req.Phone = value; return nil },
+	"attributes": func(req *CreateReq, value string) error {
+		if value == "" {
+			return nil
+		}
+		if err := json.Unmarshal([]byte(value), &req.Attributes); err != nil {
+			return fmt.Errorf("attributes should be a json object: %w", err)
+		}
+		return nil
+	},
+}
+
+// importRow is a row read from an import, err is set if it can't be read as a CreateReq
+type importRow struct {
+	row int
+	req *CreateReq
+	err error
+}
+
+// importReader will read the rows of an import one by one, io.EOF is returned at the end. The other errors stop the
+// import, the rows which can't be read are returned with their error
+type importReader interface {
+	Next() (*importRow, error)
+}
+
+// newImportReader will return the reader of the format, the header of a csv is read and checked
+func newImportReader(format ImportFormat, r io.Reader) (importReader, error) {
+	switch format {
+	case ImportCSV:
+		return newCSVImportReader(r)
+	case ImportNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+		return &ndjsonImportReader{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("format %q isn't supported: %w", format, ErrInvalidImport)
+	}
+}
+
+type csvImportReader struct {
+	reader  *csv.Reader
+	columns []string
+	row     int
+}
+
+func newCSVImportReader(r io.Reader) (*csvImportReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("the csv header is missing: %w", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read csv header: %s: %w", err, ErrInvalidImport)
+	}
+	seen := make(map[string]bool)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := importColumns[column]; !ok {
+			return nil, fmt.Errorf("unknown csv column %q: %w", column, ErrInvalidImport)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("duplicated csv column %q: %w", column, ErrInvalidImport)
+		}
+		seen[column] = true
+		header[i] = column
+	}
+	return &csvImportReader{reader: reader, columns: header}, nil
+}
+
+func (c *csvImportReader) Next() (*importRow, error) {
+	record, err := c.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	c.row++
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return &importRow{row: c.row, err: fmt.Errorf("invalid csv row: %w", perr.Err)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	req := &CreateReq{}
+	for i, value := range record {
+		if err := importColumns[c.columns[i]](req, value); err != nil {
+			return &importRow{row: c.row, err: err}, nil
+		}
+	}
+	return &importRow{row: c.row, req: req}, nil
+}
+
+type ndjsonImportReader struct {
+	scanner *bufio.Scanner
+	row     int
+}
+
+func (n *ndjsonImportReader) Next() (*importRow, error) {
+	for n.scanner.Scan() {
+		line := bytes.TrimSpace(n.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		n.row++
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		req := &CreateReq{}
+		if err := decoder.Decode(req); err != nil {
+			return &importRow{row: n.row, err: fmt.Errorf("invalid json row: %w", err)}, nil
+		}
+		return &importRow{row: n.row, req: req}, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read row %d: %w", n.row+1, err)
+	}
+	return nil, io.EOF
+}
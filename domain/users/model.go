@@ -1,6 +1,11 @@
 package users
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
 
 // Operation define the action taken to the model. for example, did we create a new user.
 type Operation string
@@ -27,6 +32,28 @@ var (
 	//
 	// Note: No ordering is guaranted by design
 	DeleteOp Operation = "delete"
+
+	// RestoreOp define the restoration of a deleted model still in its restore window.
+	//
+	// `After` model will be filled on the event with the restored user
+	//
+	// Note: No ordering is guaranted by design
+	RestoreOp Operation = "restore"
+
+	// PurgeOp define the permanent removal of a deleted model once its restore window expired.
+	// after this operation, nothing is kept about the model.
+	//
+	// No `After` model will be filled on the event, as the operation erase the user
+	//
+	// Note: No ordering is guaranted by design
+	PurgeOp Operation = "purge"
+
+	// EraseOp define the irreversible erasure of all the data held about the user (right to erasure).
+	//
+	// `Before` model will only contain the ID of the erased user, no personal data is propagated
+	//
+	// Note: No ordering is guaranted by design
+	EraseOp Operation = "erase"
 )
 
 // ChangeEvent will be emitted on each change in the user base to notify other systems of the changes.
@@ -35,6 +62,8 @@ type ChangeEvent struct {
 	Op     Operation
 	Before *User
 	After  *User
+	// Origin is the request which triggered the change
+	Origin RequestInfo
 }
 
 // User hold the definition of what is a user in the system
@@ -44,9 +73,283 @@ type User struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	NickName  string `json:"nick_name"`
+	// NickNameKey is the skeleton of the nickname, it is used to check the uniqueness of the nicknames
+	NickNameKey string `json:"-"`
 	// Password is the hash representation of the user password
 	// Note: as is, its printed on if logged, but as it contains hash representation is not that bad, but still should be improved
 	Password string `json:"password"`
 	Email    string `json:"email"`
-	Country  string `json:"country"` // Note: here it should be a defined list and not an open field
+	// CanonicalEmail identify the mailbox of the email, it is used to check the uniqueness of the emails
+	CanonicalEmail string `json:"-"`
+	// Country is the ISO 3166-1 alpha-2 code of the country of the user
+	Country string `json:"country"`
+	// Phone is the E.164 representation of the phone of the user
+	Phone string `json:"phone,omitempty"`
+	// PhoneVerifiedAt is set once the user proved to own the phone, it is reset when the phone change
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty"`
+	// Attributes are the custom attributes of the user, defined by the attribute schema
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// DeletedAt is set when the user has been soft deleted, the user can still be restored until it is purged
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Version is increased by the store on each change of the user, an update is only stored on the version it was read
+	Version int64 `json:"-"`
+}
+
+// MFA hold the second factor (TOTP) state of a user
+type MFA struct {
+	UserID string
+	// Secret is the base32 representation of the TOTP shared secret
+	Secret string
+	// Confirmed is set once the user proved to own the secret, the second factor is only required after it
+	Confirmed bool
+	// RecoveryCodes are the hash representation of the remaining single use recovery codes
+	RecoveryCodes []string
+	// LastStep is the last TOTP time step accepted, used to prevent code replay
+	LastStep int64
+}
+
+// PhoneVerification is a pending verification of the phone of a user by a code sent by SMS
+type PhoneVerification struct {
+	UserID string
+	// Phone is the phone the code has been sent to, the verification is void if the phone of the user changed since
+	Phone string
+	// CodeHash is the hash representation of the code
+	CodeHash  string
+	ExpiresAt time.Time
+	// SentAt is the time the last code was sent, no other code is sent before the resend cooldown
+	SentAt time.Time
+	// WindowStart is the start of the window limiting the codes sent and the wrong attempts, they are kept across the
+	// codes sent during the window
+	WindowStart time.Time
+	// Sent is the number of codes sent since WindowStart
+	Sent int
+	// Attempts is the number of wrong codes provided since WindowStart
+	Attempts int
+}
+
+// Challenge is a pending login waiting for the second factor of the user
+type Challenge struct {
+	ID     string
+	UserID string
+	// CreatedAt is when the password of the user was checked
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// Binding is the binding of the login, the challenge is only completed with the same binding
+	Binding string
+}
+
+// Scope define what an api key is allowed to do
+type Scope string
+
+var (
+	// ScopeUsersRead allow to read the users
+	ScopeUsersRead Scope = "users:read"
+	// ScopeUsersWrite allow to create, update and delete the users
+	ScopeUsersWrite Scope = "users:write"
+	// ScopeUsersAdmin allow to act on all the users, the other keys only act on their own user. It is only granted to
+	// the keys of the admins, and only used while their user is an admin, see Config.Admins
+	ScopeUsersAdmin Scope = "users:admin"
+)
+
+// APIKey is a named credential used by services to call the API on behalf of a user
+type APIKey struct {
+	ID     string  `json:"id"`
+	UserID string  `json:"user_id"`
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+	// Hash is the hash representation of the key, the key itself is only known by the user
+	Hash       string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HasScope will check if the key has been granted the scope
+func (k *APIKey) HasScope(scope Scope) bool {
+	return hasScope(k.Scopes, scope)
+}
+
+// withoutScope returns a copy of the scopes without scope
+func withoutScope(scopes []Scope, scope Scope) []Scope {
+	kept := make([]Scope, 0, len(scopes))
+	for _, s := range scopes {
+		if s != scope {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+func hasScope(scopes []Scope, scope Scope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldChange is the change of a single field of the user, values are the json representation of the field
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// AuditEntry is an immutable record of a change of a user.
+// The entries are chained by their hash, so any modification of a previous entry can be detected.
+// The personal data (changes values and source ip) are only chained through their digest, which allow to erase them
+// without breaking the chain
+type AuditEntry struct {
+	// Seq is the position of the entry in the audit log, starting at 1
+	Seq       uint64        `json:"seq"`
+	UserID    string        `json:"user_id"`
+	Time      time.Time     `json:"time"`
+	Op        Operation     `json:"op"`
+	Actor     string        `json:"actor"`
+	RequestID string        `json:"request_id"`
+	SourceIP  string        `json:"source_ip"`
+	Changes   []FieldChange `json:"changes"`
+	// Erased is set when the personal data of the entry has been erased
+	Erased     bool   `json:"erased,omitempty"`
+	DataDigest string `json:"data_digest"`
+	PrevHash   string `json:"prev_hash"`
+	Hash       string `json:"hash"`
+}
+
+// ComputeDataDigest will compute the digest of the personal data of the entry
+func (e AuditEntry) ComputeDataDigest() string {
+	data, _ := json.Marshal(struct {
+		SourceIP string        `json:"source_ip"`
+		Changes  []FieldChange `json:"changes"`
+	}{SourceIP: e.SourceIP, Changes: e.Changes})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ComputeHash will compute the hash of the entry chained with the previous one, Hash field is ignored and
+// the personal data are only taken into account through DataDigest. Erased is ignored too as the data are erased after
+// the entry is chained: an erased entry is only valid for a user whose erasure (EraseOp entry) is in the chain
+func (e AuditEntry) ComputeHash() string {
+	e.Hash = ""
+	e.SourceIP = ""
+	e.Changes = nil
+	e.Erased = false
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ErasureReceipt is the signed proof that the data of a user has been erased
+type ErasureReceipt struct {
+	UserID   string    `json:"user_id"`
+	ErasedAt time.Time `json:"erased_at"`
+	// Erased contains the number of records erased or anonymized by store
+	Erased map[string]int `json:"erased"`
+	// Signature is the base64 signature of the json receipt without the signature field
+	Signature string `json:"signature,omitempty"`
+}
+
+// SignedPayload will return the content of the receipt covered by the signature
+func (r ErasureReceipt) SignedPayload() []byte {
+	r.Signature = ""
+	data, _ := json.Marshal(r)
+	return data
+}
+
+// OIDCClient is an application relying on the users to authenticate them through OpenID Connect
+type OIDCClient struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// RedirectURIs are the only uris the authorization responses are sent to
+	RedirectURIs []string `json:"redirect_uris"`
+	// Confidential clients authenticate with their secret, the public ones (mobile and browser apps) can't keep one
+	Confidential bool `json:"confidential"`
+	// SecretHash is the hash representation of the secret of a confidential client
+	SecretHash string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AuthorizationCode is the authorization given by a user to a client, exchanged once for tokens
+type AuthorizationCode struct {
+	// Hash is the hash representation of the code, the code itself is only known by the client
+	Hash        string
+	ClientID    string
+	UserID      string
+	RedirectURI string
+	Scopes      []string
+	Nonce       string
+	// CodeChallenge is the S256 PKCE challenge, the client proves it initiated the authorization with its verifier
+	CodeChallenge string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+}
+
+// RefreshToken is a grant of a client to get new tokens without the user, a token is rotated on each use
+type RefreshToken struct {
+	// Hash is the hash representation of the token, the token itself is only known by the client
+	Hash string
+	// FamilyID identify the tokens rotated from the same authorization, they are all revoked if a rotated one is reused
+	FamilyID  string
+	ClientID  string
+	UserID    string
+	Scopes    []string
+	AuthTime  time.Time
+	ExpiresAt time.Time
+	// RotatedAt is set once the token has been exchanged for a new one
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+}
+
+// ImportFormat is the format of the rows of an import
+type ImportFormat string
+
+// the supported formats of the imports
+const (
+	// ImportCSV is a csv with a header naming the columns after the json fields of CreateReq
+	ImportCSV ImportFormat = "csv"
+	// ImportNDJSON is a CreateReq json object per line
+	ImportNDJSON ImportFormat = "ndjson"
+)
+
+// ImportStatus is the progress of an import job
+type ImportStatus string
+
+// the statuses of an import job, a failed job can be resumed from its checkpoint
+const (
+	ImportPending   ImportStatus = "pending"
+	ImportRunning   ImportStatus = "running"
+	ImportCompleted ImportStatus = "completed"
+	ImportFailed    ImportStatus = "failed"
+)
+
+// ImportJob is a bulk creation of users processed in the background, its rows are created through the same
+// validation as Create
+type ImportJob struct {
+	ID     string       `json:"id"`
+	Format ImportFormat `json:"format"`
+	// DryRun jobs only validate the rows, no user is created
+	DryRun bool         `json:"dry_run"`
+	Status ImportStatus `json:"status"`
+	// Processed is the number of rows processed, the checkpoint a resumed job restarts from
+	Processed int `json:"processed"`
+	// Created is the number of users created, or of valid rows for a dry run
+	Created int `json:"created"`
+	Failed  int `json:"failed"`
+	// Errors are the errors of the failed rows, only the first ones are kept
+	Errors []ImportRowError `json:"errors"`
+	// Error is the reason the job stopped before the end of its rows
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ImportRowError is the reason a row of an import failed
+type ImportRowError struct {
+	// Row is the 1-based index of the row, the csv header and the blank lines aren't counted
+	Row        int              `json:"row"`
+	Message    string           `json:"message"`
+	Violations []FieldViolation `json:"violations,omitempty"`
 }
@@ -0,0 +1,153 @@
+package users
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// nameSeparators are the punctuation allowed between the letters of a name
+const nameSeparators = " -'’."
+
+// nickNameSeparators are the punctuation allowed between the letters and digits of a nickname
+const nickNameSeparators = "-_."
+
+// maxStackedMarks is the number of combining marks accepted on a single letter
+const maxStackedMarks = 4
+
+// NamePolicy define which characters are accepted in the names of the users
+type NamePolicy struct {
+	// scripts are the allowed scripts of the letters, all the scripts are allowed if empty
+	scripts map[string]*unicode.RangeTable
+	// reserved are the keys of the nicknames which can't be used
+	reserved map[string]bool
+}
+
+// NewNamePolicy will create the policy from the configuration, an error is returned for unknown scripts
+func NewNamePolicy(c Config) (*NamePolicy, error) {
+	p := &NamePolicy{scripts: make(map[string]*unicode.RangeTable, len(c.NameScripts)), reserved: make(map[string]bool)}
+	for _, nickName := range append(defaultReservedNickNames, c.ReservedNickNames...) {
+		p.reserved[nickNameKey(strings.TrimSpace(nickName))] = true
+	}
+	for _, script := range c.NameScripts {
+		script = strings.TrimSpace(script)
+		table, ok := unicode.Scripts[script]
+		if !ok {
+			return nil, fmt.Errorf("unknown unicode script %q in name policy", script)
+		}
+		p.scripts[script] = table
+	}
+	return p, nil
+}
+
+func (p *NamePolicy) validateFirstName(firstName string) *FieldViolation {
+	return p.validateName("first_name", "firstname", firstName, nameSeparators, false)
+}
+
+func (p *NamePolicy) validateLastName(lastName string) *FieldViolation {
+	return p.validateName("last_name", "lastname", lastName, nameSeparators, false)
+}
+
+func (p *NamePolicy) validateNickName(nickName string) *FieldViolation {
+	if v := p.validateName("nick_name", "nickname", nickName, nickNameSeparators, true); v != nil {
+		return v
+	}
+	if p.reserved[nickNameKey(nickName)] {
+		return &FieldViolation{Field: "nick_name", Code: CodeReserved, Message: "nickname is reserved"}
+	}
+	return nil
+}
+
+// validateName will check the characters of the normalized name and their scripts, the length is checked by the rules
+func (p *NamePolicy) validateName(field, name, value string, separators string, digits bool) *FieldViolation {
+	value = normalizeName(value)
+	if v := validateNameCharacters(field, name, value, separators, digits); v != nil {
+		return v
+	}
+	return p.validateScripts(field, name, value)
+}
+
+// validateNameCharacters will only accept letters with their marks, the separators (not first nor last) and the digits if allowed.
+// it rejects the control, invisible and bidi override characters as well as the symbols (emoji, ...)
+func validateNameCharacters(field, name, value string, separators string, digits bool) *FieldViolation {
+	marks := 0
+	for i, r := range value {
+		if !unicode.IsMark(r) {
+			marks = 0
+		}
+		switch {
+		case unicode.IsLetter(r), digits && unicode.IsDigit(r):
+			continue
+		case unicode.IsMark(r) && i > 0 && marks < maxStackedMarks:
+			marks++
+			continue
+		case strings.ContainsRune(separators, r) && i > 0 && i+utf8.RuneLen(r) < len(value):
+			continue
+		}
+		return &FieldViolation{
+			Field:   field,
+			Code:    CodeInvalidCharacter,
+			Message: fmt.Sprintf("%s should not contains the character %U", name, r),
+			Params:  map[string]interface{}{"character": fmt.Sprintf("%U", r)},
+		}
+	}
+	return nil
+}
+
+// validateScripts will check that the letters of the name use an allowed script
+func (p *NamePolicy) validateScripts(field, name, value string) *FieldViolation {
+	if len(p.scripts) == 0 {
+		return nil
+	}
+	for _, r := range value {
+		if !unicode.IsLetter(r) || p.allowed(r) {
+			continue
+		}
+		return &FieldViolation{
+			Field:   field,
+			Code:    CodeScriptNotAllowed,
+			Message: fmt.Sprintf("%s should only use the scripts %s", name, strings.Join(p.scriptNames(), ", ")),
+			Params:  map[string]interface{}{"scripts": p.scriptNames()},
+		}
+	}
+	return nil
+}
+
+func (p *NamePolicy) allowed(r rune) bool {
+	for _, table := range p.scripts {
+		if unicode.Is(table, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *NamePolicy) scriptNames() []string {
+	names := make([]string, 0, len(p.scripts))
+	for name := range p.scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// normalizeName returns the NFC form of the name, the one which is validated and stored
+func normalizeName(name string) string {
+	return norm.NFC.String(name)
+}
+
+// characterLen returns the number of characters perceived by a reader: a letter and its combining marks are counted once
+func characterLen(s string) int {
+	var it norm.Iter
+	it.InitString(norm.NFC, s)
+	n := 0
+	for !it.Done() {
+		it.Next()
+		n++
+	}
+	return n
+}
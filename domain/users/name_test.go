@@ -0,0 +1,107 @@
+package users
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestNamePolicy_Valid(t *testing.T) {
+	p := &NamePolicy{}
+	for _, name := range []string{
+		"Zoë",
+		"Zoe\u0308", // decomposed ë
+		"Jean-Luc",
+		"O'Brien",
+		"D’Artagnan",
+		"Mary Ann",
+		"J.R.R",
+		"Ἀθηνᾶ",
+		"Владимир",
+		"さくら",
+		"王小明",
+		"محمد",
+		"Nguyễn",
+		"ณัฐวุฒิ",
+		"Sørensen",
+	} {
+		require.Nil(t, p.validateFirstName(name), name)
+	}
+	require.Nil(t, p.validateFirstName(strings.Repeat("ë", 20)))
+	require.Nil(t, p.validateNickName("bob_42"))
+}
+
+func TestNamePolicy_Invalid(t *testing.T) {
+	p := &NamePolicy{}
+	for name, code := range map[string]string{
+		"Bob\u202eevil":                          CodeInvalidCharacter, // right-to-left override
+		"Bob\u2066x\u2069":                       CodeInvalidCharacter, // left-to-right isolate
+		"Bo\u200bb":                              CodeInvalidCharacter, // zero width space
+		"Bob\u00ad":                              CodeInvalidCharacter, // soft hyphen
+		"Bob\x00":                                CodeInvalidCharacter,
+		"Bob\n":                                  CodeInvalidCharacter,
+		"Bob\U0001F600":                          CodeInvalidCharacter,
+		"Bob42":                                  CodeInvalidCharacter,
+		"-Bob":                                   CodeInvalidCharacter,
+		"Bob ":                                   CodeInvalidCharacter,
+		"\u0301Bob":                              CodeInvalidCharacter,
+		"Bo" + strings.Repeat("\u0301", 6) + "b": CodeInvalidCharacter,
+	} {
+		v := p.validateFirstName(name)
+		require.NotNil(t, v, name)
+		require.Equal(t, code, v.Code, name)
+	}
+	require.Equal(t, CodeInvalidCharacter, p.validateNickName("bob 42").Code)
+}
+
+func TestNamePolicy_Scripts(t *testing.T) {
+	_, err := NewNamePolicy(Config{NameScripts: []string{"Latin", "Klingon"}})
+	require.Error(t, err)
+
+	p, err := NewNamePolicy(Config{NameScripts: []string{"Latin", " Greek"}})
+	require.NoError(t, err)
+	require.Nil(t, p.validateFirstName("Zoë"))
+	require.Nil(t, p.validateFirstName("Ἀθηνᾶ"))
+
+	v := p.validateFirstName("Владимир")
+	require.NotNil(t, v)
+	require.Equal(t, CodeScriptNotAllowed, v.Code)
+	require.Equal(t, []string{"Greek", "Latin"}, v.Params["scripts"])
+}
+
+func TestCharacterLen(t *testing.T) {
+	require.Equal(t, 3, characterLen("Zoë"))
+	require.Equal(t, 3, characterLen("Zoe\u0308"))
+	require.Equal(t, 3, characterLen("王小明"))
+	require.Equal(t, 0, characterLen(""))
+}
+
+func FuzzValidateNames(f *testing.F) {
+	for _, seed := range []string{"Zoë", "Zoe\u0308", "Jean-Luc", "王小明", "Bob\u202eevil", "Bo\u200bb", "Bob\U0001F600", "\u0301Bob", "a\xffb"} {
+		f.Add(seed)
+	}
+	v, err := NewValidator(&EmailPolicy{}, &NamePolicy{}, DefaultRules())
+	require.NoError(f, err)
+	f.Fuzz(func(t *testing.T, name string) {
+		for _, field := range []string{"first_name", "last_name", "nick_name"} {
+			normalized := normalizeName(name)
+			if v.validateField(field, normalized) != nil {
+				continue
+			}
+			if !norm.NFC.IsNormalString(normalized) {
+				t.Fatalf("%q is accepted but its normalized form isn't NFC", name)
+			}
+			if n := characterLen(normalized); n < 2 || n > 40 {
+				t.Fatalf("%q is accepted with %d characters", name, n)
+			}
+			for _, r := range normalized {
+				if r == unicode.ReplacementChar || unicode.In(r, unicode.Cc, unicode.Cf, unicode.Co, unicode.Cs, unicode.So) {
+					t.Fatalf("%q is accepted with the character %U", name, r)
+				}
+			}
+		}
+	})
+}
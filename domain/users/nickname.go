@@ -0,0 +1,47 @@
+package users
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// defaultReservedNickNames can't be used as nickname, they could be mistaken for the staff or a system account
+var defaultReservedNickNames = []string{
+	"abuse", "admin", "administrator", "anonymous", "api", "help", "helpdesk", "hostmaster", "info", "mod",
+	"moderator", "noreply", "no-reply", "official", "owner", "postmaster", "root", "security", "staff",
+	"support", "sysadmin", "system", "webmaster", "www",
+}
+
+// nickNameConfusables is the subset of the unicode confusables (UTS #39) of the letters and digits allowed in the nicknames,
+// the characters are mapped to their lowercased latin prototype. the compatibility forms (fullwidth, mathematical, ...)
+// are handled by the NFKC normalization. Only the single character homoglyphs are kept: the sequences looking like a
+// letter ("rn" and "m", "cl" and "d") are common in the ordinary names (ex: "burn" and "bum")
+var nickNameConfusables = map[rune]string{
+	// digits
+	'0': "o", '1': "l",
+	// latin
+	'ı': "i", 'ɩ': "i", 'ɡ': "g",
+	// cyrillic
+	'а': "a", 'с': "c", 'ԁ': "d", 'е': "e", 'һ': "h", 'і': "i", 'ј': "j", 'ӏ': "l", 'о': "o", 'р': "p",
+	'ԛ': "q", 'г': "r", 'ѕ': "s", 'ѵ': "v", 'ԝ': "w", 'х': "x", 'у': "y",
+	// greek
+	'α': "a", 'ι': "i", 'ϳ': "j", 'ν': "v", 'ο': "o", 'ρ': "p", 'γ': "y",
+}
+
+// nickNameKey returns the skeleton of the nickname: two nicknames looking the same to a reader share the same key.
+// the key is case insensitive and maps the confusable characters to a common prototype
+func nickNameKey(nickName string) string {
+	folded := cases.Fold().String(norm.NFKC.String(nickName))
+
+	var skeleton strings.Builder
+	for _, r := range norm.NFD.String(folded) {
+		if prototype, ok := nickNameConfusables[r]; ok {
+			skeleton.WriteString(prototype)
+			continue
+		}
+		skeleton.WriteRune(r)
+	}
+	return norm.NFD.String(skeleton.String())
+}
@@ -0,0 +1,42 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNickNameKey(t *testing.T) {
+	for _, same := range [][]string{
+		{"bobby", "Bobby", "BOBBY", "bοbby", "ｂｏｂｂｙ", "𝐛𝐨𝐛𝐛𝐲"},
+		{"paypal", "pаypаl", "PAYPAL", "pаураl"},
+		{"modern", "MODERN", "moԁern"},
+		{"cool", "c00l", "COOL"},
+		{"straße", "STRASSE", "strasse"},
+	} {
+		for _, nickName := range same[1:] {
+			require.Equal(t, nickNameKey(same[0]), nickNameKey(nickName), "%s and %s", same[0], nickName)
+		}
+	}
+	for _, different := range [][2]string{
+		{"bobby", "bobbie"},
+		{"bob-by", "bobby"},
+		{"bóbby", "bobby"},
+		{"burn", "bum"},
+		{"dan", "clan"},
+	} {
+		require.NotEqual(t, nickNameKey(different[0]), nickNameKey(different[1]), "%s and %s", different[0], different[1])
+	}
+}
+
+func TestNamePolicy_Reserved(t *testing.T) {
+	p, err := NewNamePolicy(Config{ReservedNickNames: []string{"boss"}})
+	require.NoError(t, err)
+	for _, nickName := range []string{"admin", "ADMIN", "аdmin", "Support", "root", "boss", "B0SS"} {
+		v := p.validateNickName(nickName)
+		require.NotNil(t, v, nickName)
+		require.Equal(t, CodeReserved, v.Code, nickName)
+	}
+	require.Nil(t, p.validateNickName("administrator2"))
+	require.Nil(t, (&NamePolicy{}).validateNickName("admin"))
+}
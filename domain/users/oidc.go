@@ -0,0 +1,221 @@
+package users
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// the errors of the OpenID Connect flows, they match the error codes of OAuth 2.0 (RFC 6749 section 5.2)
+var (
+	// ErrInvalidClient is returned when the client is unknown or its authentication failed
+	ErrInvalidClient = errors.New("invalid client")
+	// ErrInvalidRedirectURI is returned when the redirect uri isn't registered for the client, the error can't be sent
+	// to the client
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+	// ErrInvalidOIDCRequest is returned when a parameter of an authorization or a token request is missing or invalid
+	ErrInvalidOIDCRequest = errors.New("invalid oidc request")
+	// ErrInvalidScope is returned when the openid scope isn't requested, or a refresh asks for more than granted
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrUnsupportedGrantType is returned for the grants other than authorization_code and refresh_token
+	ErrUnsupportedGrantType = errors.New("unsupported grant type")
+	// ErrInvalidGrant is returned when the code or the refresh token is unknown, expired, already used or issued to
+	// another client, no detail is given on purpose
+	ErrInvalidGrant = errors.New("invalid grant")
+	// ErrInvalidToken is returned when the access token is malformed, expired or its user doesn't exist anymore
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+const (
+	// ScopeOpenID is required by all the authorizations
+	ScopeOpenID = "openid"
+
+	oidcSigningAlg    = "EdDSA"
+	oidcSecretSize    = 32
+	clientSecretPfx   = "ucs_"
+	authCodePfx       = "uac_"
+	refreshTokenPfx   = "urt_"
+	idTokenType       = "JWT"
+	accessTokenType   = "at+jwt"
+	codeChallengeS256 = "S256"
+)
+
+// oidcScopes are the supported scopes, with the claims of the user they give access to
+var oidcScopes = []struct {
+	name   string
+	claims []string
+}{
+	{name: ScopeOpenID, claims: []string{"sub"}},
+	{name: "profile", claims: []string{"name", "given_name", "family_name", "nickname", "preferred_username"}},
+	{name: "email", claims: []string{"email", "email_verified"}},
+	{name: "phone", claims: []string{"phone_number", "phone_number_verified"}},
+	{name: "address", claims: []string{"address"}},
+}
+
+// parseScopes will return the supported scopes of the space separated list, in the order of oidcScopes. The unknown
+// scopes are ignored as required by OpenID Connect, the openid scope is required
+func parseScopes(scope string) ([]string, error) {
+	requested := make(map[string]bool)
+	for _, s := range strings.Fields(scope) {
+		requested[s] = true
+	}
+	if !requested[ScopeOpenID] {
+		return nil, fmt.Errorf("the %s scope is required: %w", ScopeOpenID, ErrInvalidScope)
+	}
+	var scopes []string
+	for _, s := range oidcScopes {
+		if requested[s.name] {
+			scopes = append(scopes, s.name)
+		}
+	}
+	return scopes, nil
+}
+
+// userClaims are the claims of the user given by the scopes, the empty values are omitted.
+// Note: the emails aren't verified by the service, they are always given as unverified
+func userClaims(usr *User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": usr.ID}
+	set := func(name string, value interface{}) {
+		if value != "" {
+			claims[name] = value
+		}
+	}
+	for _, scope := range scopes {
+		switch scope {
+		case "profile":
+			set("name", strings.TrimSpace(usr.FirstName+" "+usr.LastName))
+			set("given_name", usr.FirstName)
+			set("family_name", usr.LastName)
+			set("nickname", usr.NickName)
+			set("preferred_username", usr.NickName)
+		case "email":
+			if usr.Email != "" {
+				claims["email"], claims["email_verified"] = usr.Email, false
+			}
+		case "phone":
+			if usr.Phone != "" {
+				claims["phone_number"], claims["phone_number_verified"] = usr.Phone, usr.PhoneVerifiedAt != nil
+			}
+		case "address":
+			if usr.Country != "" {
+				claims["address"] = map[string]string{"country": usr.Country}
+			}
+		}
+	}
+	return claims
+}
+
+// newOIDCSecret will generate a random client secret, code or refresh token, the prefix allow to recognise it
+func newOIDCSecret(prefix string) (string, error) {
+	raw := make([]byte, oidcSecretSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("can't generate secret: %w", err)
+	}
+	return prefix + hex.EncodeToString(raw), nil
+}
+
+// hashOIDCSecret will return the representation of the secret to store, as for the api keys the secrets are random
+// so a fast hash is enough
+func hashOIDCSecret(secret string) string {
+	return hashAPIKey(secret)
+}
+
+// TokenSigner will sign the tokens issued to the clients with an ed25519 key, its public key is published for the
+// clients to verify them
+type TokenSigner interface {
+	Signer
+	Verify(data, signature []byte) bool
+	PublicKey() ed25519.PublicKey
+}
+
+// JWK is the public key of a TokenSigner as a JSON Web Key (RFC 8037)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+func newJWK(key ed25519.PublicKey) JWK {
+	x := base64.RawURLEncoding.EncodeToString(key)
+	// the key id is the thumbprint of the key (RFC 7638), computed on its required members in lexicographic order
+	thumbprint := sha256.Sum256([]byte(`{"crv":"Ed25519","kty":"OKP","x":"` + x + `"}`))
+	return JWK{
+		KeyType:   "OKP",
+		Curve:     "Ed25519",
+		X:         x,
+		KeyID:     base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+		Use:       "sig",
+		Algorithm: oidcSigningAlg,
+	}
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// signJWT will encode the claims as a compact JWT of the type, signed by the signer
+func signJWT(signer TokenSigner, typ string, claims interface{}) (string, error) {
+	header, _ := json.Marshal(jwtHeader{Algorithm: oidcSigningAlg, Type: typ, KeyID: newJWK(signer.PublicKey()).KeyID})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("can't encode claims: %w", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := signer.Sign([]byte(signed))
+	if err != nil {
+		return "", fmt.Errorf("can't sign token: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// verifyJWT will check the signature and the type of the token, then decode its claims
+func verifyJWT(signer TokenSigner, typ, token string, claims interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed token: %w", ErrInvalidToken)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !signer.Verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return fmt.Errorf("invalid signature: %w", ErrInvalidToken)
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil || header.Algorithm != oidcSigningAlg || header.Type != typ {
+		return fmt.Errorf("unexpected header: %w", ErrInvalidToken)
+	}
+	if err := decodeJWTPart(parts[1], claims); err != nil {
+		return fmt.Errorf("malformed claims: %w", ErrInvalidToken)
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// accessTokenClaims are the claims of the access tokens (RFC 9068), their audience is the issuer which serves the
+// claims of the user
+type accessTokenClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	ClientID  string `json:"client_id"`
+	Scope     string `json:"scope"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	JWTID     string `json:"jti"`
+}
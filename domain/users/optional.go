@@ -0,0 +1,117 @@
+package users
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+var jsonNull = []byte("null")
+
+// OptionalString is a field of an update which can be absent (the field is kept), null (the field is cleared)
+// or set to a value, possibly empty
+type OptionalString struct {
+	// Set is true when the field is provided, null or not
+	Set bool
+	// Null is true when the field is provided as null
+	Null  bool
+	Value string
+}
+
+// SetString returns a field set to the value
+func SetString(v string) OptionalString {
+	return OptionalString{Set: true, Value: v}
+}
+
+// NullString returns a field set to null
+func NullString() OptionalString {
+	return OptionalString{Set: true, Null: true}
+}
+
+// apply returns the value of the field after the update of the current value, null clears the value
+func (o OptionalString) apply(current string) string {
+	if !o.Set {
+		return current
+	}
+	return o.Value
+}
+
+// MarshalJSON implements json.Marshaler, an absent field is represented as null
+func (o OptionalString) MarshalJSON() ([]byte, error) {
+	if !o.Set || o.Null {
+		return jsonNull, nil
+	}
+	return json.Marshal(o.Value)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, it is only called when the field is provided
+func (o *OptionalString) UnmarshalJSON(data []byte) error {
+	*o = OptionalString{Set: true}
+	if bytes.Equal(bytes.TrimSpace(data), jsonNull) {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// OptionalAttributes are the custom attributes of an update which can be absent (the attributes are kept),
+// null (all the attributes are removed) or set, in which case only the given attributes are changed and a null value removes one
+type OptionalAttributes struct {
+	// Set is true when the attributes are provided, null or not
+	Set bool
+	// Null is true when the attributes are provided as null
+	Null  bool
+	Value map[string]interface{}
+}
+
+// SetAttributes returns attributes changing the given ones
+func SetAttributes(v map[string]interface{}) OptionalAttributes {
+	return OptionalAttributes{Set: true, Value: v}
+}
+
+// NullAttributes returns attributes removing all the current ones
+func NullAttributes() OptionalAttributes {
+	return OptionalAttributes{Set: true, Null: true}
+}
+
+// apply returns the attributes after the update of the current attributes, the current map is never modified
+func (o OptionalAttributes) apply(current map[string]interface{}) map[string]interface{} {
+	switch {
+	case !o.Set:
+		return current
+	case o.Null:
+		return nil
+	}
+	merged := make(map[string]interface{}, len(current)+len(o.Value))
+	for name, v := range current {
+		merged[name] = v
+	}
+	for name, v := range normalizeAttributeChanges(o.Value) {
+		if v == nil {
+			delete(merged, name)
+			continue
+		}
+		merged[name] = v
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// MarshalJSON implements json.Marshaler, absent attributes are represented as null
+func (o OptionalAttributes) MarshalJSON() ([]byte, error) {
+	if !o.Set || o.Null {
+		return jsonNull, nil
+	}
+	return json.Marshal(o.Value)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, it is only called when the attributes are provided
+func (o *OptionalAttributes) UnmarshalJSON(data []byte) error {
+	*o = OptionalAttributes{Set: true}
+	if bytes.Equal(bytes.TrimSpace(data), jsonNull) {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
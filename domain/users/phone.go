@@ -0,0 +1,73 @@
+package users
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+const (
+	phoneCodeDigits = 6
+	// maxPhoneCodeAttempts is the number of wrong codes accepted during a phoneCodeWindow, across the codes sent
+	maxPhoneCodeAttempts = 5
+	// phoneCodeWindow is the period limiting the codes sent to a user and its wrong attempts
+	phoneCodeWindow = 24 * time.Hour
+)
+
+// ParsePhone will parse the phone number and return it in its E.164 form (+33612345678).
+// a national number is read in the region of the ISO 3166-1 country, an international number (+...) is required without country
+func ParsePhone(raw, country string) (string, error) {
+	region := ""
+	if c, ok := LookupCountry(country); ok {
+		region = c.Alpha2
+	}
+	raw = strings.TrimSpace(raw)
+	if region == "" && !strings.HasPrefix(raw, "+") {
+		return "", errors.New("phone should be an international number (+...) when the country is unknown")
+	}
+	number, err := phonenumbers.Parse(raw, region)
+	if err != nil {
+		return "", fmt.Errorf("can't parse phone: %w", err)
+	}
+	if !phonenumbers.IsValidNumber(number) {
+		return "", errors.New("phone isn't a valid number")
+	}
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
+
+// normalizePhone returns the E.164 form of the phone, the phone is kept as is if it can't be parsed
+func normalizePhone(phone, country string) string {
+	if phone == "" {
+		return ""
+	}
+	if e164, err := ParsePhone(phone, country); err == nil {
+		return e164
+	}
+	return phone
+}
+
+// validatePhone accept the phones already normalized to E.164
+func validatePhone(phone string) *FieldViolation {
+	if _, err := ParsePhone(phone, ""); err != nil {
+		return &FieldViolation{Field: "phone", Code: CodeInvalidFormat, Message: err.Error()}
+	}
+	return nil
+}
+
+// newPhoneCode will generate a random numeric verification code
+func newPhoneCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < phoneCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("can't generate phone code: %w", err)
+	}
+	return fmt.Sprintf("%0*d", phoneCodeDigits, n), nil
+}
@@ -0,0 +1,40 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePhone(t *testing.T) {
+	for raw, expected := range map[[2]string]string{
+		{"+33 6 12 34 56 78", ""}:       "+33612345678",
+		{"06 12 34 56 78", "FR"}:        "+33612345678",
+		{"06.12.34.56.78", "france"}:    "+33612345678",
+		{"+32 470 12 34 56", "FR"}:      "+32470123456",
+		{"(202) 555-0143", "USA"}:       "+12025550143",
+		{"0041 79 123 45 67", "Italy"}:  "+41791234567",
+		{" +44 7400 123456 ", "Gondor"}: "+447400123456",
+	} {
+		phone, err := ParsePhone(raw[0], raw[1])
+		require.NoError(t, err, raw)
+		require.Equal(t, expected, phone, raw)
+	}
+
+	for _, raw := range [][2]string{
+		{"06 12 34 56 78", ""},
+		{"06 12 34 56 78", "Gondor"},
+		{"+33 6 12", ""},
+		{"not a phone", "FR"},
+		{"+999 123456789", ""},
+	} {
+		_, err := ParsePhone(raw[0], raw[1])
+		require.Error(t, err, raw)
+	}
+}
+
+func TestNewPhoneCode(t *testing.T) {
+	code, err := newPhoneCode()
+	require.NoError(t, err)
+	require.Regexp(t, `^[0-9]{6}$`, code)
+}
@@ -0,0 +1,224 @@
+package users
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// FieldRule is the declarative validation of a field of the user
+type FieldRule struct {
+	// Required refuse the empty value
+	Required bool `yaml:"required" json:"required"`
+	// Min and Max are the length limits of the value in characters, no maximum if Max is 0
+	Min int `yaml:"min" json:"min"`
+	Max int `yaml:"max" json:"max"`
+	// Pattern is a regular expression the value should match
+	Pattern string `yaml:"pattern" json:"pattern"`
+	// Enum are the only values accepted if not empty
+	Enum []string `yaml:"enum" json:"enum"`
+}
+
+// Rules is the declarative validation of the user fields, the fields are named by their json name
+type Rules struct {
+	Fields map[string]FieldRule `yaml:"fields" json:"fields"`
+}
+
+// ruleFields are the fields which can be validated by the rules, in the order of the violations, with their display name
+var ruleFields = []struct {
+	field string
+	name  string
+	value func(usr *User) string
+}{
+	{field: "first_name", name: "firstname", value: func(usr *User) string { return normalizeName(usr.FirstName) }},
+	{field: "last_name", name: "lastname", value: func(usr *User) string { return normalizeName(usr.LastName) }},
+	{field: "nick_name", name: "nickname", value: func(usr *User) string { return normalizeName(usr.NickName) }},
+	{field: "email", name: "email", value: func(usr *User) string { return lookupEmail(usr.Email) }},
+	{field: "country", name: "country", value: func(usr *User) string { return normalizeCountry(usr.Country) }},
+	{field: "phone", name: "phone", value: func(usr *User) string { return normalizePhone(usr.Phone, usr.Country) }},
+}
+
+// DefaultRules returns the rules applied when no rule is configured
+func DefaultRules() Rules {
+	return Rules{Fields: map[string]FieldRule{
+		"first_name": {Required: true, Min: 2, Max: 20},
+		"last_name":  {Required: true, Min: 4, Max: 40},
+		"nick_name":  {Required: true, Min: 4, Max: 20},
+		"email":      {Required: true},
+	}}
+}
+
+// compiledRule is a FieldRule ready to validate values
+type compiledRule struct {
+	FieldRule
+	field   string
+	name    string
+	pattern *regexp.Regexp
+	enum    map[string]bool
+}
+
+// compileRules will check the rules and compile them, an error is returned on the first invalid rule
+func compileRules(rules Rules) (map[string]*compiledRule, error) {
+	known := make(map[string]string, len(ruleFields))
+	for _, f := range ruleFields {
+		known[f.field] = f.name
+	}
+
+	compiled := make(map[string]*compiledRule, len(rules.Fields))
+	for field, rule := range rules.Fields {
+		name, ok := known[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q in rules", field)
+		}
+		if rule.Min < 0 || rule.Max < 0 || (rule.Max != 0 && rule.Min > rule.Max) {
+			return nil, fmt.Errorf("invalid length limits %d-%d for field %q", rule.Min, rule.Max, field)
+		}
+		c := &compiledRule{FieldRule: rule, field: field, name: name}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for field %q: %w", field, err)
+			}
+			c.pattern = pattern
+		}
+		if len(rule.Enum) > 0 {
+			c.enum = make(map[string]bool, len(rule.Enum))
+			for _, v := range rule.Enum {
+				c.enum[v] = true
+			}
+		}
+		compiled[field] = c
+	}
+	return compiled, nil
+}
+
+// validate will check the value against the rule, empty values are only checked to be required
+func (r *compiledRule) validate(value string) *FieldViolation {
+	if value == "" {
+		if r.Required {
+			return &FieldViolation{Field: r.field, Code: CodeRequired, Message: fmt.Sprintf("%s is required", r.name)}
+		}
+		return nil
+	}
+	if n := characterLen(value); n < r.Min || (r.Max > 0 && n > r.Max) {
+		return validateLength(r.field, r.name, value, r.Min, r.Max)
+	}
+	if r.pattern != nil && !r.pattern.MatchString(value) {
+		return &FieldViolation{
+			Field:   r.field,
+			Code:    CodeInvalidFormat,
+			Message: fmt.Sprintf("%s should match %s", r.name, r.Pattern),
+			Params:  map[string]interface{}{"pattern": r.Pattern},
+		}
+	}
+	if r.enum != nil && !r.enum[value] {
+		values := make([]string, 0, len(r.enum))
+		for v := range r.enum {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		return &FieldViolation{
+			Field:   r.field,
+			Code:    CodeNotAllowed,
+			Message: fmt.Sprintf("%s should be one of %s", r.name, strings.Join(values, ", ")),
+			Params:  map[string]interface{}{"values": values},
+		}
+	}
+	return nil
+}
+
+// Validator will validate the users against the rules, the policies and the schema of the custom attributes.
+// the rules and the schema can be replaced while running
+type Validator struct {
+	emails *EmailPolicy
+	names  *NamePolicy
+	// rules hold the map[string]*compiledRule in use
+	rules atomic.Value
+	// attributes hold the *AttributeSchema in use
+	attributes atomic.Value
+}
+
+// NewValidator will create the validator without custom attributes, an error is returned if the rules are invalid
+func NewValidator(emails *EmailPolicy, names *NamePolicy, rules Rules) (*Validator, error) {
+	v := &Validator{emails: emails, names: names}
+	if err := v.SetRules(rules); err != nil {
+		return nil, err
+	}
+	schema, err := CompileAttributeSchema(nil)
+	if err != nil {
+		return nil, err
+	}
+	v.SetAttributeSchema(schema)
+	return v, nil
+}
+
+// SetRules will replace the rules in use, the previous rules are kept if the new ones are invalid
+func (v *Validator) SetRules(rules Rules) error {
+	compiled, err := compileRules(rules)
+	if err != nil {
+		return fmt.Errorf("can't compile rules: %w", err)
+	}
+	v.rules.Store(compiled)
+	return nil
+}
+
+// SetAttributeSchema will replace the schema of the custom attributes in use
+func (v *Validator) SetAttributeSchema(schema *AttributeSchema) {
+	v.attributes.Store(schema)
+}
+
+// AttributeSchema returns the schema of the custom attributes in use
+func (v *Validator) AttributeSchema() *AttributeSchema {
+	return v.attributes.Load().(*AttributeSchema)
+}
+
+func (v *Validator) rule(field string) *compiledRule {
+	return v.rules.Load().(map[string]*compiledRule)[field]
+}
+
+// validateUser will check all the fields of a user, a new one or the result of an update
+func (v *Validator) validateUser(usr *User) error {
+	verr := &ValidationError{}
+	for _, f := range ruleFields {
+		verr.add(v.validateField(f.field, f.value(usr)))
+	}
+	verr.Violations = append(verr.Violations, v.AttributeSchema().validate(usr.Attributes)...)
+	return verr.errOrNil()
+}
+
+// validateField will check the value against the rule of the field then against the policies
+func (v *Validator) validateField(field, value string) *FieldViolation {
+	if rule := v.rule(field); rule != nil {
+		if violation := rule.validate(value); violation != nil {
+			return violation
+		}
+	}
+	if value == "" {
+		return nil
+	}
+	switch field {
+	case "first_name":
+		return v.names.validateFirstName(value)
+	case "last_name":
+		return v.names.validateLastName(value)
+	case "nick_name":
+		return v.names.validateNickName(value)
+	case "email":
+		return v.emails.validate(value)
+	case "country":
+		return validateCountry(value)
+	case "phone":
+		return validatePhone(value)
+	}
+	return nil
+}
+
+// maxLen returns the maximum length of the field, 0 if there is no maximum
+func (v *Validator) maxLen(field string) int {
+	if rule := v.rule(field); rule != nil {
+		return rule.Max
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package users
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileRules_Invalid(t *testing.T) {
+	for name, rules := range map[string]Rules{
+		"unknown field":  {Fields: map[string]FieldRule{"password": {Required: true}}},
+		"negative min":   {Fields: map[string]FieldRule{"first_name": {Min: -1}}},
+		"min over max":   {Fields: map[string]FieldRule{"first_name": {Min: 10, Max: 5}}},
+		"invalid regexp": {Fields: map[string]FieldRule{"nick_name": {Pattern: "^[a-z"}}},
+	} {
+		_, err := compileRules(rules)
+		require.Error(t, err, name)
+	}
+}
+
+func TestValidator_Rules(t *testing.T) {
+	v, err := NewValidator(&EmailPolicy{}, &NamePolicy{}, Rules{Fields: map[string]FieldRule{
+		"first_name": {Required: true, Min: 2, Max: 5},
+		"nick_name":  {Min: 3, Pattern: "^[a-z0-9]+$"},
+		"country":    {Required: true, Enum: []string{"FR", "BE"}},
+	}})
+	require.NoError(t, err)
+
+	for field, values := range map[string]map[string]string{
+		"first_name": {"": CodeRequired, "Z": CodeTooShort, "Zoë": "", "Zoëlle": CodeTooLong},
+		"nick_name":  {"": "", "bo": CodeTooShort, "bobby": "", "Bobby": CodeInvalidFormat, strings.Repeat("b", 50): ""},
+		"country":    {"": CodeRequired, "FR": "", "DE": CodeNotAllowed, "Gondor": CodeNotAllowed},
+		"last_name":  {"": "", "Li": ""},
+	} {
+		for value, code := range values {
+			violation := v.validateField(field, value)
+			if code == "" {
+				require.Nil(t, violation, "%s: %s", field, value)
+				continue
+			}
+			require.NotNil(t, violation, "%s: %s", field, value)
+			require.Equal(t, code, violation.Code, "%s: %s", field, value)
+		}
+	}
+}
+
+func TestValidator_SetRules(t *testing.T) {
+	v, err := NewValidator(&EmailPolicy{}, &NamePolicy{}, DefaultRules())
+	require.NoError(t, err)
+	require.Equal(t, CodeTooShort, v.validateField("first_name", "Z").Code)
+
+	require.Error(t, v.SetRules(Rules{Fields: map[string]FieldRule{"first_name": {Pattern: "("}}}))
+	require.Equal(t, CodeTooShort, v.validateField("first_name", "Z").Code)
+
+	require.NoError(t, v.SetRules(Rules{Fields: map[string]FieldRule{"first_name": {Min: 1}}}))
+	require.Nil(t, v.validateField("first_name", "Z"))
+	require.Equal(t, 0, v.maxLen("nick_name"))
+}
+
+func TestValidator_ValidateUser_Rules(t *testing.T) {
+	v, err := NewValidator(&EmailPolicy{}, &NamePolicy{}, Rules{Fields: map[string]FieldRule{"email": {Required: true}}})
+	require.NoError(t, err)
+	require.NoError(t, v.validateUser(&User{Email: "bob@test.com"}))
+
+	v, err = NewValidator(&EmailPolicy{}, &NamePolicy{}, DefaultRules())
+	require.NoError(t, err)
+	require.Error(t, v.validateUser(&User{Email: "bob@test.com"}))
+}
@@ -0,0 +1,54 @@
+package users
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 secret of RFC 6238 appendix B
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTotpCode_RFC6238(t *testing.T) {
+	for ts, expected := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		code, err := totpCode(rfcSecret, totpStep(time.Unix(ts, 0)))
+		require.NoError(t, err)
+		require.Equal(t, expected, code, "time %d", ts)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := validateTOTP(rfcSecret, "081804", now, 0)
+	require.True(t, ok)
+	require.Equal(t, totpStep(now), step)
+
+	_, ok = validateTOTP(rfcSecret, "081804", now.Add(totpPeriod*time.Second), 0)
+	require.True(t, ok, "previous step should be accepted")
+
+	_, ok = validateTOTP(rfcSecret, "081804", now.Add(3*totpPeriod*time.Second), 0)
+	require.False(t, ok, "expired code should be refused")
+
+	_, ok = validateTOTP(rfcSecret, "081804", now, step)
+	require.False(t, ok, "replayed code should be refused")
+
+	_, ok = validateTOTP(rfcSecret, "000000", now, 0)
+	require.False(t, ok)
+}
+
+func TestTotpURI(t *testing.T) {
+	uri := totpURI("example", "bob@example.com", "ABCDEF")
+	require.Contains(t, uri, "otpauth://totp/example:bob@example.com?")
+	require.Contains(t, uri, "secret=ABCDEF")
+	require.Contains(t, uri, "issuer=example")
+}
@@ -0,0 +1,75 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// ErrUnauthenticated is returned when the api key is unknown, expired or revoked
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrForbidden is returned when the caller is authenticated but isn't allowed to act on the user
+var ErrForbidden = errors.New("forbidden")
+
+// AuthenticateAPIKeyReq contains the raw api key provided by the caller
+type AuthenticateAPIKeyReq struct {
+	Key string
+}
+
+// AuthenticateAPIKeyResp contains the api key matching the raw key
+type AuthenticateAPIKeyResp struct {
+	APIKey *APIKey
+}
+
+// APIKeyFinder will retrieve an api key from its hash and record its usage
+type APIKeyFinder interface {
+	// FindAPIKey will return a nil APIKey without error if no key match the hash
+	FindAPIKey(ctx context.Context, hash string) (*APIKey, error)
+	TouchAPIKey(ctx context.Context, key *APIKey, at time.Time) error
+}
+
+// AuthenticateAPIKey define the function which will check the api key provided by a caller
+type AuthenticateAPIKey func(ctx context.Context, req *AuthenticateAPIKeyReq) (*AuthenticateAPIKeyResp, error)
+
+// SetupAuthenticateAPIKey will return a configured AuthenticateAPIKey function which can be used later. The
+// users:admin scope of a key is dropped once its user isn't one of the c.Admins anymore
+func SetupAuthenticateAPIKey(log logger.Logger, store APIKeyFinder, c Config, clock Clock) AuthenticateAPIKey {
+	log = log.With().Str("usecase", "apikey_authenticate").Logger()
+	return authenticateAPIKey(log, store, c, clock)
+}
+
+func authenticateAPIKey(log logger.Logger, store APIKeyFinder, c Config, clock Clock) AuthenticateAPIKey {
+	return func(ctx context.Context, req *AuthenticateAPIKeyReq) (*AuthenticateAPIKeyResp, error) {
+		if !isAPIKey(req.Key) {
+			return nil, ErrUnauthenticated
+		}
+		key, err := store.FindAPIKey(ctx, hashAPIKey(req.Key))
+		if err != nil {
+			return nil, fmt.Errorf("can't find api key: %w", err)
+		}
+		now := clock()
+		switch {
+		case key == nil:
+			return nil, ErrUnauthenticated
+		case key.RevokedAt != nil:
+			log.Debug().Str("key_id", key.ID).Msg("revoked api key used")
+			return nil, ErrUnauthenticated
+		case key.ExpiresAt != nil && !now.Before(*key.ExpiresAt):
+			log.Debug().Str("key_id", key.ID).Msg("expired api key used")
+			return nil, ErrUnauthenticated
+		}
+		if err := store.TouchAPIKey(ctx, key, now); err != nil {
+			log.Error().Str("key_id", key.ID).Err(err).Msg("can't record api key usage")
+		}
+		key.LastUsedAt = &now
+		if key.HasScope(ScopeUsersAdmin) && !isAdmin(c, key.UserID) {
+			log.Debug().Str("key_id", key.ID).Msg("admin scope of a former admin dropped")
+			key.Scopes = withoutScope(key.Scopes, ScopeUsersAdmin)
+		}
+		return &AuthenticateAPIKeyResp{APIKey: key}, nil
+	}
+}
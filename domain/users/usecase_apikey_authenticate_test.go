@@ -0,0 +1,84 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/apikeystore"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+func TestSetupAuthenticateAPIKey(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	userStore := userstore.NewInMemory()
+	keyStore := apikeystore.NewInMemory()
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email: "test-apikey-auth-1",
+	})
+	expiresAt := now.Add(time.Hour)
+	created, err := users.SetupCreateAPIKey(logger.Logger{}, userStore, keyStore, users.Config{}, fixedClock(now))(context.Background(), &users.CreateAPIKeyReq{
+		UserID:    usr.ID,
+		Name:      "ci",
+		Scopes:    []users.Scope{users.ScopeUsersRead},
+		ExpiresAt: &expiresAt,
+	})
+	require.NoError(t, err)
+
+	t.Run("valid key", func(t *testing.T) {
+		authenticate := users.SetupAuthenticateAPIKey(logger.Logger{}, keyStore, users.Config{}, fixedClock(now))
+		res, err := authenticate(context.Background(), &users.AuthenticateAPIKeyReq{Key: created.Key})
+		require.NoError(t, err)
+		require.Equal(t, created.APIKey.ID, res.APIKey.ID)
+
+		keys, _ := keyStore.ListAPIKeys(context.Background(), usr.ID)
+		require.True(t, now.Equal(*keys[0].LastUsedAt))
+	})
+	t.Run("unknown key", func(t *testing.T) {
+		authenticate := users.SetupAuthenticateAPIKey(logger.Logger{}, keyStore, users.Config{}, fixedClock(now))
+		_, err := authenticate(context.Background(), &users.AuthenticateAPIKeyReq{Key: "uak_unknown"})
+		require.True(t, errors.Is(err, users.ErrUnauthenticated))
+	})
+	t.Run("expired key", func(t *testing.T) {
+		authenticate := users.SetupAuthenticateAPIKey(logger.Logger{}, keyStore, users.Config{}, fixedClock(expiresAt))
+		_, err := authenticate(context.Background(), &users.AuthenticateAPIKeyReq{Key: created.Key})
+		require.True(t, errors.Is(err, users.ErrUnauthenticated))
+	})
+	t.Run("revoked key", func(t *testing.T) {
+		_, err := users.SetupRevokeAPIKey(logger.Logger{}, keyStore, fixedClock(now))(context.Background(), &users.RevokeAPIKeyReq{
+			UserID: usr.ID,
+			KeyID:  created.APIKey.ID,
+		})
+		require.NoError(t, err)
+		authenticate := users.SetupAuthenticateAPIKey(logger.Logger{}, keyStore, users.Config{}, fixedClock(now))
+		_, err = authenticate(context.Background(), &users.AuthenticateAPIKeyReq{Key: created.Key})
+		require.True(t, errors.Is(err, users.ErrUnauthenticated))
+	})
+}
+
+func TestSetupAuthenticateAPIKey_Admin(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	keyStore := apikeystore.NewInMemory()
+	admin, _ := userStore.Add(context.Background(), &users.User{Email: "test-apikey-auth-admin"})
+	c := users.Config{Admins: []string{admin.ID}}
+	created, err := users.SetupCreateAPIKey(logger.Logger{}, userStore, keyStore, c, users.SystemClock)(context.Background(), &users.CreateAPIKeyReq{
+		UserID: admin.ID,
+		Name:   "ops",
+		Scopes: []users.Scope{users.ScopeUsersRead, users.ScopeUsersAdmin},
+	})
+	require.NoError(t, err)
+
+	res, err := users.SetupAuthenticateAPIKey(logger.Logger{}, keyStore, c, users.SystemClock)(context.Background(), &users.AuthenticateAPIKeyReq{Key: created.Key})
+	require.NoError(t, err)
+	require.True(t, res.APIKey.HasScope(users.ScopeUsersAdmin))
+
+	res, err = users.SetupAuthenticateAPIKey(logger.Logger{}, keyStore, users.Config{}, users.SystemClock)(context.Background(), &users.AuthenticateAPIKeyReq{Key: created.Key})
+	require.NoError(t, err)
+	require.False(t, res.APIKey.HasScope(users.ScopeUsersAdmin), "the scope is dropped once the user isn't an admin")
+	require.True(t, res.APIKey.HasScope(users.ScopeUsersRead))
+}
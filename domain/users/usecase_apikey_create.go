@@ -0,0 +1,100 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// ErrInvalidAPIKey is returned if the api key to create isn't valid
+var ErrInvalidAPIKey = errors.New("provided api key isn't valid")
+
+// CreateAPIKeyReq contains the required parameters to create a new api key for a user
+type CreateAPIKeyReq struct {
+	UserID    string     `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []Scope    `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResp contains the created api key.
+// Note: Key is only returned here, it is stored hashed and can't be retrieved later
+type CreateAPIKeyResp struct {
+	APIKey *APIKey `json:"api_key"`
+	Key    string  `json:"key"`
+}
+
+// APIKeyAdder will save a new api key in the system and generate an id for it
+type APIKeyAdder interface {
+	AddAPIKey(ctx context.Context, key *APIKey) (*APIKey, error)
+}
+
+// CreateAPIKey define the function which will create an api key for a user
+type CreateAPIKey func(ctx context.Context, req *CreateAPIKeyReq) (*CreateAPIKeyResp, error)
+
+// SetupCreateAPIKey will return a configured CreateAPIKey function which can be used later
+func SetupCreateAPIKey(log logger.Logger, repo Searcher, store APIKeyAdder, c Config, clock Clock) CreateAPIKey {
+	return validateCreateAPIKey(clock, createAPIKey(repo, store, c, clock))
+}
+
+func createAPIKey(repo Searcher, store APIKeyAdder, c Config, clock Clock) CreateAPIKey {
+	return func(ctx context.Context, req *CreateAPIKeyReq) (*CreateAPIKeyResp, error) {
+		usr, err := findUser(ctx, repo, repo.Query().ByID(req.UserID))
+		if err != nil {
+			return nil, err
+		}
+		if hasScope(req.Scopes, ScopeUsersAdmin) && !isAdmin(c, usr.ID) {
+			return nil, fmt.Errorf("can't validate api key: %s isn't an admin: %w", ScopeUsersAdmin, ErrInvalidAPIKey)
+		}
+		key, err := newAPIKey()
+		if err != nil {
+			return nil, err
+		}
+		apiKey, err := store.AddAPIKey(ctx, &APIKey{
+			UserID:    usr.ID,
+			Name:      req.Name,
+			Scopes:    req.Scopes,
+			Hash:      hashAPIKey(key),
+			CreatedAt: clock(),
+			ExpiresAt: req.ExpiresAt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("can't save api key: %w", err)
+		}
+		return &CreateAPIKeyResp{APIKey: apiKey, Key: key}, nil
+	}
+}
+
+func validateCreateAPIKey(clock Clock, createFunc CreateAPIKey) CreateAPIKey {
+	return func(ctx context.Context, req *CreateAPIKeyReq) (*CreateAPIKeyResp, error) {
+		if req.Name == "" {
+			return nil, fmt.Errorf("can't validate api key: name is required: %w", ErrInvalidAPIKey)
+		}
+		if len(req.Scopes) == 0 {
+			return nil, fmt.Errorf("can't validate api key: at least one scope is required: %w", ErrInvalidAPIKey)
+		}
+		for _, scope := range req.Scopes {
+			if scope != ScopeUsersRead && scope != ScopeUsersWrite && scope != ScopeUsersAdmin {
+				return nil, fmt.Errorf("can't validate api key: unknown scope %q: %w", scope, ErrInvalidAPIKey)
+			}
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(clock()) {
+			return nil, fmt.Errorf("can't validate api key: expiry should be in the future: %w", ErrInvalidAPIKey)
+		}
+		return createFunc(ctx, req)
+	}
+}
+
+// isAdmin tells if the user is one of the admins
+func isAdmin(c Config, userID string) bool {
+	for _, admin := range c.Admins {
+		if strings.TrimSpace(admin) == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/apikeystore"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+func TestSetupCreateAPIKey_OK(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email: "test-apikey-create-1",
+	})
+	create := users.SetupCreateAPIKey(logger.Logger{}, userStore, apikeystore.NewInMemory(), users.Config{}, users.SystemClock)
+	res, err := create(context.Background(), &users.CreateAPIKeyReq{
+		UserID: usr.ID,
+		Name:   "ci",
+		Scopes: []users.Scope{users.ScopeUsersRead},
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(res.Key, "uak_"))
+	require.NotEmpty(t, res.APIKey.ID)
+	require.NotContains(t, res.APIKey.Hash, res.Key)
+}
+
+func TestSetupCreateAPIKey_Invalid(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email: "test-apikey-create-2",
+	})
+	past := time.Now().Add(-time.Hour)
+	create := users.SetupCreateAPIKey(logger.Logger{}, userStore, apikeystore.NewInMemory(), users.Config{}, users.SystemClock)
+	for name, req := range map[string]*users.CreateAPIKeyReq{
+		"no name":       {UserID: usr.ID, Scopes: []users.Scope{users.ScopeUsersRead}},
+		"no scope":      {UserID: usr.ID, Name: "ci"},
+		"unknown scope": {UserID: usr.ID, Name: "ci", Scopes: []users.Scope{"admin"}},
+		"expired":       {UserID: usr.ID, Name: "ci", Scopes: []users.Scope{users.ScopeUsersRead}, ExpiresAt: &past},
+	} {
+		_, err := create(context.Background(), req)
+		require.True(t, errors.Is(err, users.ErrInvalidAPIKey), name)
+	}
+
+	_, err := create(context.Background(), &users.CreateAPIKeyReq{UserID: "unknown", Name: "ci", Scopes: []users.Scope{users.ScopeUsersRead}})
+	require.True(t, errors.Is(err, users.ErrUserNotFound))
+}
+
+func TestSetupCreateAPIKey_Admin(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	admin, _ := userStore.Add(context.Background(), &users.User{Email: "test-apikey-create-admin"})
+	usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-apikey-create-3"})
+	create := users.SetupCreateAPIKey(logger.Logger{}, userStore, apikeystore.NewInMemory(), users.Config{
+		Admins: []string{" " + admin.ID + " ", usr.Email},
+	}, users.SystemClock)
+
+	res, err := create(context.Background(), &users.CreateAPIKeyReq{UserID: admin.ID, Name: "ops", Scopes: []users.Scope{users.ScopeUsersAdmin}})
+	require.NoError(t, err)
+	require.True(t, res.APIKey.HasScope(users.ScopeUsersAdmin))
+
+	_, err = create(context.Background(), &users.CreateAPIKeyReq{UserID: usr.ID, Name: "ops", Scopes: []users.Scope{users.ScopeUsersAdmin}})
+	require.True(t, errors.Is(err, users.ErrInvalidAPIKey), "the email chosen by a user doesn't make it an admin")
+}
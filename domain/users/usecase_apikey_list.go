@@ -0,0 +1,41 @@
+package users
+
+import (
+	"context"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+// ListAPIKeysReq contains the required parameters to list the api keys of a user
+type ListAPIKeysReq struct {
+	UserID string `json:"id"`
+}
+
+// ListAPIKeysResp contains the api keys of the user, revoked ones included
+type ListAPIKeysResp struct {
+	APIKeys []*APIKey `json:"api_keys"`
+}
+
+// APIKeyLister will retrieve all the api keys of a user
+type APIKeyLister interface {
+	ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error)
+}
+
+// ListAPIKeys define the function which will list the api keys of a user
+type ListAPIKeys func(ctx context.Context, req *ListAPIKeysReq) (*ListAPIKeysResp, error)
+
+// SetupListAPIKeys will return a configured ListAPIKeys function which can be used later
+func SetupListAPIKeys(log logger.Logger, store APIKeyLister) ListAPIKeys {
+	return listAPIKeys(store)
+}
+
+func listAPIKeys(store APIKeyLister) ListAPIKeys {
+	return func(ctx context.Context, req *ListAPIKeysReq) (*ListAPIKeysResp, error) {
+		keys, err := store.ListAPIKeys(ctx, req.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("can't list api keys: %w", err)
+		}
+		return &ListAPIKeysResp{APIKeys: keys}, nil
+	}
+}
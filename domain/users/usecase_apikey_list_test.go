@@ -0,0 +1,22 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/apikeystore"
+	"go-users-example/infra/logger"
+)
+
+func TestSetupListAPIKeys_OK(t *testing.T) {
+	keyStore := apikeystore.NewInMemory()
+	_, _ = keyStore.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-apikey-list-1", Name: "ci", Hash: "hash"})
+	list := users.SetupListAPIKeys(logger.Logger{}, keyStore)
+	res, err := list(context.Background(), &users.ListAPIKeysReq{UserID: "test-apikey-list-1"})
+	require.NoError(t, err)
+	require.Len(t, res.APIKeys, 1)
+	require.Equal(t, "ci", res.APIKeys[0].Name)
+}
@@ -0,0 +1,65 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// RevokeAPIKeyReq contains the required parameters to revoke an api key of a user
+type RevokeAPIKeyReq struct {
+	UserID string `json:"id"`
+	KeyID  string `json:"key_id"`
+}
+
+// RevokeAPIKeyResp contains the revoked api key
+type RevokeAPIKeyResp struct {
+	APIKey *APIKey `json:"api_key"`
+}
+
+// APIKeyRevoker will revoke api keys, a revoked key can't be used anymore
+type APIKeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, key *APIKey, at time.Time) (*APIKey, error)
+	RevokeUserAPIKeys(ctx context.Context, userID string, at time.Time) ([]*APIKey, error)
+}
+
+// RevokeAPIKey define the function which will revoke an api key of a user
+type RevokeAPIKey func(ctx context.Context, req *RevokeAPIKeyReq) (*RevokeAPIKeyResp, error)
+
+// RevokeDeletedUserAPIKeys define the function which will revoke all the api keys of a user on its deletion
+type RevokeDeletedUserAPIKeys func(ctx context.Context, evt *ChangeEvent) error
+
+// SetupRevokeAPIKey will return a configured RevokeAPIKey function which can be used later
+func SetupRevokeAPIKey(log logger.Logger, store APIKeyRevoker, clock Clock) RevokeAPIKey {
+	return revokeAPIKey(store, clock)
+}
+
+// SetupRevokeDeletedUserAPIKeys will return a configured RevokeDeletedUserAPIKeys function
+// which should be fed with the user ChangeEvent.
+// Note: the keys aren't brought back if the user is restored
+func SetupRevokeDeletedUserAPIKeys(log logger.Logger, store APIKeyRevoker, clock Clock) RevokeDeletedUserAPIKeys {
+	log = log.With().Str("usecase", "apikey_revoke_deleted_user").Logger()
+	return func(ctx context.Context, evt *ChangeEvent) error {
+		if evt.Op != DeleteOp || evt.Before == nil {
+			return nil
+		}
+		keys, err := store.RevokeUserAPIKeys(ctx, evt.Before.ID, clock())
+		if err != nil {
+			return fmt.Errorf("can't revoke api keys of user %s: %w", evt.Before.ID, err)
+		}
+		log.Debug().Str("user_id", evt.Before.ID).Int("revoked", len(keys)).Msg("api keys of deleted user revoked")
+		return nil
+	}
+}
+
+func revokeAPIKey(store APIKeyRevoker, clock Clock) RevokeAPIKey {
+	return func(ctx context.Context, req *RevokeAPIKeyReq) (*RevokeAPIKeyResp, error) {
+		key, err := store.RevokeAPIKey(ctx, &APIKey{ID: req.KeyID, UserID: req.UserID}, clock())
+		if err != nil {
+			return nil, fmt.Errorf("can't revoke api key: %w", err)
+		}
+		return &RevokeAPIKeyResp{APIKey: key}, nil
+	}
+}
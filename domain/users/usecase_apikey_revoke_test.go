@@ -0,0 +1,41 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/apikeystore"
+	"go-users-example/infra/logger"
+)
+
+func TestSetupRevokeAPIKey_OK(t *testing.T) {
+	keyStore := apikeystore.NewInMemory()
+	key, _ := keyStore.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-apikey-revoke-1", Hash: "hash"})
+	revoke := users.SetupRevokeAPIKey(logger.Logger{}, keyStore, users.SystemClock)
+	res, err := revoke(context.Background(), &users.RevokeAPIKeyReq{UserID: "test-apikey-revoke-1", KeyID: key.ID})
+	require.NoError(t, err)
+	require.NotNil(t, res.APIKey.RevokedAt)
+}
+
+func TestSetupRevokeDeletedUserAPIKeys(t *testing.T) {
+	keyStore := apikeystore.NewInMemory()
+	_, _ = keyStore.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-apikey-revoke-2", Hash: "hash"})
+	revoke := users.SetupRevokeDeletedUserAPIKeys(logger.Logger{}, keyStore, users.SystemClock)
+
+	require.NoError(t, revoke(context.Background(), &users.ChangeEvent{
+		Op:    users.UpdateOp,
+		After: &users.User{ID: "test-apikey-revoke-2"},
+	}))
+	key, _ := keyStore.FindAPIKey(context.Background(), "hash")
+	require.Nil(t, key.RevokedAt)
+
+	require.NoError(t, revoke(context.Background(), &users.ChangeEvent{
+		Op:     users.DeleteOp,
+		Before: &users.User{ID: "test-apikey-revoke-2"},
+	}))
+	key, _ = keyStore.FindAPIKey(context.Background(), "hash")
+	require.NotNil(t, key.RevokedAt)
+}
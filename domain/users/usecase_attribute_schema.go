@@ -0,0 +1,30 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+
+	"go-users-example/infra/logger"
+)
+
+// GetAttributeSchemaReq contains the parameters to get the schema of the custom attributes
+type GetAttributeSchemaReq struct{}
+
+// GetAttributeSchemaResp contains the JSON Schema of the custom attributes accepted on the users
+type GetAttributeSchemaResp struct {
+	Schema json.RawMessage `json:"schema"`
+}
+
+// GetAttributeSchema define the function which will return the schema of the custom attributes in use
+type GetAttributeSchema func(ctx context.Context, req *GetAttributeSchemaReq) (*GetAttributeSchemaResp, error)
+
+// SetupGetAttributeSchema will return a configured GetAttributeSchema function which can be used later
+func SetupGetAttributeSchema(log logger.Logger, validator *Validator) GetAttributeSchema {
+	return getAttributeSchema(validator)
+}
+
+func getAttributeSchema(validator *Validator) GetAttributeSchema {
+	return func(ctx context.Context, req *GetAttributeSchemaReq) (*GetAttributeSchemaResp, error) {
+		return &GetAttributeSchemaResp{Schema: validator.AttributeSchema().Raw()}, nil
+	}
+}
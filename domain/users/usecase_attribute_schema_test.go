@@ -0,0 +1,23 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestSetupGetAttributeSchema(t *testing.T) {
+	getSchema := users.SetupGetAttributeSchema(logger.Logger{}, newValidator(t, users.Config{}))
+	res, err := getSchema(context.Background(), &users.GetAttributeSchemaReq{})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "object", "properties": {}}`, string(res.Schema))
+
+	getSchema = users.SetupGetAttributeSchema(logger.Logger{}, newAttributeValidator(t))
+	res, err = getSchema(context.Background(), &users.GetAttributeSchemaReq{})
+	require.NoError(t, err)
+	require.JSONEq(t, testAttributeSchema, string(res.Schema))
+}
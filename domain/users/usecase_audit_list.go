@@ -0,0 +1,38 @@
+package users
+
+import (
+	"context"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+// ListUserAuditReq contains the required parameters to retrieve the audit log of a user
+type ListUserAuditReq struct {
+	UserID string `json:"id"`
+}
+
+// ListUserAuditResp contains the audit entries of the user, ordered by Seq
+type ListUserAuditResp struct {
+	Entries []*AuditEntry `json:"entries"`
+}
+
+// AuditLister will retrieve the entries of the audit log, ordered by Seq
+type AuditLister interface {
+	ListAudit(ctx context.Context) ([]*AuditEntry, error)
+	ListUserAudit(ctx context.Context, userID string) ([]*AuditEntry, error)
+}
+
+// ListUserAudit define the function which will retrieve the audit log of a user
+type ListUserAudit func(ctx context.Context, req *ListUserAuditReq) (*ListUserAuditResp, error)
+
+// SetupListUserAudit will return a configured ListUserAudit function which can be used later
+func SetupListUserAudit(log logger.Logger, store AuditLister) ListUserAudit {
+	return func(ctx context.Context, req *ListUserAuditReq) (*ListUserAuditResp, error) {
+		entries, err := store.ListUserAudit(ctx, req.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("can't list audit entries: %w", err)
+		}
+		return &ListUserAuditResp{Entries: entries}, nil
+	}
+}
@@ -0,0 +1,22 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/auditstore"
+	"go-users-example/infra/logger"
+)
+
+func TestSetupListUserAudit_OK(t *testing.T) {
+	store := auditstore.NewInMemory()
+	_, _ = store.AppendAudit(context.Background(), &users.AuditEntry{UserID: "test-audit-list-1", Op: users.CreateOp})
+	_, _ = store.AppendAudit(context.Background(), &users.AuditEntry{UserID: "test-audit-list-2", Op: users.CreateOp})
+	list := users.SetupListUserAudit(logger.Logger{}, store)
+	res, err := list(context.Background(), &users.ListUserAuditReq{UserID: "test-audit-list-1"})
+	require.NoError(t, err)
+	require.Len(t, res.Entries, 1)
+}
@@ -0,0 +1,54 @@
+package users
+
+import (
+	"context"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+// AuditAppender will append an entry at the end of the audit log.
+// The store is responsible to set the Seq and to chain the entry (PrevHash and Hash) atomically, DataDigest is already set.
+// The store erasing the data of a user (see UserDataEraser) records the erasure itself, in the same step, and erases
+// the data of the entries of the user appended later
+type AuditAppender interface {
+	AppendAudit(ctx context.Context, entry *AuditEntry) (*AuditEntry, error)
+}
+
+// RecordAudit define the function which will record a ChangeEvent in the audit log
+type RecordAudit func(ctx context.Context, evt *ChangeEvent) error
+
+// SetupRecordAudit will return a configured RecordAudit function which should be fed with the user ChangeEvent by the
+// store as the change is committed, so a change is never kept without its entry
+func SetupRecordAudit(log logger.Logger, store AuditAppender) RecordAudit {
+	log = log.With().Str("usecase", "audit_record").Logger()
+	return func(ctx context.Context, evt *ChangeEvent) error {
+		if evt.Op == EraseOp {
+			// recorded by the store along the erasure of the data, see AuditAppender
+			return nil
+		}
+		userID := ""
+		switch {
+		case evt.After != nil:
+			userID = evt.After.ID
+		case evt.Before != nil:
+			userID = evt.Before.ID
+		}
+		entry := &AuditEntry{
+			UserID:    userID,
+			Time:      evt.Time.UTC(),
+			Op:        evt.Op,
+			Actor:     evt.Origin.Actor,
+			RequestID: evt.Origin.RequestID,
+			SourceIP:  evt.Origin.SourceIP,
+			Changes:   diffUsers(evt.Before, evt.After),
+		}
+		entry.DataDigest = entry.ComputeDataDigest()
+		entry, err := store.AppendAudit(ctx, entry)
+		if err != nil {
+			return fmt.Errorf("can't append audit entry: %w", err)
+		}
+		log.Debug().Uint64("seq", entry.Seq).Str("user_id", userID).Msg("change audited")
+		return nil
+	}
+}
@@ -0,0 +1,33 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/auditstore"
+	"go-users-example/infra/logger"
+)
+
+func TestSetupRecordAudit_OK(t *testing.T) {
+	store := auditstore.NewInMemory()
+	record := users.SetupRecordAudit(logger.Logger{}, store)
+	err := record(context.Background(), &users.ChangeEvent{
+		Time:   time.Now(),
+		Op:     users.UpdateOp,
+		Before: &users.User{ID: "test-audit-record-1", Email: "before@test.com"},
+		After:  &users.User{ID: "test-audit-record-1", Email: "after@test.com"},
+		Origin: users.RequestInfo{Actor: "user:admin", RequestID: "req-1", SourceIP: "10.0.0.1"},
+	})
+	require.NoError(t, err)
+
+	entries, _ := store.ListUserAudit(context.Background(), "test-audit-record-1")
+	require.Len(t, entries, 1)
+	require.Equal(t, "user:admin", entries[0].Actor)
+	require.Equal(t, "req-1", entries[0].RequestID)
+	require.Equal(t, "10.0.0.1", entries[0].SourceIP)
+	require.Equal(t, []users.FieldChange{{Field: "email", Before: "before@test.com", After: "after@test.com"}}, entries[0].Changes)
+}
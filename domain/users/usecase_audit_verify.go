@@ -0,0 +1,66 @@
+package users
+
+import (
+	"context"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+// VerifyAuditResp contains the result of the audit log verification
+type VerifyAuditResp struct {
+	Valid   bool `json:"valid"`
+	Entries int  `json:"entries"`
+	// BrokenAt is the Seq of the first entry which doesn't match the chain, set only if the log isn't valid
+	BrokenAt uint64 `json:"broken_at,omitempty"`
+}
+
+// VerifyAudit define the function which will check that the audit log hasn't been tampered
+type VerifyAudit func(ctx context.Context) (*VerifyAuditResp, error)
+
+// SetupVerifyAudit will return a configured VerifyAudit function which can be used later
+func SetupVerifyAudit(log logger.Logger, store AuditLister) VerifyAudit {
+	log = log.With().Str("usecase", "audit_verify").Logger()
+	return func(ctx context.Context) (*VerifyAuditResp, error) {
+		entries, err := store.ListAudit(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("can't list audit entries: %w", err)
+		}
+		res := &VerifyAuditResp{Valid: true, Entries: len(entries)}
+		// the data of an entry can only be erased with the erasure of its user, recorded in the chain
+		erasedUsers := make(map[string]bool)
+		for _, entry := range entries {
+			if entry.Op == EraseOp {
+				erasedUsers[entry.UserID] = true
+			}
+		}
+		prevHash := ""
+		for i, entry := range entries {
+			if entry.Seq != uint64(i+1) || entry.PrevHash != prevHash || entry.Hash != entry.ComputeHash() || !validAuditData(entry, erasedUsers) {
+				log.Warn().Uint64("seq", entry.Seq).Msg("audit log chain broken")
+				res.Valid = false
+				res.BrokenAt = uint64(i + 1)
+				return res, nil
+			}
+			prevHash = entry.Hash
+		}
+		return res, nil
+	}
+}
+
+// validAuditData will check the personal data of the entry against its digest, erased entries should have no data left
+// and belong to an erased user
+func validAuditData(entry *AuditEntry, erasedUsers map[string]bool) bool {
+	if !entry.Erased {
+		return entry.DataDigest == entry.ComputeDataDigest()
+	}
+	if !erasedUsers[entry.UserID] || entry.SourceIP != "" {
+		return false
+	}
+	for _, c := range entry.Changes {
+		if c.Before != "" || c.After != "" {
+			return false
+		}
+	}
+	return true
+}
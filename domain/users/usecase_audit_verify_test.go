@@ -0,0 +1,104 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/auditstore"
+	"go-users-example/infra/logger"
+)
+
+// tamperedAudit return the entries of the wrapped store altered by tamper
+type tamperedAudit struct {
+	*auditstore.InMemory
+	tamper func(entries []*users.AuditEntry) []*users.AuditEntry
+}
+
+func (t *tamperedAudit) ListAudit(ctx context.Context) ([]*users.AuditEntry, error) {
+	entries, err := t.InMemory.ListAudit(ctx)
+	return t.tamper(entries), err
+}
+
+func TestSetupVerifyAudit(t *testing.T) {
+	store := auditstore.NewInMemory()
+	for _, id := range []string{"test-audit-verify-1", "test-audit-verify-2", "test-audit-verify-3"} {
+		entry := &users.AuditEntry{
+			UserID:  id,
+			Op:      users.UpdateOp,
+			Actor:   "user:admin",
+			Changes: []users.FieldChange{{Field: "email", Before: "before@test.com", After: "after@test.com"}},
+		}
+		entry.DataDigest = entry.ComputeDataDigest()
+		_, _ = store.AppendAudit(context.Background(), entry)
+	}
+
+	res, err := users.SetupVerifyAudit(logger.Logger{}, store)(context.Background())
+	require.NoError(t, err)
+	require.True(t, res.Valid)
+	require.Equal(t, 3, res.Entries)
+
+	erased, err := users.SetupVerifyAudit(logger.Logger{}, &tamperedAudit{InMemory: store, tamper: func(entries []*users.AuditEntry) []*users.AuditEntry {
+		entries[1].Changes[0].Before, entries[1].Changes[0].After, entries[1].Erased = "", "", true
+		return entries
+	}})(context.Background())
+	require.NoError(t, err)
+	require.False(t, erased.Valid, "personal data can't be erased without the erasure of the user")
+	require.Equal(t, uint64(2), erased.BrokenAt)
+
+	for name, tc := range map[string]struct {
+		tamper   func(entries []*users.AuditEntry) []*users.AuditEntry
+		brokenAt uint64
+	}{
+		"modified field": {
+			tamper: func(entries []*users.AuditEntry) []*users.AuditEntry {
+				entries[1].Changes[0].After = "attacker@test.com"
+				return entries
+			},
+			brokenAt: 2,
+		},
+		"modified erased entry": {
+			tamper: func(entries []*users.AuditEntry) []*users.AuditEntry {
+				entries[1].Changes[0].After, entries[1].Erased = "attacker@test.com", true
+				return entries
+			},
+			brokenAt: 2,
+		},
+		"rehashed entry": {
+			tamper: func(entries []*users.AuditEntry) []*users.AuditEntry {
+				entries[1].Actor = "nobody"
+				entries[1].Hash = entries[1].ComputeHash()
+				return entries
+			},
+			brokenAt: 3,
+		},
+		"removed entry": {
+			tamper: func(entries []*users.AuditEntry) []*users.AuditEntry {
+				return append(entries[:1], entries[2:]...)
+			},
+			brokenAt: 2,
+		},
+	} {
+		res, err := users.SetupVerifyAudit(logger.Logger{}, &tamperedAudit{InMemory: store, tamper: tc.tamper})(context.Background())
+		require.NoError(t, err)
+		require.False(t, res.Valid, name)
+		require.Equal(t, tc.brokenAt, res.BrokenAt, name)
+	}
+
+	// the erasure of a user is recorded with the erasure of its data, even for its changes audited later
+	n, err := store.EraseUserData(context.Background(), "test-audit-verify-2")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	late := &users.AuditEntry{UserID: "test-audit-verify-2", Op: users.UpdateOp, SourceIP: "10.0.0.1"}
+	late.DataDigest = late.ComputeDataDigest()
+	late, _ = store.AppendAudit(context.Background(), late)
+	require.True(t, late.Erased)
+	require.Empty(t, late.SourceIP)
+
+	res, err = users.SetupVerifyAudit(logger.Logger{}, store)(context.Background())
+	require.NoError(t, err)
+	require.True(t, res.Valid)
+	require.Equal(t, 5, res.Entries)
+}
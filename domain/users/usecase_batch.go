@@ -0,0 +1,207 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+var (
+	// ErrInvalidBatch is returned if a batch is empty, has too many operations or an operation isn't exactly one change
+	ErrInvalidBatch = errors.New("provided batch isn't valid")
+	// ErrAtomicBatchUnsupported is returned for an atomic batch if the store can't apply changes atomically
+	ErrAtomicBatchUnsupported = errors.New("atomic batch isn't supported")
+	// ErrBatchAborted is the error of the operations of an atomic batch which were reverted, or not run, because
+	// another operation of the batch failed
+	ErrBatchAborted = errors.New("operation aborted by the failure of the batch")
+)
+
+// BatchOperation is one change of a batch, only one of Create, Update or Delete is set
+type BatchOperation struct {
+	Create *CreateReq `json:"create,omitempty"`
+	// Update keeps the absent fields and clears the null ones, as Update does
+	Update *UpdateReq `json:"update,omitempty"`
+	Delete *DeleteReq `json:"delete,omitempty"`
+}
+
+// BatchReq contains the operations to apply in their order
+type BatchReq struct {
+	Operations []*BatchOperation `json:"operations"`
+	// Atomic will apply all the operations or none of them, the batch stops at the first failed operation
+	Atomic bool `json:"atomic"`
+}
+
+// BatchResult is the result of the operation of the same index
+type BatchResult struct {
+	// User is the created, updated or deleted user if the operation succeeded
+	User *User `json:"user,omitempty"`
+	// Err is the reason of the failure of the operation, ErrBatchAborted for the other operations of a failed atomic batch
+	Err error `json:"-"`
+}
+
+// BatchResp contains a result for each operation of the batch
+type BatchResp struct {
+	Results []*BatchResult `json:"results"`
+	// Failed is the number of failed operations, none of the operations of an atomic batch is applied if one failed
+	Failed int `json:"failed"`
+}
+
+// Transactor is implemented by the stores which can apply several changes atomically
+type Transactor interface {
+	// InTransaction will apply the changes made with the context of fn atomically, they are all reverted if fn
+	// returns an error
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Batch define the function which will apply several changes of users in one request
+type Batch func(ctx context.Context, req *BatchReq) (*BatchResp, error)
+
+// SetupBatch will return a configured Batch function which can be used later. The operations are applied by the use
+// cases, transactor is nil if the store can't apply changes atomically. hasher and validator are the ones of the use
+// cases, an atomic batch validates its creates and hashes its passwords before locking the store
+func SetupBatch(log logger.Logger, create Create, update Update, deleteUser Delete, transactor Transactor, hasher Hasher, validator *Validator, c Config) Batch {
+	log = log.With().Str("usecase", "users_batch").Logger()
+	return validateBatch(transactor, c, batchUsers(log, create, update, deleteUser, transactor, hasher, validator))
+}
+
+func batchUsers(log logger.Logger, create Create, update Update, deleteUser Delete, transactor Transactor, hasher Hasher, validator *Validator) Batch {
+	apply := func(ctx context.Context, op *BatchOperation) *BatchResult {
+		var (
+			usr *User
+			err error
+		)
+		switch {
+		case op.Create != nil:
+			var res *CreateResp
+			if res, err = create(ctx, op.Create); err == nil {
+				usr = res.User
+			}
+		case op.Update != nil:
+			var res *UpdateResp
+			if res, err = update(ctx, op.Update); err == nil {
+				usr = res.User
+			}
+		default:
+			var res *DeleteResp
+			if res, err = deleteUser(ctx, op.Delete); err == nil {
+				usr = res.User
+			}
+		}
+		return &BatchResult{User: usr, Err: err}
+	}
+
+	return func(ctx context.Context, req *BatchReq) (*BatchResp, error) {
+		res := &BatchResp{Results: make([]*BatchResult, len(req.Operations))}
+		if !req.Atomic {
+			for i, op := range req.Operations {
+				res.Results[i] = apply(ctx, op)
+				if res.Results[i].Err != nil {
+					res.Failed++
+				}
+			}
+			log.Debug().Int("operations", len(req.Operations)).Int("failed", res.Failed).Msg("batch applied")
+			return res, nil
+		}
+
+		// the slow part of the operations is done before the transaction which locks the store
+		ops, failed, err := prepareOperations(hasher, validator, req.Operations)
+		if err != nil {
+			res.Results[failed] = &BatchResult{Err: err}
+			return abortBatch(log, res, failed), nil
+		}
+
+		// the notifications of the changes are held back until the changes are committed
+		ctx, pending := withPendingEffects(ctx)
+		err = transactor.InTransaction(ctx, func(ctx context.Context) error {
+			for i, op := range ops {
+				res.Results[i] = apply(ctx, op)
+				if res.Results[i].Err != nil {
+					failed = i
+					return res.Results[i].Err
+				}
+			}
+			return nil
+		})
+		if err != nil && failed < 0 {
+			return nil, fmt.Errorf("can't commit the batch: %w", err)
+		}
+		if failed >= 0 {
+			return abortBatch(log, res, failed), nil
+		}
+		pending.run()
+		log.Debug().Int("operations", len(req.Operations)).Msg("atomic batch committed")
+		return res, nil
+	}
+}
+
+// prepareOperations validates the creates and hashes the passwords of the operations of an atomic batch, the
+// operations keep the hashes and aren't modified. it returns the index of the first operation which can't be prepared
+// with its error, -1 otherwise
+func prepareOperations(hasher Hasher, validator *Validator, ops []*BatchOperation) ([]*BatchOperation, int, error) {
+	prepared := make([]*BatchOperation, len(ops))
+	for i, op := range ops {
+		prepared[i] = op
+		switch {
+		case op.Create != nil:
+			if err := validateCreateReq(validator, op.Create); err != nil {
+				return nil, i, err
+			}
+			req := *op.Create
+			var err error
+			if req.hashedPassword, err = hasher.Hash(req.RawPassword); err != nil {
+				return nil, i, fmt.Errorf("can't hash the password: %w", err)
+			}
+			prepared[i] = &BatchOperation{Create: &req}
+		case op.Update != nil && op.Update.RawPassword.Set && op.Update.RawPassword.Value != "":
+			req := *op.Update
+			var err error
+			if req.hashedPassword, err = hasher.Hash(req.RawPassword.Value); err != nil {
+				return nil, i, fmt.Errorf("can't hash the password: %w", err)
+			}
+			prepared[i] = &BatchOperation{Update: &req}
+		}
+	}
+	return prepared, -1, nil
+}
+
+// abortBatch marks the operations of an atomic batch as aborted, except the failed one
+func abortBatch(log logger.Logger, res *BatchResp, failed int) *BatchResp {
+	for i := range res.Results {
+		if i != failed {
+			res.Results[i] = &BatchResult{Err: ErrBatchAborted}
+		}
+	}
+	res.Failed = len(res.Results)
+	log.Debug().Int("operations", len(res.Results)).Int("failed_operation", failed).Msg("atomic batch reverted")
+	return res
+}
+
+func validateBatch(transactor Transactor, c Config, batchFunc Batch) Batch {
+	return func(ctx context.Context, req *BatchReq) (*BatchResp, error) {
+		if len(req.Operations) == 0 {
+			return nil, fmt.Errorf("the batch has no operation: %w", ErrInvalidBatch)
+		}
+		if len(req.Operations) > c.BatchMaxSize {
+			return nil, fmt.Errorf("the batch has more than %d operations: %w", c.BatchMaxSize, ErrInvalidBatch)
+		}
+		for i, op := range req.Operations {
+			changes := 0
+			if op != nil {
+				for _, set := range []bool{op.Create != nil, op.Update != nil, op.Delete != nil} {
+					if set {
+						changes++
+					}
+				}
+			}
+			if changes != 1 {
+				return nil, fmt.Errorf("operation %d should have one of create, update or delete: %w", i, ErrInvalidBatch)
+			}
+		}
+		if req.Atomic && transactor == nil {
+			return nil, fmt.Errorf("the store has no transaction: %w", ErrAtomicBatchUnsupported)
+		}
+		return batchFunc(ctx, req)
+	}
+}
@@ -0,0 +1,161 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/infra/userstore"
+)
+
+func newBatch(t *testing.T, store *userstore.InMemory, notifier *usernotifier.InMemory, transactor users.Transactor) users.Batch {
+	log, validator, hasher := logger.Logger{}, newValidator(t, users.Config{}), pwdhasher.NewBcryptWithCost(bcrypt.MinCost)
+	return users.SetupBatch(log,
+		users.SetupCreate(log, notifier, store, hasher, validator),
+		users.SetupUpdate(log, notifier, store, hasher, validator),
+		users.SetupDelete(log, notifier, store),
+		transactor, hasher, validator, users.Config{BatchMaxSize: 3})
+}
+
+func createBatchReq(email string) *users.CreateReq {
+	return &users.CreateReq{FirstName: "test", LastName: "test", NickName: email, Email: email + "@test.com", RawPassword: "password"}
+}
+
+func TestSetupBatch(t *testing.T) {
+	store, notifier := userstore.NewInMemory(), usernotifier.NewInMemory()
+	batch := newBatch(t, store, notifier, store)
+	usr, _ := store.Add(context.Background(), &users.User{FirstName: "test", LastName: "test", NickName: "test-batch", Email: "test-batch@test.com"})
+
+	res, err := batch(context.Background(), &users.BatchReq{Operations: []*users.BatchOperation{
+		{Create: createBatchReq("test-batch-1")},
+		{Update: &users.UpdateReq{ID: usr.ID, Country: users.OptionalString{Set: true, Value: "FR"}}},
+		{Delete: &users.DeleteReq{ID: "unknown"}},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Failed)
+	require.Equal(t, "test-batch-1@test.com", res.Results[0].User.Email)
+	require.Equal(t, "FR", res.Results[1].User.Country)
+	require.ErrorIs(t, res.Results[2].Err, userstore.ErrNotFound)
+	require.Nil(t, res.Results[2].User)
+}
+
+func TestSetupBatch_Atomic(t *testing.T) {
+	store, notifier := userstore.NewInMemory(), usernotifier.NewInMemory()
+	events := notifier.Listen()
+	batch := newBatch(t, store, notifier, store)
+	usr, _ := store.Add(context.Background(), &users.User{FirstName: "test", LastName: "test", NickName: "test-batch", Email: "test-batch@test.com"})
+
+	t.Run("revert all the operations", func(t *testing.T) {
+		res, err := batch(context.Background(), &users.BatchReq{Atomic: true, Operations: []*users.BatchOperation{
+			{Create: createBatchReq("test-batch-atomic-1")},
+			{Delete: &users.DeleteReq{ID: usr.ID}},
+			{Create: createBatchReq("test-batch-atomic-1")},
+		}})
+		require.NoError(t, err)
+		require.Equal(t, 3, res.Failed)
+		require.ErrorIs(t, res.Results[0].Err, users.ErrBatchAborted)
+		require.ErrorIs(t, res.Results[1].Err, users.ErrBatchAborted)
+		require.ErrorIs(t, res.Results[2].Err, userstore.ErrAlreadyExist)
+
+		found, err := store.Search(context.Background(), store.Query().ByEmail("test-batch-atomic-1@test.com").ByID(usr.ID))
+		require.NoError(t, err)
+		require.Len(t, found, 1, "the user isn't created and the other one isn't deleted")
+		require.Nil(t, found[0].DeletedAt)
+		require.Never(t, func() bool { return len(events) > 0 }, 50*time.Millisecond, 5*time.Millisecond, "the reverted changes aren't notified")
+	})
+	t.Run("commit all the operations", func(t *testing.T) {
+		res, err := batch(context.Background(), &users.BatchReq{Atomic: true, Operations: []*users.BatchOperation{
+			{Create: createBatchReq("test-batch-atomic-1")},
+			{Delete: &users.DeleteReq{ID: usr.ID}},
+		}})
+		require.NoError(t, err)
+		require.Zero(t, res.Failed)
+		require.NotNil(t, res.Results[1].User.DeletedAt)
+		require.Eventually(t, func() bool { return len(events) == 2 }, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestSetupBatch_Invalid(t *testing.T) {
+	store := userstore.NewInMemory()
+	op := &users.BatchOperation{Delete: &users.DeleteReq{ID: "id"}}
+	for name, req := range map[string]*users.BatchReq{
+		"empty":         {},
+		"too large":     {Operations: []*users.BatchOperation{op, op, op, op}},
+		"no change":     {Operations: []*users.BatchOperation{op, {}}},
+		"several":       {Operations: []*users.BatchOperation{{Create: createBatchReq("test-batch"), Delete: op.Delete}}},
+		"nil operation": {Operations: []*users.BatchOperation{nil}},
+	} {
+		_, err := newBatch(t, store, usernotifier.NewInMemory(), store)(context.Background(), req)
+		require.ErrorIs(t, err, users.ErrInvalidBatch, name)
+	}
+
+	_, err := newBatch(t, store, usernotifier.NewInMemory(), nil)(context.Background(), &users.BatchReq{Atomic: true, Operations: []*users.BatchOperation{op}})
+	require.ErrorIs(t, err, users.ErrAtomicBatchUnsupported)
+}
+
+// lockCheckHasher will record if the store is locked when a password is hashed
+type lockCheckHasher struct {
+	users.Hasher
+	store  *userstore.InMemory
+	locked bool
+}
+
+func (h *lockCheckHasher) Hash(pwd string) (string, error) {
+	searched := make(chan struct{})
+	go func() {
+		_, _ = h.store.Search(context.Background(), h.store.Query().ByID("unknown"))
+		close(searched)
+	}()
+	select {
+	case <-searched:
+	case <-time.After(time.Second):
+		h.locked = true
+	}
+	return h.Hasher.Hash(pwd)
+}
+
+func TestSetupBatch_AtomicHashOutsideTransaction(t *testing.T) {
+	log, validator, store, notifier := logger.Logger{}, newValidator(t, users.Config{}), userstore.NewInMemory(), usernotifier.NewInMemory()
+	hasher := &lockCheckHasher{Hasher: pwdhasher.NewBcryptWithCost(bcrypt.MinCost), store: store}
+	batch := users.SetupBatch(log,
+		users.SetupCreate(log, notifier, store, hasher, validator),
+		users.SetupUpdate(log, notifier, store, hasher, validator),
+		users.SetupDelete(log, notifier, store),
+		store, hasher, validator, users.Config{BatchMaxSize: 3})
+	usr, _ := store.Add(context.Background(), &users.User{FirstName: "test", LastName: "test", NickName: "test-batch", Email: "test-batch@test.com"})
+
+	t.Run("hash the passwords before the transaction", func(t *testing.T) {
+		res, err := batch(context.Background(), &users.BatchReq{Atomic: true, Operations: []*users.BatchOperation{
+			{Create: createBatchReq("test-batch-hash-1")},
+			{Update: &users.UpdateReq{ID: usr.ID, RawPassword: users.SetString("new-password")}},
+		}})
+		require.NoError(t, err)
+		require.Zero(t, res.Failed)
+		require.False(t, hasher.locked)
+		require.NoError(t, bcrypt.CompareHashAndPassword([]byte(res.Results[0].User.Password), []byte("password")))
+		require.NoError(t, bcrypt.CompareHashAndPassword([]byte(res.Results[1].User.Password), []byte("new-password")))
+	})
+	t.Run("refuse an invalid create before the transaction", func(t *testing.T) {
+		invalid := createBatchReq("test-batch-hash-2")
+		invalid.FirstName = ""
+		res, err := batch(context.Background(), &users.BatchReq{Atomic: true, Operations: []*users.BatchOperation{
+			{Create: createBatchReq("test-batch-hash-3")},
+			{Create: invalid},
+		}})
+		require.NoError(t, err)
+		require.Equal(t, 2, res.Failed)
+		require.ErrorIs(t, res.Results[0].Err, users.ErrBatchAborted)
+		require.ErrorIs(t, res.Results[1].Err, users.ErrInvalidUser)
+
+		found, err := store.Search(context.Background(), store.Query().ByEmail("test-batch-hash-3@test.com"))
+		require.NoError(t, err)
+		require.Empty(t, found)
+	})
+}
@@ -0,0 +1,45 @@
+package users
+
+import (
+	"context"
+
+	"go-users-example/infra/logger"
+)
+
+// ListCountriesReq contains the parameters to list the countries
+type ListCountriesReq struct {
+	// Languages is the priority list of the languages of the reader (ex: "fr-CH, fr;q=0.9, en;q=0.8"), english if empty
+	Languages string `json:"languages"`
+}
+
+// LocalizedCountry is a country with its name in the language of the reader
+type LocalizedCountry struct {
+	Country
+	LocalName string `json:"local_name"`
+}
+
+// ListCountriesResp contains all the countries which can be set on a user
+type ListCountriesResp struct {
+	// Language is the language of the local names
+	Language  string             `json:"language"`
+	Countries []LocalizedCountry `json:"countries"`
+}
+
+// ListCountries define the function which will list the countries accepted on the users
+type ListCountries func(ctx context.Context, req *ListCountriesReq) (*ListCountriesResp, error)
+
+// SetupListCountries will return a configured ListCountries function which can be used later
+func SetupListCountries(log logger.Logger) ListCountries {
+	return listCountries()
+}
+
+func listCountries() ListCountries {
+	return func(ctx context.Context, req *ListCountriesReq) (*ListCountriesResp, error) {
+		names := NewCountryNames(req.Languages)
+		res := &ListCountriesResp{Language: names.Language(), Countries: make([]LocalizedCountry, 0, len(countries))}
+		for _, c := range countries {
+			res.Countries = append(res.Countries, LocalizedCountry{Country: c, LocalName: names.Name(c.Alpha2)})
+		}
+		return res, nil
+	}
+}
@@ -0,0 +1,26 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestSetupListCountries_OK(t *testing.T) {
+	list := users.SetupListCountries(logger.Logger{})
+	res, err := list(context.Background(), &users.ListCountriesReq{Languages: "fr"})
+	require.NoError(t, err)
+	require.Equal(t, "fr", res.Language)
+	require.Len(t, res.Countries, 249)
+	for _, c := range res.Countries {
+		if c.Alpha2 == "ES" {
+			require.Equal(t, "ESP", c.Alpha3)
+			require.Equal(t, "Spain", c.Name)
+			require.Equal(t, "Espagne", c.LocalName)
+		}
+	}
+}
@@ -20,6 +20,12 @@ type CreateReq struct {
 	Email       string `json:"email"`
 	Country     string `json:"country"`
 	RawPassword string `json:"password"`
+	// Phone is read in the region of the country if it isn't an international number
+	Phone string `json:"phone"`
+	// Attributes are the custom attributes of the user
+	Attributes map[string]interface{} `json:"attributes"`
+	// hashedPassword is the hash of RawPassword when it was already hashed, by an atomic batch
+	hashedPassword string
 }
 
 // CreateResp contains the field which will be returned on successful user creation
@@ -39,8 +45,9 @@ type Hasher interface {
 
 // ChangeNotifier will propagate change event about the user.
 // Note: Here the change is asynchronous and considered "losable".
-// 		 for a more event driven architecture,
-//		 the create usecase should notify only and the event should trigger the creation
+//
+//	for a more event driven architecture,
+//	the create usecase should notify only and the event should trigger the creation
 type ChangeNotifier interface {
 	Notify(event *ChangeEvent) error
 }
@@ -49,24 +56,34 @@ type ChangeNotifier interface {
 type Create func(ctx context.Context, req *CreateReq) (*CreateResp, error)
 
 // SetupCreate will return a configured Create function which can be used later
-func SetupCreate(log logger.Logger, notifier ChangeNotifier, repo Adder, hasher Hasher) Create {
+func SetupCreate(log logger.Logger, notifier ChangeNotifier, repo Adder, hasher Hasher, validator *Validator) Create {
 	log = log.With().Str("usecase", "user_create").Logger()
-	return validateCreate(notifyCreate(log, notifier, createUser(repo, hasher)))
+	return validateCreate(validator, notifyCreate(log, notifier, createUser(repo, hasher, validator.emails)))
 }
 
-func createUser(repo Adder, hash Hasher) Create {
+func createUser(repo Adder, hash Hasher, emails *EmailPolicy) Create {
 	return func(ctx context.Context, req *CreateReq) (*CreateResp, error) {
-		hashedPwd, err := hash.Hash(req.RawPassword)
+		email, canonicalEmail, err := emails.normalizeEmail(req.Email)
 		if err != nil {
-			return nil, fmt.Errorf("can't hash the password: %w", err)
+			return nil, err
+		}
+		hashedPwd := req.hashedPassword
+		if hashedPwd == "" {
+			if hashedPwd, err = hash.Hash(req.RawPassword); err != nil {
+				return nil, fmt.Errorf("can't hash the password: %w", err)
+			}
 		}
 		newUser, err := repo.Add(ctx, &User{
-			FirstName: req.FirstName,
-			LastName:  req.LastName,
-			NickName:  req.NickName,
-			Password:  hashedPwd,
-			Email:     req.Email,
-			Country:   req.Country,
+			FirstName:      normalizeName(req.FirstName),
+			LastName:       normalizeName(req.LastName),
+			NickName:       normalizeName(req.NickName),
+			NickNameKey:    nickNameKey(req.NickName),
+			Password:       hashedPwd,
+			Email:          email,
+			CanonicalEmail: canonicalEmail,
+			Country:        normalizeCountry(req.Country),
+			Phone:          normalizePhone(req.Phone, req.Country),
+			Attributes:     normalizeAttributes(req.Attributes),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("can't save new user: %w", err)
@@ -75,22 +92,32 @@ func createUser(repo Adder, hash Hasher) Create {
 	}
 }
 
-func validateCreate(createFunc Create) Create {
+func validateCreate(validator *Validator, createFunc Create) Create {
 	return func(ctx context.Context, req *CreateReq) (*CreateResp, error) {
-		err := validateUser(&User{
-			FirstName: req.FirstName,
-			LastName:  req.LastName,
-			NickName:  req.NickName,
-			Email:     req.Email,
-			Country:   req.Country,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("can't validate user: %s: %w", err.Error(), ErrInvalidUser)
+		if err := validateCreateReq(validator, req); err != nil {
+			return nil, err
 		}
 		return createFunc(ctx, req)
 	}
 }
 
+// validateCreateReq validates the user which will be created by req
+func validateCreateReq(validator *Validator, req *CreateReq) error {
+	err := validator.validateUser(&User{
+		FirstName:  req.FirstName,
+		LastName:   req.LastName,
+		NickName:   req.NickName,
+		Email:      req.Email,
+		Country:    req.Country,
+		Phone:      req.Phone,
+		Attributes: req.Attributes,
+	})
+	if err != nil {
+		return fmt.Errorf("can't validate user: %w", err)
+	}
+	return nil
+}
+
 func notifyCreate(log logger.Logger, notifier ChangeNotifier, createFunc Create) Create {
 	log = log.With().Str("us_middleware", "notifier").Logger()
 	return func(ctx context.Context, req *CreateReq) (*CreateResp, error) {
@@ -98,18 +125,21 @@ func notifyCreate(log logger.Logger, notifier ChangeNotifier, createFunc Create)
 		if err != nil {
 			return res, err
 		}
-		go func(u User) {
+		origin := RequestInfoFromContext(ctx)
+		u := *res.User
+		afterCommit(ctx, func() {
 			evt := &ChangeEvent{
 				Time:   time.Now(),
 				Op:     CreateOp,
 				Before: nil,
 				After:  &u,
+				Origin: origin,
 			}
 			log.Debug().Interface("user", u).Msg("notify user creation")
 			if err := notifier.Notify(evt); err != nil {
 				log.Error().Interface("user", u).Err(err).Msg("can't send user creation event")
 			}
-		}(*res.User)
+		})
 		return res, nil
 	}
 }
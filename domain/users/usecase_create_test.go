@@ -2,9 +2,13 @@ package users_test
 
 import (
 	"context"
+	"errors"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
 	"go-users-example/domain/users"
 	"go-users-example/infra/logger"
@@ -13,16 +17,161 @@ import (
 	"go-users-example/infra/userstore"
 )
 
+func newValidator(t *testing.T, c users.Config) *users.Validator {
+	emails, err := users.NewEmailPolicy(c)
+	require.NoError(t, err)
+	names, err := users.NewNamePolicy(c)
+	require.NoError(t, err)
+	validator, err := users.NewValidator(emails, names, users.DefaultRules())
+	require.NoError(t, err)
+	return validator
+}
+
 func TestSetupCreate_OK(t *testing.T) {
-	create := users.SetupCreate(logger.Logger{}, usernotifier.NewInMemory(), userstore.NewInMemory(), pwdhasher.NewBcrypt())
+	create := users.SetupCreate(logger.Logger{}, usernotifier.NewInMemory(), userstore.NewInMemory(), pwdhasher.NewBcrypt(), newValidator(t, users.Config{}))
 	res, err := create(context.Background(), &users.CreateReq{
 		FirstName:   "test",
 		LastName:    "test",
 		NickName:    "test",
 		Email:       "test-create-1@test.com",
+		Country:     "france",
 		RawPassword: "",
 	})
 	require.NoError(t, err)
 	require.Equal(t, res.User.Email, "test-create-1@test.com")
+	require.Equal(t, "FR", res.User.Country)
 	require.NotEmpty(t, res.User.ID)
 }
+
+func TestSetupCreate_Invalid(t *testing.T) {
+	create := users.SetupCreate(logger.Logger{}, usernotifier.NewInMemory(), userstore.NewInMemory(), pwdhasher.NewBcrypt(), newValidator(t, users.Config{}))
+	_, err := create(context.Background(), &users.CreateReq{
+		FirstName: "t",
+		LastName:  "t",
+		NickName:  "test",
+		Email:     "test-create-2",
+		Country:   "Gondor",
+	})
+	require.True(t, errors.Is(err, users.ErrInvalidUser))
+
+	var verr *users.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Violations, 4)
+}
+
+func TestSetupCreate_Email(t *testing.T) {
+	blocklist, err := ioutil.TempFile("", "disposable")
+	require.NoError(t, err)
+	defer os.Remove(blocklist.Name())
+	_, err = blocklist.WriteString("# disposable domains\nmailinator.com\n")
+	require.NoError(t, err)
+	require.NoError(t, blocklist.Close())
+
+	validator := newValidator(t, users.Config{EmailProviderRules: true, EmailBlocklistFile: blocklist.Name()})
+	create := users.SetupCreate(logger.Logger{}, usernotifier.NewInMemory(), userstore.NewInMemory(), pwdhasher.NewBcryptWithCost(bcrypt.MinCost), validator)
+
+	res, err := create(context.Background(), &users.CreateReq{FirstName: "test", LastName: "test", NickName: "test-1", Email: "Bob@BÜCHER.example"})
+	require.NoError(t, err)
+	require.Equal(t, "Bob@xn--bcher-kva.example", res.User.Email)
+
+	res, err = create(context.Background(), &users.CreateReq{FirstName: "test", LastName: "test", NickName: "test-2", Email: "bob.smith+news@gmail.com"})
+	require.NoError(t, err)
+	require.Equal(t, "bob.smith+news@gmail.com", res.User.Email)
+
+	_, err = create(context.Background(), &users.CreateReq{FirstName: "test", LastName: "test", NickName: "test-3", Email: "BobSmith@googlemail.com"})
+	require.True(t, errors.Is(err, userstore.ErrAlreadyExist))
+
+	_, err = create(context.Background(), &users.CreateReq{FirstName: "test", LastName: "test", NickName: "test-4", Email: "bob@eu.mailinator.com"})
+	var verr *users.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, users.CodeDisposable, verr.Violations[0].Code)
+}
+
+func TestSetupCreate_NickName(t *testing.T) {
+	create := users.SetupCreate(logger.Logger{}, usernotifier.NewInMemory(), userstore.NewInMemory(), pwdhasher.NewBcryptWithCost(bcrypt.MinCost), newValidator(t, users.Config{}))
+
+	res, err := create(context.Background(), &users.CreateReq{FirstName: "test", LastName: "test", NickName: "PayPal", Email: "test-create-nick-1@test.com"})
+	require.NoError(t, err)
+	require.Equal(t, "PayPal", res.User.NickName)
+
+	_, err = create(context.Background(), &users.CreateReq{FirstName: "test", LastName: "test", NickName: "pаypаl", Email: "test-create-nick-2@test.com"})
+	require.True(t, errors.Is(err, userstore.ErrNickNameAlreadyExist))
+
+	_, err = create(context.Background(), &users.CreateReq{FirstName: "test", LastName: "test", NickName: "Admin", Email: "test-create-nick-3@test.com"})
+	var verr *users.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, users.CodeReserved, verr.Violations[0].Code)
+}
+
+const testAttributeSchema = `{
+	"type": "object",
+	"properties": {
+		"department": {"type": "string", "enum": ["sales", "legal"]},
+		"level": {"type": "integer", "minimum": 1}
+	},
+	"required": ["department"]
+}`
+
+func newAttributeValidator(t *testing.T) *users.Validator {
+	validator := newValidator(t, users.Config{})
+	schema, err := users.CompileAttributeSchema([]byte(testAttributeSchema))
+	require.NoError(t, err)
+	validator.SetAttributeSchema(schema)
+	return validator
+}
+
+func TestSetupCreate_Attributes(t *testing.T) {
+	store := userstore.NewInMemory()
+	validator := newAttributeValidator(t)
+	create := users.SetupCreate(logger.Logger{}, usernotifier.NewInMemory(), store, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), validator)
+
+	_, err := create(context.Background(), &users.CreateReq{FirstName: "test", LastName: "test", NickName: "test-1", Email: "test-attributes-1@test.com"})
+	var verr *users.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, "attributes.department", verr.Violations[0].Field)
+
+	res, err := create(context.Background(), &users.CreateReq{
+		FirstName: "test", LastName: "test", NickName: "test-2", Email: "test-attributes-2@test.com",
+		Attributes: map[string]interface{}{"department": "sales", "level": 2},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"department": "sales", "level": float64(2)}, res.User.Attributes)
+
+	search := users.SetupSearch(logger.Logger{}, store, validator)
+	found, err := search(context.Background(), &users.SearchReq{Attributes: map[string][]string{"level": {"2"}}})
+	require.NoError(t, err)
+	require.Len(t, found.Users, 1)
+	require.Equal(t, res.User.ID, found.Users[0].ID)
+
+	_, err = search(context.Background(), &users.SearchReq{Attributes: map[string][]string{"level": {"two"}}})
+	require.True(t, errors.Is(err, users.ErrInvalidUser))
+}
+
+func TestSetupCreate_Phone(t *testing.T) {
+	store := userstore.NewInMemory()
+	create := users.SetupCreate(logger.Logger{}, usernotifier.NewInMemory(), store, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), newValidator(t, users.Config{}))
+
+	res, err := create(context.Background(), &users.CreateReq{
+		FirstName: "test", LastName: "test", NickName: "test-1", Email: "test-phone-1@test.com", Country: "FR", Phone: "06 12 34 56 78",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "+33612345678", res.User.Phone)
+
+	_, err = create(context.Background(), &users.CreateReq{
+		FirstName: "test", LastName: "test", NickName: "test-2", Email: "test-phone-2@test.com", Phone: "06 12 34 56 78",
+	})
+	var verr *users.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, "phone", verr.Violations[0].Field)
+
+	_, err = create(context.Background(), &users.CreateReq{
+		FirstName: "test", LastName: "test", NickName: "test-3", Email: "test-phone-3@test.com", Phone: "+33 6 12 34 56 78",
+	})
+	require.True(t, errors.Is(err, userstore.ErrPhoneAlreadyExist))
+
+	search := users.SetupSearch(logger.Logger{}, store, newValidator(t, users.Config{}))
+	found, err := search(context.Background(), &users.SearchReq{Phones: []string{"+33 6 12 34 56 78"}})
+	require.NoError(t, err)
+	require.Len(t, found.Users, 1)
+	require.Equal(t, res.User.ID, found.Users[0].ID)
+}
@@ -49,18 +49,21 @@ func notifyDelete(log logger.Logger, notifier ChangeNotifier, deleteFunc Delete)
 		if err != nil {
 			return res, err
 		}
-		go func(u User) {
+		origin := RequestInfoFromContext(ctx)
+		u := *res.User
+		afterCommit(ctx, func() {
 			evt := &ChangeEvent{
 				Time:   time.Now(),
 				Op:     DeleteOp,
-				Before: nil,
-				After:  &u,
+				Before: &u,
+				After:  nil,
+				Origin: origin,
 			}
-			log.Debug().Interface("user", u).Msg("notify user creation")
+			log.Debug().Interface("user", u).Msg("notify user deletion")
 			if err := notifier.Notify(evt); err != nil {
-				log.Error().Interface("user", u).Err(err).Msg("can't send user creation event")
+				log.Error().Interface("user", u).Err(err).Msg("can't send user deletion event")
 			}
-		}(*res.User)
+		})
 		return res, nil
 	}
 }
@@ -0,0 +1,93 @@
+package users
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// EraseUserReq contains the required parameters to erase all the data held about a user
+type EraseUserReq struct {
+	UserID string `json:"id"`
+}
+
+// EraseUserResp contains the signed receipt of the erasure
+type EraseUserResp struct {
+	Receipt *ErasureReceipt `json:"receipt"`
+}
+
+// UserEraser will permanently remove the user from the store, even if it is still in its restore window
+type UserEraser interface {
+	Erase(ctx context.Context, user *User) (*User, error)
+}
+
+// UserDataEraser will irreversibly delete or anonymize the data held about a user in an auxiliary store
+// and return the number of records affected
+type UserDataEraser interface {
+	EraseUserData(ctx context.Context, userID string) (int, error)
+}
+
+// Signer will sign the erasure receipts so they can be verified later
+type Signer interface {
+	Sign(data []byte) ([]byte, error)
+}
+
+// EraseUser define the function which will erase all the data held about a user
+type EraseUser func(ctx context.Context, req *EraseUserReq) (*EraseUserResp, error)
+
+// SetupEraseUser will return a configured EraseUser function which can be used later.
+// erasers are the auxiliary stores holding data about the users, by name
+func SetupEraseUser(log logger.Logger, notifier ChangeNotifier, repo UserEraser, erasers map[string]UserDataEraser, signer Signer, clock Clock) EraseUser {
+	log = log.With().Str("usecase", "user_erase").Logger()
+	return eraseUser(log, notifier, repo, erasers, signer, clock)
+}
+
+func eraseUser(log logger.Logger, notifier ChangeNotifier, repo UserEraser, erasers map[string]UserDataEraser, signer Signer, clock Clock) EraseUser {
+	return func(ctx context.Context, req *EraseUserReq) (*EraseUserResp, error) {
+		erased := map[string]int{}
+		// the data of the auxiliary stores is erased before the user, the erasure can be run again until the user is
+		// erased if an auxiliary store fails
+		for name, eraser := range erasers {
+			n, err := eraser.EraseUserData(ctx, req.UserID)
+			if err != nil {
+				return nil, fmt.Errorf("can't erase user data from %s: %w", name, err)
+			}
+			erased[name] = n
+		}
+		usr, err := repo.Erase(ctx, &User{ID: req.UserID})
+		if err != nil {
+			return nil, fmt.Errorf("can't erase user: %w", err)
+		}
+		erased["users"] = 1
+		notifyErasure(ctx, log, notifier, usr.ID)
+		receipt := &ErasureReceipt{
+			UserID:   usr.ID,
+			ErasedAt: clock().UTC(),
+			Erased:   erased,
+		}
+		signature, err := signer.Sign(receipt.SignedPayload())
+		if err != nil {
+			return nil, fmt.Errorf("can't sign erasure receipt: %w", err)
+		}
+		receipt.Signature = base64.StdEncoding.EncodeToString(signature)
+		return &EraseUserResp{Receipt: receipt}, nil
+	}
+}
+
+// notifyErasure will send the erasure event of the user, the event only holds its id
+func notifyErasure(ctx context.Context, log logger.Logger, notifier ChangeNotifier, userID string) {
+	evt := &ChangeEvent{
+		Time:   time.Now(),
+		Op:     EraseOp,
+		Before: &User{ID: userID},
+		After:  nil,
+		Origin: RequestInfoFromContext(ctx),
+	}
+	log.Debug().Str("user_id", userID).Msg("notify user erasure")
+	if err := notifier.Notify(evt); err != nil {
+		log.Error().Str("user_id", userID).Err(err).Msg("can't send user erasure event")
+	}
+}
@@ -0,0 +1,117 @@
+package users_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/apikeystore"
+	"go-users-example/infra/auditstore"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/mfastore"
+	"go-users-example/infra/signer"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/infra/userstore"
+)
+
+func TestSetupEraseUser_OK(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	mfaStore := mfastore.NewInMemory()
+	keyStore := apikeystore.NewInMemory()
+	auditStore := auditstore.NewInMemory()
+	notifier := usernotifier.NewInMemory()
+	events := notifier.Listen()
+	sign, _ := signer.NewEd25519(signer.Config{})
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email: "test-erase-1",
+	})
+	_ = mfaStore.SaveMFA(context.Background(), &users.MFA{UserID: usr.ID, Secret: "secret"})
+	_, _ = keyStore.AddAPIKey(context.Background(), &users.APIKey{UserID: usr.ID, Hash: "hash"})
+	_ = users.SetupRecordAudit(logger.Logger{}, auditStore)(context.Background(), &users.ChangeEvent{Op: users.CreateOp, After: usr})
+
+	erase := users.SetupEraseUser(logger.Logger{}, notifier, userStore, map[string]users.UserDataEraser{
+		"mfa":      mfaStore,
+		"api_keys": keyStore,
+		"audit":    auditStore,
+	}, sign, users.SystemClock)
+	res, err := erase(context.Background(), &users.EraseUserReq{UserID: usr.ID})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"users": 1, "mfa": 1, "api_keys": 1, "audit": 1}, res.Receipt.Erased)
+
+	signature, _ := base64.StdEncoding.DecodeString(res.Receipt.Signature)
+	require.True(t, sign.Verify(res.Receipt.SignedPayload(), signature))
+
+	found, _ := userStore.Search(context.Background(), userStore.Query().ByID(usr.ID).WithDeleted())
+	require.Empty(t, found)
+	entries, _ := auditStore.ListUserAudit(context.Background(), usr.ID)
+	require.Len(t, entries, 2)
+	require.True(t, entries[0].Erased)
+	require.Equal(t, users.EraseOp, entries[1].Op)
+	verified, _ := users.SetupVerifyAudit(logger.Logger{}, auditStore)(context.Background())
+	require.True(t, verified.Valid)
+
+	select {
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatal("didn't receive erase event, time out after 3sec")
+	case evt := <-events:
+		require.Equal(t, users.EraseOp, evt.Op)
+		require.Equal(t, &users.User{ID: usr.ID}, evt.Before)
+	}
+}
+
+// failingEraser can't erase the data of any user until it is available
+type failingEraser struct {
+	available bool
+}
+
+func (e *failingEraser) EraseUserData(ctx context.Context, userID string) (int, error) {
+	if !e.available {
+		return 0, errors.New("unavailable")
+	}
+	return 1, nil
+}
+
+func TestSetupEraseUser_Retry(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	keyStore := apikeystore.NewInMemory()
+	notifier := usernotifier.NewInMemory()
+	events := notifier.Listen()
+	sign, _ := signer.NewEd25519(signer.Config{})
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email: "test-erase-2",
+	})
+	_, _ = keyStore.AddAPIKey(context.Background(), &users.APIKey{UserID: usr.ID, Hash: "hash"})
+
+	eraser := &failingEraser{}
+	erase := users.SetupEraseUser(logger.Logger{}, notifier, userStore, map[string]users.UserDataEraser{
+		"api_keys": keyStore,
+		"failing":  eraser,
+	}, sign, users.SystemClock)
+	_, err := erase(context.Background(), &users.EraseUserReq{UserID: usr.ID})
+	require.Error(t, err)
+	found, _ := userStore.Search(context.Background(), userStore.Query().ByID(usr.ID))
+	require.Len(t, found, 1, "the user is kept until its data is erased")
+
+	eraser.available = true
+	res, err := erase(context.Background(), &users.EraseUserReq{UserID: usr.ID})
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Receipt.Erased["users"])
+	require.Equal(t, 1, res.Receipt.Erased["failing"])
+	found, _ = userStore.Search(context.Background(), userStore.Query().ByID(usr.ID).WithDeleted())
+	require.Empty(t, found)
+	keys, _ := keyStore.ListAPIKeys(context.Background(), usr.ID)
+	require.Empty(t, keys)
+
+	select {
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatal("didn't receive erase event, time out after 3sec")
+	case evt := <-events:
+		require.Equal(t, users.EraseOp, evt.Op)
+		require.Equal(t, &users.User{ID: usr.ID}, evt.Before)
+	}
+}
@@ -0,0 +1,153 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// ExportUserDataReq contains the required parameters to export the data held about a user
+type ExportUserDataReq struct {
+	UserID string `json:"id"`
+}
+
+// ExportUserDataResp contains everything held about the user.
+// Note: secrets (password hash, mfa secret and recovery codes, api keys hash, oidc codes and tokens hash, phone code
+// hash) aren't exported
+type ExportUserDataResp struct {
+	ExportedAt time.Time     `json:"exported_at"`
+	User       *User         `json:"user"`
+	MFAEnabled bool          `json:"mfa_enabled"`
+	APIKeys    []*APIKey     `json:"api_keys"`
+	Audit      []*AuditEntry `json:"audit"`
+	// OIDCGrants are the authorization codes and the refresh tokens given by the user to the OpenID Connect clients
+	OIDCGrants []*ExportedOIDCGrant `json:"oidc_grants"`
+	// PhoneVerification is the pending verification of the phone of the user, if any
+	PhoneVerification *ExportedPhoneVerification `json:"phone_verification,omitempty"`
+}
+
+// ExportedOIDCGrant is an authorization code or a refresh token of the user, without its hash
+type ExportedOIDCGrant struct {
+	// Type is GrantAuthorizationCode or GrantRefreshToken
+	Type      string     `json:"type"`
+	ClientID  string     `json:"client_id"`
+	Scopes    []string   `json:"scopes"`
+	AuthTime  time.Time  `json:"auth_time"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ExportedPhoneVerification is the pending phone verification of the user, without the hash of its code
+type ExportedPhoneVerification struct {
+	Phone     string    `json:"phone"`
+	SentAt    time.Time `json:"sent_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Sent      int       `json:"sent"`
+	Attempts  int       `json:"attempts"`
+}
+
+// OIDCGrantLister will list the authorization codes and the refresh tokens given by a user to the OpenID Connect clients
+type OIDCGrantLister interface {
+	ListUserCodes(ctx context.Context, userID string) ([]*AuthorizationCode, error)
+	ListUserRefreshTokens(ctx context.Context, userID string) ([]*RefreshToken, error)
+}
+
+// ExportUserData define the function which will gather all the data held about a user
+type ExportUserData func(ctx context.Context, req *ExportUserDataReq) (*ExportUserDataResp, error)
+
+// SetupExportUserData will return a configured ExportUserData function which can be used later
+func SetupExportUserData(log logger.Logger, repo Searcher, mfaStore MFAStore, keys APIKeyLister, audit AuditLister, grants OIDCGrantLister,
+	phones PhoneVerificationStore, clock Clock) ExportUserData {
+	log = log.With().Str("usecase", "user_export").Logger()
+	return func(ctx context.Context, req *ExportUserDataReq) (*ExportUserDataResp, error) {
+		usr, err := findUser(ctx, repo, repo.Query().ByID(req.UserID).WithDeleted())
+		if err != nil {
+			return nil, err
+		}
+		exported := *usr
+		exported.Password = ""
+
+		mfa, err := mfaStore.GetMFA(ctx, usr.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve mfa: %w", err)
+		}
+		apiKeys, err := keys.ListAPIKeys(ctx, usr.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't list api keys: %w", err)
+		}
+		entries, err := audit.ListUserAudit(ctx, usr.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't list audit entries: %w", err)
+		}
+		oidcGrants, err := exportOIDCGrants(ctx, grants, usr.ID)
+		if err != nil {
+			return nil, err
+		}
+		verification, err := phones.GetPhoneVerification(ctx, usr.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve phone verification: %w", err)
+		}
+		log.Debug().Str("user_id", usr.ID).Msg("user data exported")
+
+		return &ExportUserDataResp{
+			ExportedAt:        clock().UTC(),
+			User:              &exported,
+			MFAEnabled:        mfa != nil && mfa.Confirmed,
+			APIKeys:           apiKeys,
+			Audit:             entries,
+			OIDCGrants:        oidcGrants,
+			PhoneVerification: exportPhoneVerification(verification),
+		}, nil
+	}
+}
+
+// exportOIDCGrants will return the authorization codes then the refresh tokens of the user, without their hash
+func exportOIDCGrants(ctx context.Context, grants OIDCGrantLister, userID string) ([]*ExportedOIDCGrant, error) {
+	codes, err := grants.ListUserCodes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("can't list authorization codes: %w", err)
+	}
+	tokens, err := grants.ListUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("can't list refresh tokens: %w", err)
+	}
+	res := make([]*ExportedOIDCGrant, 0, len(codes)+len(tokens))
+	for _, code := range codes {
+		res = append(res, &ExportedOIDCGrant{
+			Type:      GrantAuthorizationCode,
+			ClientID:  code.ClientID,
+			Scopes:    code.Scopes,
+			AuthTime:  code.AuthTime,
+			ExpiresAt: code.ExpiresAt,
+		})
+	}
+	for _, token := range tokens {
+		res = append(res, &ExportedOIDCGrant{
+			Type:      GrantRefreshToken,
+			ClientID:  token.ClientID,
+			Scopes:    token.Scopes,
+			AuthTime:  token.AuthTime,
+			ExpiresAt: token.ExpiresAt,
+			RotatedAt: token.RotatedAt,
+			RevokedAt: token.RevokedAt,
+		})
+	}
+	return res, nil
+}
+
+// exportPhoneVerification will return the pending phone verification without the hash of its code
+func exportPhoneVerification(verification *PhoneVerification) *ExportedPhoneVerification {
+	if verification == nil {
+		return nil
+	}
+	return &ExportedPhoneVerification{
+		Phone:     verification.Phone,
+		SentAt:    verification.SentAt,
+		ExpiresAt: verification.ExpiresAt,
+		Sent:      verification.Sent,
+		Attempts:  verification.Attempts,
+	}
+}
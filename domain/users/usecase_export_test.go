@@ -0,0 +1,57 @@
+package users_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/apikeystore"
+	"go-users-example/infra/auditstore"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/mfastore"
+	"go-users-example/infra/oidcstore"
+	"go-users-example/infra/phonestore"
+	"go-users-example/infra/userstore"
+)
+
+func TestSetupExportUserData_OK(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	mfaStore := mfastore.NewInMemory()
+	keyStore := apikeystore.NewInMemory()
+	auditStore := auditstore.NewInMemory()
+	oidcStore := oidcstore.NewInMemory()
+	phoneStore := phonestore.NewInMemory()
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email:    "test-export-1",
+		Password: "hash",
+	})
+	_ = mfaStore.SaveMFA(context.Background(), &users.MFA{UserID: usr.ID, Secret: "secret", Confirmed: true})
+	_, _ = keyStore.AddAPIKey(context.Background(), &users.APIKey{UserID: usr.ID, Name: "ci", Hash: "hash"})
+	_ = users.SetupRecordAudit(logger.Logger{}, auditStore)(context.Background(), &users.ChangeEvent{Op: users.CreateOp, After: usr})
+	_ = oidcStore.AddCode(context.Background(), &users.AuthorizationCode{Hash: "code-hash", UserID: usr.ID, ClientID: "client", Scopes: []string{"openid"}})
+	_ = oidcStore.AddRefreshToken(context.Background(), &users.RefreshToken{Hash: "token-hash", UserID: usr.ID, ClientID: "client"})
+	_ = phoneStore.SavePhoneVerification(context.Background(), &users.PhoneVerification{UserID: usr.ID, Phone: "+33612345678", CodeHash: "code-hash", Sent: 1})
+
+	export := users.SetupExportUserData(logger.Logger{}, userStore, mfaStore, keyStore, auditStore, oidcStore, phoneStore, users.SystemClock)
+	res, err := export(context.Background(), &users.ExportUserDataReq{UserID: usr.ID})
+	require.NoError(t, err)
+	require.Equal(t, "test-export-1", res.User.Email)
+	require.Empty(t, res.User.Password)
+	require.True(t, res.MFAEnabled)
+	require.Len(t, res.APIKeys, 1)
+	require.Len(t, res.Audit, 1)
+	require.Len(t, res.OIDCGrants, 2)
+	require.Equal(t, users.GrantAuthorizationCode, res.OIDCGrants[0].Type)
+	require.Equal(t, users.GrantRefreshToken, res.OIDCGrants[1].Type)
+	require.Equal(t, "+33612345678", res.PhoneVerification.Phone)
+	data, _ := json.Marshal(res)
+	require.NotContains(t, string(data), "code-hash", "the hashes of the codes are never exported")
+	require.NotContains(t, string(data), "token-hash", "the hashes of the tokens are never exported")
+
+	_, err = export(context.Background(), &users.ExportUserDataReq{UserID: "unknown"})
+	require.True(t, errors.Is(err, users.ErrUserNotFound))
+}
@@ -0,0 +1,147 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+// ErrInvalidExport is returned if an exported field is unknown or can't be exported
+var ErrInvalidExport = errors.New("provided export isn't valid")
+
+// ExportFieldType is the type of the values of an exported field
+type ExportFieldType string
+
+// the types of the exported fields
+const (
+	// ExportString values are strings, empty if unset
+	ExportString ExportFieldType = "string"
+	// ExportTime values are *time.Time, nil if unset
+	ExportTime ExportFieldType = "time"
+	// ExportObject values are map[string]interface{}, nil if unset
+	ExportObject ExportFieldType = "object"
+)
+
+// ExportField is an exported field of the users, named after its json field
+type ExportField struct {
+	Name  string
+	Type  ExportFieldType
+	value func(usr *User) interface{}
+}
+
+// exportFields are the fields which can be exported in their default order, the password hash is never exported
+var exportFields = []ExportField{
+	{Name: "id", Type: ExportString, value: func(usr *User) interface{} { return usr.ID }},
+	{Name: "first_name", Type: ExportString, value: func(usr *User) interface{} { return usr.FirstName }},
+	{Name: "last_name", Type: ExportString, value: func(usr *User) interface{} { return usr.LastName }},
+	{Name: "nick_name", Type: ExportString, value: func(usr *User) interface{} { return usr.NickName }},
+	{Name: "email", Type: ExportString, value: func(usr *User) interface{} { return usr.Email }},
+	{Name: "country", Type: ExportString, value: func(usr *User) interface{} { return usr.Country }},
+	{Name: "phone", Type: ExportString, value: func(usr *User) interface{} { return usr.Phone }},
+	{Name: "phone_verified_at", Type: ExportTime, value: func(usr *User) interface{} { return usr.PhoneVerifiedAt }},
+	{Name: "attributes", Type: ExportObject, value: func(usr *User) interface{} { return usr.Attributes }},
+	{Name: "deleted_at", Type: ExportTime, value: func(usr *User) interface{} { return usr.DeletedAt }},
+}
+
+// ExportUsersReq contains the filters of the exported users, as the ones of a search, and the exported fields. All
+// the users are exported without filter
+type ExportUsersReq struct {
+	SearchReq
+	// Fields are the names of the exported fields in their order, all the fields if empty
+	Fields []string
+	Writer ExportWriter
+}
+
+// ExportUsersResp contains the number of exported users
+type ExportUsersResp struct {
+	Exported int `json:"exported"`
+}
+
+// ExportWriter will write the exported users in a format
+type ExportWriter interface {
+	// Begin is called once with the exported fields, before the users
+	Begin(fields []ExportField) error
+	// Write is called with the values of the exported fields of each user, the values are only valid during the call
+	Write(values []interface{}) error
+}
+
+// Scanner will read the users from a consistent snapshot of the store, the changes made during a scan aren't seen
+// by it
+type Scanner interface {
+	Query() Queryer
+	// Scan will call fn with each user matching the query, ordered by id. All the users match a query without criteria
+	Scan(ctx context.Context, q Queryer, fn func(usr *User) error) error
+}
+
+// ExportUsers define the function which will stream the users to the writer, from a snapshot of the user base
+type ExportUsers func(ctx context.Context, req *ExportUsersReq) (*ExportUsersResp, error)
+
+// SetupExportUsers will return a configured ExportUsers function which can be used later
+func SetupExportUsers(log logger.Logger, repo Scanner, validator *Validator) ExportUsers {
+	return exportUsers(repo, validator)
+}
+
+func exportUsers(repo Scanner, validator *Validator) ExportUsers {
+	return func(ctx context.Context, req *ExportUsersReq) (*ExportUsersResp, error) {
+		fields, err := selectExportFields(req.Fields)
+		if err != nil {
+			return nil, err
+		}
+		q, err := searchQuery(repo.Query(), validator, &req.SearchReq)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := req.Writer.Begin(fields); err != nil {
+			return nil, fmt.Errorf("can't write export: %w", err)
+		}
+		res := &ExportUsersResp{}
+		values := make([]interface{}, len(fields))
+		err = repo.Scan(ctx, q, func(usr *User) error {
+			for i, field := range fields {
+				values[i] = field.value(usr)
+			}
+			if err := req.Writer.Write(values); err != nil {
+				return fmt.Errorf("can't write export: %w", err)
+			}
+			res.Exported++
+			return nil
+		})
+		if err != nil {
+			return res, fmt.Errorf("can't export users: %w", err)
+		}
+		return res, nil
+	}
+}
+
+// selectExportFields will return the fields by name, or all the fields. The fields are exported once
+func selectExportFields(names []string) ([]ExportField, error) {
+	if len(names) == 0 {
+		return exportFields, nil
+	}
+	fields := make([]ExportField, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		field, ok := exportField(name)
+		if !ok {
+			return nil, fmt.Errorf("field %q can't be exported: %w", name, ErrInvalidExport)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func exportField(name string) (ExportField, bool) {
+	for _, field := range exportFields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return ExportField{}, false
+}
@@ -0,0 +1,83 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+// recordingWriter will keep the exported fields and a copy of the values of the users
+type recordingWriter struct {
+	fields []string
+	rows   [][]interface{}
+}
+
+func (r *recordingWriter) Begin(fields []users.ExportField) error {
+	for _, field := range fields {
+		r.fields = append(r.fields, field.Name)
+	}
+	return nil
+}
+
+func (r *recordingWriter) Write(values []interface{}) error {
+	r.rows = append(r.rows, append([]interface{}(nil), values...))
+	return nil
+}
+
+func TestSetupExportUsers(t *testing.T) {
+	store := userstore.NewInMemory()
+	for _, usr := range []*users.User{
+		{FirstName: "test", Email: "test-export-1@test.com", Password: "hash", Country: "FR"},
+		{FirstName: "test", Email: "test-export-2@test.com", Password: "hash", Country: "US"},
+	} {
+		_, err := store.Add(context.Background(), usr)
+		require.NoError(t, err)
+	}
+	deleted, _ := store.Add(context.Background(), &users.User{FirstName: "test", Email: "test-export-3@test.com", Country: "FR"})
+	_, err := store.Delete(context.Background(), deleted)
+	require.NoError(t, err)
+	export := users.SetupExportUsers(logger.Logger{}, store, newValidator(t, users.Config{}))
+
+	t.Run("all the users and fields", func(t *testing.T) {
+		w := &recordingWriter{}
+		res, err := export(context.Background(), &users.ExportUsersReq{Writer: w})
+		require.NoError(t, err)
+		require.Equal(t, 2, res.Exported)
+		require.Len(t, w.rows, 2)
+		require.NotContains(t, w.fields, "password", "the password hashes are never exported")
+		require.Equal(t, "id", w.fields[0])
+	})
+	t.Run("filters and projection", func(t *testing.T) {
+		w := &recordingWriter{}
+		res, err := export(context.Background(), &users.ExportUsersReq{
+			SearchReq: users.SearchReq{Country: []string{"france"}, WithDeleted: true},
+			Fields:    []string{"email", "deleted_at", "email"},
+			Writer:    w,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, res.Exported)
+		require.Equal(t, []string{"email", "deleted_at"}, w.fields)
+		emails := []interface{}{w.rows[0][0], w.rows[1][0]}
+		require.ElementsMatch(t, []interface{}{"test-export-1@test.com", "test-export-3@test.com"}, emails)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for name, req := range map[string]*users.ExportUsersReq{
+			"password":          {Fields: []string{"email", "password"}},
+			"unknown field":     {Fields: []string{"nick_name_key"}},
+			"unknown attribute": {SearchReq: users.SearchReq{Attributes: map[string][]string{"plan": {"pro"}}}},
+		} {
+			w := &recordingWriter{}
+			req.Writer = w
+			_, err := export(context.Background(), req)
+			require.Error(t, err, name)
+			require.Nil(t, w.fields, "%s: nothing is written", name)
+		}
+		_, err := export(context.Background(), &users.ExportUsersReq{Fields: []string{"password"}, Writer: &recordingWriter{}})
+		require.ErrorIs(t, err, users.ErrInvalidExport)
+	})
+}
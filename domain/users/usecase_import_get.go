@@ -0,0 +1,39 @@
+package users
+
+import (
+	"context"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+// GetImportJobReq contains the id of the import job
+type GetImportJobReq struct {
+	ID string `json:"id"`
+}
+
+// GetImportJobResp contains the progress of the import job and the errors of its rows
+type GetImportJobResp struct {
+	Job *ImportJob `json:"job"`
+}
+
+// GetImportJob define the function which will return an import job
+type GetImportJob func(ctx context.Context, req *GetImportJobReq) (*GetImportJobResp, error)
+
+// SetupGetImportJob will return a configured GetImportJob function which can be used later
+func SetupGetImportJob(log logger.Logger, store ImportJobStore) GetImportJob {
+	return getImportJob(store)
+}
+
+func getImportJob(store ImportJobStore) GetImportJob {
+	return func(ctx context.Context, req *GetImportJobReq) (*GetImportJobResp, error) {
+		job, err := store.GetImportJob(ctx, req.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't get import job: %w", err)
+		}
+		if job == nil {
+			return nil, fmt.Errorf("import job %q: %w", req.ID, ErrImportJobNotFound)
+		}
+		return &GetImportJobResp{Job: job}, nil
+	}
+}
@@ -0,0 +1,54 @@
+package users
+
+import (
+	"context"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+// ResumeImportReq contains the id of the failed import job
+type ResumeImportReq struct {
+	ID string `json:"id"`
+}
+
+// ResumeImportResp contains the job processing the rest of the import in the background
+type ResumeImportResp struct {
+	Job *ImportJob `json:"job"`
+}
+
+// ResumeImport define the function which will run a failed import job again from its checkpoint
+type ResumeImport func(ctx context.Context, req *ResumeImportReq) (*ResumeImportResp, error)
+
+// SetupResumeImport will return a configured ResumeImport function which can be used later. The jobs run in the
+// background until background is done, the lifetime of the server
+func SetupResumeImport(background context.Context, log logger.Logger, store ImportJobStore, run RunImport, clock Clock) ResumeImport {
+	log = log.With().Str("usecase", "user_import_resume").Logger()
+	return resumeImport(background, log, store, run, clock)
+}
+
+func resumeImport(background context.Context, log logger.Logger, store ImportJobStore, run RunImport, clock Clock) ResumeImport {
+	return func(ctx context.Context, req *ResumeImportReq) (*ResumeImportResp, error) {
+		job, err := store.GetImportJob(ctx, req.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't get import job: %w", err)
+		}
+		if job == nil {
+			return nil, fmt.Errorf("import job %q: %w", req.ID, ErrImportJobNotFound)
+		}
+		if job.Status != ImportFailed {
+			return nil, fmt.Errorf("import job %q is %s: %w", job.ID, job.Status, ErrImportNotResumable)
+		}
+		// the job is resumed once, by the first of the concurrent requests
+		job.Status, job.UpdatedAt = ImportPending, clock()
+		resumed, err := store.TransitionImportJob(ctx, job, ImportFailed)
+		if err != nil {
+			return nil, fmt.Errorf("can't save import job: %w", err)
+		}
+		if !resumed {
+			return nil, fmt.Errorf("import job %q is already resumed: %w", job.ID, ErrImportNotResumable)
+		}
+		runInBackground(ctx, background, log, run, job.ID)
+		return &ResumeImportResp{Job: job}, nil
+	}
+}
@@ -0,0 +1,71 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/importstore"
+	"go-users-example/infra/logger"
+)
+
+func TestSetupResumeImport(t *testing.T) {
+	jobStore := importstore.NewInMemory()
+	failed := addImportJob(t, jobStore, &users.ImportJob{Format: users.ImportCSV, Status: users.ImportFailed, Processed: 100}, importCSV)
+	background, stop := context.WithCancel(context.Background())
+	defer stop()
+	resumed := make(chan context.Context, 1)
+	resume := users.SetupResumeImport(background, logger.Logger{}, jobStore, func(ctx context.Context, req *users.RunImportReq) (*users.RunImportResp, error) {
+		require.Equal(t, failed.ID, req.ID)
+		resumed <- ctx
+		return &users.RunImportResp{}, nil
+	}, users.SystemClock)
+
+	reqCtx, endReq := context.WithCancel(context.Background())
+	res, err := resume(reqCtx, &users.ResumeImportReq{ID: failed.ID})
+	endReq()
+	require.NoError(t, err)
+	require.Equal(t, users.ImportPending, res.Job.Status)
+	require.Equal(t, 100, res.Job.Processed)
+	runCtx := <-resumed
+	require.NoError(t, runCtx.Err(), "the job outlives the request")
+	stop()
+	require.Error(t, runCtx.Err(), "the job ends with the server")
+
+	_, err = resume(context.Background(), &users.ResumeImportReq{ID: failed.ID})
+	require.ErrorIs(t, err, users.ErrImportNotResumable, "a pending job can't be resumed")
+
+	_, err = resume(context.Background(), &users.ResumeImportReq{ID: "unknown"})
+	require.ErrorIs(t, err, users.ErrImportJobNotFound)
+}
+
+func TestSetupResumeImport_Concurrent(t *testing.T) {
+	jobStore := importstore.NewInMemory()
+	resumed := make(chan string, 10)
+	resume := users.SetupResumeImport(context.Background(), logger.Logger{}, jobStore, func(ctx context.Context, req *users.RunImportReq) (*users.RunImportResp, error) {
+		resumed <- req.ID
+		return &users.RunImportResp{}, nil
+	}, users.SystemClock)
+	failed := addImportJob(t, jobStore, &users.ImportJob{Format: users.ImportCSV, Status: users.ImportFailed}, importCSV)
+
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := resume(context.Background(), &users.ResumeImportReq{ID: failed.ID})
+			errs <- err
+		}()
+	}
+	succeeded := 0
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err == nil {
+			succeeded++
+		} else {
+			require.ErrorIs(t, err, users.ErrImportNotResumable)
+		}
+	}
+	require.Equal(t, 1, succeeded, "the job is resumed once")
+	require.Equal(t, failed.ID, <-resumed)
+	require.Len(t, resumed, 0)
+}
@@ -0,0 +1,172 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"go-users-example/infra/logger"
+)
+
+// importBatchSize is the number of rows created between two checkpoints of a job
+const importBatchSize = 100
+
+// RunImportReq contains the id of the job to run
+type RunImportReq struct {
+	ID string
+}
+
+// RunImportResp contains the job once completed, or failed
+type RunImportResp struct {
+	Job *ImportJob
+}
+
+// RunImport define the function which will create the users of an import job from its checkpoint
+type RunImport func(ctx context.Context, req *RunImportReq) (*RunImportResp, error)
+
+// SetupRunImport will return a configured RunImport function which can be used later. The rows go through the same
+// validation and notification as Create, c.ImportWorkers of them are created concurrently
+func SetupRunImport(log logger.Logger, notifier ChangeNotifier, repo Adder, hasher Hasher, validator *Validator, store ImportJobStore, c Config, clock Clock) RunImport {
+	log = log.With().Str("usecase", "user_import_run").Logger()
+	create := validateCreate(validator, notifyCreate(log, notifier, createUser(repo, hasher, validator.emails)))
+	dryRun := validateCreate(validator, func(ctx context.Context, req *CreateReq) (*CreateResp, error) {
+		return &CreateResp{}, nil
+	})
+	return runImport(log, store, create, dryRun, c.ImportWorkers, clock)
+}
+
+func runImport(log logger.Logger, store ImportJobStore, create, dryRun Create, workers int, clock Clock) RunImport {
+	if workers < 1 {
+		workers = 1
+	}
+	return func(ctx context.Context, req *RunImportReq) (*RunImportResp, error) {
+		job, err := store.GetImportJob(ctx, req.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't get import job: %w", err)
+		}
+		if job == nil {
+			return nil, fmt.Errorf("import job %q: %w", req.ID, ErrImportJobNotFound)
+		}
+		if job.Status == ImportCompleted {
+			return &RunImportResp{Job: job}, nil
+		}
+		createFunc := create
+		if job.DryRun {
+			createFunc = dryRun
+		}
+
+		job.Status, job.Error, job.UpdatedAt = ImportRunning, "", clock()
+		if err := store.SaveImportJob(ctx, job); err != nil {
+			return nil, fmt.Errorf("can't save import job: %w", err)
+		}
+		if err := processImport(ctx, store, job, createFunc, workers, clock); err != nil {
+			log.Warn().Err(err).Str("job_id", job.ID).Int("processed", job.Processed).Msg("import job stopped")
+			job.Status, job.Error = ImportFailed, err.Error()
+		} else {
+			finishedAt := clock()
+			job.Status, job.FinishedAt = ImportCompleted, &finishedAt
+		}
+		job.UpdatedAt = clock()
+		if err := store.SaveImportJob(ctx, job); err != nil {
+			return nil, fmt.Errorf("can't save import job: %w", err)
+		}
+		if job.Status == ImportCompleted {
+			if err := store.RemoveImportSource(ctx, job.ID); err != nil {
+				return nil, fmt.Errorf("can't remove rows of import job: %w", err)
+			}
+		}
+		return &RunImportResp{Job: job}, nil
+	}
+}
+
+// processImport will create the rows after the checkpoint of the job by batches, the checkpoint is saved after each
+// batch. A batch is always completed, the job stops between two batches when the context is done
+func processImport(ctx context.Context, store ImportJobStore, job *ImportJob, create Create, workers int, clock Clock) error {
+	source, err := store.OpenImportSource(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("can't open rows: %w", err)
+	}
+	defer source.Close()
+	rows, err := newImportReader(job.Format, source)
+	if err != nil {
+		return err
+	}
+	for skipped := 0; skipped < job.Processed; skipped++ {
+		if _, err := rows.Next(); err != nil {
+			return fmt.Errorf("can't skip to checkpoint: %w", err)
+		}
+	}
+
+	batch := make([]*importRow, 0, importBatchSize)
+	for end := false; !end; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		for len(batch) < importBatchSize {
+			row, err := rows.Next()
+			if errors.Is(err, io.EOF) {
+				end = true
+				break
+			}
+			if err != nil {
+				return err
+			}
+			batch = append(batch, row)
+		}
+
+		for i, err := range createRows(ctx, create, batch, workers) {
+			if err == nil {
+				job.Created++
+				continue
+			}
+			job.Failed++
+			if len(job.Errors) < maxImportErrors {
+				job.Errors = append(job.Errors, newImportRowError(batch[i].row, err))
+			}
+		}
+		job.Processed += len(batch)
+		job.UpdatedAt = clock()
+		if err := store.SaveImportJob(ctx, job); err != nil {
+			return fmt.Errorf("can't save checkpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// createRows will create the rows with the workers, the errors are returned in the order of the rows
+func createRows(ctx context.Context, create Create, rows []*importRow, workers int) []error {
+	errs := make([]error, len(rows))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if rows[i].err != nil {
+					errs[i] = rows[i].err
+					continue
+				}
+				_, errs[i] = create(ctx, rows[i].req)
+			}
+		}()
+	}
+	for i := range rows {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
+
+func newImportRowError(row int, err error) ImportRowError {
+	rerr := ImportRowError{Row: row, Message: err.Error()}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		rerr.Violations = verr.Violations
+	}
+	return rerr
+}
@@ -0,0 +1,119 @@
+package users_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/importstore"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/infra/userstore"
+)
+
+const importCSV = `first_name,last_name,nick_name,email,country,attributes
+test,test,test-import-1,test-import-1@test.com,france,
+test,test,test-import-2,test-import-2,Gondor,
+test,test,test-import-3,test-import-3@test.com,,"{}"
+test,test,test-import-4,test-import-4@test.com,,not json
+`
+
+func newRunImport(t *testing.T, userStore *userstore.InMemory, jobStore *importstore.InMemory, clock users.Clock) users.RunImport {
+	return users.SetupRunImport(logger.Logger{}, usernotifier.NewInMemory(), userStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost),
+		newValidator(t, users.Config{}), jobStore, users.Config{ImportWorkers: 2}, clock)
+}
+
+func addImportJob(t *testing.T, store *importstore.InMemory, job *users.ImportJob, rows string) *users.ImportJob {
+	job, err := store.AddImportJob(context.Background(), job, strings.NewReader(rows))
+	require.NoError(t, err)
+	return job
+}
+
+func countUsers(t *testing.T, store *userstore.InMemory) int {
+	found, err := store.Search(context.Background(), store.Query().ByFirstName("test"))
+	require.NoError(t, err)
+	return len(found)
+}
+
+func TestSetupRunImport(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	userStore, jobStore := userstore.NewInMemory(), importstore.NewInMemory()
+	run := newRunImport(t, userStore, jobStore, fixedClock(now))
+
+	job := addImportJob(t, jobStore, &users.ImportJob{Format: users.ImportCSV, Status: users.ImportPending}, importCSV)
+	res, err := run(context.Background(), &users.RunImportReq{ID: job.ID})
+	require.NoError(t, err)
+	require.Equal(t, users.ImportCompleted, res.Job.Status)
+	require.Equal(t, now, *res.Job.FinishedAt)
+	require.Equal(t, 4, res.Job.Processed)
+	require.Equal(t, 2, res.Job.Created)
+	require.Equal(t, 2, res.Job.Failed)
+	require.Len(t, res.Job.Errors, 2)
+	require.Equal(t, 2, res.Job.Errors[0].Row)
+	require.Len(t, res.Job.Errors[0].Violations, 2, "the rows are validated as a creation")
+	require.Equal(t, 4, res.Job.Errors[1].Row)
+	require.Contains(t, res.Job.Errors[1].Message, "attributes should be a json object")
+	require.Equal(t, 2, countUsers(t, userStore))
+
+	// the rows, with their passwords, are removed once the job is completed
+	_, err = jobStore.OpenImportSource(context.Background(), job.ID)
+	require.ErrorIs(t, err, importstore.ErrNotFound)
+
+	// a completed job isn't run again
+	res, err = run(context.Background(), &users.RunImportReq{ID: job.ID})
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Job.Created)
+	require.Equal(t, 2, countUsers(t, userStore))
+
+	_, err = run(context.Background(), &users.RunImportReq{ID: "unknown"})
+	require.ErrorIs(t, err, users.ErrImportJobNotFound)
+}
+
+func TestSetupRunImport_DryRun(t *testing.T) {
+	userStore, jobStore := userstore.NewInMemory(), importstore.NewInMemory()
+	run := newRunImport(t, userStore, jobStore, users.SystemClock)
+
+	job := addImportJob(t, jobStore, &users.ImportJob{Format: users.ImportNDJSON, DryRun: true}, `{"first_name": "test", "last_name": "test", "nick_name": "test-dry-1", "email": "test-dry-1@test.com"}
+
+{"first_name": "test", "last_name": "test", "nick_name": "test-dry-2", "email": "test-dry-2@test.com", "role": "admin"}
+{"first_name": "t", "last_name": "test", "nick_name": "test-dry-3", "email": "test-dry-3@test.com"}
+`)
+	res, err := run(context.Background(), &users.RunImportReq{ID: job.ID})
+	require.NoError(t, err)
+	require.Equal(t, users.ImportCompleted, res.Job.Status)
+	require.Equal(t, 3, res.Job.Processed, "the blank lines aren't rows")
+	require.Equal(t, 1, res.Job.Created)
+	require.Equal(t, 2, res.Job.Failed)
+	require.Contains(t, res.Job.Errors[0].Message, "invalid json row")
+	require.Equal(t, 0, countUsers(t, userStore), "a dry run doesn't create the users")
+}
+
+func TestSetupRunImport_Checkpoint(t *testing.T) {
+	userStore, jobStore := userstore.NewInMemory(), importstore.NewInMemory()
+	run := newRunImport(t, userStore, jobStore, users.SystemClock)
+
+	// the job failed after its first row
+	job := addImportJob(t, jobStore, &users.ImportJob{Format: users.ImportCSV, Status: users.ImportFailed, Processed: 1, Created: 1}, importCSV)
+	res, err := run(context.Background(), &users.RunImportReq{ID: job.ID})
+	require.NoError(t, err)
+	require.Equal(t, users.ImportCompleted, res.Job.Status)
+	require.Empty(t, res.Job.Error)
+	require.Equal(t, 4, res.Job.Processed)
+	require.Equal(t, 2, res.Job.Created)
+	require.Equal(t, 1, countUsers(t, userStore), "the rows before the checkpoint aren't created again")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job = addImportJob(t, jobStore, &users.ImportJob{Format: users.ImportCSV}, importCSV)
+	res, err = run(ctx, &users.RunImportReq{ID: job.ID})
+	require.NoError(t, err)
+	require.Equal(t, users.ImportFailed, res.Job.Status)
+	require.Equal(t, context.Canceled.Error(), res.Job.Error)
+	require.Equal(t, 0, res.Job.Processed)
+}
@@ -0,0 +1,98 @@
+package users
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go-users-example/infra/logger"
+)
+
+// StartImportReq contains the rows of an import, read from Source until its end
+type StartImportReq struct {
+	Format ImportFormat
+	DryRun bool
+	Source io.Reader
+}
+
+// StartImportResp contains the job processing the import in the background
+type StartImportResp struct {
+	Job *ImportJob `json:"job"`
+}
+
+// ImportJobStore will keep the import jobs, and their rows until they complete
+type ImportJobStore interface {
+	// AddImportJob will save a new job with the rows read from source and generate an id for the job
+	AddImportJob(ctx context.Context, job *ImportJob, source io.Reader) (*ImportJob, error)
+	GetImportJob(ctx context.Context, id string) (*ImportJob, error)
+	SaveImportJob(ctx context.Context, job *ImportJob) error
+	// TransitionImportJob will save the job only if its stored status is still from, false is returned otherwise
+	TransitionImportJob(ctx context.Context, job *ImportJob, from ImportStatus) (bool, error)
+	// OpenImportSource will return the rows of the job from their start
+	OpenImportSource(ctx context.Context, id string) (io.ReadCloser, error)
+	// RemoveImportSource will delete the rows of a completed job
+	RemoveImportSource(ctx context.Context, id string) error
+}
+
+// StartImport define the function which will save the rows of an import and process them in the background
+type StartImport func(ctx context.Context, req *StartImportReq) (*StartImportResp, error)
+
+// SetupStartImport will return a configured StartImport function which can be used later. The jobs run in the
+// background until background is done, the lifetime of the server
+func SetupStartImport(background context.Context, log logger.Logger, store ImportJobStore, run RunImport, clock Clock) StartImport {
+	log = log.With().Str("usecase", "user_import_start").Logger()
+	return validateStartImport(startImport(background, log, store, run, clock))
+}
+
+func startImport(background context.Context, log logger.Logger, store ImportJobStore, run RunImport, clock Clock) StartImport {
+	return func(ctx context.Context, req *StartImportReq) (*StartImportResp, error) {
+		now := clock()
+		job, err := store.AddImportJob(ctx, &ImportJob{
+			Format:    req.Format,
+			DryRun:    req.DryRun,
+			Status:    ImportPending,
+			Errors:    []ImportRowError{},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}, req.Source)
+		if err != nil {
+			return nil, fmt.Errorf("can't save import job: %w", err)
+		}
+		runInBackground(ctx, background, log, run, job.ID)
+		return &StartImportResp{Job: job}, nil
+	}
+}
+
+// validateStartImport will check the format and the csv header before the rows are saved, the first line is read
+// ahead and given back to the source
+func validateStartImport(startFunc StartImport) StartImport {
+	return func(ctx context.Context, req *StartImportReq) (*StartImportResp, error) {
+		buffered := bufio.NewReader(req.Source)
+		line, err := buffered.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("can't read rows: %w", err)
+		}
+		if _, err := newImportReader(req.Format, bytes.NewReader(line)); err != nil {
+			return nil, err
+		}
+		return startFunc(ctx, &StartImportReq{
+			Format: req.Format,
+			DryRun: req.DryRun,
+			Source: io.MultiReader(bytes.NewReader(line), buffered),
+		})
+	}
+}
+
+// runInBackground will run the job beyond the request until background is done, with the origin of the request for
+// the change events of the created users
+func runInBackground(ctx, background context.Context, log logger.Logger, run RunImport, id string) {
+	ctx = WithRequestInfo(background, RequestInfoFromContext(ctx))
+	go func() {
+		if _, err := run(ctx, &RunImportReq{ID: id}); err != nil {
+			log.Error().Err(err).Str("job_id", id).Msg("can't run import job")
+		}
+	}()
+}
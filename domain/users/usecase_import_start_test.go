@@ -0,0 +1,57 @@
+package users_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/importstore"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+func TestSetupStartImport(t *testing.T) {
+	userStore, jobStore := userstore.NewInMemory(), importstore.NewInMemory()
+	start := users.SetupStartImport(context.Background(), logger.Logger{}, jobStore, newRunImport(t, userStore, jobStore, users.SystemClock), users.SystemClock)
+	getJob := users.SetupGetImportJob(logger.Logger{}, jobStore)
+
+	res, err := start(context.Background(), &users.StartImportReq{Format: users.ImportCSV, Source: strings.NewReader(importCSV)})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Job.ID)
+	require.Equal(t, users.ImportPending, res.Job.Status)
+
+	require.Eventually(t, func() bool {
+		job, err := getJob(context.Background(), &users.GetImportJobReq{ID: res.Job.ID})
+		require.NoError(t, err)
+		return job.Job.Status == users.ImportCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 2, countUsers(t, userStore))
+
+	_, err = getJob(context.Background(), &users.GetImportJobReq{ID: "unknown"})
+	require.ErrorIs(t, err, users.ErrImportJobNotFound)
+}
+
+func TestSetupStartImport_Invalid(t *testing.T) {
+	jobStore := importstore.NewInMemory()
+	start := users.SetupStartImport(context.Background(), logger.Logger{}, jobStore, func(ctx context.Context, req *users.RunImportReq) (*users.RunImportResp, error) {
+		t.Error("the invalid imports aren't run")
+		return nil, nil
+	}, users.SystemClock)
+
+	tests := map[string]*users.StartImportReq{
+		"unknown format":   {Format: "xml", Source: strings.NewReader("<users/>")},
+		"empty csv":        {Format: users.ImportCSV, Source: strings.NewReader("")},
+		"unknown column":   {Format: users.ImportCSV, Source: strings.NewReader("email,role\n")},
+		"duplicate column": {Format: users.ImportCSV, Source: strings.NewReader("email,Email\n")},
+	}
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := start(context.Background(), req)
+			require.ErrorIs(t, err, users.ErrInvalidImport)
+		})
+	}
+}
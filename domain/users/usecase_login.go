@@ -0,0 +1,204 @@
+package users
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// ErrInvalidCredentials is returned when the login can't be completed, no detail is given on purpose
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrTooManyLoginAttempts is returned when the user, or the client, failed too many logins recently
+var ErrTooManyLoginAttempts = errors.New("too many login attempts")
+
+const (
+	// maxLoginAttempts is the number of failed passwords accepted for an email during a loginAttemptWindow, and the
+	// number of challenges of a user not completed
+	maxLoginAttempts = 10
+	// maxClientLoginAttempts is the number of failed passwords and second factors accepted for a client (its source
+	// ip) during a loginAttemptWindow, across the users
+	maxClientLoginAttempts = 100
+	// loginAttemptWindow is the period limiting the login attempts
+	loginAttemptWindow = 15 * time.Minute
+)
+
+// LoginReq contains the required parameters to log a user in
+type LoginReq struct {
+	Email       string `json:"email"`
+	RawPassword string `json:"password"`
+	// Binding is what the login is made for (ex: an authorization request), its challenge is bound to it
+	Binding string `json:"-"`
+}
+
+// LoginResp contains the logged user, or the challenge to complete if the user enabled a second factor
+type LoginResp struct {
+	User *User `json:"user,omitempty"`
+	// ChallengeID is set when the second factor is required to complete the login, see LoginMFA
+	ChallengeID string `json:"challenge_id,omitempty"`
+}
+
+// HashComparer will check a raw value against its hashed representation
+type HashComparer interface {
+	Compare(hashed, raw string) error
+}
+
+// PasswordHasher will hash the passwords and check them against their hash
+type PasswordHasher interface {
+	Hasher
+	HashComparer
+}
+
+// ChallengeStore will keep the pending login challenges
+type ChallengeStore interface {
+	AddChallenge(ctx context.Context, challenge *Challenge) (*Challenge, error)
+	// TakeChallenge will return and remove the challenge so it can only be used once
+	TakeChallenge(ctx context.Context, id string) (*Challenge, error)
+}
+
+// LoginAttemptStore will keep the recent login attempts of the users and of the clients
+type LoginAttemptStore interface {
+	// AddLoginAttempt will record the attempt of the key made at `at`, unless the key already made max attempts since
+	// `since`: false is then returned. The check and the record are atomic
+	AddLoginAttempt(ctx context.Context, key string, at, since time.Time, max int) (bool, error)
+	// RemoveLoginAttempt will forget the attempt of the key made at `at`
+	RemoveLoginAttempt(ctx context.Context, key string, at time.Time) error
+}
+
+// Login define the function which will check the credentials of a user
+type Login func(ctx context.Context, req *LoginReq) (*LoginResp, error)
+
+// SetupLogin will return a configured Login function which can be used later
+func SetupLogin(log logger.Logger, repo Searcher, hasher PasswordHasher, mfaStore MFAStore, challenges ChallengeStore,
+	attempts LoginAttemptStore, c Config, clock Clock) Login {
+	log = log.With().Str("usecase", "user_login").Logger()
+	return throttleLogin(attempts, clock, challengeLogin(mfaStore, challenges, attempts, c, clock, loginUser(log, repo, hasher)))
+}
+
+func loginUser(log logger.Logger, repo Searcher, hasher PasswordHasher) Login {
+	// the password of an unknown email is compared to this hash, so its login takes as long as a wrong password
+	dummyHash, err := hasher.Hash("no user has this password")
+	if err != nil {
+		log.Error().Err(err).Msg("can't hash the password of the unknown emails")
+	}
+	return func(ctx context.Context, req *LoginReq) (*LoginResp, error) {
+		usr, err := findUser(ctx, repo, repo.Query().ByEmail(lookupEmail(req.Email)))
+		if errors.Is(err, ErrUserNotFound) {
+			_ = hasher.Compare(dummyHash, req.RawPassword)
+			return nil, ErrInvalidCredentials
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := hasher.Compare(usr.Password, req.RawPassword); err != nil {
+			log.Debug().Str("user_id", usr.ID).Err(err).Msg("password mismatch")
+			return nil, ErrInvalidCredentials
+		}
+		return &LoginResp{User: usr}, nil
+	}
+}
+
+// throttleLogin will refuse the login once the email or the client failed too many passwords, the login is recorded as
+// an attempt and forgotten once the password is checked
+func throttleLogin(attempts LoginAttemptStore, clock Clock, loginFunc Login) Login {
+	return func(ctx context.Context, req *LoginReq) (*LoginResp, error) {
+		at := clock()
+		// the email isn't kept, only its digest
+		limits := append(clientLoginLimit(ctx), loginLimit{
+			key: fmt.Sprintf("email:%x", sha256.Sum256([]byte(lookupEmail(req.Email)))),
+			max: maxLoginAttempts,
+		})
+		if err := addLoginAttempt(ctx, attempts, at, limits); err != nil {
+			return nil, err
+		}
+		res, err := loginFunc(ctx, req)
+		if err != nil {
+			return res, err
+		}
+		if err := removeLoginAttempt(ctx, attempts, at, limits); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+}
+
+// challengeLogin will replace the logged user by a challenge if the user enabled its second factor. A challenge is an
+// attempt of the user until it is completed, see loginMFA
+func challengeLogin(mfaStore MFAStore, challenges ChallengeStore, attempts LoginAttemptStore, c Config, clock Clock, loginFunc Login) Login {
+	return func(ctx context.Context, req *LoginReq) (*LoginResp, error) {
+		res, err := loginFunc(ctx, req)
+		if err != nil {
+			return res, err
+		}
+		mfa, err := mfaStore.GetMFA(ctx, res.User.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve mfa: %w", err)
+		}
+		if mfa == nil || !mfa.Confirmed {
+			return res, nil
+		}
+		createdAt := clock()
+		if err := addLoginAttempt(ctx, attempts, createdAt, challengeLoginLimit(res.User.ID)); err != nil {
+			return nil, err
+		}
+		challenge, err := challenges.AddChallenge(ctx, &Challenge{
+			UserID:    res.User.ID,
+			CreatedAt: createdAt,
+			ExpiresAt: createdAt.Add(c.MFAChallengeTTL),
+			Binding:   req.Binding,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("can't create challenge: %w", err)
+		}
+		return &LoginResp{ChallengeID: challenge.ID}, nil
+	}
+}
+
+// loginLimit is the maximum of attempts of a key during a loginAttemptWindow
+type loginLimit struct {
+	key string
+	max int
+}
+
+// clientLoginLimit returns the limit of the client of the request, none if its source ip is unknown
+func clientLoginLimit(ctx context.Context) []loginLimit {
+	ip := RequestInfoFromContext(ctx).SourceIP
+	if ip == "" {
+		return nil
+	}
+	return []loginLimit{{key: "client:" + ip, max: maxClientLoginAttempts}}
+}
+
+// challengeLoginLimit returns the limit of the challenges of the user
+func challengeLoginLimit(userID string) []loginLimit {
+	return []loginLimit{{key: "challenge:" + userID, max: maxLoginAttempts}}
+}
+
+// addLoginAttempt will record the attempt made at `at` for each limit, ErrTooManyLoginAttempts is returned once one of
+// them is reached
+func addLoginAttempt(ctx context.Context, attempts LoginAttemptStore, at time.Time, limits []loginLimit) error {
+	for _, limit := range limits {
+		ok, err := attempts.AddLoginAttempt(ctx, limit.key, at, at.Add(-loginAttemptWindow), limit.max)
+		if err != nil {
+			return fmt.Errorf("can't record login attempt: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("can't attempt more than %d logins in %s: %w", limit.max, loginAttemptWindow, ErrTooManyLoginAttempts)
+		}
+	}
+	return nil
+}
+
+// removeLoginAttempt will forget the attempt made at `at` for each limit, only the failed attempts are limited
+func removeLoginAttempt(ctx context.Context, attempts LoginAttemptStore, at time.Time, limits []loginLimit) error {
+	for _, limit := range limits {
+		if err := attempts.RemoveLoginAttempt(ctx, limit.key, at); err != nil {
+			return fmt.Errorf("can't remove login attempt: %w", err)
+		}
+	}
+	return nil
+}
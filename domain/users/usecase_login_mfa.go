@@ -0,0 +1,105 @@
+package users
+
+import (
+	"context"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+// LoginMFAReq contains the second factor to complete a login challenge, either a TOTP code or a recovery code
+type LoginMFAReq struct {
+	ChallengeID  string `json:"challenge_id"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	// Binding should be the binding of the login which created the challenge
+	Binding string `json:"-"`
+}
+
+// LoginMFA define the function which will complete a login challenge with the second factor of the user
+type LoginMFA func(ctx context.Context, req *LoginMFAReq) (*LoginResp, error)
+
+// SetupLoginMFA will return a configured LoginMFA function which can be used later
+func SetupLoginMFA(log logger.Logger, repo Searcher, comparer HashComparer, mfaStore MFAStore, challenges ChallengeStore,
+	attempts LoginAttemptStore, clock Clock) LoginMFA {
+	log = log.With().Str("usecase", "user_login_mfa").Logger()
+	return loginMFA(log, repo, comparer, mfaStore, challenges, attempts, clock)
+}
+
+// loginMFA will complete the challenge, a challenge not completed stays an attempt of its user: the codes guessed for
+// a user are limited by its challenges, see challengeLogin
+func loginMFA(log logger.Logger, repo Searcher, comparer HashComparer, mfaStore MFAStore, challenges ChallengeStore,
+	attempts LoginAttemptStore, clock Clock) LoginMFA {
+	return func(ctx context.Context, req *LoginMFAReq) (*LoginResp, error) {
+		at := clock()
+		limits := clientLoginLimit(ctx)
+		if err := addLoginAttempt(ctx, attempts, at, limits); err != nil {
+			return nil, err
+		}
+		challenge, err := challenges.TakeChallenge(ctx, req.ChallengeID)
+		if err != nil {
+			log.Debug().Err(err).Msg("unknown challenge")
+			return nil, ErrInvalidCredentials
+		}
+		if clock().After(challenge.ExpiresAt) {
+			return nil, ErrInvalidCredentials
+		}
+		if challenge.Binding != req.Binding {
+			log.Debug().Str("user_id", challenge.UserID).Msg("challenge completed for another login")
+			return nil, ErrInvalidCredentials
+		}
+		mfa, err := mfaStore.GetMFA(ctx, challenge.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve mfa: %w", err)
+		}
+		if mfa == nil || !mfa.Confirmed {
+			return nil, ErrInvalidCredentials
+		}
+
+		used := *mfa
+		switch {
+		case req.RecoveryCode != "":
+			if !useRecoveryCode(comparer, &used, req.RecoveryCode) {
+				return nil, ErrInvalidCredentials
+			}
+		default:
+			step, ok := validateTOTP(mfa.Secret, req.Code, clock(), mfa.LastStep)
+			if !ok {
+				return nil, ErrInvalidCredentials
+			}
+			used.LastStep = step
+		}
+		// the code is only accepted if no concurrent login used it (or a later one) in between
+		swapped, err := mfaStore.SwapMFA(ctx, mfa, &used)
+		if err != nil {
+			return nil, fmt.Errorf("can't save mfa: %w", err)
+		}
+		if !swapped {
+			log.Debug().Str("user_id", challenge.UserID).Msg("second factor used concurrently")
+			return nil, ErrInvalidCredentials
+		}
+		if err := removeLoginAttempt(ctx, attempts, at, limits); err != nil {
+			return nil, err
+		}
+		if err := removeLoginAttempt(ctx, attempts, challenge.CreatedAt, challengeLoginLimit(challenge.UserID)); err != nil {
+			return nil, err
+		}
+
+		usr, err := findUser(ctx, repo, repo.Query().ByID(challenge.UserID))
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResp{User: usr}, nil
+	}
+}
+
+// useRecoveryCode will consume the matching recovery code of the user
+func useRecoveryCode(comparer HashComparer, mfa *MFA, code string) bool {
+	for i, hashed := range mfa.RecoveryCodes {
+		if comparer.Compare(hashed, code) == nil {
+			mfa.RecoveryCodes = append(mfa.RecoveryCodes[:i:i], mfa.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
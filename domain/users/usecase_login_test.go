@@ -0,0 +1,234 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/mfastore"
+	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/userstore"
+)
+
+// countingHasher count the passwords compared
+type countingHasher struct {
+	*pwdhasher.Bcrypt
+	compared int32
+}
+
+func (h *countingHasher) Compare(hashed, raw string) error {
+	atomic.AddInt32(&h.compared, 1)
+	return h.Bcrypt.Compare(hashed, raw)
+}
+
+func TestSetupLogin(t *testing.T) {
+	hasher := &countingHasher{Bcrypt: pwdhasher.NewBcryptWithCost(bcrypt.MinCost)}
+	userStore := userstore.NewInMemory()
+	hashed, _ := hasher.Hash("password")
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email:    "test-login-1@test.com",
+		Password: hashed,
+	})
+	login := users.SetupLogin(logger.Logger{}, userStore, hasher, mfastore.NewInMemory(), mfastore.NewInMemory(), mfastore.NewInMemory(), users.Config{}, users.SystemClock)
+
+	t.Run("login without mfa", func(t *testing.T) {
+		res, err := login(context.Background(), &users.LoginReq{Email: "test-login-1@test.com", RawPassword: "password"})
+		require.NoError(t, err)
+		require.Equal(t, usr.ID, res.User.ID)
+		require.Empty(t, res.ChallengeID)
+	})
+	t.Run("login with wrong password", func(t *testing.T) {
+		_, err := login(context.Background(), &users.LoginReq{Email: "test-login-1@test.com", RawPassword: "wrong"})
+		require.True(t, errors.Is(err, users.ErrInvalidCredentials))
+	})
+	t.Run("login with unknown email", func(t *testing.T) {
+		compared := atomic.LoadInt32(&hasher.compared)
+		_, err := login(context.Background(), &users.LoginReq{Email: "unknown@test.com", RawPassword: "password"})
+		require.True(t, errors.Is(err, users.ErrInvalidCredentials))
+		require.Equal(t, compared+1, atomic.LoadInt32(&hasher.compared), "the password is compared as for a known email")
+	})
+}
+
+func TestSetupLogin_Throttle(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	hasher := pwdhasher.NewBcryptWithCost(bcrypt.MinCost)
+	userStore := userstore.NewInMemory()
+	attempts := mfastore.NewInMemory()
+	hashed, _ := hasher.Hash("password")
+	usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-login-throttle-1@test.com", Password: hashed})
+	login := users.SetupLogin(logger.Logger{}, userStore, hasher, attempts, attempts, attempts, users.Config{}, func() time.Time { return now })
+
+	t.Run("successful logins aren't limited", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			_, err := login(context.Background(), &users.LoginReq{Email: usr.Email, RawPassword: "password"})
+			require.NoError(t, err)
+		}
+	})
+	t.Run("email is limited after the failed passwords", func(t *testing.T) {
+		for _, email := range []string{usr.Email, "test-login-throttle-unknown@test.com"} {
+			for i := 0; i < 10; i++ {
+				_, err := login(context.Background(), &users.LoginReq{Email: email, RawPassword: "wrong"})
+				require.True(t, errors.Is(err, users.ErrInvalidCredentials))
+			}
+			_, err := login(context.Background(), &users.LoginReq{Email: email, RawPassword: "password"})
+			require.True(t, errors.Is(err, users.ErrTooManyLoginAttempts), "an unknown email is limited the same way")
+		}
+
+		now = now.Add(16 * time.Minute)
+		res, err := login(context.Background(), &users.LoginReq{Email: usr.Email, RawPassword: "password"})
+		require.NoError(t, err)
+		require.Equal(t, usr.ID, res.User.ID)
+	})
+	t.Run("client is limited across the emails", func(t *testing.T) {
+		ctx := users.WithRequestInfo(context.Background(), users.RequestInfo{SourceIP: "10.0.0.1"})
+		for i := 0; i < 100; i++ {
+			_, err := login(ctx, &users.LoginReq{Email: fmt.Sprintf("test-login-throttle-%d@test.com", i), RawPassword: "wrong"})
+			require.True(t, errors.Is(err, users.ErrInvalidCredentials))
+		}
+		_, err := login(ctx, &users.LoginReq{Email: usr.Email, RawPassword: "password"})
+		require.True(t, errors.Is(err, users.ErrTooManyLoginAttempts))
+
+		_, err = login(context.Background(), &users.LoginReq{Email: usr.Email, RawPassword: "password"})
+		require.NoError(t, err, "another client isn't limited")
+	})
+}
+
+func TestSetupLogin_MFA(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	clock := fixedClock(now)
+	hasher := pwdhasher.NewBcryptWithCost(bcrypt.MinCost)
+	userStore := userstore.NewInMemory()
+	mfaStore := mfastore.NewInMemory()
+	hashed, _ := hasher.Hash("password")
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email:    "test-login-mfa-1@test.com",
+		Password: hashed,
+	})
+	enrolment, err := users.SetupEnrollMFA(logger.Logger{}, userStore, mfaStore, hasher, users.Config{})(
+		context.Background(), &users.EnrollMFAReq{ID: usr.ID})
+	require.NoError(t, err)
+	code, _ := users.TOTPCode(enrolment.Secret, users.TOTPStep(now))
+	_, err = users.SetupConfirmMFA(logger.Logger{}, mfaStore, clock)(context.Background(), &users.ConfirmMFAReq{ID: usr.ID, Code: code})
+	require.NoError(t, err)
+
+	cfg := users.Config{MFAChallengeTTL: time.Minute}
+	login := users.SetupLogin(logger.Logger{}, userStore, hasher, mfaStore, mfaStore, mfaStore, cfg, clock)
+	challenge := func(t *testing.T) string {
+		res, err := login(context.Background(), &users.LoginReq{Email: usr.Email, RawPassword: "password"})
+		require.NoError(t, err)
+		require.Nil(t, res.User)
+		require.NotEmpty(t, res.ChallengeID)
+		return res.ChallengeID
+	}
+
+	t.Run("code already used on confirmation is refused", func(t *testing.T) {
+		loginMFA := users.SetupLoginMFA(logger.Logger{}, userStore, hasher, mfaStore, mfaStore, mfaStore, clock)
+		_, err := loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: challenge(t), Code: code})
+		require.True(t, errors.Is(err, users.ErrInvalidCredentials))
+	})
+	t.Run("next code complete the login", func(t *testing.T) {
+		later := now.Add(30 * time.Second)
+		loginMFA := users.SetupLoginMFA(logger.Logger{}, userStore, hasher, mfaStore, mfaStore, mfaStore, fixedClock(later))
+		nextCode, _ := users.TOTPCode(enrolment.Secret, users.TOTPStep(later))
+		res, err := loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: challenge(t), Code: nextCode})
+		require.NoError(t, err)
+		require.Equal(t, usr.ID, res.User.ID)
+	})
+	t.Run("expired challenge is refused", func(t *testing.T) {
+		later := now.Add(2 * time.Minute)
+		loginMFA := users.SetupLoginMFA(logger.Logger{}, userStore, hasher, mfaStore, mfaStore, mfaStore, fixedClock(later))
+		nextCode, _ := users.TOTPCode(enrolment.Secret, users.TOTPStep(later))
+		_, err := loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: challenge(t), Code: nextCode})
+		require.True(t, errors.Is(err, users.ErrInvalidCredentials))
+	})
+	t.Run("recovery code can be used once", func(t *testing.T) {
+		loginMFA := users.SetupLoginMFA(logger.Logger{}, userStore, hasher, mfaStore, mfaStore, mfaStore, clock)
+		res, err := loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: challenge(t), RecoveryCode: enrolment.RecoveryCodes[0]})
+		require.NoError(t, err)
+		require.Equal(t, usr.ID, res.User.ID)
+
+		_, err = loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: challenge(t), RecoveryCode: enrolment.RecoveryCodes[0]})
+		require.True(t, errors.Is(err, users.ErrInvalidCredentials))
+	})
+	t.Run("challenge is completed for the login which created it", func(t *testing.T) {
+		loginMFA := users.SetupLoginMFA(logger.Logger{}, userStore, hasher, mfaStore, mfaStore, mfaStore, clock)
+		bound := func(t *testing.T, binding string) string {
+			res, err := login(context.Background(), &users.LoginReq{Email: usr.Email, RawPassword: "password", Binding: binding})
+			require.NoError(t, err)
+			require.NotEmpty(t, res.ChallengeID)
+			return res.ChallengeID
+		}
+		_, err := loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: bound(t, "oidc:a"), RecoveryCode: enrolment.RecoveryCodes[1], Binding: "oidc:b"})
+		require.True(t, errors.Is(err, users.ErrInvalidCredentials))
+		_, err = loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: bound(t, "oidc:a"), RecoveryCode: enrolment.RecoveryCodes[1]})
+		require.True(t, errors.Is(err, users.ErrInvalidCredentials), "a bound challenge isn't completed by another login")
+
+		res, err := loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: bound(t, "oidc:a"), RecoveryCode: enrolment.RecoveryCodes[1], Binding: "oidc:a"})
+		require.NoError(t, err)
+		require.Equal(t, usr.ID, res.User.ID)
+	})
+	t.Run("code used by concurrent logins is accepted once", func(t *testing.T) {
+		later := now.Add(time.Minute)
+		stale, _ := mfaStore.GetMFA(context.Background(), usr.ID)
+		loginMFA := users.SetupLoginMFA(logger.Logger{}, userStore, hasher, &staleMFAStore{InMemory: mfaStore, mfa: stale}, mfaStore, mfaStore, fixedClock(later))
+		nextCode, _ := users.TOTPCode(enrolment.Secret, users.TOTPStep(later))
+		_, err := loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: challenge(t), Code: nextCode})
+		require.NoError(t, err)
+
+		_, err = loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: challenge(t), Code: nextCode})
+		require.True(t, errors.Is(err, users.ErrInvalidCredentials))
+	})
+}
+
+func TestSetupLogin_MFAThrottle(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	clock := fixedClock(now)
+	hasher := pwdhasher.NewBcryptWithCost(bcrypt.MinCost)
+	userStore := userstore.NewInMemory()
+	mfaStore := mfastore.NewInMemory()
+	hashed, _ := hasher.Hash("password")
+	usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-login-mfa-throttle-1@test.com", Password: hashed})
+	enrolment, err := users.SetupEnrollMFA(logger.Logger{}, userStore, mfaStore, hasher, users.Config{})(
+		context.Background(), &users.EnrollMFAReq{ID: usr.ID})
+	require.NoError(t, err)
+	code, _ := users.TOTPCode(enrolment.Secret, users.TOTPStep(now))
+	_, err = users.SetupConfirmMFA(logger.Logger{}, mfaStore, clock)(context.Background(), &users.ConfirmMFAReq{ID: usr.ID, Code: code})
+	require.NoError(t, err)
+
+	login := users.SetupLogin(logger.Logger{}, userStore, hasher, mfaStore, mfaStore, mfaStore, users.Config{MFAChallengeTTL: time.Minute}, clock)
+	loginMFA := users.SetupLoginMFA(logger.Logger{}, userStore, hasher, mfaStore, mfaStore, mfaStore, clock)
+
+	var challenges []string
+	for i := 0; i < 10; i++ {
+		res, err := login(context.Background(), &users.LoginReq{Email: usr.Email, RawPassword: "password"})
+		require.NoError(t, err)
+		challenges = append(challenges, res.ChallengeID)
+	}
+	_, err = login(context.Background(), &users.LoginReq{Email: usr.Email, RawPassword: "password"})
+	require.True(t, errors.Is(err, users.ErrTooManyLoginAttempts), "the codes guessed are limited by the challenges")
+
+	// a completed challenge isn't counted anymore
+	_, err = loginMFA(context.Background(), &users.LoginMFAReq{ChallengeID: challenges[0], RecoveryCode: enrolment.RecoveryCodes[0]})
+	require.NoError(t, err)
+	_, err = login(context.Background(), &users.LoginReq{Email: usr.Email, RawPassword: "password"})
+	require.NoError(t, err)
+}
+
+// staleMFAStore will always read the same mfa, as a login racing with another one would
+type staleMFAStore struct {
+	*mfastore.InMemory
+	mfa *users.MFA
+}
+
+func (s *staleMFAStore) GetMFA(ctx context.Context, userID string) (*users.MFA, error) {
+	mfa := *s.mfa
+	return &mfa, nil
+}
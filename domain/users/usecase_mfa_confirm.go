@@ -0,0 +1,56 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+// ErrInvalidCode is returned when the provided second factor code isn't valid
+var ErrInvalidCode = errors.New("invalid code")
+
+// ConfirmMFAReq contains the required parameters to confirm the second factor enrolment
+type ConfirmMFAReq struct {
+	ID   string `json:"id"`
+	Code string `json:"code"`
+}
+
+// ConfirmMFAResp is returned on successful confirmation, the second factor is now required on login
+type ConfirmMFAResp struct {
+	Enabled bool `json:"enabled"`
+}
+
+// ConfirmMFA define the function which will enable the second factor once the user proved to own the secret
+type ConfirmMFA func(ctx context.Context, req *ConfirmMFAReq) (*ConfirmMFAResp, error)
+
+// SetupConfirmMFA will return a configured ConfirmMFA function which can be used later
+func SetupConfirmMFA(log logger.Logger, store MFAStore, clock Clock) ConfirmMFA {
+	return confirmMFA(store, clock)
+}
+
+func confirmMFA(store MFAStore, clock Clock) ConfirmMFA {
+	return func(ctx context.Context, req *ConfirmMFAReq) (*ConfirmMFAResp, error) {
+		mfa, err := store.GetMFA(ctx, req.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve mfa: %w", err)
+		}
+		if mfa == nil {
+			return nil, ErrMFANotEnrolled
+		}
+		if mfa.Confirmed {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		step, ok := validateTOTP(mfa.Secret, req.Code, clock(), mfa.LastStep)
+		if !ok {
+			return nil, ErrInvalidCode
+		}
+		mfa.Confirmed = true
+		mfa.LastStep = step
+		if err := store.SaveMFA(ctx, mfa); err != nil {
+			return nil, fmt.Errorf("can't save mfa: %w", err)
+		}
+		return &ConfirmMFAResp{Enabled: true}, nil
+	}
+}
@@ -0,0 +1,87 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-users-example/infra/logger"
+)
+
+// ErrMFAAlreadyEnabled is returned when enrolling a user which already confirmed its second factor
+var ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+
+// ErrMFANotEnrolled is returned when confirming the second factor of a user which didn't enroll
+var ErrMFANotEnrolled = errors.New("mfa not enrolled")
+
+// EnrollMFAReq contains the required parameters to start the second factor enrolment of a user
+type EnrollMFAReq struct {
+	ID string `json:"id"`
+}
+
+// EnrollMFAResp contains the secret to register in the authenticator app.
+// Note: the recovery codes are only returned here, they are stored hashed
+type EnrollMFAResp struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAStore will save and retrieve the second factor state of the users
+type MFAStore interface {
+	// GetMFA will return a nil MFA without error if the user never enrolled
+	GetMFA(ctx context.Context, userID string) (*MFA, error)
+	SaveMFA(ctx context.Context, mfa *MFA) error
+	// SwapMFA will save the mfa only if the stored one is still old, false is returned without error otherwise
+	SwapMFA(ctx context.Context, old, mfa *MFA) (bool, error)
+}
+
+// EnrollMFA define the function which will start the second factor enrolment of a user
+type EnrollMFA func(ctx context.Context, req *EnrollMFAReq) (*EnrollMFAResp, error)
+
+// SetupEnrollMFA will return a configured EnrollMFA function which can be used later
+func SetupEnrollMFA(log logger.Logger, repo Searcher, store MFAStore, hasher Hasher, c Config) EnrollMFA {
+	return enrollMFA(repo, store, hasher, c.MFAIssuer)
+}
+
+func enrollMFA(repo Searcher, store MFAStore, hasher Hasher, issuer string) EnrollMFA {
+	return func(ctx context.Context, req *EnrollMFAReq) (*EnrollMFAResp, error) {
+		usr, err := findUser(ctx, repo, repo.Query().ByID(req.ID))
+		if err != nil {
+			return nil, err
+		}
+		current, err := store.GetMFA(ctx, usr.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve mfa: %w", err)
+		}
+		if current != nil && current.Confirmed {
+			return nil, ErrMFAAlreadyEnabled
+		}
+
+		secret, err := newTOTPSecret()
+		if err != nil {
+			return nil, err
+		}
+		codes, err := newRecoveryCodes()
+		if err != nil {
+			return nil, err
+		}
+		hashedCodes := make([]string, 0, len(codes))
+		for _, code := range codes {
+			hashed, err := hasher.Hash(code)
+			if err != nil {
+				return nil, fmt.Errorf("can't hash recovery code: %w", err)
+			}
+			hashedCodes = append(hashedCodes, hashed)
+		}
+
+		if err := store.SaveMFA(ctx, &MFA{UserID: usr.ID, Secret: secret, RecoveryCodes: hashedCodes}); err != nil {
+			return nil, fmt.Errorf("can't save mfa: %w", err)
+		}
+		return &EnrollMFAResp{
+			Secret:        secret,
+			URI:           totpURI(issuer, usr.Email, secret),
+			RecoveryCodes: codes,
+		}, nil
+	}
+}
@@ -0,0 +1,61 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/mfastore"
+	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/userstore"
+)
+
+func fixedClock(t time.Time) users.Clock {
+	return func() time.Time { return t }
+}
+
+func TestSetupEnrollMFA_OK(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email: "test-mfa-enroll-1@test.com",
+	})
+	mfaStore := mfastore.NewInMemory()
+	enroll := users.SetupEnrollMFA(logger.Logger{}, userStore, mfaStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), users.Config{MFAIssuer: "test"})
+	res, err := enroll(context.Background(), &users.EnrollMFAReq{ID: usr.ID})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Secret)
+	require.True(t, strings.HasPrefix(res.URI, "otpauth://totp/test:test-mfa-enroll-1@test.com?"))
+	require.Len(t, res.RecoveryCodes, 10)
+
+	mfa, _ := mfaStore.GetMFA(context.Background(), usr.ID)
+	require.False(t, mfa.Confirmed)
+	require.NotContains(t, mfa.RecoveryCodes, res.RecoveryCodes[0])
+}
+
+func TestSetupConfirmMFA(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	mfaStore := mfastore.NewInMemory()
+	_ = mfaStore.SaveMFA(context.Background(), &users.MFA{UserID: "test-mfa-confirm-1", Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"})
+	confirm := users.SetupConfirmMFA(logger.Logger{}, mfaStore, fixedClock(now))
+
+	_, err := confirm(context.Background(), &users.ConfirmMFAReq{ID: "test-mfa-confirm-1", Code: "000000"})
+	require.True(t, errors.Is(err, users.ErrInvalidCode))
+
+	code, _ := users.TOTPCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", users.TOTPStep(now))
+	res, err := confirm(context.Background(), &users.ConfirmMFAReq{ID: "test-mfa-confirm-1", Code: code})
+	require.NoError(t, err)
+	require.True(t, res.Enabled)
+
+	_, err = confirm(context.Background(), &users.ConfirmMFAReq{ID: "test-mfa-confirm-1", Code: code})
+	require.True(t, errors.Is(err, users.ErrMFAAlreadyEnabled))
+
+	_, err = confirm(context.Background(), &users.ConfirmMFAReq{ID: "unknown", Code: code})
+	require.True(t, errors.Is(err, users.ErrMFANotEnrolled))
+}
@@ -0,0 +1,103 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go-users-example/infra/logger"
+)
+
+// Reasons for which a nickname isn't available
+const (
+	NickNameInvalid  = "invalid"
+	NickNameReserved = "reserved"
+	NickNameTaken    = "taken"
+)
+
+// maxNickNameSuggestions is the number of available nicknames suggested when the nickname is taken
+const maxNickNameSuggestions = 3
+
+// NickNameAvailabilityReq contains the nickname to check
+type NickNameAvailabilityReq struct {
+	NickName string `json:"nick_name"`
+}
+
+// NickNameAvailabilityResp tells if the nickname can be used by a new user, with close nicknames which can if it's taken
+type NickNameAvailabilityResp struct {
+	NickName  string `json:"nick_name"`
+	Available bool   `json:"available"`
+	// Reason is why the nickname can't be used: invalid, reserved or taken
+	Reason string `json:"reason,omitempty"`
+	// Violation is set when the nickname isn't valid
+	Violation   *FieldViolation `json:"violation,omitempty"`
+	Suggestions []string        `json:"suggestions,omitempty"`
+}
+
+// NickNameChecker will tell if a nickname key is used by a user, the deleted users included
+type NickNameChecker interface {
+	NickNameUsed(ctx context.Context, key string) (bool, error)
+}
+
+// CheckNickNameAvailability define the function which will tell if a nickname can be used
+// Note: the availability isn't a reservation, the nickname can still be taken before the user creation
+type CheckNickNameAvailability func(ctx context.Context, req *NickNameAvailabilityReq) (*NickNameAvailabilityResp, error)
+
+// SetupCheckNickNameAvailability will return a configured CheckNickNameAvailability function which can be used later
+func SetupCheckNickNameAvailability(log logger.Logger, repo NickNameChecker, validator *Validator) CheckNickNameAvailability {
+	return checkNickNameAvailability(repo, validator)
+}
+
+func checkNickNameAvailability(repo NickNameChecker, validator *Validator) CheckNickNameAvailability {
+	return func(ctx context.Context, req *NickNameAvailabilityReq) (*NickNameAvailabilityResp, error) {
+		nickName := normalizeName(req.NickName)
+		res := &NickNameAvailabilityResp{NickName: nickName}
+		if v := validator.validateField("nick_name", nickName); v != nil {
+			res.Reason, res.Violation = NickNameInvalid, v
+			if v.Code == CodeReserved {
+				res.Reason = NickNameReserved
+			}
+			return res, nil
+		}
+
+		used, err := repo.NickNameUsed(ctx, nickNameKey(nickName))
+		if err != nil {
+			return nil, fmt.Errorf("can't check nickname: %w", err)
+		}
+		if !used {
+			res.Available = true
+			return res, nil
+		}
+
+		res.Reason = NickNameTaken
+		if res.Suggestions, err = suggestNickNames(ctx, repo, validator, nickName); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+}
+
+// suggestNickNames will look for available nicknames made of the nickname followed by a number
+func suggestNickNames(ctx context.Context, repo NickNameChecker, validator *Validator, nickName string) ([]string, error) {
+	var suggestions []string
+	for n := 1; n < 100 && len(suggestions) < maxNickNameSuggestions; n++ {
+		suffix := strconv.Itoa(n)
+		base := []rune(nickName)
+		max := validator.maxLen("nick_name")
+		for max > 0 && len(base) > 0 && characterLen(string(base))+len(suffix) > max {
+			base = base[:len(base)-1]
+		}
+		candidate := string(base) + suffix
+		if validator.validateField("nick_name", candidate) != nil {
+			continue
+		}
+		used, err := repo.NickNameUsed(ctx, nickNameKey(candidate))
+		if err != nil {
+			return nil, fmt.Errorf("can't check suggested nickname: %w", err)
+		}
+		if !used {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	return suggestions, nil
+}
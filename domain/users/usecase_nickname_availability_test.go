@@ -0,0 +1,47 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/infra/userstore"
+)
+
+func TestSetupCheckNickNameAvailability(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	check := users.SetupCheckNickNameAvailability(logger.Logger{}, userStore, newValidator(t, users.Config{}))
+	create := users.SetupCreate(logger.Logger{}, usernotifier.NewInMemory(), userStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), newValidator(t, users.Config{}))
+	for _, nickName := range []string{"bobby", "BOBBY1", "bobby3"} {
+		_, err := create(context.Background(), &users.CreateReq{FirstName: "test", LastName: "test", NickName: nickName, Email: nickName + "@test.com"})
+		require.NoError(t, err)
+	}
+
+	res, err := check(context.Background(), &users.NickNameAvailabilityReq{NickName: "alice"})
+	require.NoError(t, err)
+	require.True(t, res.Available)
+	require.Empty(t, res.Suggestions)
+
+	res, err = check(context.Background(), &users.NickNameAvailabilityReq{NickName: "bobby"})
+	require.NoError(t, err)
+	require.False(t, res.Available)
+	require.Equal(t, users.NickNameTaken, res.Reason)
+	require.Equal(t, []string{"bobby2", "bobby4", "bobby5"}, res.Suggestions)
+
+	res, err = check(context.Background(), &users.NickNameAvailabilityReq{NickName: "support"})
+	require.NoError(t, err)
+	require.False(t, res.Available)
+	require.Equal(t, users.NickNameReserved, res.Reason)
+
+	res, err = check(context.Background(), &users.NickNameAvailabilityReq{NickName: "bob😀"})
+	require.NoError(t, err)
+	require.False(t, res.Available)
+	require.Equal(t, users.NickNameInvalid, res.Reason)
+	require.Equal(t, users.CodeInvalidCharacter, res.Violation.Code)
+}
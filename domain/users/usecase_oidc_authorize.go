@@ -0,0 +1,139 @@
+package users
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"go-users-example/infra/logger"
+)
+
+// AuthorizationReq contains the parameters of an authorization request of a client, only the authorization code flow
+// with PKCE is supported (OpenID Connect Core section 3.1.2.1, RFC 7636)
+type AuthorizationReq struct {
+	ClientID            string `json:"client_id,omitempty"`
+	RedirectURI         string `json:"redirect_uri,omitempty"`
+	ResponseType        string `json:"response_type,omitempty"`
+	Scope               string `json:"scope,omitempty"`
+	State               string `json:"state,omitempty"`
+	Nonce               string `json:"nonce,omitempty"`
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+}
+
+// Binding returns the binding of the logins made for this authorization request, see LoginReq
+func (r AuthorizationReq) Binding() string {
+	raw, _ := json.Marshal(r)
+	sum := sha256.Sum256(raw)
+	return "oidc:" + hex.EncodeToString(sum[:])
+}
+
+// PrepareAuthorizationResp contains the client and the scopes to present to the user before the login
+type PrepareAuthorizationResp struct {
+	Client *OIDCClient
+	Scopes []string
+}
+
+// AuthorizeReq contains an authorization request consented by the user who logged in
+type AuthorizeReq struct {
+	AuthorizationReq
+	UserID string
+}
+
+// AuthorizeResp contains the code to send to the redirect uri of the client
+type AuthorizeResp struct {
+	Code string
+}
+
+// AuthorizationCodeStore will keep the codes until the clients exchange them
+type AuthorizationCodeStore interface {
+	AddCode(ctx context.Context, code *AuthorizationCode) error
+	// TakeCode will return and remove the code so it can only be used once
+	TakeCode(ctx context.Context, hash string) (*AuthorizationCode, error)
+}
+
+// PrepareAuthorization define the function which will check the authorization request of a client before the user
+// logs in. ErrInvalidClient and ErrInvalidRedirectURI can't be sent to the redirect uri, the other errors can
+type PrepareAuthorization func(ctx context.Context, req *AuthorizationReq) (*PrepareAuthorizationResp, error)
+
+// Authorize define the function which will give the code of an authorization request to the client
+type Authorize func(ctx context.Context, req *AuthorizeReq) (*AuthorizeResp, error)
+
+// SetupPrepareAuthorization will return a configured PrepareAuthorization function which can be used later
+func SetupPrepareAuthorization(log logger.Logger, clients OIDCClientStore) PrepareAuthorization {
+	return prepareAuthorization(clients)
+}
+
+// SetupAuthorize will return a configured Authorize function which can be used later
+func SetupAuthorize(log logger.Logger, clients OIDCClientStore, repo Searcher, codes AuthorizationCodeStore, c Config, clock Clock) Authorize {
+	return authorize(prepareAuthorization(clients), repo, codes, c, clock)
+}
+
+// codeChallengePattern match the S256 challenges, the base64url encoding of a sha256 hash
+var codeChallengePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+
+func prepareAuthorization(clients OIDCClientStore) PrepareAuthorization {
+	return func(ctx context.Context, req *AuthorizationReq) (*PrepareAuthorizationResp, error) {
+		client, err := clients.GetClient(ctx, req.ClientID)
+		if err != nil {
+			return nil, fmt.Errorf("can't get client: %w", err)
+		}
+		if client == nil {
+			return nil, fmt.Errorf("client %q: %w", req.ClientID, ErrInvalidClient)
+		}
+		registered := false
+		for _, uri := range client.RedirectURIs {
+			registered = registered || uri == req.RedirectURI
+		}
+		if !registered {
+			return nil, fmt.Errorf("redirect uri %q isn't registered: %w", req.RedirectURI, ErrInvalidRedirectURI)
+		}
+
+		if req.ResponseType != "code" {
+			return nil, fmt.Errorf("only the code response type is supported: %w", ErrInvalidOIDCRequest)
+		}
+		scopes, err := parseScopes(req.Scope)
+		if err != nil {
+			return nil, err
+		}
+		if req.CodeChallengeMethod != codeChallengeS256 || !codeChallengePattern.MatchString(req.CodeChallenge) {
+			return nil, fmt.Errorf("a %s code challenge is required: %w", codeChallengeS256, ErrInvalidOIDCRequest)
+		}
+		return &PrepareAuthorizationResp{Client: client, Scopes: scopes}, nil
+	}
+}
+
+func authorize(prepare PrepareAuthorization, repo Searcher, codes AuthorizationCodeStore, c Config, clock Clock) Authorize {
+	return func(ctx context.Context, req *AuthorizeReq) (*AuthorizeResp, error) {
+		prepared, err := prepare(ctx, &req.AuthorizationReq)
+		if err != nil {
+			return nil, err
+		}
+		usr, err := findUser(ctx, repo, repo.Query().ByID(req.UserID))
+		if err != nil {
+			return nil, err
+		}
+		code, err := newOIDCSecret(authCodePfx)
+		if err != nil {
+			return nil, err
+		}
+		now := clock()
+		if err := codes.AddCode(ctx, &AuthorizationCode{
+			Hash:          hashOIDCSecret(code),
+			ClientID:      prepared.Client.ID,
+			UserID:        usr.ID,
+			RedirectURI:   req.RedirectURI,
+			Scopes:        prepared.Scopes,
+			Nonce:         req.Nonce,
+			CodeChallenge: req.CodeChallenge,
+			AuthTime:      now,
+			ExpiresAt:     now.Add(c.OIDCCodeTTL),
+		}); err != nil {
+			return nil, fmt.Errorf("can't save code: %w", err)
+		}
+		return &AuthorizeResp{Code: code}, nil
+	}
+}
@@ -0,0 +1,84 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/oidcstore"
+	"go-users-example/infra/userstore"
+)
+
+// testCodeVerifier is the PKCE verifier of testCodeChallenge
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r-wW1gFWFkEjXk"
+	testCodeChallenge = "q1UFFuNXXfijawQOgEHqJA4VG8kynKA1pUJYmvUVahg"
+)
+
+func newAuthorizationReq(clientID string) users.AuthorizationReq {
+	return users.AuthorizationReq{
+		ClientID:            clientID,
+		RedirectURI:         "https://app.test/callback",
+		ResponseType:        "code",
+		Scope:               "openid email unknown",
+		State:               "state",
+		Nonce:               "nonce",
+		CodeChallenge:       testCodeChallenge,
+		CodeChallengeMethod: "S256",
+	}
+}
+
+func TestSetupPrepareAuthorization(t *testing.T) {
+	store := oidcstore.NewInMemory()
+	client, _ := store.AddClient(context.Background(), &users.OIDCClient{Name: "app", RedirectURIs: []string{"https://app.test/callback"}})
+	prepare := users.SetupPrepareAuthorization(logger.Logger{}, store)
+
+	req := newAuthorizationReq(client.ID)
+	res, err := prepare(context.Background(), &req)
+	require.NoError(t, err)
+	require.Equal(t, "app", res.Client.Name)
+	// the unknown scopes are ignored
+	require.Equal(t, []string{"openid", "email"}, res.Scopes)
+
+	for name, tc := range map[string]struct {
+		change func(req *users.AuthorizationReq)
+		err    error
+	}{
+		"unknown client":       {change: func(req *users.AuthorizationReq) { req.ClientID = "unknown" }, err: users.ErrInvalidClient},
+		"unknown redirect uri": {change: func(req *users.AuthorizationReq) { req.RedirectURI = "https://evil.test/callback" }, err: users.ErrInvalidRedirectURI},
+		"no redirect uri":      {change: func(req *users.AuthorizationReq) { req.RedirectURI = "" }, err: users.ErrInvalidRedirectURI},
+		"implicit flow":        {change: func(req *users.AuthorizationReq) { req.ResponseType = "id_token" }, err: users.ErrInvalidOIDCRequest},
+		"no openid scope":      {change: func(req *users.AuthorizationReq) { req.Scope = "email" }, err: users.ErrInvalidScope},
+		"no code challenge":    {change: func(req *users.AuthorizationReq) { req.CodeChallenge = "" }, err: users.ErrInvalidOIDCRequest},
+		"plain code challenge": {change: func(req *users.AuthorizationReq) { req.CodeChallengeMethod = "plain" }, err: users.ErrInvalidOIDCRequest},
+		"short code challenge": {change: func(req *users.AuthorizationReq) { req.CodeChallenge = "short" }, err: users.ErrInvalidOIDCRequest},
+	} {
+		req := newAuthorizationReq(client.ID)
+		tc.change(&req)
+		_, err := prepare(context.Background(), &req)
+		require.True(t, errors.Is(err, tc.err), "%s: %v", name, err)
+	}
+}
+
+func TestSetupAuthorize(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-oidc-authorize-1"})
+	store := oidcstore.NewInMemory()
+	client, _ := store.AddClient(context.Background(), &users.OIDCClient{Name: "app", RedirectURIs: []string{"https://app.test/callback"}})
+	authorize := users.SetupAuthorize(logger.Logger{}, store, userStore, store, users.Config{}, users.SystemClock)
+
+	res, err := authorize(context.Background(), &users.AuthorizeReq{AuthorizationReq: newAuthorizationReq(client.ID), UserID: usr.ID})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(res.Code, "uac_"))
+
+	_, err = authorize(context.Background(), &users.AuthorizeReq{AuthorizationReq: newAuthorizationReq(client.ID), UserID: "unknown"})
+	require.True(t, errors.Is(err, users.ErrUserNotFound))
+
+	_, err = authorize(context.Background(), &users.AuthorizeReq{AuthorizationReq: newAuthorizationReq("unknown"), UserID: usr.ID})
+	require.True(t, errors.Is(err, users.ErrInvalidClient))
+}
@@ -0,0 +1,113 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"go-users-example/infra/logger"
+)
+
+// ErrInvalidOIDCClient is returned if the client to register isn't valid
+var ErrInvalidOIDCClient = errors.New("provided oidc client isn't valid")
+
+// RegisterOIDCClientReq contains the required parameters to register a new OpenID Connect client
+type RegisterOIDCClientReq struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Confidential bool     `json:"confidential"`
+}
+
+// RegisterOIDCClientResp contains the registered client.
+// Note: Secret is only returned here for the confidential clients, it is stored hashed and can't be retrieved later
+type RegisterOIDCClientResp struct {
+	Client *OIDCClient `json:"client"`
+	Secret string      `json:"secret,omitempty"`
+}
+
+// OIDCClientStore will keep the registered clients
+type OIDCClientStore interface {
+	// AddClient will save a new client and generate an id for it
+	AddClient(ctx context.Context, client *OIDCClient) (*OIDCClient, error)
+	// GetClient will return a nil client without error if no client match the id
+	GetClient(ctx context.Context, id string) (*OIDCClient, error)
+}
+
+// RegisterOIDCClient define the function which will register a client of the OpenID Connect provider
+type RegisterOIDCClient func(ctx context.Context, req *RegisterOIDCClientReq) (*RegisterOIDCClientResp, error)
+
+// SetupRegisterOIDCClient will return a configured RegisterOIDCClient function which can be used later
+func SetupRegisterOIDCClient(log logger.Logger, store OIDCClientStore, clock Clock) RegisterOIDCClient {
+	return validateRegisterOIDCClient(registerOIDCClient(store, clock))
+}
+
+func registerOIDCClient(store OIDCClientStore, clock Clock) RegisterOIDCClient {
+	return func(ctx context.Context, req *RegisterOIDCClientReq) (*RegisterOIDCClientResp, error) {
+		client := &OIDCClient{
+			Name:         req.Name,
+			RedirectURIs: req.RedirectURIs,
+			Confidential: req.Confidential,
+			CreatedAt:    clock(),
+		}
+		var secret string
+		if req.Confidential {
+			var err error
+			if secret, err = newOIDCSecret(clientSecretPfx); err != nil {
+				return nil, err
+			}
+			client.SecretHash = hashOIDCSecret(secret)
+		}
+		client, err := store.AddClient(ctx, client)
+		if err != nil {
+			return nil, fmt.Errorf("can't save client: %w", err)
+		}
+		return &RegisterOIDCClientResp{Client: client, Secret: secret}, nil
+	}
+}
+
+func validateRegisterOIDCClient(registerFunc RegisterOIDCClient) RegisterOIDCClient {
+	return func(ctx context.Context, req *RegisterOIDCClientReq) (*RegisterOIDCClientResp, error) {
+		if strings.TrimSpace(req.Name) == "" {
+			return nil, fmt.Errorf("can't validate client: name is required: %w", ErrInvalidOIDCClient)
+		}
+		if len(req.RedirectURIs) == 0 {
+			return nil, fmt.Errorf("can't validate client: at least one redirect uri is required: %w", ErrInvalidOIDCClient)
+		}
+		for _, uri := range req.RedirectURIs {
+			if err := validateRedirectURI(uri); err != nil {
+				return nil, fmt.Errorf("can't validate client: redirect uri %q %s: %w", uri, err, ErrInvalidOIDCClient)
+			}
+		}
+		return registerFunc(ctx, req)
+	}
+}
+
+// validateRedirectURI will accept the absolute uris without fragment: https, http on the loopback interface and the
+// private-use schemes of the native apps (com.example.app:/callback)
+func validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() {
+		return errors.New("isn't an absolute uri")
+	}
+	if u.Fragment != "" || strings.Contains(uri, "#") {
+		return errors.New("shouldn't have a fragment")
+	}
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return errors.New("should have a host")
+		}
+	case "http":
+		if ip := net.ParseIP(u.Hostname()); u.Hostname() != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return errors.New("should use https, http is only allowed on the loopback interface")
+		}
+	default:
+		if !strings.Contains(u.Scheme, ".") {
+			return errors.New("should use https or a private-use scheme in reverse domain order")
+		}
+	}
+	return nil
+}
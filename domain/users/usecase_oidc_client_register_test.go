@@ -0,0 +1,51 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/oidcstore"
+)
+
+func TestSetupRegisterOIDCClient_OK(t *testing.T) {
+	store := oidcstore.NewInMemory()
+	register := users.SetupRegisterOIDCClient(logger.Logger{}, store, users.SystemClock)
+
+	res, err := register(context.Background(), &users.RegisterOIDCClientReq{
+		Name:         "app",
+		RedirectURIs: []string{"https://app.test/callback", "http://127.0.0.1:8000/callback", "com.test.app:/callback"},
+		Confidential: true,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Client.ID)
+	require.True(t, strings.HasPrefix(res.Secret, "ucs_"))
+	require.NotContains(t, res.Client.SecretHash, res.Secret)
+
+	res, err = register(context.Background(), &users.RegisterOIDCClientReq{Name: "spa", RedirectURIs: []string{"http://localhost:3000/"}})
+	require.NoError(t, err)
+	require.Empty(t, res.Secret)
+	client, _ := store.GetClient(context.Background(), res.Client.ID)
+	require.Equal(t, "spa", client.Name)
+	require.False(t, client.Confidential)
+}
+
+func TestSetupRegisterOIDCClient_Invalid(t *testing.T) {
+	register := users.SetupRegisterOIDCClient(logger.Logger{}, oidcstore.NewInMemory(), users.SystemClock)
+	for name, req := range map[string]*users.RegisterOIDCClientReq{
+		"no name":          {RedirectURIs: []string{"https://app.test/callback"}},
+		"no redirect uri":  {Name: "app"},
+		"relative uri":     {Name: "app", RedirectURIs: []string{"/callback"}},
+		"fragment":         {Name: "app", RedirectURIs: []string{"https://app.test/callback#token"}},
+		"http not on host": {Name: "app", RedirectURIs: []string{"http://app.test/callback"}},
+		"unknown scheme":   {Name: "app", RedirectURIs: []string{"javascript:alert(1)"}},
+	} {
+		_, err := register(context.Background(), req)
+		require.True(t, errors.Is(err, users.ErrInvalidOIDCClient), name)
+	}
+}
@@ -0,0 +1,42 @@
+package users
+
+import (
+	"context"
+
+	"go-users-example/infra/logger"
+)
+
+// DescribeOIDCProviderResp contains what the clients should know about the OpenID Connect provider
+type DescribeOIDCProviderResp struct {
+	Issuer string
+	// SigningAlg is the algorithm of the signatures of the tokens
+	SigningAlg string
+	Scopes     []string
+	Claims     []string
+	// Keys are the public keys verifying the signatures of the tokens
+	Keys []JWK
+}
+
+// DescribeOIDCProvider define the function which will describe the OpenID Connect provider
+type DescribeOIDCProvider func(ctx context.Context) (*DescribeOIDCProviderResp, error)
+
+// SetupDescribeOIDCProvider will return a configured DescribeOIDCProvider function which can be used later
+func SetupDescribeOIDCProvider(log logger.Logger, signer TokenSigner, c Config) DescribeOIDCProvider {
+	return describeOIDCProvider(signer, c)
+}
+
+func describeOIDCProvider(signer TokenSigner, c Config) DescribeOIDCProvider {
+	return func(ctx context.Context) (*DescribeOIDCProviderResp, error) {
+		res := &DescribeOIDCProviderResp{
+			Issuer:     c.OIDCIssuer,
+			SigningAlg: oidcSigningAlg,
+			Claims:     []string{"iss", "aud", "exp", "iat", "auth_time", "nonce"},
+			Keys:       []JWK{newJWK(signer.PublicKey())},
+		}
+		for _, s := range oidcScopes {
+			res.Scopes = append(res.Scopes, s.name)
+			res.Claims = append(res.Claims, s.claims...)
+		}
+		return res, nil
+	}
+}
@@ -0,0 +1,28 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/signer"
+)
+
+func TestSetupDescribeOIDCProvider(t *testing.T) {
+	// the key of RFC 8037 appendix A
+	key, _ := signer.NewEd25519(signer.Config{Key: "nWGxne/9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A="})
+	res, err := users.SetupDescribeOIDCProvider(logger.Logger{}, key, testOIDCConfig)(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "https://id.test", res.Issuer)
+	require.Equal(t, "EdDSA", res.SigningAlg)
+	require.Equal(t, []string{"openid", "profile", "email", "phone", "address"}, res.Scopes)
+	require.Contains(t, res.Claims, "preferred_username")
+
+	require.Len(t, res.Keys, 1)
+	require.Equal(t, "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo", res.Keys[0].X)
+	// the key id is the RFC 7638 thumbprint of the key
+	require.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", res.Keys[0].KeyID)
+}
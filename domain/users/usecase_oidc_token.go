@@ -0,0 +1,251 @@
+package users
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// the grants supported by the token endpoint
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// TokenReq contains the parameters of a token request of a client (RFC 6749 sections 4.1.3 and 6), the client
+// authenticate with its secret unless it is public
+type TokenReq struct {
+	GrantType    string `json:"grant_type,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RedirectURI  string `json:"redirect_uri,omitempty"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// TokenResp contains the tokens issued to the client, a new refresh token replace the one exchanged
+type TokenResp struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	Scope        string `json:"scope"`
+}
+
+// RefreshTokenStore will keep the refresh tokens issued to the clients
+type RefreshTokenStore interface {
+	AddRefreshToken(ctx context.Context, token *RefreshToken) error
+	// RotateRefreshToken will mark the token as rotated at the time and return it as it was before, so only one
+	// caller can rotate it
+	RotateRefreshToken(ctx context.Context, hash string, at time.Time) (*RefreshToken, error)
+	// RevokeRefreshTokenFamily will revoke all the tokens of the family
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error
+}
+
+// ExchangeToken define the function which will issue the tokens of a client for a code or a refresh token
+type ExchangeToken func(ctx context.Context, req *TokenReq) (*TokenResp, error)
+
+// SetupExchangeToken will return a configured ExchangeToken function which can be used later
+func SetupExchangeToken(log logger.Logger, clients OIDCClientStore, repo Searcher, codes AuthorizationCodeStore, tokens RefreshTokenStore, signer TokenSigner, c Config, clock Clock) ExchangeToken {
+	log = log.With().Str("usecase", "oidc_token_exchange").Logger()
+	issue := issueTokens(tokens, signer, c, clock)
+	return authenticateClient(clients, map[string]exchangeGrant{
+		GrantAuthorizationCode: exchangeCode(log, repo, codes, clock, issue),
+		GrantRefreshToken:      exchangeRefreshToken(log, repo, tokens, clock, issue),
+	})
+}
+
+// exchangeGrant will issue the tokens of an authenticated client for its grant
+type exchangeGrant func(ctx context.Context, client *OIDCClient, req *TokenReq) (*TokenResp, error)
+
+// authenticateClient will check the secret of the confidential clients before exchanging their grant
+func authenticateClient(clients OIDCClientStore, grants map[string]exchangeGrant) ExchangeToken {
+	return func(ctx context.Context, req *TokenReq) (*TokenResp, error) {
+		exchange, ok := grants[req.GrantType]
+		if !ok {
+			return nil, fmt.Errorf("grant type %q: %w", req.GrantType, ErrUnsupportedGrantType)
+		}
+		client, err := clients.GetClient(ctx, req.ClientID)
+		if err != nil {
+			return nil, fmt.Errorf("can't get client: %w", err)
+		}
+		if client == nil {
+			return nil, fmt.Errorf("client %q: %w", req.ClientID, ErrInvalidClient)
+		}
+		if client.Confidential && subtle.ConstantTimeCompare([]byte(hashOIDCSecret(req.ClientSecret)), []byte(client.SecretHash)) != 1 {
+			return nil, fmt.Errorf("client %q secret mismatch: %w", client.ID, ErrInvalidClient)
+		}
+		if !client.Confidential && req.ClientSecret != "" {
+			return nil, fmt.Errorf("public client %q has no secret: %w", client.ID, ErrInvalidClient)
+		}
+		return exchange(ctx, client, req)
+	}
+}
+
+func exchangeCode(log logger.Logger, repo Searcher, codes AuthorizationCodeStore, clock Clock, issue issueFunc) exchangeGrant {
+	return func(ctx context.Context, client *OIDCClient, req *TokenReq) (*TokenResp, error) {
+		if req.Code == "" || req.CodeVerifier == "" {
+			return nil, fmt.Errorf("the code and its verifier are required: %w", ErrInvalidOIDCRequest)
+		}
+		code, err := codes.TakeCode(ctx, hashOIDCSecret(req.Code))
+		if err != nil {
+			log.Debug().Err(err).Msg("unknown code")
+			return nil, ErrInvalidGrant
+		}
+		switch {
+		case code.ClientID != client.ID:
+			log.Debug().Str("client_id", client.ID).Msg("code of another client")
+			return nil, ErrInvalidGrant
+		case code.RedirectURI != req.RedirectURI:
+			return nil, ErrInvalidGrant
+		case !clock().Before(code.ExpiresAt):
+			return nil, ErrInvalidGrant
+		case !verifyCodeChallenge(code.CodeChallenge, req.CodeVerifier):
+			log.Debug().Str("client_id", client.ID).Msg("code verifier mismatch")
+			return nil, ErrInvalidGrant
+		}
+		usr, err := findUser(ctx, repo, repo.Query().ByID(code.UserID))
+		if err != nil {
+			log.Debug().Err(err).Msg("code of an unknown user")
+			return nil, ErrInvalidGrant
+		}
+		return issue(ctx, client, usr, &RefreshToken{Scopes: code.Scopes, AuthTime: code.AuthTime}, code.Nonce)
+	}
+}
+
+// exchangeRefreshToken will rotate the refresh token before checking the request, so a refused request consumes it.
+// A token already rotated has leaked (or its rotation response was lost): all the tokens of its family are revoked
+func exchangeRefreshToken(log logger.Logger, repo Searcher, tokens RefreshTokenStore, clock Clock, issue issueFunc) exchangeGrant {
+	return func(ctx context.Context, client *OIDCClient, req *TokenReq) (*TokenResp, error) {
+		if req.RefreshToken == "" {
+			return nil, fmt.Errorf("the refresh token is required: %w", ErrInvalidOIDCRequest)
+		}
+		now := clock()
+		token, err := tokens.RotateRefreshToken(ctx, hashOIDCSecret(req.RefreshToken), now)
+		if err != nil {
+			log.Debug().Err(err).Msg("unknown refresh token")
+			return nil, ErrInvalidGrant
+		}
+		switch {
+		case token.ClientID != client.ID:
+			log.Debug().Str("client_id", client.ID).Msg("refresh token of another client")
+			return nil, ErrInvalidGrant
+		case token.RotatedAt != nil:
+			log.Warn().Str("client_id", client.ID).Str("family_id", token.FamilyID).Msg("rotated refresh token reused, revoke its family")
+			if err := tokens.RevokeRefreshTokenFamily(ctx, token.FamilyID, now); err != nil {
+				return nil, fmt.Errorf("can't revoke refresh tokens: %w", err)
+			}
+			return nil, ErrInvalidGrant
+		case token.RevokedAt != nil, !now.Before(token.ExpiresAt):
+			return nil, ErrInvalidGrant
+		}
+
+		scopes := token.Scopes
+		if req.Scope != "" {
+			if scopes, err = narrowScopes(token.Scopes, req.Scope); err != nil {
+				return nil, err
+			}
+		}
+		usr, err := findUser(ctx, repo, repo.Query().ByID(token.UserID))
+		if err != nil {
+			log.Debug().Err(err).Msg("refresh token of an unknown user")
+			return nil, ErrInvalidGrant
+		}
+		return issue(ctx, client, usr, &RefreshToken{FamilyID: token.FamilyID, Scopes: scopes, AuthTime: token.AuthTime}, "")
+	}
+}
+
+// narrowScopes will return the requested scopes, which should have been granted
+func narrowScopes(granted []string, scope string) ([]string, error) {
+	scopes, err := parseScopes(scope)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range scopes {
+		found := false
+		for _, g := range granted {
+			found = found || g == s
+		}
+		if !found {
+			return nil, fmt.Errorf("the %s scope wasn't granted: %w", s, ErrInvalidScope)
+		}
+	}
+	return scopes, nil
+}
+
+// verifyCodeChallenge will check the verifier is the origin of the S256 challenge
+func verifyCodeChallenge(challenge, verifier string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+// issueFunc will issue the tokens of the user to the client, the grant is the refresh token to issue without its
+// hash, client, user and expiry. A grant without family starts a new one
+type issueFunc func(ctx context.Context, client *OIDCClient, usr *User, grant *RefreshToken, nonce string) (*TokenResp, error)
+
+func issueTokens(tokens RefreshTokenStore, signer TokenSigner, c Config, clock Clock) issueFunc {
+	return func(ctx context.Context, client *OIDCClient, usr *User, grant *RefreshToken, nonce string) (*TokenResp, error) {
+		now := clock()
+		jti, err := newOIDCSecret("")
+		if err != nil {
+			return nil, err
+		}
+		scope := strings.Join(grant.Scopes, " ")
+		accessToken, err := signJWT(signer, accessTokenType, accessTokenClaims{
+			Issuer:    c.OIDCIssuer,
+			Subject:   usr.ID,
+			Audience:  c.OIDCIssuer,
+			ClientID:  client.ID,
+			Scope:     scope,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(c.OIDCTokenTTL).Unix(),
+			JWTID:     jti,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		claims := userClaims(usr, grant.Scopes)
+		claims["iss"], claims["aud"] = c.OIDCIssuer, client.ID
+		claims["iat"], claims["exp"] = now.Unix(), now.Add(c.OIDCTokenTTL).Unix()
+		claims["auth_time"] = grant.AuthTime.Unix()
+		if nonce != "" {
+			claims["nonce"] = nonce
+		}
+		idToken, err := signJWT(signer, idTokenType, claims)
+		if err != nil {
+			return nil, err
+		}
+
+		refreshToken, err := newOIDCSecret(refreshTokenPfx)
+		if err != nil {
+			return nil, err
+		}
+		token := *grant
+		token.Hash, token.ClientID, token.UserID, token.ExpiresAt = hashOIDCSecret(refreshToken), client.ID, usr.ID, now.Add(c.OIDCRefreshTokenTTL)
+		if token.FamilyID == "" {
+			token.FamilyID = jti
+		}
+		if err := tokens.AddRefreshToken(ctx, &token); err != nil {
+			return nil, fmt.Errorf("can't save refresh token: %w", err)
+		}
+
+		return &TokenResp{
+			AccessToken:  accessToken,
+			TokenType:    "Bearer",
+			ExpiresIn:    int64(c.OIDCTokenTTL / time.Second),
+			RefreshToken: refreshToken,
+			IDToken:      idToken,
+			Scope:        scope,
+		}, nil
+	}
+}
@@ -0,0 +1,215 @@
+package users_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/oidcstore"
+	"go-users-example/infra/signer"
+	"go-users-example/infra/userstore"
+)
+
+var testOIDCConfig = users.Config{
+	OIDCIssuer:          "https://id.test",
+	OIDCCodeTTL:         time.Minute,
+	OIDCTokenTTL:        15 * time.Minute,
+	OIDCRefreshTokenTTL: time.Hour,
+}
+
+// oidcFixture is a user who authorized a confidential client
+type oidcFixture struct {
+	usr    *users.User
+	client *users.OIDCClient
+	secret string
+	signer *signer.Ed25519
+	store  *oidcstore.InMemory
+	users  *userstore.InMemory
+	// authorize will return a new code of the user for the client
+	authorize func() string
+	exchange  users.ExchangeToken
+}
+
+func newOIDCFixture(t *testing.T, clock users.Clock) *oidcFixture {
+	f := &oidcFixture{store: oidcstore.NewInMemory(), users: userstore.NewInMemory()}
+	f.signer, _ = signer.NewEd25519(signer.Config{})
+	verifiedAt := time.Now()
+	f.usr, _ = f.users.Add(context.Background(), &users.User{
+		Email: "test-oidc@test.com", FirstName: "test", LastName: "oidc", NickName: "tester", Phone: "+33612345678", PhoneVerifiedAt: &verifiedAt,
+	})
+	registered, err := users.SetupRegisterOIDCClient(logger.Logger{}, f.store, users.SystemClock)(context.Background(), &users.RegisterOIDCClientReq{
+		Name: "app", RedirectURIs: []string{"https://app.test/callback"}, Confidential: true,
+	})
+	require.NoError(t, err)
+	f.client, f.secret = registered.Client, registered.Secret
+
+	authorize := users.SetupAuthorize(logger.Logger{}, f.store, f.users, f.store, testOIDCConfig, clock)
+	f.authorize = func() string {
+		res, err := authorize(context.Background(), &users.AuthorizeReq{AuthorizationReq: newAuthorizationReq(f.client.ID), UserID: f.usr.ID})
+		require.NoError(t, err)
+		return res.Code
+	}
+	f.exchange = users.SetupExchangeToken(logger.Logger{}, f.store, f.users, f.store, f.store, f.signer, testOIDCConfig, clock)
+	return f
+}
+
+func (f *oidcFixture) codeReq(code string) *users.TokenReq {
+	return &users.TokenReq{
+		GrantType:    users.GrantAuthorizationCode,
+		Code:         code,
+		RedirectURI:  "https://app.test/callback",
+		CodeVerifier: testCodeVerifier,
+		ClientID:     f.client.ID,
+		ClientSecret: f.secret,
+	}
+}
+
+func (f *oidcFixture) refreshReq(token string) *users.TokenReq {
+	return &users.TokenReq{GrantType: users.GrantRefreshToken, RefreshToken: token, ClientID: f.client.ID, ClientSecret: f.secret}
+}
+
+// decodeJWT will verify the signature of the token and return its header and claims
+func decodeJWT(t *testing.T, key ed25519.PublicKey, token string) (map[string]interface{}, map[string]interface{}) {
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(key, []byte(parts[0]+"."+parts[1]), signature))
+	var header, claims map[string]interface{}
+	for i, v := range []*map[string]interface{}{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, v))
+	}
+	return header, claims
+}
+
+func TestSetupExchangeToken_Code(t *testing.T) {
+	f := newOIDCFixture(t, users.SystemClock)
+
+	res, err := f.exchange(context.Background(), f.codeReq(f.authorize()))
+	require.NoError(t, err)
+	require.Equal(t, "Bearer", res.TokenType)
+	require.Equal(t, int64(900), res.ExpiresIn)
+	require.Equal(t, "openid email", res.Scope)
+	require.True(t, strings.HasPrefix(res.RefreshToken, "urt_"))
+
+	header, claims := decodeJWT(t, f.signer.PublicKey(), res.IDToken)
+	require.Equal(t, "EdDSA", header["alg"])
+	require.NotEmpty(t, header["kid"])
+	require.Equal(t, "https://id.test", claims["iss"])
+	require.Equal(t, f.usr.ID, claims["sub"])
+	require.Equal(t, f.client.ID, claims["aud"])
+	require.Equal(t, "nonce", claims["nonce"])
+	require.Equal(t, "test-oidc@test.com", claims["email"])
+	require.Equal(t, false, claims["email_verified"])
+	// the claims of the scopes which weren't granted aren't given
+	require.NotContains(t, claims, "phone_number")
+
+	header, claims = decodeJWT(t, f.signer.PublicKey(), res.AccessToken)
+	require.Equal(t, "at+jwt", header["typ"])
+	require.Equal(t, f.client.ID, claims["client_id"])
+	require.Equal(t, "openid email", claims["scope"])
+}
+
+func TestSetupExchangeToken_InvalidCode(t *testing.T) {
+	now := time.Now()
+	f := newOIDCFixture(t, func() time.Time { return now })
+
+	for name, tc := range map[string]struct {
+		change func(req *users.TokenReq)
+		err    error
+	}{
+		"unsupported grant":  {change: func(req *users.TokenReq) { req.GrantType = "password" }, err: users.ErrUnsupportedGrantType},
+		"unknown client":     {change: func(req *users.TokenReq) { req.ClientID = "unknown" }, err: users.ErrInvalidClient},
+		"wrong secret":       {change: func(req *users.TokenReq) { req.ClientSecret = "ucs_wrong" }, err: users.ErrInvalidClient},
+		"no verifier":        {change: func(req *users.TokenReq) { req.CodeVerifier = "" }, err: users.ErrInvalidOIDCRequest},
+		"wrong verifier":     {change: func(req *users.TokenReq) { req.CodeVerifier = strings.Repeat("a", 43) }, err: users.ErrInvalidGrant},
+		"other redirect uri": {change: func(req *users.TokenReq) { req.RedirectURI = "https://app.test/other" }, err: users.ErrInvalidGrant},
+		"unknown code":       {change: func(req *users.TokenReq) { req.Code = "uac_unknown" }, err: users.ErrInvalidGrant},
+		"expired code":       {change: func(req *users.TokenReq) { now = now.Add(time.Minute) }, err: users.ErrInvalidGrant},
+	} {
+		req := f.codeReq(f.authorize())
+		tc.change(req)
+		_, err := f.exchange(context.Background(), req)
+		require.True(t, errors.Is(err, tc.err), "%s: %v", name, err)
+	}
+
+	// a code is exchanged once
+	req := f.codeReq(f.authorize())
+	_, err := f.exchange(context.Background(), req)
+	require.NoError(t, err)
+	_, err = f.exchange(context.Background(), req)
+	require.True(t, errors.Is(err, users.ErrInvalidGrant))
+}
+
+func TestSetupExchangeToken_PublicClient(t *testing.T) {
+	f := newOIDCFixture(t, users.SystemClock)
+	client, _ := f.store.AddClient(context.Background(), &users.OIDCClient{Name: "spa", RedirectURIs: []string{"https://app.test/callback"}})
+	authorize := users.SetupAuthorize(logger.Logger{}, f.store, f.users, f.store, testOIDCConfig, users.SystemClock)
+	code, err := authorize(context.Background(), &users.AuthorizeReq{AuthorizationReq: newAuthorizationReq(client.ID), UserID: f.usr.ID})
+	require.NoError(t, err)
+
+	// the code of a client can't be exchanged by another one
+	_, err = f.exchange(context.Background(), f.codeReq(code.Code))
+	require.True(t, errors.Is(err, users.ErrInvalidGrant))
+
+	code, _ = authorize(context.Background(), &users.AuthorizeReq{AuthorizationReq: newAuthorizationReq(client.ID), UserID: f.usr.ID})
+	req := f.codeReq(code.Code)
+	req.ClientID = client.ID
+	_, err = f.exchange(context.Background(), req)
+	require.True(t, errors.Is(err, users.ErrInvalidClient), "a public client has no secret")
+
+	req.ClientSecret = ""
+	_, err = f.exchange(context.Background(), req)
+	require.NoError(t, err)
+}
+
+func TestSetupExchangeToken_Refresh(t *testing.T) {
+	f := newOIDCFixture(t, users.SystemClock)
+	first, err := f.exchange(context.Background(), f.codeReq(f.authorize()))
+	require.NoError(t, err)
+
+	second, err := f.exchange(context.Background(), f.refreshReq(first.RefreshToken))
+	require.NoError(t, err)
+	require.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	_, claims := decodeJWT(t, f.signer.PublicKey(), second.IDToken)
+	require.Equal(t, f.usr.ID, claims["sub"])
+	require.NotContains(t, claims, "nonce")
+
+	// the scopes can be narrowed, not extended
+	req := f.refreshReq(second.RefreshToken)
+	req.Scope = "openid phone"
+	_, err = f.exchange(context.Background(), req)
+	require.True(t, errors.Is(err, users.ErrInvalidScope))
+	third, err := f.exchange(context.Background(), f.refreshReq(second.RefreshToken))
+	require.True(t, errors.Is(err, users.ErrInvalidGrant), "the refresh token is rotated even if the request fails")
+	require.Nil(t, third)
+
+	// the reuse of a rotated token revokes its family
+	f = newOIDCFixture(t, users.SystemClock)
+	first, _ = f.exchange(context.Background(), f.codeReq(f.authorize()))
+	req = f.refreshReq(first.RefreshToken)
+	req.Scope = "openid"
+	second, err = f.exchange(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, "openid", second.Scope)
+	_, err = f.exchange(context.Background(), f.refreshReq(first.RefreshToken))
+	require.True(t, errors.Is(err, users.ErrInvalidGrant))
+	_, err = f.exchange(context.Background(), f.refreshReq(second.RefreshToken))
+	require.True(t, errors.Is(err, users.ErrInvalidGrant))
+
+	// the refresh tokens of another family are kept
+	other, _ := f.exchange(context.Background(), f.codeReq(f.authorize()))
+	_, err = f.exchange(context.Background(), f.refreshReq(other.RefreshToken))
+	require.NoError(t, err)
+}
@@ -0,0 +1,46 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-users-example/infra/logger"
+)
+
+// UserInfoReq contains the access token issued to a client
+type UserInfoReq struct {
+	AccessToken string
+}
+
+// UserInfoResp contains the claims of the user given by the scopes of the access token
+type UserInfoResp struct {
+	Claims map[string]interface{}
+}
+
+// GetUserInfo define the function which will return the claims of the user of an access token
+type GetUserInfo func(ctx context.Context, req *UserInfoReq) (*UserInfoResp, error)
+
+// SetupGetUserInfo will return a configured GetUserInfo function which can be used later
+func SetupGetUserInfo(log logger.Logger, repo Searcher, signer TokenSigner, c Config, clock Clock) GetUserInfo {
+	log = log.With().Str("usecase", "oidc_userinfo").Logger()
+	return getUserInfo(log, repo, signer, c, clock)
+}
+
+func getUserInfo(log logger.Logger, repo Searcher, signer TokenSigner, c Config, clock Clock) GetUserInfo {
+	return func(ctx context.Context, req *UserInfoReq) (*UserInfoResp, error) {
+		var claims accessTokenClaims
+		if err := verifyJWT(signer, accessTokenType, req.AccessToken, &claims); err != nil {
+			return nil, err
+		}
+		if claims.Issuer != c.OIDCIssuer || claims.Audience != c.OIDCIssuer || clock().Unix() >= claims.ExpiresAt {
+			return nil, fmt.Errorf("access token expired or issued for another server: %w", ErrInvalidToken)
+		}
+		usr, err := findUser(ctx, repo, repo.Query().ByID(claims.Subject))
+		if err != nil {
+			log.Debug().Err(err).Msg("access token of an unknown user")
+			return nil, fmt.Errorf("access token of an unknown user: %w", ErrInvalidToken)
+		}
+		return &UserInfoResp{Claims: userClaims(usr, strings.Fields(claims.Scope))}, nil
+	}
+}
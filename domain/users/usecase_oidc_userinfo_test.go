@@ -0,0 +1,51 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/signer"
+)
+
+func TestSetupGetUserInfo(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+	f := newOIDCFixture(t, clock)
+	req := f.codeReq(f.authorize())
+	tokens, err := f.exchange(context.Background(), req)
+	require.NoError(t, err)
+
+	userInfo := users.SetupGetUserInfo(logger.Logger{}, f.users, f.signer, testOIDCConfig, clock)
+	res, err := userInfo(context.Background(), &users.UserInfoReq{AccessToken: tokens.AccessToken})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"sub": f.usr.ID, "email": "test-oidc@test.com", "email_verified": false}, res.Claims)
+
+	other, _ := signer.NewEd25519(signer.Config{})
+	// the first character of the claims is always significant, unlike the padding bits of the last one
+	parts := strings.Split(tokens.AccessToken, ".")
+	parts[1] = "f" + parts[1][1:]
+	for name, tc := range map[string]struct {
+		token    string
+		userInfo users.GetUserInfo
+	}{
+		"malformed":       {token: "token", userInfo: userInfo},
+		"id token":        {token: tokens.IDToken, userInfo: userInfo},
+		"other signer":    {token: tokens.AccessToken, userInfo: users.SetupGetUserInfo(logger.Logger{}, f.users, other, testOIDCConfig, clock)},
+		"expired":         {token: tokens.AccessToken, userInfo: users.SetupGetUserInfo(logger.Logger{}, f.users, f.signer, testOIDCConfig, func() time.Time { return now.Add(time.Hour) })},
+		"tampered claims": {token: strings.Join(parts, "."), userInfo: userInfo},
+	} {
+		_, err := tc.userInfo(context.Background(), &users.UserInfoReq{AccessToken: tc.token})
+		require.True(t, errors.Is(err, users.ErrInvalidToken), "%s: %v", name, err)
+	}
+
+	_, _ = f.users.Delete(context.Background(), f.usr)
+	_, err = userInfo(context.Background(), &users.UserInfoReq{AccessToken: tokens.AccessToken})
+	require.True(t, errors.Is(err, users.ErrInvalidToken), "the user has been deleted")
+}
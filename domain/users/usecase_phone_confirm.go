@@ -0,0 +1,102 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// ErrPhoneVerificationNotFound is returned when confirming a phone without pending verification, the code may have expired
+var ErrPhoneVerificationNotFound = errors.New("phone verification not found")
+
+// ConfirmPhoneReq contains the required parameters to confirm the phone of a user
+type ConfirmPhoneReq struct {
+	ID   string `json:"id"`
+	Code string `json:"code"`
+}
+
+// ConfirmPhoneResp is returned on successful confirmation with the user and its verified phone
+type ConfirmPhoneResp struct {
+	User *User `json:"user"`
+	// before is the user as it was before the confirmation, used to notify the full change
+	before *User
+}
+
+// ConfirmPhone define the function which will mark the phone as verified once the user provided the code sent to it
+type ConfirmPhone func(ctx context.Context, req *ConfirmPhoneReq) (*ConfirmPhoneResp, error)
+
+// SetupConfirmPhone will return a configured ConfirmPhone function which can be used later
+func SetupConfirmPhone(log logger.Logger, notifier ChangeNotifier, repo UpdateRepo, store PhoneVerificationStore, comparer HashComparer, clock Clock) ConfirmPhone {
+	log = log.With().Str("usecase", "user_phone_confirm").Logger()
+	return notifyConfirmPhone(log, notifier, confirmPhone(repo, store, comparer, clock))
+}
+
+func confirmPhone(repo UpdateRepo, store PhoneVerificationStore, comparer HashComparer, clock Clock) ConfirmPhone {
+	return func(ctx context.Context, req *ConfirmPhoneReq) (*ConfirmPhoneResp, error) {
+		usr, err := findUser(ctx, repo, repo.Query().ByID(req.ID))
+		if err != nil {
+			return nil, err
+		}
+		verification, err := store.GetPhoneVerification(ctx, usr.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve phone verification: %w", err)
+		}
+		if verification == nil || verification.CodeHash == "" || verification.Phone != usr.Phone || !clock().Before(verification.ExpiresAt) {
+			return nil, ErrPhoneVerificationNotFound
+		}
+
+		if err := comparer.Compare(verification.CodeHash, req.Code); err != nil {
+			verification.Attempts++
+			if verification.Attempts >= maxPhoneCodeAttempts {
+				// the code is void, the verification is kept to refuse the next codes of the window
+				verification.CodeHash = ""
+			}
+			if err := store.SavePhoneVerification(ctx, verification); err != nil {
+				return nil, fmt.Errorf("can't save phone verification: %w", err)
+			}
+			return nil, ErrInvalidCode
+		}
+		if err := store.DeletePhoneVerification(ctx, usr.ID); err != nil {
+			return nil, fmt.Errorf("can't delete phone verification: %w", err)
+		}
+
+		before := *usr
+		verified := *usr
+		verifiedAt := clock()
+		verified.PhoneVerifiedAt = &verifiedAt
+		// Note: the user can still be changed between the search and the update, the last update wins
+		updated, err := repo.Update(ctx, &verified)
+		if err != nil {
+			return nil, fmt.Errorf("can't save verified phone: %w", err)
+		}
+		return &ConfirmPhoneResp{User: updated, before: &before}, nil
+	}
+}
+
+func notifyConfirmPhone(log logger.Logger, notifier ChangeNotifier, confirmFunc ConfirmPhone) ConfirmPhone {
+	log = log.With().Str("us_middleware", "notifier").Logger()
+	return func(ctx context.Context, req *ConfirmPhoneReq) (*ConfirmPhoneResp, error) {
+		res, err := confirmFunc(ctx, req)
+		if err != nil {
+			return res, err
+		}
+		origin := RequestInfoFromContext(ctx)
+		go func(before *User, u User) {
+			evt := &ChangeEvent{
+				Time:   time.Now(),
+				Op:     UpdateOp,
+				Before: before,
+				After:  &u,
+				Origin: origin,
+			}
+			log.Debug().Interface("user", u).Msg("notify user phone verification")
+			if err := notifier.Notify(evt); err != nil {
+				log.Error().Interface("user", u).Err(err).Msg("can't send user phone verification event")
+			}
+		}(res.before, *res.User)
+		return res, nil
+	}
+}
@@ -0,0 +1,102 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/phonestore"
+	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/smssender"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/infra/userstore"
+)
+
+// sendPhoneCode will send a verification code to the phone of the user and return it
+func sendPhoneCode(t *testing.T, verify users.VerifyPhone, sender *smssender.InMemory, usr *users.User) string {
+	_, err := verify(context.Background(), &users.VerifyPhoneReq{ID: usr.ID})
+	require.NoError(t, err)
+	messages := sender.Messages(usr.Phone)
+	text := messages[len(messages)-1].Text
+	return text[strings.LastIndex(text, " ")+1:]
+}
+
+func TestSetupConfirmPhone_OK(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	userStore := userstore.NewInMemory()
+	phoneStore := phonestore.NewInMemory()
+	sender := smssender.NewInMemory(logger.Logger{})
+	notifier := usernotifier.NewInMemory()
+	events := notifier.Listen()
+	hasher := pwdhasher.NewBcryptWithCost(bcrypt.MinCost)
+	verify := users.SetupVerifyPhone(logger.Logger{}, userStore, phoneStore, hasher, sender, users.Config{PhoneCodeTTL: time.Minute}, fixedClock(now))
+	confirm := users.SetupConfirmPhone(logger.Logger{}, notifier, userStore, phoneStore, hasher, fixedClock(now))
+
+	usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-phone-confirm-1@test.com", Phone: "+33612345601"})
+	code := sendPhoneCode(t, verify, sender, usr)
+
+	_, err := confirm(context.Background(), &users.ConfirmPhoneReq{ID: usr.ID, Code: "wrong"})
+	require.True(t, errors.Is(err, users.ErrInvalidCode))
+
+	res, err := confirm(context.Background(), &users.ConfirmPhoneReq{ID: usr.ID, Code: code})
+	require.NoError(t, err)
+	require.Equal(t, now, *res.User.PhoneVerifiedAt)
+
+	select {
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatal("didn't receive update event, time out after 3sec")
+	case evt := <-events:
+		require.Equal(t, users.UpdateOp, evt.Op)
+		require.Nil(t, evt.Before.PhoneVerifiedAt)
+		require.NotNil(t, evt.After.PhoneVerifiedAt)
+	}
+
+	_, err = confirm(context.Background(), &users.ConfirmPhoneReq{ID: usr.ID, Code: code})
+	require.True(t, errors.Is(err, users.ErrPhoneVerificationNotFound))
+}
+
+func TestSetupConfirmPhone_Invalid(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	userStore := userstore.NewInMemory()
+	phoneStore := phonestore.NewInMemory()
+	sender := smssender.NewInMemory(logger.Logger{})
+	hasher := pwdhasher.NewBcryptWithCost(bcrypt.MinCost)
+	verify := users.SetupVerifyPhone(logger.Logger{}, userStore, phoneStore, hasher, sender, users.Config{PhoneCodeTTL: time.Minute}, fixedClock(now))
+
+	t.Run("too many attempts", func(t *testing.T) {
+		confirm := users.SetupConfirmPhone(logger.Logger{}, usernotifier.NewInMemory(), userStore, phoneStore, hasher, fixedClock(now))
+		usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-phone-confirm-2@test.com", Phone: "+33612345602"})
+		code := sendPhoneCode(t, verify, sender, usr)
+		for i := 0; i < 5; i++ {
+			_, err := confirm(context.Background(), &users.ConfirmPhoneReq{ID: usr.ID, Code: "wrong"})
+			require.True(t, errors.Is(err, users.ErrInvalidCode))
+		}
+		_, err := confirm(context.Background(), &users.ConfirmPhoneReq{ID: usr.ID, Code: code})
+		require.True(t, errors.Is(err, users.ErrPhoneVerificationNotFound))
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		confirm := users.SetupConfirmPhone(logger.Logger{}, usernotifier.NewInMemory(), userStore, phoneStore, hasher, fixedClock(now.Add(time.Minute)))
+		usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-phone-confirm-3@test.com", Phone: "+33612345603"})
+		code := sendPhoneCode(t, verify, sender, usr)
+		_, err := confirm(context.Background(), &users.ConfirmPhoneReq{ID: usr.ID, Code: code})
+		require.True(t, errors.Is(err, users.ErrPhoneVerificationNotFound))
+	})
+
+	t.Run("phone changed since the code was sent", func(t *testing.T) {
+		confirm := users.SetupConfirmPhone(logger.Logger{}, usernotifier.NewInMemory(), userStore, phoneStore, hasher, fixedClock(now))
+		usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-phone-confirm-4@test.com", Phone: "+33612345604"})
+		code := sendPhoneCode(t, verify, sender, usr)
+		_, err := userStore.Update(context.Background(), &users.User{ID: usr.ID, Phone: "+33612345605", Version: usr.Version})
+		require.NoError(t, err)
+		_, err = confirm(context.Background(), &users.ConfirmPhoneReq{ID: usr.ID, Code: code})
+		require.True(t, errors.Is(err, users.ErrPhoneVerificationNotFound))
+	})
+}
@@ -0,0 +1,104 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// ErrNoPhone is returned when verifying the phone of a user which doesn't have one
+var ErrNoPhone = errors.New("user has no phone")
+
+// ErrPhoneAlreadyVerified is returned when verifying a phone which has already been verified
+var ErrPhoneAlreadyVerified = errors.New("phone already verified")
+
+// ErrTooManyPhoneCodes is returned when a code is requested during the resend cooldown, or once the codes or the wrong
+// attempts of the day are exhausted
+var ErrTooManyPhoneCodes = errors.New("too many phone codes")
+
+// VerifyPhoneReq contains the required parameters to send a verification code to the phone of a user
+type VerifyPhoneReq struct {
+	ID string `json:"id"`
+}
+
+// VerifyPhoneResp is returned once the code has been sent
+type VerifyPhoneResp struct {
+	Phone     string    `json:"phone"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SMSSender will send a text message to a phone
+type SMSSender interface {
+	SendSMS(ctx context.Context, phone, message string) error
+}
+
+// PhoneVerificationStore will save and retrieve the pending phone verifications of the users
+type PhoneVerificationStore interface {
+	// GetPhoneVerification will return a nil PhoneVerification without error if there is no pending verification
+	GetPhoneVerification(ctx context.Context, userID string) (*PhoneVerification, error)
+	SavePhoneVerification(ctx context.Context, verification *PhoneVerification) error
+	DeletePhoneVerification(ctx context.Context, userID string) error
+}
+
+// VerifyPhone define the function which will send a verification code to the phone of a user
+type VerifyPhone func(ctx context.Context, req *VerifyPhoneReq) (*VerifyPhoneResp, error)
+
+// SetupVerifyPhone will return a configured VerifyPhone function which can be used later
+func SetupVerifyPhone(log logger.Logger, repo Searcher, store PhoneVerificationStore, hasher Hasher, sender SMSSender, c Config, clock Clock) VerifyPhone {
+	return verifyPhone(repo, store, hasher, sender, c, clock)
+}
+
+func verifyPhone(repo Searcher, store PhoneVerificationStore, hasher Hasher, sender SMSSender, c Config, clock Clock) VerifyPhone {
+	return func(ctx context.Context, req *VerifyPhoneReq) (*VerifyPhoneResp, error) {
+		usr, err := findUser(ctx, repo, repo.Query().ByID(req.ID))
+		if err != nil {
+			return nil, err
+		}
+		if usr.Phone == "" {
+			return nil, ErrNoPhone
+		}
+		if usr.PhoneVerifiedAt != nil {
+			return nil, ErrPhoneAlreadyVerified
+		}
+
+		now := clock()
+		verification, err := store.GetPhoneVerification(ctx, usr.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't retrieve phone verification: %w", err)
+		}
+		if verification == nil || !now.Before(verification.WindowStart.Add(phoneCodeWindow)) {
+			verification = &PhoneVerification{UserID: usr.ID, WindowStart: now}
+		}
+		switch {
+		case now.Before(verification.SentAt.Add(c.PhoneResendCooldown)):
+			return nil, fmt.Errorf("can't send phone code before %s: %w", verification.SentAt.Add(c.PhoneResendCooldown), ErrTooManyPhoneCodes)
+		case c.PhoneMaxDailyCodes > 0 && verification.Sent >= c.PhoneMaxDailyCodes:
+			return nil, fmt.Errorf("can't send more than %d phone codes: %w", c.PhoneMaxDailyCodes, ErrTooManyPhoneCodes)
+		case verification.Attempts >= maxPhoneCodeAttempts:
+			return nil, fmt.Errorf("can't send phone code after %d wrong codes: %w", verification.Attempts, ErrTooManyPhoneCodes)
+		}
+
+		code, err := newPhoneCode()
+		if err != nil {
+			return nil, err
+		}
+		hashed, err := hasher.Hash(code)
+		if err != nil {
+			return nil, fmt.Errorf("can't hash phone code: %w", err)
+		}
+		// Note: a new code replace the pending one, the codes sent and the wrong attempts are kept for the window
+		verification.Phone, verification.CodeHash, verification.ExpiresAt = usr.Phone, hashed, now.Add(c.PhoneCodeTTL)
+		verification.SentAt = now
+		verification.Sent++
+		if err := store.SavePhoneVerification(ctx, verification); err != nil {
+			return nil, fmt.Errorf("can't save phone verification: %w", err)
+		}
+		if err := sender.SendSMS(ctx, usr.Phone, fmt.Sprintf("Your %s verification code is %s", c.MFAIssuer, code)); err != nil {
+			return nil, fmt.Errorf("can't send phone code: %w", err)
+		}
+		return &VerifyPhoneResp{Phone: usr.Phone, ExpiresAt: verification.ExpiresAt}, nil
+	}
+}
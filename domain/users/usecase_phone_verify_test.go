@@ -0,0 +1,95 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/phonestore"
+	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/smssender"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/infra/userstore"
+)
+
+func TestSetupVerifyPhone(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	userStore := userstore.NewInMemory()
+	phoneStore := phonestore.NewInMemory()
+	sender := smssender.NewInMemory(logger.Logger{})
+	verify := users.SetupVerifyPhone(logger.Logger{}, userStore, phoneStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), sender,
+		users.Config{MFAIssuer: "test", PhoneCodeTTL: 10 * time.Minute}, fixedClock(now))
+
+	noPhone, _ := userStore.Add(context.Background(), &users.User{Email: "test-phone-verify-1@test.com"})
+	_, err := verify(context.Background(), &users.VerifyPhoneReq{ID: noPhone.ID})
+	require.True(t, errors.Is(err, users.ErrNoPhone))
+
+	verifiedAt := now
+	verified, _ := userStore.Add(context.Background(), &users.User{Email: "test-phone-verify-2@test.com", Phone: "+33612345602", PhoneVerifiedAt: &verifiedAt})
+	_, err = verify(context.Background(), &users.VerifyPhoneReq{ID: verified.ID})
+	require.True(t, errors.Is(err, users.ErrPhoneAlreadyVerified))
+
+	usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-phone-verify-3@test.com", Phone: "+33612345603"})
+	res, err := verify(context.Background(), &users.VerifyPhoneReq{ID: usr.ID})
+	require.NoError(t, err)
+	require.Equal(t, "+33612345603", res.Phone)
+	require.Equal(t, now.Add(10*time.Minute), res.ExpiresAt)
+
+	messages := sender.Messages("+33612345603")
+	require.Len(t, messages, 1)
+	require.Regexp(t, `^Your test verification code is [0-9]{6}$`, messages[0].Text)
+	verification, _ := phoneStore.GetPhoneVerification(context.Background(), usr.ID)
+	require.Equal(t, "+33612345603", verification.Phone)
+	require.NotContains(t, messages[0].Text, verification.CodeHash)
+}
+
+func TestSetupVerifyPhone_Limits(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	current := now
+	clock := func() time.Time { return current }
+	userStore := userstore.NewInMemory()
+	phoneStore := phonestore.NewInMemory()
+	hasher := pwdhasher.NewBcryptWithCost(bcrypt.MinCost)
+	cfg := users.Config{PhoneCodeTTL: 10 * time.Minute, PhoneResendCooldown: time.Minute, PhoneMaxDailyCodes: 2}
+	verify := users.SetupVerifyPhone(logger.Logger{}, userStore, phoneStore, hasher, smssender.NewInMemory(logger.Logger{}), cfg, clock)
+	confirm := users.SetupConfirmPhone(logger.Logger{}, usernotifier.NewInMemory(), userStore, phoneStore, hasher, clock)
+
+	t.Run("resend cooldown and daily limit", func(t *testing.T) {
+		usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-phone-verify-4@test.com", Phone: "+33612345604"})
+		for _, tc := range []struct {
+			after   time.Duration
+			limited bool
+		}{
+			{after: 0},
+			{after: 30 * time.Second, limited: true},
+			{after: time.Minute},
+			{after: 2 * time.Minute, limited: true},
+			{after: 25 * time.Hour},
+		} {
+			current = now.Add(tc.after)
+			_, err := verify(context.Background(), &users.VerifyPhoneReq{ID: usr.ID})
+			require.Equal(t, tc.limited, errors.Is(err, users.ErrTooManyPhoneCodes), "after %s: %v", tc.after, err)
+		}
+	})
+
+	t.Run("wrong attempts are kept across the codes", func(t *testing.T) {
+		current = now
+		usr, _ := userStore.Add(context.Background(), &users.User{Email: "test-phone-verify-5@test.com", Phone: "+33612345605"})
+		_, err := verify(context.Background(), &users.VerifyPhoneReq{ID: usr.ID})
+		require.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			_, err := confirm(context.Background(), &users.ConfirmPhoneReq{ID: usr.ID, Code: "wrong"})
+			require.True(t, errors.Is(err, users.ErrInvalidCode))
+		}
+
+		current = now.Add(time.Hour)
+		_, err = verify(context.Background(), &users.VerifyPhoneReq{ID: usr.ID})
+		require.True(t, errors.Is(err, users.ErrTooManyPhoneCodes))
+	})
+}
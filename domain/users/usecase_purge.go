@@ -0,0 +1,85 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// PurgeResp contains the users which have been permanently removed by the purge
+type PurgeResp struct {
+	Users []*User `json:"users"`
+}
+
+// Purger will permanently remove all the users deleted before deletedBefore
+type Purger interface {
+	Purge(ctx context.Context, deletedBefore time.Time) ([]*User, error)
+}
+
+// Purge define the function which will permanently remove the users deleted for longer than the restore window
+type Purge func(ctx context.Context) (*PurgeResp, error)
+
+// SetupPurge will return a configured Purge function which can be used later
+func SetupPurge(log logger.Logger, notifier ChangeNotifier, repo Purger, c Config) Purge {
+	log = log.With().Str("usecase", "user_purge").Logger()
+	return notifyPurge(log, notifier, purgeUsers(repo, c.RestoreWindow))
+}
+
+// SchedulePurge will run the purge every interval until the context is done
+func SchedulePurge(ctx context.Context, log logger.Logger, purge Purge, interval time.Duration) {
+	log = log.With().Str("usecase", "user_purge").Logger()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			res, err := purge(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("can't purge deleted users")
+				continue
+			}
+			log.Debug().Int("purged", len(res.Users)).Msg("deleted users purged")
+		}
+	}
+}
+
+func purgeUsers(repo Purger, window time.Duration) Purge {
+	return func(ctx context.Context) (*PurgeResp, error) {
+		purged, err := repo.Purge(ctx, time.Now().Add(-window))
+		if err != nil {
+			return nil, fmt.Errorf("can't purge users: %w", err)
+		}
+		return &PurgeResp{Users: purged}, nil
+	}
+}
+
+func notifyPurge(log logger.Logger, notifier ChangeNotifier, purgeFunc Purge) Purge {
+	log = log.With().Str("us_middleware", "notifier").Logger()
+	return func(ctx context.Context) (*PurgeResp, error) {
+		res, err := purgeFunc(ctx)
+		if err != nil {
+			return res, err
+		}
+		origin := RequestInfoFromContext(ctx)
+		for _, usr := range res.Users {
+			go func(u User) {
+				evt := &ChangeEvent{
+					Time:   time.Now(),
+					Op:     PurgeOp,
+					Before: &u,
+					After:  nil,
+					Origin: origin,
+				}
+				log.Debug().Str("user_id", u.ID).Msg("notify user purge")
+				if err := notifier.Notify(evt); err != nil {
+					log.Error().Str("user_id", u.ID).Err(err).Msg("can't send user purge event")
+				}
+			}(*usr)
+		}
+		return res, nil
+	}
+}
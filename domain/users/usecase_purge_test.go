@@ -0,0 +1,37 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/infra/userstore"
+)
+
+func TestSetupPurge_OK(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	notifier := usernotifier.NewInMemory()
+	events := notifier.Listen()
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email: "test-purge-1",
+	})
+	_, _ = userStore.Delete(context.Background(), usr)
+	purge := users.SetupPurge(logger.Logger{}, notifier, userStore, users.Config{RestoreWindow: 0})
+	res, err := purge(context.Background())
+	require.NoError(t, err)
+	require.Len(t, res.Users, 1)
+	require.Equal(t, usr.ID, res.Users[0].ID)
+
+	select {
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatal("didn't receive purge event, time out after 3sec")
+	case evt := <-events:
+		require.Equal(t, users.PurgeOp, evt.Op)
+		require.Equal(t, usr.ID, evt.Before.ID)
+	}
+}
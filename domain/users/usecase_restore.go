@@ -0,0 +1,68 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// RestoreReq contains the required parameters to restore a deleted user
+type RestoreReq struct {
+	ID string `json:"id"`
+}
+
+// RestoreResp contains the field which will be returned on successful user restoration
+type RestoreResp struct {
+	User *User `json:"user"`
+}
+
+// Restorer will bring back a deleted user if it has been deleted after deletedSince
+type Restorer interface {
+	Restore(ctx context.Context, user *User, deletedSince time.Time) (*User, error)
+}
+
+// Restore define the function which will restore a deleted user in the system
+type Restore func(ctx context.Context, req *RestoreReq) (*RestoreResp, error)
+
+// SetupRestore will return a configured Restore function which can be used later
+func SetupRestore(log logger.Logger, notifier ChangeNotifier, repo Restorer, c Config) Restore {
+	log = log.With().Str("usecase", "user_restore").Logger()
+	return notifyRestore(log, notifier, restoreUser(repo, c.RestoreWindow))
+}
+
+func restoreUser(repo Restorer, window time.Duration) Restore {
+	return func(ctx context.Context, req *RestoreReq) (*RestoreResp, error) {
+		usr, err := repo.Restore(ctx, &User{ID: req.ID}, time.Now().Add(-window))
+		if err != nil {
+			return nil, fmt.Errorf("can't restore user: %w", err)
+		}
+		return &RestoreResp{User: usr}, nil
+	}
+}
+
+func notifyRestore(log logger.Logger, notifier ChangeNotifier, restoreFunc Restore) Restore {
+	log = log.With().Str("us_middleware", "notifier").Logger()
+	return func(ctx context.Context, req *RestoreReq) (*RestoreResp, error) {
+		res, err := restoreFunc(ctx, req)
+		if err != nil {
+			return res, err
+		}
+		origin := RequestInfoFromContext(ctx)
+		go func(u User) {
+			evt := &ChangeEvent{
+				Time:   time.Now(),
+				Op:     RestoreOp,
+				Before: nil,
+				After:  &u,
+				Origin: origin,
+			}
+			log.Debug().Interface("user", u).Msg("notify user restoration")
+			if err := notifier.Notify(evt); err != nil {
+				log.Error().Interface("user", u).Err(err).Msg("can't send user restoration event")
+			}
+		}(*res.User)
+		return res, nil
+	}
+}
@@ -0,0 +1,42 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/infra/userstore"
+)
+
+func TestSetupRestore_OK(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email: "test-restore-1",
+	})
+	_, _ = userStore.Delete(context.Background(), usr)
+	restore := users.SetupRestore(logger.Logger{}, usernotifier.NewInMemory(), userStore, users.Config{RestoreWindow: time.Hour})
+	res, err := restore(context.Background(), &users.RestoreReq{
+		ID: usr.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "test-restore-1", res.User.Email)
+	require.Nil(t, res.User.DeletedAt)
+}
+
+func TestSetupRestore_WindowExpired(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	usr, _ := userStore.Add(context.Background(), &users.User{
+		Email: "test-restore-2",
+	})
+	_, _ = userStore.Delete(context.Background(), usr)
+	restore := users.SetupRestore(logger.Logger{}, usernotifier.NewInMemory(), userStore, users.Config{RestoreWindow: 0})
+	_, err := restore(context.Background(), &users.RestoreReq{
+		ID: usr.ID,
+	})
+	require.Error(t, err)
+}
@@ -2,19 +2,33 @@ package users
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 
 	"go-users-example/infra/logger"
 )
 
+// ErrUserNotFound is returned when a usecase can't find the user it should act on
+var ErrUserNotFound = errors.New("user not found")
+
 // SearchReq contains the required parameters to search users
 type SearchReq struct {
 	IDs       []string
 	Emails    []string
 	FirstName []string
-	LastName []string
-	NickName []string
-	Country []string
+	LastName  []string
+	NickName  []string
+	// SimilarNickNames will match the nicknames similar to these ones, as they are unique: regardless of their case and
+	// of their confusable characters
+	SimilarNickNames []string
+	Country          []string
+	// Phones are international numbers, normalized to E.164
+	Phones []string
+	// Attributes are the values of the custom attributes by name, parsed according to their type in the attribute schema
+	Attributes map[string][]string
+	// WithDeleted will also return the soft deleted users which can still be restored
+	WithDeleted bool
 }
 
 // SearchResp contains the field which will be returned on successful user search
@@ -29,7 +43,15 @@ type Queryer interface {
 	ByFirstName(firstName string) Queryer
 	ByLastName(lastName string) Queryer
 	ByNickName(nickName string) Queryer
+	// ByNickNameKey will match the nicknames with the key, the similar nicknames regardless of their case and of their
+	// confusable characters
+	ByNickNameKey(key string) Queryer
 	ByCountry(country string) Queryer
+	ByPhone(phone string) Queryer
+	// ByAttribute will match the users with the custom attribute set to the value, the value is a json scalar (string, float64, bool).
+	// The values of a same attribute are alternatives, but the users should match all the searched attributes
+	ByAttribute(name string, value interface{}) Queryer
+	WithDeleted() Queryer
 }
 
 // Searcher will allow searching users based on different criteria
@@ -42,32 +64,16 @@ type Searcher interface {
 type Search func(ctx context.Context, req *SearchReq) (*SearchResp, error)
 
 // SetupSearch will return a configured Create function which can be used later
-func SetupSearch(log logger.Logger, repo Searcher) Search {
+func SetupSearch(log logger.Logger, repo Searcher, validator *Validator) Search {
 	log = log.With().Str("usecase", "user_search").Logger()
-	return searchUser(repo)
+	return searchUser(repo, validator)
 }
 
-func searchUser(repo Searcher) Search {
+func searchUser(repo Searcher, validator *Validator) Search {
 	return func(ctx context.Context, req *SearchReq) (*SearchResp, error) {
-		qBuilder := repo.Query()
-
-		for _, id := range req.IDs {
-			qBuilder = qBuilder.ByID(id)
-		}
-		for _, email := range req.Emails {
-			qBuilder = qBuilder.ByEmail(email)
-		}
-		for _, firstName := range req.FirstName {
-			qBuilder = qBuilder.ByFirstName(firstName)
-		}
-		for _, lastName := range req.LastName {
-			qBuilder = qBuilder.ByLastName(lastName)
-		}
-		for _, nickName := range req.NickName {
-			qBuilder = qBuilder.ByNickName(nickName)
-		}
-		for _, country := range req.Country {
-			qBuilder = qBuilder.ByCountry(country)
+		qBuilder, err := searchQuery(repo.Query(), validator, req)
+		if err != nil {
+			return nil, err
 		}
 
 		users, err := repo.Search(ctx, qBuilder)
@@ -78,3 +84,68 @@ func searchUser(repo Searcher) Search {
 		return &SearchResp{Users: users}, nil
 	}
 }
+
+// searchQuery will add the criteria of the search to the query, the attributes are parsed according to the attribute
+// schema
+func searchQuery(qBuilder Queryer, validator *Validator, req *SearchReq) (Queryer, error) {
+	for _, id := range req.IDs {
+		qBuilder = qBuilder.ByID(id)
+	}
+	for _, email := range req.Emails {
+		qBuilder = qBuilder.ByEmail(lookupEmail(email))
+	}
+	for _, firstName := range req.FirstName {
+		qBuilder = qBuilder.ByFirstName(firstName)
+	}
+	for _, lastName := range req.LastName {
+		qBuilder = qBuilder.ByLastName(lastName)
+	}
+	for _, nickName := range req.NickName {
+		qBuilder = qBuilder.ByNickName(nickName)
+	}
+	for _, nickName := range req.SimilarNickNames {
+		qBuilder = qBuilder.ByNickNameKey(nickNameKey(nickName))
+	}
+	for _, country := range req.Country {
+		qBuilder = qBuilder.ByCountry(normalizeCountry(country))
+	}
+	for _, phone := range req.Phones {
+		qBuilder = qBuilder.ByPhone(normalizePhone(phone, ""))
+	}
+	schema := validator.AttributeSchema()
+	names := make([]string, 0, len(req.Attributes))
+	for name := range req.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	verr := &ValidationError{}
+	for _, name := range names {
+		for _, raw := range req.Attributes[name] {
+			value, violation := schema.value(name, raw)
+			if violation != nil {
+				verr.add(violation)
+				continue
+			}
+			qBuilder = qBuilder.ByAttribute(name, value)
+		}
+	}
+	if err := verr.errOrNil(); err != nil {
+		return nil, fmt.Errorf("can't search by attributes: %w", err)
+	}
+	if req.WithDeleted {
+		qBuilder = qBuilder.WithDeleted()
+	}
+	return qBuilder, nil
+}
+
+// findUser will return the only user matching the query
+func findUser(ctx context.Context, repo Searcher, q Queryer) (*User, error) {
+	res, err := repo.Search(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("can't search user: %w", err)
+	}
+	if len(res) != 1 {
+		return nil, fmt.Errorf("can't find user: %w", ErrUserNotFound)
+	}
+	return res[0], nil
+}
@@ -2,60 +2,127 @@ package users
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"go-users-example/infra/logger"
 )
 
-// UpdateReq contains the required parameters to Update a new user
+// ErrPreconditionFailed is returned if the user isn't at the version the update is based on
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// UpdateReq contains the required parameters to Update a user. the absent fields are kept, the null ones are cleared
+// and the others are set, even when empty. the validation runs against the resulting user
 type UpdateReq struct {
-	ID          string `json:"id"`
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	NickName    string `json:"nick_name"`
-	Email       string `json:"email"`
-	RawPassword string `json:"password"`
-	Country     string `json:"country"`
+	ID          string         `json:"id"`
+	FirstName   OptionalString `json:"first_name"`
+	LastName    OptionalString `json:"last_name"`
+	NickName    OptionalString `json:"nick_name"`
+	Email       OptionalString `json:"email"`
+	RawPassword OptionalString `json:"password"`
+	Country     OptionalString `json:"country"`
+	// Phone is read in the region of the resulting country of the user if it isn't an international number
+	Phone OptionalString `json:"phone"`
+	// Attributes are the custom attributes to change, the other attributes are kept and a null value removes the attribute
+	Attributes OptionalAttributes `json:"attributes"`
+	// Version is the version of the user the update is based on, the update is rejected if the user changed since.
+	// the update is applied on the current version when it is 0
+	Version int64 `json:"-"`
+	// hashedPassword is the hash of RawPassword when it was already hashed, by an atomic batch
+	hashedPassword string
 }
 
 // UpdateResp contains the field which will be returned on successful user update
 type UpdateResp struct {
 	User *User `json:"user"`
+	// before is the user as it was before the update, used to notify the full change
+	before *User
 }
 
-// Updater will update a user in the system
+// Updater will replace the user with the same ID, all the fields are stored as provided.
+// the user must still be at the version provided, else the update is rejected with a conflict
 type Updater interface {
 	Update(ctx context.Context, user *User) (*User, error)
 }
 
+// UpdateRepo will retrieve the user before updating it, to be able to notify the full change
+type UpdateRepo interface {
+	Updater
+	Searcher
+}
+
 // Update define the function which will Update a user in the system
 type Update func(ctx context.Context, req *UpdateReq) (*UpdateResp, error)
 
 // SetupUpdate will return a configured Update function which can be used later
-func SetupUpdate(log logger.Logger, notifier ChangeNotifier, repo Updater) Update {
+func SetupUpdate(log logger.Logger, notifier ChangeNotifier, repo UpdateRepo, hasher Hasher, validator *Validator) Update {
 	log = log.With().Str("usecase", "user_update").Logger()
-	return validateUpdate(log, notifyUpdate(log, notifier, updateUser(repo)))
+	return validateUpdate(log, notifyUpdate(log, notifier, updateUser(repo, hasher, validator)))
 }
 
-func updateUser(repo Updater) Update {
+func updateUser(repo UpdateRepo, hash Hasher, validator *Validator) Update {
 	return func(ctx context.Context, req *UpdateReq) (*UpdateResp, error) {
-		newUser, err := repo.Update(ctx, &User{
-			ID:        req.ID,
-			FirstName: req.FirstName,
-			LastName:  req.LastName,
-			NickName:  req.NickName,
-			Password:  req.RawPassword,
-			Email:     req.Email,
-			Country:   req.Country,
-		})
+		// the user is updated on the version read, a concurrent change is rejected by the repo instead of being lost
+		current, err := findUser(ctx, repo, repo.Query().ByID(req.ID))
+		if err != nil {
+			return nil, err
+		}
+		if req.Version != 0 && req.Version != current.Version {
+			return nil, fmt.Errorf("user %s is at version %d, not %d: %w", req.ID, current.Version, req.Version, ErrPreconditionFailed)
+		}
+		usr := applyUpdate(current, req)
+		if err := validator.validateUser(usr); err != nil {
+			return nil, fmt.Errorf("can't validate user: %w", err)
+		}
+		if usr.Email != current.Email {
+			usr.CanonicalEmail = ""
+			if usr.Email != "" {
+				if usr.Email, usr.CanonicalEmail, err = validator.emails.normalizeEmail(usr.Email); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if req.RawPassword.Set {
+			usr.Password = req.hashedPassword
+			if usr.Password == "" {
+				if usr.Password, err = hash.Hash(req.RawPassword.Value); err != nil {
+					return nil, fmt.Errorf("can't hash the password: %w", err)
+				}
+			}
+		}
+
+		before := *current
+		newUser, err := repo.Update(ctx, usr)
 		if err != nil {
 			return nil, fmt.Errorf("can't save new user: %w", err)
 		}
-		return &UpdateResp{User: newUser}, nil
+		return &UpdateResp{User: newUser, before: &before}, nil
 	}
 }
 
+// applyUpdate returns the user resulting of the update of the current user, the current user isn't modified.
+// the password is kept, it is hashed by the caller
+func applyUpdate(current *User, req *UpdateReq) *User {
+	usr := *current
+	usr.FirstName = normalizeName(req.FirstName.apply(current.FirstName))
+	usr.LastName = normalizeName(req.LastName.apply(current.LastName))
+	if req.NickName.Set {
+		usr.NickName = normalizeName(req.NickName.Value)
+		usr.NickNameKey = nickNameKey(req.NickName.Value)
+	}
+	usr.Email = req.Email.apply(current.Email)
+	usr.Country = normalizeCountry(req.Country.apply(current.Country))
+	if req.Phone.Set {
+		usr.Phone = normalizePhone(req.Phone.Value, usr.Country)
+	}
+	if usr.Phone != current.Phone {
+		usr.PhoneVerifiedAt = nil
+	}
+	usr.Attributes = req.Attributes.apply(current.Attributes)
+	return &usr
+}
+
 func notifyUpdate(log logger.Logger, notifier ChangeNotifier, UpdateFunc Update) Update {
 	log = log.With().Str("us_middleware", "notifier").Logger()
 	return func(ctx context.Context, req *UpdateReq) (*UpdateResp, error) {
@@ -63,36 +130,33 @@ func notifyUpdate(log logger.Logger, notifier ChangeNotifier, UpdateFunc Update)
 		if err != nil {
 			return res, err
 		}
-		go func(u User) {
+		origin := RequestInfoFromContext(ctx)
+		before, u := res.before, *res.User
+		afterCommit(ctx, func() {
 			evt := &ChangeEvent{
 				Time:   time.Now(),
 				Op:     UpdateOp,
-				Before: nil,
+				Before: before,
 				After:  &u,
+				Origin: origin,
 			}
 			log.Debug().Interface("user", u).Msg("notify user update")
 			if err := notifier.Notify(evt); err != nil {
 				log.Error().Interface("user", u).Err(err).Msg("can't send user update event")
 			}
-		}(*res.User)
+		})
 		return res, nil
 	}
 }
 
+// validateUpdate will refuse the updates which can't be applied whatever the current user
 func validateUpdate(log logger.Logger, updateFunc Update) Update {
 	return func(ctx context.Context, req *UpdateReq) (*UpdateResp, error) {
-		log.Debug().Interface("req", req).Msg("receive update")
-		if err := validateFirstName(req.FirstName); req.FirstName != "" && err != nil {
-			return nil, fmt.Errorf("can't validate user: %s: %w", err.Error(), ErrInvalidUser)
-		}
-		if err := validateLastName(req.LastName); req.LastName != "" && err != nil {
-			return nil, fmt.Errorf("can't validate user: %s: %w", err.Error(), ErrInvalidUser)
-		}
-		if err := validateNickName(req.NickName); req.NickName != "" && err != nil {
-			return nil, fmt.Errorf("can't validate user: %s: %w", err.Error(), ErrInvalidUser)
-		}
-		if err := validateEmail(req.Email); req.Email != "" && err != nil {
-			return nil, fmt.Errorf("can't validate user: %s: %w", err.Error(), ErrInvalidUser)
+		log.Debug().Str("id", req.ID).Msg("receive update")
+		if req.RawPassword.Set && req.RawPassword.Value == "" {
+			verr := &ValidationError{}
+			verr.add(&FieldViolation{Field: "password", Code: CodeRequired, Message: "password can't be removed"})
+			return nil, fmt.Errorf("can't validate user: %w", verr)
 		}
 		return updateFunc(ctx, req)
 	}
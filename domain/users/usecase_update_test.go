@@ -2,27 +2,296 @@ package users_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
 	"go-users-example/domain/users"
 	"go-users-example/infra/logger"
+	"go-users-example/infra/mfastore"
+	"go-users-example/infra/pwdhasher"
 	"go-users-example/infra/usernotifier"
 	"go-users-example/infra/userstore"
 )
 
+// addValidUser will add a user which satisfy the default rules
+func addValidUser(t *testing.T, store *userstore.InMemory, usr *users.User) *users.User {
+	usr.FirstName, usr.LastName, usr.NickName, usr.NickNameKey = "test", "test", "tester", "tester"
+	usr, err := store.Add(context.Background(), usr)
+	require.NoError(t, err)
+	return usr
+}
+
 func TestSetupUpdate_OK(t *testing.T) {
 	userStore := userstore.NewInMemory()
-	usr, _ := userStore.Add(context.Background(), &users.User{
-		Email: "test-update-1",
+	usr := addValidUser(t, userStore, &users.User{
+		Email: "test-update-1@test.com",
 	})
-	update := users.SetupUpdate(logger.Logger{}, usernotifier.NewInMemory(), userStore)
+	update := users.SetupUpdate(logger.Logger{}, usernotifier.NewInMemory(), userStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), newValidator(t, users.Config{}))
 	res, err := update(context.Background(), &users.UpdateReq{
 		ID:    usr.ID,
-		Email: "test-update-1-updated@test.com",
+		Email: users.SetString("test-update-1-updated@test.com"),
 	})
 	require.NoError(t, err)
 	require.Equal(t, res.User.Email, "test-update-1-updated@test.com")
 	require.NotEmpty(t, res.User.ID)
 }
+
+// readTogetherStore will make the searches wait for each other, the concurrent updates read the same version of the user
+type readTogetherStore struct {
+	*userstore.InMemory
+	read sync.WaitGroup
+}
+
+func (s *readTogetherStore) Search(ctx context.Context, q users.Queryer) ([]*users.User, error) {
+	res, err := s.InMemory.Search(ctx, q)
+	s.read.Done()
+	s.read.Wait()
+	return res, err
+}
+
+func TestSetupUpdate_Concurrent(t *testing.T) {
+	userStore := &readTogetherStore{InMemory: userstore.NewInMemory()}
+	usr := addValidUser(t, userStore.InMemory, &users.User{
+		Email: "test-update-concurrent@test.com",
+	})
+	update := users.SetupUpdate(logger.Logger{}, usernotifier.NewInMemory(), userStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), newValidator(t, users.Config{}))
+
+	reqs := []*users.UpdateReq{
+		{ID: usr.ID, FirstName: users.SetString("first")},
+		{ID: usr.ID, LastName: users.SetString("last")},
+	}
+	errs := make([]error, len(reqs))
+	var wg sync.WaitGroup
+	userStore.read.Add(len(reqs))
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(i int, req *users.UpdateReq) {
+			defer wg.Done()
+			_, errs[i] = update(context.Background(), req)
+		}(i, req)
+	}
+	wg.Wait()
+
+	// only one of the updates is stored, the other is rejected instead of overwriting it with the version it read
+	res, err := userStore.InMemory.Search(context.Background(), userStore.Query().ByID(usr.ID))
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, usr.Version+1, res[0].Version)
+	if errs[0] == nil {
+		require.ErrorIs(t, errs[1], userstore.ErrVersionConflict)
+		require.Equal(t, "first", res[0].FirstName)
+		require.Equal(t, "test", res[0].LastName)
+	} else {
+		require.ErrorIs(t, errs[0], userstore.ErrVersionConflict)
+		require.NoError(t, errs[1])
+		require.Equal(t, "test", res[0].FirstName)
+		require.Equal(t, "last", res[0].LastName)
+	}
+}
+
+func TestSetupUpdate_Version(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	usr := addValidUser(t, userStore, &users.User{
+		Email: "test-update-version@test.com",
+	})
+	update := users.SetupUpdate(logger.Logger{}, usernotifier.NewInMemory(), userStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), newValidator(t, users.Config{}))
+
+	res, err := update(context.Background(), &users.UpdateReq{ID: usr.ID, FirstName: users.SetString("first"), Version: usr.Version})
+	require.NoError(t, err)
+	require.Equal(t, usr.Version+1, res.User.Version)
+
+	_, err = update(context.Background(), &users.UpdateReq{ID: usr.ID, LastName: users.SetString("last"), Version: usr.Version})
+	require.ErrorIs(t, err, users.ErrPreconditionFailed)
+	found, err := userStore.Search(context.Background(), userStore.Query().ByID(usr.ID))
+	require.NoError(t, err)
+	require.Equal(t, "test", found[0].LastName)
+}
+
+func TestSetupUpdate_NotifyBefore(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	notifier := usernotifier.NewInMemory()
+	events := notifier.Listen()
+	usr := addValidUser(t, userStore, &users.User{
+		Email: "test-update-2@test.com",
+	})
+	update := users.SetupUpdate(logger.Logger{}, notifier, userStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), newValidator(t, users.Config{}))
+	ctx := users.WithRequestInfo(context.Background(), users.RequestInfo{Actor: "user:admin", RequestID: "req-1"})
+	_, err := update(ctx, &users.UpdateReq{
+		ID:    usr.ID,
+		Email: users.SetString("test-update-2-updated@test.com"),
+	})
+	require.NoError(t, err)
+
+	select {
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatal("didn't receive update event, time out after 3sec")
+	case evt := <-events:
+		require.Equal(t, "test-update-2@test.com", evt.Before.Email)
+		require.Equal(t, "test-update-2-updated@test.com", evt.After.Email)
+		require.Equal(t, "user:admin", evt.Origin.Actor)
+		require.Equal(t, "req-1", evt.Origin.RequestID)
+	}
+}
+
+func TestSetupUpdate_Attributes(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	notifier := usernotifier.NewInMemory()
+	events := notifier.Listen()
+	usr := addValidUser(t, userStore, &users.User{
+		Email:      "test-update-attributes@test.com",
+		Attributes: map[string]interface{}{"department": "sales", "level": float64(2)},
+	})
+	update := users.SetupUpdate(logger.Logger{}, notifier, userStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), newAttributeValidator(t))
+
+	_, err := update(context.Background(), &users.UpdateReq{ID: usr.ID, Attributes: users.SetAttributes(map[string]interface{}{"department": nil})})
+	require.Error(t, err)
+
+	res, err := update(context.Background(), &users.UpdateReq{ID: usr.ID, Attributes: users.SetAttributes(map[string]interface{}{"department": "legal", "level": nil})})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"department": "legal"}, res.User.Attributes)
+
+	select {
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatal("didn't receive update event, time out after 3sec")
+	case evt := <-events:
+		require.Equal(t, map[string]interface{}{"department": "sales", "level": float64(2)}, evt.Before.Attributes)
+		require.Equal(t, map[string]interface{}{"department": "legal"}, evt.After.Attributes)
+	}
+}
+
+func TestSetupUpdate_Phone(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	usr := addValidUser(t, userStore, &users.User{Email: "test-update-phone@test.com", Country: "BE"})
+	update := users.SetupUpdate(logger.Logger{}, usernotifier.NewInMemory(), userStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), newValidator(t, users.Config{}))
+
+	res, err := update(context.Background(), &users.UpdateReq{ID: usr.ID, Phone: users.SetString("0470 12 34 56")})
+	require.NoError(t, err)
+	require.Equal(t, "+32470123456", res.User.Phone)
+
+	res, err = update(context.Background(), &users.UpdateReq{ID: usr.ID, Phone: users.SetString("06 12 34 56 78"), Country: users.SetString("FR")})
+	require.NoError(t, err)
+	require.Equal(t, "+33612345678", res.User.Phone)
+	require.Equal(t, "FR", res.User.Country)
+
+	_, err = update(context.Background(), &users.UpdateReq{ID: usr.ID, Phone: users.SetString("0470")})
+	require.True(t, errors.Is(err, users.ErrInvalidUser))
+}
+
+func TestSetupUpdate_Clear(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	verifiedAt := time.Now()
+	usr := addValidUser(t, userStore, &users.User{
+		Email:           "test-update-clear@test.com",
+		Country:         "FR",
+		Phone:           "+33612345678",
+		PhoneVerifiedAt: &verifiedAt,
+		Attributes:      map[string]interface{}{"department": "sales"},
+	})
+	validator := newValidator(t, users.Config{})
+	require.NoError(t, validator.SetRules(users.Rules{Fields: map[string]users.FieldRule{
+		"first_name": {Required: true, Min: 2, Max: 20},
+		"nick_name":  {Min: 4, Max: 20},
+	}}))
+	schema, err := users.CompileAttributeSchema([]byte(`{"type": "object", "properties": {"department": {"type": "string"}, "level": {"type": "integer"}}}`))
+	require.NoError(t, err)
+	validator.SetAttributeSchema(schema)
+	update := users.SetupUpdate(logger.Logger{}, usernotifier.NewInMemory(), userStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), validator)
+
+	used, err := userStore.NickNameUsed(context.Background(), "tester")
+	require.NoError(t, err)
+	require.True(t, used)
+
+	res, err := update(context.Background(), &users.UpdateReq{
+		ID:         usr.ID,
+		NickName:   users.NullString(),
+		Country:    users.SetString(""),
+		LastName:   users.SetString(""),
+		Attributes: users.SetAttributes(map[string]interface{}{"level": 2}),
+	})
+	require.NoError(t, err)
+	require.Empty(t, res.User.NickName)
+	require.Empty(t, res.User.Country)
+	require.Empty(t, res.User.LastName)
+	require.Equal(t, "test", res.User.FirstName)
+	require.Equal(t, "+33612345678", res.User.Phone)
+	require.NotNil(t, res.User.PhoneVerifiedAt)
+	require.Equal(t, map[string]interface{}{"department": "sales", "level": float64(2)}, res.User.Attributes)
+
+	// the nickname is freed
+	used, err = userStore.NickNameUsed(context.Background(), "tester")
+	require.NoError(t, err)
+	require.False(t, used)
+
+	res, err = update(context.Background(), &users.UpdateReq{ID: usr.ID, Phone: users.NullString(), Attributes: users.NullAttributes()})
+	require.NoError(t, err)
+	require.Empty(t, res.User.Phone)
+	require.Nil(t, res.User.PhoneVerifiedAt)
+	require.Nil(t, res.User.Attributes)
+
+	// the validation runs against the resulting user
+	_, err = update(context.Background(), &users.UpdateReq{ID: usr.ID, FirstName: users.NullString()})
+	var verr *users.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, "first_name", verr.Violations[0].Field)
+	require.Equal(t, users.CodeRequired, verr.Violations[0].Code)
+
+	_, err = update(context.Background(), &users.UpdateReq{ID: usr.ID, RawPassword: users.NullString()})
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, "password", verr.Violations[0].Field)
+}
+
+func TestSetupUpdate_Password(t *testing.T) {
+	hasher := pwdhasher.NewBcryptWithCost(bcrypt.MinCost)
+	userStore := userstore.NewInMemory()
+	usr := addValidUser(t, userStore, &users.User{Email: "test-update-password@test.com"})
+	update := users.SetupUpdate(logger.Logger{}, usernotifier.NewInMemory(), userStore, hasher, newValidator(t, users.Config{}))
+	login := users.SetupLogin(logger.Logger{}, userStore, hasher, mfastore.NewInMemory(), mfastore.NewInMemory(), mfastore.NewInMemory(), users.Config{}, users.SystemClock)
+
+	res, err := update(context.Background(), &users.UpdateReq{ID: usr.ID, RawPassword: users.SetString("new-password")})
+	require.NoError(t, err)
+	require.NotEqual(t, "new-password", res.User.Password)
+
+	logged, err := login(context.Background(), &users.LoginReq{Email: "test-update-password@test.com", RawPassword: "new-password"})
+	require.NoError(t, err)
+	require.Equal(t, usr.ID, logged.User.ID)
+
+	// the hash is kept when the password isn't updated
+	_, err = update(context.Background(), &users.UpdateReq{ID: usr.ID, FirstName: users.SetString("updated")})
+	require.NoError(t, err)
+	_, err = login(context.Background(), &users.LoginReq{Email: "test-update-password@test.com", RawPassword: "new-password"})
+	require.NoError(t, err)
+}
+
+func TestSetupUpdate_PhoneChangeResetVerification(t *testing.T) {
+	userStore := userstore.NewInMemory()
+	verifiedAt := time.Now()
+	usr := addValidUser(t, userStore, &users.User{Email: "test-update-phone-reset@test.com", Phone: "+33612345678", PhoneVerifiedAt: &verifiedAt})
+	update := users.SetupUpdate(logger.Logger{}, usernotifier.NewInMemory(), userStore, pwdhasher.NewBcryptWithCost(bcrypt.MinCost), newValidator(t, users.Config{}))
+
+	res, err := update(context.Background(), &users.UpdateReq{ID: usr.ID, Phone: users.SetString("+33 6 12 34 56 78")})
+	require.NoError(t, err)
+	require.NotNil(t, res.User.PhoneVerifiedAt)
+
+	res, err = update(context.Background(), &users.UpdateReq{ID: usr.ID, Phone: users.SetString("+33 6 12 34 56 79")})
+	require.NoError(t, err)
+	require.Nil(t, res.User.PhoneVerifiedAt)
+}
+
+func TestUpdateReq_JSON(t *testing.T) {
+	var req users.UpdateReq
+	require.NoError(t, json.Unmarshal([]byte(`{"first_name": "Bob", "nick_name": null, "country": "", "attributes": {"level": null}}`), &req))
+	require.Equal(t, users.SetString("Bob"), req.FirstName)
+	require.Equal(t, users.NullString(), req.NickName)
+	require.Equal(t, users.SetString(""), req.Country)
+	require.False(t, req.LastName.Set)
+	require.Equal(t, users.SetAttributes(map[string]interface{}{"level": nil}), req.Attributes)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"attributes": null}`), &req))
+	require.Equal(t, users.NullAttributes(), req.Attributes)
+}
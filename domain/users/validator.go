@@ -1,55 +1,96 @@
 package users
 
 import (
-	"errors"
+	"fmt"
 	"strings"
 )
 
-func validateUser(usr *User) error {
-	if err := validateFirstName(usr.FirstName); err != nil {
-		return err
-	}
-	if err := validateLastName(usr.LastName); err != nil {
-		return err
-	}
-	if err := validateNickName(usr.NickName); err != nil {
-		return err
+// Violation codes which can be returned in a FieldViolation
+const (
+	CodeTooShort      = "too_short"
+	CodeTooLong       = "too_long"
+	CodeInvalidFormat = "invalid_format"
+	CodeDisposable    = "disposable"
+	CodeUnknown       = "unknown"
+	// CodeInvalidCharacter is returned for the control, invisible, bidi override or symbol characters
+	CodeInvalidCharacter = "invalid_character"
+	CodeScriptNotAllowed = "script_not_allowed"
+	CodeReserved         = "reserved"
+	CodeRequired         = "required"
+	CodeNotAllowed       = "not_allowed"
+)
+
+// FieldViolation describe why a field isn't valid
+type FieldViolation struct {
+	Field   string                 `json:"field"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// ValidationError collect all the violations found on a user, it wraps ErrInvalidUser
+type ValidationError struct {
+	Violations []FieldViolation `json:"violations"`
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Message)
 	}
-	if err := validateEmail(usr.Email); err != nil {
-		return err
+	return strings.Join(msgs, ", ")
+}
+
+// Unwrap allow to match the error with ErrInvalidUser
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidUser
+}
+
+// add will collect the violation if any
+func (e *ValidationError) add(v *FieldViolation) {
+	if v != nil {
+		e.Violations = append(e.Violations, *v)
 	}
-	return nil
 }
 
-func validateFirstName(firstName string) error {
-	if len(firstName) < 2 || len(firstName) > 20 {
-		return errors.New("firstname should have a len greater than 2 and less than 20")
+// errOrNil will return the ValidationError only if a violation has been found
+func (e *ValidationError) errOrNil() error {
+	if len(e.Violations) == 0 {
+		return nil
 	}
-	return nil
+	return e
 }
 
-func validateLastName(lastName string) error {
-	if len(lastName) < 4 || len(lastName) > 40 {
-		return errors.New("lastname should have a len greater than 4 and less than 40")
+func validateLength(field, name, value string, min, max int) *FieldViolation {
+	code := ""
+	switch {
+	case characterLen(value) < min:
+		code = CodeTooShort
+	case characterLen(value) > max:
+		code = CodeTooLong
+	default:
+		return nil
+	}
+	return &FieldViolation{
+		Field:   field,
+		Code:    code,
+		Message: fmt.Sprintf("%s should have a len greater than %d and less than %d", name, min, max),
+		Params:  map[string]interface{}{"min": min, "max": max},
 	}
-	return nil
 }
 
-func validateNickName(nickName string) error {
-	if len(nickName) < 4 || len(nickName) > 20 {
-		return errors.New("nickname should have a len greater than 4 and less than 20")
+func validateEmail(email string) *FieldViolation {
+	if _, err := ParseEmail(email); err != nil {
+		return &FieldViolation{Field: "email", Code: CodeInvalidFormat, Message: err.Error()}
 	}
 	return nil
 }
 
-func validateEmail(email string) error {
-	part := strings.Split(email, "@")
-	if len(part) != 2 {
-		return errors.New("an email should contains one '@'")
-	}
-	part = strings.Split(part[1], ".")
-	if len(part) != 2 {
-		return errors.New("an email should contains one '.' on host part")
+// validateCountry accept an empty country or a known ISO 3166-1 country
+func validateCountry(country string) *FieldViolation {
+	if _, ok := LookupCountry(country); country != "" && !ok {
+		return &FieldViolation{Field: "country", Code: CodeUnknown, Message: "country should be an ISO 3166-1 code or name"}
 	}
 	return nil
 }
@@ -0,0 +1,35 @@
+package users
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator_ValidateUser(t *testing.T) {
+	v, err := NewValidator(&EmailPolicy{}, &NamePolicy{}, DefaultRules())
+	require.NoError(t, err)
+	require.NoError(t, v.validateUser(&User{FirstName: "bob", LastName: "smith", NickName: "bobby", Email: "bob@test.com"}))
+
+	err = v.validateUser(&User{FirstName: "b", LastName: "smith", NickName: "bobbybobbybobbybobbybobby", Email: "bob"})
+	require.True(t, errors.Is(err, ErrInvalidUser))
+
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, []FieldViolation{
+		{
+			Field:   "first_name",
+			Code:    CodeTooShort,
+			Message: "firstname should have a len greater than 2 and less than 20",
+			Params:  map[string]interface{}{"min": 2, "max": 20},
+		},
+		{
+			Field:   "nick_name",
+			Code:    CodeTooLong,
+			Message: "nickname should have a len greater than 4 and less than 20",
+			Params:  map[string]interface{}{"min": 4, "max": 20},
+		},
+		{Field: "email", Code: CodeInvalidFormat, Message: "an email should contains one '@'"},
+	}, verr.Violations)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-users-example/domain/users"
+)
+
+// importContentTypes are the content types of the import formats, the format of a file is found by its extension
+var importContentTypes = map[string]string{
+	"csv":    "text/csv",
+	"ndjson": "application/x-ndjson",
+	"jsonl":  "application/x-ndjson",
+}
+
+// importClient is a client of the import endpoints of a running server, the users are stored by the server
+type importClient struct {
+	server string
+	apiKey string
+	client *http.Client
+}
+
+// runImportCommand will import the users of a csv or ndjson file (`-` for stdin) through the server and wait for the
+// end of the job. The errors of the rows are printed, the exit code is 1 if the job failed or a row was refused
+func runImportCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	server := fs.String("server", "http://localhost:8080", "url of the server")
+	apiKey := fs.String("api-key", os.Getenv("USERS_API_KEY"), "api key with the users:write scope, USERS_API_KEY by default")
+	format := fs.String("format", "", "csv or ndjson, found by the extension of the file if empty")
+	dryRun := fs.Bool("dry-run", false, "validate the rows without creating the users")
+	resume := fs.String("resume", "", "id of a failed job to resume instead of importing a file")
+	poll := fs.Duration("poll", time.Second, "interval between two checks of the job progress")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: go-users-example import [flags] <file|->")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if (*resume == "") == (fs.NArg() != 1) {
+		fs.Usage()
+		return 2
+	}
+
+	c := &importClient{server: strings.TrimSuffix(*server, "/"), apiKey: *apiKey, client: &http.Client{}}
+	var (
+		location string
+		err      error
+	)
+	if *resume != "" {
+		location, err = c.resume(*resume)
+	} else {
+		location, err = c.start(fs.Arg(0), *format, *dryRun)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "import:", err)
+		return 1
+	}
+
+	job, err := c.wait(location, *poll, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, "import:", err)
+		return 1
+	}
+	for _, rerr := range job.Errors {
+		fmt.Fprintf(stdout, "row %d: %s\n", rerr.Row, rerr.Message)
+	}
+	if job.Failed > len(job.Errors) {
+		fmt.Fprintf(stdout, "%d more rows refused\n", job.Failed-len(job.Errors))
+	}
+	fmt.Fprintf(stderr, "job %s %s: %d rows, %d created, %d refused\n", job.ID, job.Status, job.Processed, job.Created, job.Failed)
+	if job.Status == users.ImportFailed {
+		fmt.Fprintf(stderr, "import: %s, resume it with -resume %s\n", job.Error, job.ID)
+		return 1
+	}
+	if job.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// start will stream the rows of the file to the server and return the path of the job
+func (c *importClient) start(path, format string, dryRun bool) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	contentType, ok := importContentTypes[strings.ToLower(format)]
+	if !ok {
+		return "", fmt.Errorf("unknown format %q, it should be csv or ndjson", format)
+	}
+	var rows io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		rows = f
+	}
+
+	target := c.server + "/v1/users:import"
+	if dryRun {
+		target += "?dry_run=true"
+	}
+	req, err := http.NewRequest(http.MethodPost, target, rows)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.accepted(req)
+}
+
+// resume will resume the failed job and return its path
+func (c *importClient) resume(id string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, c.server+"/v1/users:import/"+url.PathEscape(id)+":resume", nil)
+	if err != nil {
+		return "", err
+	}
+	return c.accepted(req)
+}
+
+func (c *importClient) accepted(req *http.Request) (string, error) {
+	var res struct {
+		Job *users.ImportJob `json:"job"`
+	}
+	location, err := c.do(req, http.StatusAccepted, &res)
+	if err != nil {
+		return "", err
+	}
+	return location, nil
+}
+
+// wait will poll the job until it is completed or failed, its progress is printed on each change
+func (c *importClient) wait(location string, poll time.Duration, progress io.Writer) (*users.ImportJob, error) {
+	processed := -1
+	for {
+		req, err := http.NewRequest(http.MethodGet, c.server+location, nil)
+		if err != nil {
+			return nil, err
+		}
+		var res users.GetImportJobResp
+		if _, err := c.do(req, http.StatusOK, &res); err != nil {
+			return nil, err
+		}
+		job := res.Job
+		if job.Status == users.ImportCompleted || job.Status == users.ImportFailed {
+			return job, nil
+		}
+		if job.Processed != processed {
+			processed = job.Processed
+			fmt.Fprintf(progress, "job %s %s: %d rows processed\n", job.ID, job.Status, job.Processed)
+		}
+		time.Sleep(poll)
+	}
+}
+
+// do will send the request with the api key and decode the response, a problem is returned as an error
+func (c *importClient) do(req *http.Request, status int, v interface{}) (string, error) {
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		var p struct {
+			Title      string                 `json:"title"`
+			Detail     string                 `json:"detail"`
+			Violations []users.FieldViolation `json:"violations"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&p); err != nil || p.Title == "" {
+			return "", fmt.Errorf("unexpected response: %s", resp.Status)
+		}
+		msg := p.Title + ": " + p.Detail
+		for _, v := range p.Violations {
+			msg += "\n  " + v.Message
+		}
+		return "", errors.New(msg)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", fmt.Errorf("can't decode response: %w", err)
+	}
+	return resp.Header.Get("Location"), nil
+}
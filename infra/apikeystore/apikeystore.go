@@ -0,0 +1,8 @@
+package apikeystore
+
+import (
+	"errors"
+)
+
+// ErrNotFound is returned if the api key isn't found for the user
+var ErrNotFound = errors.New("api key not found")
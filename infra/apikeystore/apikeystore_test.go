@@ -0,0 +1,107 @@
+package apikeystore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+)
+
+type apiKeyStore interface {
+	users.APIKeyAdder
+	users.APIKeyLister
+	users.APIKeyRevoker
+	users.APIKeyFinder
+	users.UserDataEraser
+}
+
+func runTestSuite(t *testing.T, store apiKeyStore) {
+	runTestAdd(t, store)
+	runTestRevoke(t, store)
+	runTestErase(t, store)
+}
+
+func runTestErase(t *testing.T, store apiKeyStore) {
+	t.Run("erase api keys of a user", func(t *testing.T) {
+		_, _ = store.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-erase-1", Hash: "hash-erase-1"})
+		_, _ = store.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-erase-1", Hash: "hash-erase-2"})
+
+		n, err := store.EraseUserData(context.Background(), "test-erase-1")
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		keys, _ := store.ListAPIKeys(context.Background(), "test-erase-1")
+		require.Empty(t, keys)
+		found, _ := store.FindAPIKey(context.Background(), "hash-erase-1")
+		require.Nil(t, found)
+	})
+}
+
+func runTestAdd(t *testing.T, store apiKeyStore) {
+	t.Run("add and find api key", func(t *testing.T) {
+		key, err := store.AddAPIKey(context.Background(), &users.APIKey{
+			UserID: "test-add-1",
+			Name:   "ci",
+			Hash:   "hash-add-1",
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, key.ID)
+
+		found, err := store.FindAPIKey(context.Background(), "hash-add-1")
+		require.NoError(t, err)
+		require.Equal(t, key.ID, found.ID)
+
+		now := time.Now()
+		require.NoError(t, store.TouchAPIKey(context.Background(), found, now))
+		found, _ = store.FindAPIKey(context.Background(), "hash-add-1")
+		require.True(t, now.Equal(*found.LastUsedAt))
+	})
+	t.Run("find unknown api key", func(t *testing.T) {
+		found, err := store.FindAPIKey(context.Background(), "unknown")
+		require.NoError(t, err)
+		require.Nil(t, found)
+	})
+	t.Run("list api keys of a user", func(t *testing.T) {
+		_, _ = store.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-list-1", Hash: "hash-list-1"})
+		_, _ = store.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-list-1", Hash: "hash-list-2"})
+		_, _ = store.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-list-2", Hash: "hash-list-3"})
+
+		keys, err := store.ListAPIKeys(context.Background(), "test-list-1")
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+	})
+}
+
+func runTestRevoke(t *testing.T, store apiKeyStore) {
+	t.Run("revoke api key", func(t *testing.T) {
+		key, _ := store.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-revoke-1", Hash: "hash-revoke-1"})
+		revoked, err := store.RevokeAPIKey(context.Background(), &users.APIKey{ID: key.ID, UserID: "test-revoke-1"}, time.Now())
+		require.NoError(t, err)
+		require.NotNil(t, revoked.RevokedAt)
+
+		found, _ := store.FindAPIKey(context.Background(), "hash-revoke-1")
+		require.NotNil(t, found.RevokedAt)
+	})
+	t.Run("revoke api key of another user", func(t *testing.T) {
+		key, _ := store.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-revoke-2", Hash: "hash-revoke-2"})
+		_, err := store.RevokeAPIKey(context.Background(), &users.APIKey{ID: key.ID, UserID: "other"}, time.Now())
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrNotFound))
+	})
+	t.Run("revoke all api keys of a user", func(t *testing.T) {
+		_, _ = store.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-revoke-3", Hash: "hash-revoke-3"})
+		_, _ = store.AddAPIKey(context.Background(), &users.APIKey{UserID: "test-revoke-3", Hash: "hash-revoke-4"})
+
+		revoked, err := store.RevokeUserAPIKeys(context.Background(), "test-revoke-3", time.Now())
+		require.NoError(t, err)
+		require.Len(t, revoked, 2)
+
+		revoked, err = store.RevokeUserAPIKeys(context.Background(), "test-revoke-3", time.Now())
+		require.NoError(t, err)
+		require.Empty(t, revoked)
+	})
+}
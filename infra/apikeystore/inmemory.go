@@ -0,0 +1,129 @@
+package apikeystore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"go-users-example/domain/users"
+)
+
+// InMemory is an api key repo implementation which will store inmemory the api keys.
+type InMemory struct {
+	mu       sync.RWMutex
+	dataByID map[string]*users.APIKey
+	idByHash map[string]string
+}
+
+// NewInMemory will initialise the store
+func NewInMemory() *InMemory {
+	return &InMemory{dataByID: make(map[string]*users.APIKey), idByHash: make(map[string]string)}
+}
+
+// AddAPIKey implements users.APIKeyAdder
+func (i *InMemory) AddAPIKey(ctx context.Context, key *users.APIKey) (*users.APIKey, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	key.ID = uuid.NewV4().String()
+	stored := *key
+	i.dataByID[key.ID] = &stored
+	i.idByHash[key.Hash] = key.ID
+
+	return key, nil
+}
+
+// ListAPIKeys implements users.APIKeyLister, the keys are sorted by creation date
+func (i *InMemory) ListAPIKeys(ctx context.Context, userID string) ([]*users.APIKey, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var res []*users.APIKey
+	for _, key := range i.dataByID {
+		if key.UserID == userID {
+			k := *key
+			res = append(res, &k)
+		}
+	}
+	sort.Slice(res, func(a, b int) bool { return res[a].CreatedAt.Before(res[b].CreatedAt) })
+	return res, nil
+}
+
+// RevokeAPIKey implements users.APIKeyRevoker
+func (i *InMemory) RevokeAPIKey(ctx context.Context, key *users.APIKey, at time.Time) (*users.APIKey, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	stored, ok := i.dataByID[key.ID]
+	if !ok || stored.UserID != key.UserID {
+		return nil, ErrNotFound
+	}
+	if stored.RevokedAt == nil {
+		stored.RevokedAt = &at
+	}
+	k := *stored
+	return &k, nil
+}
+
+// RevokeUserAPIKeys implements users.APIKeyRevoker, only the keys not already revoked are returned
+func (i *InMemory) RevokeUserAPIKeys(ctx context.Context, userID string, at time.Time) ([]*users.APIKey, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var res []*users.APIKey
+	for _, stored := range i.dataByID {
+		if stored.UserID != userID || stored.RevokedAt != nil {
+			continue
+		}
+		stored.RevokedAt = &at
+		k := *stored
+		res = append(res, &k)
+	}
+	return res, nil
+}
+
+// FindAPIKey implements users.APIKeyFinder
+func (i *InMemory) FindAPIKey(ctx context.Context, hash string) (*users.APIKey, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	id, ok := i.idByHash[hash]
+	if !ok {
+		return nil, nil
+	}
+	k := *i.dataByID[id]
+	return &k, nil
+}
+
+// TouchAPIKey implements users.APIKeyFinder
+func (i *InMemory) TouchAPIKey(ctx context.Context, key *users.APIKey, at time.Time) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	stored, ok := i.dataByID[key.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.LastUsedAt = &at
+	return nil
+}
+
+// EraseUserData will delete all the api keys of the user. implements users.UserDataEraser
+func (i *InMemory) EraseUserData(ctx context.Context, userID string) (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	n := 0
+	for id, key := range i.dataByID {
+		if key.UserID != userID {
+			continue
+		}
+		delete(i.idByHash, key.Hash)
+		delete(i.dataByID, id)
+		n++
+	}
+	return n, nil
+}
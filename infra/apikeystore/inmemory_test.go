@@ -0,0 +1,7 @@
+package apikeystore
+
+import "testing"
+
+func TestInMemory(t *testing.T) {
+	runTestSuite(t, NewInMemory())
+}
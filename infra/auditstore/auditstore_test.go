@@ -0,0 +1,69 @@
+package auditstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+)
+
+type auditStore interface {
+	users.AuditAppender
+	users.AuditLister
+	users.UserDataEraser
+}
+
+func runTestSuite(t *testing.T, store auditStore) {
+	t.Run("entries are chained", func(t *testing.T) {
+		first, err := store.AppendAudit(context.Background(), &users.AuditEntry{UserID: "test-audit-1", Op: users.CreateOp})
+		require.NoError(t, err)
+		second, err := store.AppendAudit(context.Background(), &users.AuditEntry{UserID: "test-audit-2", Op: users.CreateOp})
+		require.NoError(t, err)
+
+		require.Equal(t, first.Seq+1, second.Seq)
+		require.Equal(t, first.Hash, second.PrevHash)
+		require.Equal(t, second.ComputeHash(), second.Hash)
+	})
+	t.Run("list entries of a user", func(t *testing.T) {
+		_, _ = store.AppendAudit(context.Background(), &users.AuditEntry{UserID: "test-audit-3", Op: users.CreateOp})
+		_, _ = store.AppendAudit(context.Background(), &users.AuditEntry{UserID: "test-audit-3", Op: users.UpdateOp})
+
+		entries, err := store.ListUserAudit(context.Background(), "test-audit-3")
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, users.CreateOp, entries[0].Op)
+		require.Equal(t, users.UpdateOp, entries[1].Op)
+
+		all, err := store.ListAudit(context.Background())
+		require.NoError(t, err)
+		require.Greater(t, len(all), 2)
+	})
+	t.Run("returned entries can't alter the log", func(t *testing.T) {
+		entries, _ := store.ListUserAudit(context.Background(), "test-audit-3")
+		entries[0].Actor = "tampered"
+
+		entries, _ = store.ListUserAudit(context.Background(), "test-audit-3")
+		require.NotEqual(t, "tampered", entries[0].Actor)
+	})
+	t.Run("erase personal data of a user", func(t *testing.T) {
+		entry, _ := store.AppendAudit(context.Background(), &users.AuditEntry{
+			UserID:   "test-audit-4",
+			Op:       users.UpdateOp,
+			SourceIP: "10.0.0.1",
+			Changes:  []users.FieldChange{{Field: "email", Before: "before@test.com", After: "after@test.com"}},
+		})
+
+		n, err := store.EraseUserData(context.Background(), "test-audit-4")
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+
+		entries, _ := store.ListUserAudit(context.Background(), "test-audit-4")
+		require.True(t, entries[0].Erased)
+		require.Empty(t, entries[0].SourceIP)
+		require.Equal(t, []users.FieldChange{{Field: "email"}}, entries[0].Changes)
+		require.Equal(t, entry.Hash, entries[0].Hash)
+		require.Equal(t, entries[0].ComputeHash(), entries[0].Hash)
+	})
+}
@@ -0,0 +1,110 @@
+package auditstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-users-example/domain/users"
+)
+
+// InMemory is an append only audit log implementation which will store inmemory the entries.
+// The only allowed modification is the erasure of the personal data of an entry.
+type InMemory struct {
+	mu      sync.RWMutex
+	entries []users.AuditEntry
+	// erasedUsers are the users whose data has been erased, their entries appended later (ex: the changes notified
+	// before the erasure) are erased on arrival
+	erasedUsers map[string]bool
+}
+
+// NewInMemory will initialise the store
+func NewInMemory() *InMemory {
+	return &InMemory{erasedUsers: make(map[string]bool)}
+}
+
+// AppendAudit implements users.AuditAppender
+func (i *InMemory) AppendAudit(ctx context.Context, entry *users.AuditEntry) (*users.AuditEntry, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.append(entry), nil
+}
+
+func (i *InMemory) append(entry *users.AuditEntry) *users.AuditEntry {
+	entry.Seq = uint64(len(i.entries) + 1)
+	entry.PrevHash = ""
+	if len(i.entries) > 0 {
+		entry.PrevHash = i.entries[len(i.entries)-1].Hash
+	}
+	entry.Hash = entry.ComputeHash()
+	if i.erasedUsers[entry.UserID] {
+		entry.Changes = append([]users.FieldChange(nil), entry.Changes...)
+		eraseEntry(entry)
+	}
+
+	stored := *entry
+	stored.Changes = append([]users.FieldChange(nil), entry.Changes...)
+	i.entries = append(i.entries, stored)
+
+	return entry
+}
+
+// ListAudit implements users.AuditLister
+func (i *InMemory) ListAudit(ctx context.Context) ([]*users.AuditEntry, error) {
+	return i.list(func(*users.AuditEntry) bool { return true }), nil
+}
+
+// ListUserAudit implements users.AuditLister
+func (i *InMemory) ListUserAudit(ctx context.Context, userID string) ([]*users.AuditEntry, error) {
+	return i.list(func(e *users.AuditEntry) bool { return e.UserID == userID }), nil
+}
+
+func (i *InMemory) list(match func(*users.AuditEntry) bool) []*users.AuditEntry {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var res []*users.AuditEntry
+	for idx := range i.entries {
+		if !match(&i.entries[idx]) {
+			continue
+		}
+		e := i.entries[idx]
+		e.Changes = append([]users.FieldChange(nil), e.Changes...)
+		res = append(res, &e)
+	}
+	return res
+}
+
+// EraseUserData will erase the personal data of the entries of the user and record the erasure, the chain stays
+// verifiable. implements users.UserDataEraser
+func (i *InMemory) EraseUserData(ctx context.Context, userID string) (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	n := 0
+	for idx := range i.entries {
+		e := &i.entries[idx]
+		if e.UserID != userID || e.Erased {
+			continue
+		}
+		eraseEntry(e)
+		n++
+	}
+	i.erasedUsers[userID] = true
+
+	info := users.RequestInfoFromContext(ctx)
+	erasure := &users.AuditEntry{UserID: userID, Time: time.Now().UTC(), Op: users.EraseOp, Actor: info.Actor, RequestID: info.RequestID}
+	erasure.DataDigest = erasure.ComputeDataDigest()
+	i.append(erasure)
+	return n, nil
+}
+
+func eraseEntry(e *users.AuditEntry) {
+	e.SourceIP = ""
+	for c := range e.Changes {
+		e.Changes[c].Before = ""
+		e.Changes[c].After = ""
+	}
+	e.Erased = true
+}
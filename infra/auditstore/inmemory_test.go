@@ -0,0 +1,7 @@
+package auditstore
+
+import "testing"
+
+func TestInMemory(t *testing.T) {
+	runTestSuite(t, NewInMemory())
+}
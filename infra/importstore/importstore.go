@@ -0,0 +1,8 @@
+package importstore
+
+import (
+	"errors"
+)
+
+// ErrNotFound is returned if the rows of an import job are unknown or have been removed
+var ErrNotFound = errors.New("import job not found")
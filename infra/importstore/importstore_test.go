@@ -0,0 +1,87 @@
+package importstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+)
+
+func runTestSuite(t *testing.T, store users.ImportJobStore) {
+	runTestJob(t, store)
+	runTestSource(t, store)
+}
+
+func runTestJob(t *testing.T, store users.ImportJobStore) {
+	t.Run("get unknown job", func(t *testing.T) {
+		job, err := store.GetImportJob(context.Background(), "unknown")
+		require.NoError(t, err)
+		require.Nil(t, job)
+	})
+	t.Run("add, save and get job", func(t *testing.T) {
+		job, err := store.AddImportJob(context.Background(), &users.ImportJob{
+			Format: users.ImportCSV,
+			Status: users.ImportPending,
+			Errors: []users.ImportRowError{},
+		}, strings.NewReader("email\n"))
+		require.NoError(t, err)
+		require.NotEmpty(t, job.ID)
+
+		job.Status, job.Processed = users.ImportRunning, 2
+		job.Errors = append(job.Errors, users.ImportRowError{Row: 1, Message: "invalid"})
+		require.NoError(t, store.SaveImportJob(context.Background(), job))
+		job.Errors[0].Message = "changed after save"
+
+		stored, err := store.GetImportJob(context.Background(), job.ID)
+		require.NoError(t, err)
+		require.Equal(t, users.ImportRunning, stored.Status)
+		require.Equal(t, 2, stored.Processed)
+		require.Equal(t, []users.ImportRowError{{Row: 1, Message: "invalid"}}, stored.Errors)
+	})
+	t.Run("save unknown job", func(t *testing.T) {
+		err := store.SaveImportJob(context.Background(), &users.ImportJob{ID: "unknown"})
+		require.True(t, errors.Is(err, ErrNotFound))
+		_, err = store.TransitionImportJob(context.Background(), &users.ImportJob{ID: "unknown"}, users.ImportFailed)
+		require.True(t, errors.Is(err, ErrNotFound))
+	})
+	t.Run("transition job from its status", func(t *testing.T) {
+		job, err := store.AddImportJob(context.Background(), &users.ImportJob{Format: users.ImportCSV, Status: users.ImportFailed}, strings.NewReader("email\n"))
+		require.NoError(t, err)
+
+		job.Status = users.ImportPending
+		ok, err := store.TransitionImportJob(context.Background(), job, users.ImportFailed)
+		require.NoError(t, err)
+		require.True(t, ok)
+		ok, err = store.TransitionImportJob(context.Background(), job, users.ImportFailed)
+		require.NoError(t, err)
+		require.False(t, ok, "the job isn't failed anymore")
+
+		stored, err := store.GetImportJob(context.Background(), job.ID)
+		require.NoError(t, err)
+		require.Equal(t, users.ImportPending, stored.Status)
+	})
+}
+
+func runTestSource(t *testing.T, store users.ImportJobStore) {
+	t.Run("open and remove rows", func(t *testing.T) {
+		job, err := store.AddImportJob(context.Background(), &users.ImportJob{Format: users.ImportNDJSON}, strings.NewReader("{}\n{}\n"))
+		require.NoError(t, err)
+
+		for n := 0; n < 2; n++ {
+			source, err := store.OpenImportSource(context.Background(), job.ID)
+			require.NoError(t, err)
+			rows, _ := io.ReadAll(source)
+			require.NoError(t, source.Close())
+			require.Equal(t, "{}\n{}\n", string(rows), "the rows are read from their start")
+		}
+
+		require.NoError(t, store.RemoveImportSource(context.Background(), job.ID))
+		_, err = store.OpenImportSource(context.Background(), job.ID)
+		require.True(t, errors.Is(err, ErrNotFound))
+	})
+}
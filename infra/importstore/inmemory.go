@@ -0,0 +1,109 @@
+package importstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/satori/go.uuid"
+
+	"go-users-example/domain/users"
+)
+
+// InMemory is an import job repo implementation which will store inmemory the jobs and their rows.
+type InMemory struct {
+	mu          sync.Mutex
+	jobByID     map[string]users.ImportJob
+	sourceByJob map[string][]byte
+}
+
+// NewInMemory will initialise the store
+func NewInMemory() *InMemory {
+	return &InMemory{jobByID: make(map[string]users.ImportJob), sourceByJob: make(map[string][]byte)}
+}
+
+// AddImportJob implements users.ImportJobStore
+func (i *InMemory) AddImportJob(ctx context.Context, job *users.ImportJob, source io.Reader) (*users.ImportJob, error) {
+	rows, err := io.ReadAll(source)
+	if err != nil {
+		return nil, fmt.Errorf("can't read rows: %w", err)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	job.ID = uuid.NewV4().String()
+	i.jobByID[job.ID] = copyJob(job)
+	i.sourceByJob[job.ID] = rows
+	return job, nil
+}
+
+// GetImportJob implements users.ImportJobStore
+func (i *InMemory) GetImportJob(ctx context.Context, id string) (*users.ImportJob, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	job, ok := i.jobByID[id]
+	if !ok {
+		return nil, nil
+	}
+	job = copyJob(&job)
+	return &job, nil
+}
+
+// SaveImportJob implements users.ImportJobStore
+func (i *InMemory) SaveImportJob(ctx context.Context, job *users.ImportJob) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.jobByID[job.ID]; !ok {
+		return ErrNotFound
+	}
+	i.jobByID[job.ID] = copyJob(job)
+	return nil
+}
+
+// TransitionImportJob implements users.ImportJobStore
+func (i *InMemory) TransitionImportJob(ctx context.Context, job *users.ImportJob, from users.ImportStatus) (bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	stored, ok := i.jobByID[job.ID]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if stored.Status != from {
+		return false, nil
+	}
+	i.jobByID[job.ID] = copyJob(job)
+	return true, nil
+}
+
+// OpenImportSource implements users.ImportJobStore
+func (i *InMemory) OpenImportSource(ctx context.Context, id string) (io.ReadCloser, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	rows, ok := i.sourceByJob[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(rows)), nil
+}
+
+// RemoveImportSource implements users.ImportJobStore
+func (i *InMemory) RemoveImportSource(ctx context.Context, id string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.sourceByJob, id)
+	return nil
+}
+
+func copyJob(job *users.ImportJob) users.ImportJob {
+	stored := *job
+	stored.Errors = append([]users.ImportRowError(nil), job.Errors...)
+	return stored
+}
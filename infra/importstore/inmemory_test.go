@@ -0,0 +1,7 @@
+package importstore
+
+import "testing"
+
+func TestInMemory(t *testing.T) {
+	runTestSuite(t, NewInMemory())
+}
@@ -0,0 +1,149 @@
+package mfastore
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"go-users-example/domain/users"
+)
+
+// InMemory is a mfa repo implementation which will store inmemory the second factors, login challenges and login attempts.
+type InMemory struct {
+	mu            sync.Mutex
+	mfaByUserID   map[string]users.MFA
+	challengeByID map[string]users.Challenge
+	attemptsByKey map[string][]time.Time
+}
+
+// NewInMemory will initialise the store
+func NewInMemory() *InMemory {
+	return &InMemory{
+		mfaByUserID:   make(map[string]users.MFA),
+		challengeByID: make(map[string]users.Challenge),
+		attemptsByKey: make(map[string][]time.Time),
+	}
+}
+
+// GetMFA implements users.MFAStore
+func (i *InMemory) GetMFA(ctx context.Context, userID string) (*users.MFA, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	mfa, ok := i.mfaByUserID[userID]
+	if !ok {
+		return nil, nil
+	}
+	mfa.RecoveryCodes = append([]string(nil), mfa.RecoveryCodes...)
+	return &mfa, nil
+}
+
+// SaveMFA implements users.MFAStore
+func (i *InMemory) SaveMFA(ctx context.Context, mfa *users.MFA) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	stored := *mfa
+	stored.RecoveryCodes = append([]string(nil), mfa.RecoveryCodes...)
+	i.mfaByUserID[mfa.UserID] = stored
+	return nil
+}
+
+// SwapMFA implements users.MFAStore
+func (i *InMemory) SwapMFA(ctx context.Context, old, mfa *users.MFA) (bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	current, ok := i.mfaByUserID[mfa.UserID]
+	if !ok || !reflect.DeepEqual(current, *old) {
+		return false, nil
+	}
+	stored := *mfa
+	stored.RecoveryCodes = append([]string(nil), mfa.RecoveryCodes...)
+	i.mfaByUserID[mfa.UserID] = stored
+	return true, nil
+}
+
+// AddChallenge implements users.ChallengeStore
+func (i *InMemory) AddChallenge(ctx context.Context, challenge *users.Challenge) (*users.Challenge, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	challenge.ID = uuid.NewV4().String()
+	i.challengeByID[challenge.ID] = *challenge
+	return challenge, nil
+}
+
+// TakeChallenge implements users.ChallengeStore
+func (i *InMemory) TakeChallenge(ctx context.Context, id string) (*users.Challenge, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	challenge, ok := i.challengeByID[id]
+	if !ok {
+		return nil, ErrChallengeNotFound
+	}
+	delete(i.challengeByID, id)
+	return &challenge, nil
+}
+
+// AddLoginAttempt implements users.LoginAttemptStore, the attempts made before since are dropped
+func (i *InMemory) AddLoginAttempt(ctx context.Context, key string, at, since time.Time, max int) (bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var recent []time.Time
+	for _, attempt := range i.attemptsByKey[key] {
+		if !attempt.Before(since) {
+			recent = append(recent, attempt)
+		}
+	}
+	if len(recent) >= max {
+		i.attemptsByKey[key] = recent
+		return false, nil
+	}
+	i.attemptsByKey[key] = append(recent, at)
+	return true, nil
+}
+
+// RemoveLoginAttempt implements users.LoginAttemptStore
+func (i *InMemory) RemoveLoginAttempt(ctx context.Context, key string, at time.Time) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	attempts := i.attemptsByKey[key]
+	for n, attempt := range attempts {
+		if attempt.Equal(at) {
+			attempts = append(attempts[:n:n], attempts[n+1:]...)
+			break
+		}
+	}
+	if len(attempts) == 0 {
+		delete(i.attemptsByKey, key)
+		return nil
+	}
+	i.attemptsByKey[key] = attempts
+	return nil
+}
+
+// EraseUserData will delete the second factor and the pending challenges of the user. implements users.UserDataEraser
+func (i *InMemory) EraseUserData(ctx context.Context, userID string) (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	n := 0
+	if _, ok := i.mfaByUserID[userID]; ok {
+		delete(i.mfaByUserID, userID)
+		n++
+	}
+	for id, challenge := range i.challengeByID {
+		if challenge.UserID == userID {
+			delete(i.challengeByID, id)
+			n++
+		}
+	}
+	return n, nil
+}
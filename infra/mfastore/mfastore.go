@@ -0,0 +1,8 @@
+package mfastore
+
+import (
+	"errors"
+)
+
+// ErrChallengeNotFound is returned if the challenge is unknown or has already been used
+var ErrChallengeNotFound = errors.New("challenge not found")
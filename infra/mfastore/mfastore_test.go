@@ -0,0 +1,129 @@
+package mfastore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+)
+
+type mfaStore interface {
+	users.MFAStore
+	users.ChallengeStore
+	users.LoginAttemptStore
+	users.UserDataEraser
+}
+
+func runTestSuite(t *testing.T, store mfaStore) {
+	runTestMFA(t, store)
+	runTestChallenge(t, store)
+	runTestLoginAttempts(t, store)
+	runTestErase(t, store)
+}
+
+func runTestLoginAttempts(t *testing.T, store mfaStore) {
+	t.Run("attempts are limited during the window", func(t *testing.T) {
+		now := time.Now()
+		for i := 0; i < 3; i++ {
+			ok, err := store.AddLoginAttempt(context.Background(), "test-attempt-1", now.Add(time.Duration(i)*time.Minute), now.Add(-time.Hour), 3)
+			require.NoError(t, err)
+			require.True(t, ok)
+		}
+		ok, err := store.AddLoginAttempt(context.Background(), "test-attempt-1", now.Add(3*time.Minute), now.Add(-time.Hour), 3)
+		require.NoError(t, err)
+		require.False(t, ok)
+		ok, _ = store.AddLoginAttempt(context.Background(), "test-attempt-2", now, now.Add(-time.Hour), 3)
+		require.True(t, ok, "the keys are limited separately")
+
+		// the first attempt is out of the window
+		ok, err = store.AddLoginAttempt(context.Background(), "test-attempt-1", now.Add(4*time.Minute), now.Add(time.Second), 3)
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+	t.Run("removed attempt isn't counted", func(t *testing.T) {
+		now := time.Now()
+		_, _ = store.AddLoginAttempt(context.Background(), "test-attempt-3", now, now.Add(-time.Hour), 1)
+		require.NoError(t, store.RemoveLoginAttempt(context.Background(), "test-attempt-3", now))
+		ok, err := store.AddLoginAttempt(context.Background(), "test-attempt-3", now, now.Add(-time.Hour), 1)
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+}
+
+func runTestErase(t *testing.T, store mfaStore) {
+	t.Run("erase mfa and challenges of a user", func(t *testing.T) {
+		_ = store.SaveMFA(context.Background(), &users.MFA{UserID: "test-erase-1", Secret: "secret"})
+		challenge, _ := store.AddChallenge(context.Background(), &users.Challenge{UserID: "test-erase-1"})
+
+		n, err := store.EraseUserData(context.Background(), "test-erase-1")
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		mfa, _ := store.GetMFA(context.Background(), "test-erase-1")
+		require.Nil(t, mfa)
+		_, err = store.TakeChallenge(context.Background(), challenge.ID)
+		require.True(t, errors.Is(err, ErrChallengeNotFound))
+	})
+}
+
+func runTestMFA(t *testing.T, store mfaStore) {
+	t.Run("get unknown mfa", func(t *testing.T) {
+		mfa, err := store.GetMFA(context.Background(), "unknown")
+		require.NoError(t, err)
+		require.Nil(t, mfa)
+	})
+	t.Run("save and get mfa", func(t *testing.T) {
+		require.NoError(t, store.SaveMFA(context.Background(), &users.MFA{
+			UserID:        "test-mfa-1",
+			Secret:        "secret",
+			RecoveryCodes: []string{"a", "b"},
+		}))
+		mfa, err := store.GetMFA(context.Background(), "test-mfa-1")
+		require.NoError(t, err)
+		require.Equal(t, "secret", mfa.Secret)
+		require.Equal(t, []string{"a", "b"}, mfa.RecoveryCodes)
+		require.False(t, mfa.Confirmed)
+	})
+	t.Run("swap mfa only once", func(t *testing.T) {
+		_ = store.SaveMFA(context.Background(), &users.MFA{UserID: "test-mfa-2", Secret: "secret", RecoveryCodes: []string{"a"}})
+		old, _ := store.GetMFA(context.Background(), "test-mfa-2")
+
+		next := *old
+		next.LastStep = 42
+		swapped, err := store.SwapMFA(context.Background(), old, &next)
+		require.NoError(t, err)
+		require.True(t, swapped)
+
+		replayed := *old
+		replayed.LastStep = 43
+		swapped, err = store.SwapMFA(context.Background(), old, &replayed)
+		require.NoError(t, err)
+		require.False(t, swapped)
+
+		mfa, _ := store.GetMFA(context.Background(), "test-mfa-2")
+		require.Equal(t, int64(42), mfa.LastStep)
+	})
+}
+
+func runTestChallenge(t *testing.T, store mfaStore) {
+	t.Run("challenge can be taken once", func(t *testing.T) {
+		challenge, err := store.AddChallenge(context.Background(), &users.Challenge{
+			UserID:    "test-challenge-1",
+			ExpiresAt: time.Now(),
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, challenge.ID)
+
+		taken, err := store.TakeChallenge(context.Background(), challenge.ID)
+		require.NoError(t, err)
+		require.Equal(t, "test-challenge-1", taken.UserID)
+
+		_, err = store.TakeChallenge(context.Background(), challenge.ID)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrChallengeNotFound))
+	})
+}
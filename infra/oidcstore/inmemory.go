@@ -0,0 +1,179 @@
+package oidcstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"go-users-example/domain/users"
+)
+
+// InMemory is an oidc repo implementation which will store inmemory the clients, the authorization codes and the
+// refresh tokens.
+// Note: the expired codes and refresh tokens are only removed with the data of their user
+type InMemory struct {
+	mu                 sync.Mutex
+	clientByID         map[string]users.OIDCClient
+	codeByHash         map[string]users.AuthorizationCode
+	refreshTokenByHash map[string]users.RefreshToken
+}
+
+// NewInMemory will initialise the store
+func NewInMemory() *InMemory {
+	return &InMemory{
+		clientByID:         make(map[string]users.OIDCClient),
+		codeByHash:         make(map[string]users.AuthorizationCode),
+		refreshTokenByHash: make(map[string]users.RefreshToken),
+	}
+}
+
+// AddClient implements users.OIDCClientStore
+func (i *InMemory) AddClient(ctx context.Context, client *users.OIDCClient) (*users.OIDCClient, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	client.ID = uuid.NewV4().String()
+	stored := *client
+	stored.RedirectURIs = append([]string(nil), client.RedirectURIs...)
+	i.clientByID[client.ID] = stored
+	return client, nil
+}
+
+// GetClient implements users.OIDCClientStore
+func (i *InMemory) GetClient(ctx context.Context, id string) (*users.OIDCClient, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	client, ok := i.clientByID[id]
+	if !ok {
+		return nil, nil
+	}
+	client.RedirectURIs = append([]string(nil), client.RedirectURIs...)
+	return &client, nil
+}
+
+// AddCode implements users.AuthorizationCodeStore
+func (i *InMemory) AddCode(ctx context.Context, code *users.AuthorizationCode) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	stored := *code
+	stored.Scopes = append([]string(nil), code.Scopes...)
+	i.codeByHash[code.Hash] = stored
+	return nil
+}
+
+// TakeCode implements users.AuthorizationCodeStore
+func (i *InMemory) TakeCode(ctx context.Context, hash string) (*users.AuthorizationCode, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	code, ok := i.codeByHash[hash]
+	if !ok {
+		return nil, ErrCodeNotFound
+	}
+	delete(i.codeByHash, hash)
+	return &code, nil
+}
+
+// AddRefreshToken implements users.RefreshTokenStore
+func (i *InMemory) AddRefreshToken(ctx context.Context, token *users.RefreshToken) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	stored := *token
+	stored.Scopes = append([]string(nil), token.Scopes...)
+	i.refreshTokenByHash[token.Hash] = stored
+	return nil
+}
+
+// RotateRefreshToken implements users.RefreshTokenStore
+func (i *InMemory) RotateRefreshToken(ctx context.Context, hash string, at time.Time) (*users.RefreshToken, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	token, ok := i.refreshTokenByHash[hash]
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+	rotated := token
+	if rotated.RotatedAt == nil {
+		rotated.RotatedAt = &at
+	}
+	i.refreshTokenByHash[hash] = rotated
+	token.Scopes = append([]string(nil), token.Scopes...)
+	return &token, nil
+}
+
+// RevokeRefreshTokenFamily implements users.RefreshTokenStore
+func (i *InMemory) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for hash, token := range i.refreshTokenByHash {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &at
+			i.refreshTokenByHash[hash] = token
+		}
+	}
+	return nil
+}
+
+// ListUserCodes will return the authorization codes of the user, by authentication time. implements users.OIDCGrantLister
+func (i *InMemory) ListUserCodes(ctx context.Context, userID string) ([]*users.AuthorizationCode, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var res []*users.AuthorizationCode
+	for _, code := range i.codeByHash {
+		if code.UserID == userID {
+			code := code
+			code.Scopes = append([]string(nil), code.Scopes...)
+			res = append(res, &code)
+		}
+	}
+	sort.Slice(res, func(a, b int) bool { return res[a].AuthTime.Before(res[b].AuthTime) })
+	return res, nil
+}
+
+// ListUserRefreshTokens will return the refresh tokens of the user, rotated and revoked ones included, by
+// authentication time. implements users.OIDCGrantLister
+func (i *InMemory) ListUserRefreshTokens(ctx context.Context, userID string) ([]*users.RefreshToken, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var res []*users.RefreshToken
+	for _, token := range i.refreshTokenByHash {
+		if token.UserID == userID {
+			token := token
+			token.Scopes = append([]string(nil), token.Scopes...)
+			res = append(res, &token)
+		}
+	}
+	sort.Slice(res, func(a, b int) bool { return res[a].AuthTime.Before(res[b].AuthTime) })
+	return res, nil
+}
+
+// EraseUserData will delete the authorization codes and the refresh tokens of the user. implements users.UserDataEraser
+func (i *InMemory) EraseUserData(ctx context.Context, userID string) (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	n := 0
+	for hash, code := range i.codeByHash {
+		if code.UserID == userID {
+			delete(i.codeByHash, hash)
+			n++
+		}
+	}
+	for hash, token := range i.refreshTokenByHash {
+		if token.UserID == userID {
+			delete(i.refreshTokenByHash, hash)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,7 @@
+package oidcstore
+
+import "testing"
+
+func TestInMemory(t *testing.T) {
+	runTestSuite(t, NewInMemory())
+}
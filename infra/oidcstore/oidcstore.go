@@ -0,0 +1,11 @@
+package oidcstore
+
+import (
+	"errors"
+)
+
+// ErrCodeNotFound is returned if the authorization code is unknown or has already been used
+var ErrCodeNotFound = errors.New("authorization code not found")
+
+// ErrRefreshTokenNotFound is returned if the refresh token is unknown
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
@@ -0,0 +1,140 @@
+package oidcstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+)
+
+type oidcStore interface {
+	users.OIDCClientStore
+	users.AuthorizationCodeStore
+	users.RefreshTokenStore
+	users.OIDCGrantLister
+	users.UserDataEraser
+}
+
+func runTestSuite(t *testing.T, store oidcStore) {
+	runTestClient(t, store)
+	runTestCode(t, store)
+	runTestRefreshToken(t, store)
+	runTestList(t, store)
+	runTestErase(t, store)
+}
+
+func runTestList(t *testing.T, store oidcStore) {
+	t.Run("list codes and refresh tokens of a user", func(t *testing.T) {
+		_ = store.AddCode(context.Background(), &users.AuthorizationCode{Hash: "test-list-code", UserID: "test-list-1", ClientID: "client"})
+		_ = store.AddCode(context.Background(), &users.AuthorizationCode{Hash: "test-list-other", UserID: "test-list-2"})
+		_ = store.AddRefreshToken(context.Background(), &users.RefreshToken{Hash: "test-list-refresh", UserID: "test-list-1", ClientID: "client"})
+		_, _ = store.RotateRefreshToken(context.Background(), "test-list-refresh", time.Now())
+
+		codes, err := store.ListUserCodes(context.Background(), "test-list-1")
+		require.NoError(t, err)
+		require.Len(t, codes, 1)
+		require.Equal(t, "client", codes[0].ClientID)
+		tokens, err := store.ListUserRefreshTokens(context.Background(), "test-list-1")
+		require.NoError(t, err)
+		require.Len(t, tokens, 1)
+		require.NotNil(t, tokens[0].RotatedAt, "the rotated tokens are listed")
+	})
+}
+
+func runTestClient(t *testing.T, store oidcStore) {
+	t.Run("get unknown client", func(t *testing.T) {
+		client, err := store.GetClient(context.Background(), "unknown")
+		require.NoError(t, err)
+		require.Nil(t, client)
+	})
+	t.Run("add and get client", func(t *testing.T) {
+		added, err := store.AddClient(context.Background(), &users.OIDCClient{
+			Name:         "test-client-1",
+			RedirectURIs: []string{"https://app.test/callback"},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, added.ID)
+
+		client, err := store.GetClient(context.Background(), added.ID)
+		require.NoError(t, err)
+		require.Equal(t, "test-client-1", client.Name)
+		require.Equal(t, []string{"https://app.test/callback"}, client.RedirectURIs)
+	})
+}
+
+func runTestCode(t *testing.T, store oidcStore) {
+	t.Run("code can be taken once", func(t *testing.T) {
+		require.NoError(t, store.AddCode(context.Background(), &users.AuthorizationCode{
+			Hash:   "test-code-1",
+			UserID: "test-code-user-1",
+			Scopes: []string{"openid"},
+		}))
+
+		code, err := store.TakeCode(context.Background(), "test-code-1")
+		require.NoError(t, err)
+		require.Equal(t, "test-code-user-1", code.UserID)
+		require.Equal(t, []string{"openid"}, code.Scopes)
+
+		_, err = store.TakeCode(context.Background(), "test-code-1")
+		require.True(t, errors.Is(err, ErrCodeNotFound))
+	})
+}
+
+func runTestRefreshToken(t *testing.T, store oidcStore) {
+	t.Run("rotate unknown refresh token", func(t *testing.T) {
+		_, err := store.RotateRefreshToken(context.Background(), "unknown", time.Now())
+		require.True(t, errors.Is(err, ErrRefreshTokenNotFound))
+	})
+	t.Run("refresh token is rotated once", func(t *testing.T) {
+		require.NoError(t, store.AddRefreshToken(context.Background(), &users.RefreshToken{
+			Hash:     "test-refresh-1",
+			FamilyID: "test-family-1",
+			UserID:   "test-refresh-user-1",
+		}))
+
+		at := time.Now()
+		token, err := store.RotateRefreshToken(context.Background(), "test-refresh-1", at)
+		require.NoError(t, err)
+		require.Equal(t, "test-refresh-user-1", token.UserID)
+		require.Nil(t, token.RotatedAt)
+
+		token, err = store.RotateRefreshToken(context.Background(), "test-refresh-1", at.Add(time.Minute))
+		require.NoError(t, err)
+		require.NotNil(t, token.RotatedAt)
+		require.True(t, at.Equal(*token.RotatedAt))
+	})
+	t.Run("revoke refresh token family", func(t *testing.T) {
+		for _, hash := range []string{"test-refresh-2", "test-refresh-3"} {
+			require.NoError(t, store.AddRefreshToken(context.Background(), &users.RefreshToken{Hash: hash, FamilyID: "test-family-2"}))
+		}
+		require.NoError(t, store.AddRefreshToken(context.Background(), &users.RefreshToken{Hash: "test-refresh-4", FamilyID: "test-family-3"}))
+
+		require.NoError(t, store.RevokeRefreshTokenFamily(context.Background(), "test-family-2", time.Now()))
+
+		for hash, revoked := range map[string]bool{"test-refresh-2": true, "test-refresh-3": true, "test-refresh-4": false} {
+			token, err := store.RotateRefreshToken(context.Background(), hash, time.Now())
+			require.NoError(t, err)
+			require.Equal(t, revoked, token.RevokedAt != nil, hash)
+		}
+	})
+}
+
+func runTestErase(t *testing.T, store oidcStore) {
+	t.Run("erase codes and refresh tokens of a user", func(t *testing.T) {
+		_ = store.AddCode(context.Background(), &users.AuthorizationCode{Hash: "test-erase-code", UserID: "test-erase-1"})
+		_ = store.AddRefreshToken(context.Background(), &users.RefreshToken{Hash: "test-erase-refresh", UserID: "test-erase-1"})
+
+		n, err := store.EraseUserData(context.Background(), "test-erase-1")
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		_, err = store.TakeCode(context.Background(), "test-erase-code")
+		require.True(t, errors.Is(err, ErrCodeNotFound))
+		_, err = store.RotateRefreshToken(context.Background(), "test-erase-refresh", time.Now())
+		require.True(t, errors.Is(err, ErrRefreshTokenNotFound))
+	})
+}
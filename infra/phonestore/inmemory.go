@@ -0,0 +1,61 @@
+package phonestore
+
+import (
+	"context"
+	"sync"
+
+	"go-users-example/domain/users"
+)
+
+// InMemory is a phone verification repo implementation which will store inmemory the pending verifications.
+type InMemory struct {
+	mu                   sync.Mutex
+	verificationByUserID map[string]users.PhoneVerification
+}
+
+// NewInMemory will initialise the store
+func NewInMemory() *InMemory {
+	return &InMemory{verificationByUserID: make(map[string]users.PhoneVerification)}
+}
+
+// GetPhoneVerification implements users.PhoneVerificationStore
+func (i *InMemory) GetPhoneVerification(ctx context.Context, userID string) (*users.PhoneVerification, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	verification, ok := i.verificationByUserID[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &verification, nil
+}
+
+// SavePhoneVerification implements users.PhoneVerificationStore
+func (i *InMemory) SavePhoneVerification(ctx context.Context, verification *users.PhoneVerification) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.verificationByUserID[verification.UserID] = *verification
+	return nil
+}
+
+// DeletePhoneVerification implements users.PhoneVerificationStore
+func (i *InMemory) DeletePhoneVerification(ctx context.Context, userID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.verificationByUserID, userID)
+	return nil
+}
+
+// EraseUserData will delete the pending phone verification of the user. implements users.UserDataEraser
+func (i *InMemory) EraseUserData(ctx context.Context, userID string) (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.verificationByUserID[userID]; !ok {
+		return 0, nil
+	}
+	delete(i.verificationByUserID, userID)
+	return 1, nil
+}
@@ -0,0 +1,64 @@
+package phonestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+)
+
+type phoneStore interface {
+	users.PhoneVerificationStore
+	users.UserDataEraser
+}
+
+func runTestSuite(t *testing.T, store phoneStore) {
+	runTestVerification(t, store)
+	runTestErase(t, store)
+}
+
+func runTestVerification(t *testing.T, store phoneStore) {
+	t.Run("get unknown verification", func(t *testing.T) {
+		verification, err := store.GetPhoneVerification(context.Background(), "unknown")
+		require.NoError(t, err)
+		require.Nil(t, verification)
+	})
+	t.Run("save, replace and delete verification", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Minute)
+		require.NoError(t, store.SavePhoneVerification(context.Background(), &users.PhoneVerification{
+			UserID: "test-phone-1", Phone: "+33612345678", CodeHash: "hash-1", ExpiresAt: expiresAt,
+		}))
+		require.NoError(t, store.SavePhoneVerification(context.Background(), &users.PhoneVerification{
+			UserID: "test-phone-1", Phone: "+33612345678", CodeHash: "hash-2", ExpiresAt: expiresAt, Attempts: 1,
+		}))
+
+		verification, err := store.GetPhoneVerification(context.Background(), "test-phone-1")
+		require.NoError(t, err)
+		require.Equal(t, "hash-2", verification.CodeHash)
+		require.Equal(t, 1, verification.Attempts)
+
+		require.NoError(t, store.DeletePhoneVerification(context.Background(), "test-phone-1"))
+		verification, err = store.GetPhoneVerification(context.Background(), "test-phone-1")
+		require.NoError(t, err)
+		require.Nil(t, verification)
+	})
+}
+
+func runTestErase(t *testing.T, store phoneStore) {
+	t.Run("erase verification of a user", func(t *testing.T) {
+		require.NoError(t, store.SavePhoneVerification(context.Background(), &users.PhoneVerification{UserID: "test-erase-1", Phone: "+33612345678"}))
+
+		n, err := store.EraseUserData(context.Background(), "test-erase-1")
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+
+		verification, _ := store.GetPhoneVerification(context.Background(), "test-erase-1")
+		require.Nil(t, verification)
+		n, err = store.EraseUserData(context.Background(), "test-erase-1")
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+	})
+}
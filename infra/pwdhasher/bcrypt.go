@@ -16,6 +16,11 @@ func NewBcrypt() *Bcrypt {
 	return &Bcrypt{cost: defaultBcryptCost}
 }
 
+// NewBcryptWithCost will instantiate a bcrypt hasher with a specific cost, lower cost are faster but less secure
+func NewBcryptWithCost(cost int) *Bcrypt {
+	return &Bcrypt{cost: cost}
+}
+
 // Hash will generate a securely crypted password
 func (b *Bcrypt) Hash(pwd string) (string, error) {
 	res, err := bcrypt.GenerateFromPassword([]byte(pwd), b.cost)
@@ -25,4 +30,7 @@ func (b *Bcrypt) Hash(pwd string) (string, error) {
 	return string(res), nil
 }
 
-
+// Compare will check the password against its crypted representation
+func (b *Bcrypt) Compare(hashed, pwd string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(pwd))
+}
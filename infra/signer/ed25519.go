@@ -0,0 +1,57 @@
+package signer
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// Config will hold signer specific configuration
+type Config struct {
+	// Key is the base64 representation of the ed25519 seed, a random key is generated if empty
+	Key string `env:"SIGNER_KEY"`
+	// TokenKey is the base64 representation of the ed25519 seed signing the OpenID Connect tokens, a random key is
+	// generated if empty
+	TokenKey string `env:"SIGNER_TOKEN_KEY"`
+}
+
+// Ed25519 will sign data with an ed25519 private key
+type Ed25519 struct {
+	key ed25519.PrivateKey
+}
+
+// NewEd25519 will instantiate an ed25519 signer.
+// Note: if no key is configured, the generated one is lost on restart and previous signatures can't be verified
+func NewEd25519(c Config) (*Ed25519, error) {
+	if c.Key == "" {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("can't generate key: %w", err)
+		}
+		return &Ed25519{key: key}, nil
+	}
+	seed, err := base64.StdEncoding.DecodeString(c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("key should be %d bytes, got %d: %w", ed25519.SeedSize, len(seed), ErrInvalidKey)
+	}
+	return &Ed25519{key: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// Sign implements users.Signer
+func (e *Ed25519) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(e.key, data), nil
+}
+
+// PublicKey will return the key to verify the signatures
+func (e *Ed25519) PublicKey() ed25519.PublicKey {
+	return e.key.Public().(ed25519.PublicKey)
+}
+
+// Verify will check the signature of the data
+func (e *Ed25519) Verify(data, signature []byte) bool {
+	return ed25519.Verify(e.PublicKey(), data, signature)
+}
@@ -0,0 +1,32 @@
+package signer
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEd25519(t *testing.T) {
+	t.Run("sign and verify with generated key", func(t *testing.T) {
+		s, err := NewEd25519(Config{})
+		require.NoError(t, err)
+		sig, err := s.Sign([]byte("data"))
+		require.NoError(t, err)
+		require.True(t, s.Verify([]byte("data"), sig))
+		require.False(t, s.Verify([]byte("tampered"), sig))
+	})
+	t.Run("configured key is stable", func(t *testing.T) {
+		key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+		s1, err := NewEd25519(Config{Key: key})
+		require.NoError(t, err)
+		s2, _ := NewEd25519(Config{Key: key})
+		require.Equal(t, s1.PublicKey(), s2.PublicKey())
+	})
+	t.Run("invalid key size", func(t *testing.T) {
+		_, err := NewEd25519(Config{Key: base64.StdEncoding.EncodeToString([]byte("short"))})
+		require.True(t, errors.Is(err, ErrInvalidKey))
+	})
+}
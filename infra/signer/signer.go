@@ -0,0 +1,8 @@
+package signer
+
+import (
+	"errors"
+)
+
+// ErrInvalidKey is returned if the configured key can't be used
+var ErrInvalidKey = errors.New("invalid signing key")
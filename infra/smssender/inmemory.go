@@ -0,0 +1,53 @@
+package smssender
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-users-example/infra/logger"
+)
+
+// Message is a text message sent to a phone
+type Message struct {
+	Phone string
+	Text  string
+	Time  time.Time
+}
+
+// InMemory is a stand-in of an SMS gateway which records and logs the messages instead of sending them.
+// Note: the messages (verification codes, ...) are logged, it should only be used locally
+type InMemory struct {
+	mu       sync.Mutex
+	log      logger.Logger
+	messages []Message
+}
+
+// NewInMemory will instantiate properly an InMemory
+func NewInMemory(log logger.Logger) *InMemory {
+	return &InMemory{log: log.With().Str("infra", "sms_sender").Logger()}
+}
+
+// SendSMS will record the message. implements users.SMSSender
+func (i *InMemory) SendSMS(ctx context.Context, phone, message string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.messages = append(i.messages, Message{Phone: phone, Text: message, Time: time.Now()})
+	i.log.Info().Str("phone", phone).Str("message", message).Msg("sms sent")
+	return nil
+}
+
+// Messages returns the messages sent to the phone, from the oldest to the newest
+func (i *InMemory) Messages(phone string) []Message {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var res []Message
+	for _, m := range i.messages {
+		if m.Phone == phone {
+			res = append(res, m)
+		}
+	}
+	return res
+}
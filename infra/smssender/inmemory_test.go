@@ -0,0 +1,23 @@
+package smssender
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/infra/logger"
+)
+
+func TestInMemory(t *testing.T) {
+	sender := NewInMemory(logger.Logger{})
+	require.NoError(t, sender.SendSMS(context.Background(), "+33612345678", "first"))
+	require.NoError(t, sender.SendSMS(context.Background(), "+32470123456", "other"))
+	require.NoError(t, sender.SendSMS(context.Background(), "+33612345678", "second"))
+
+	messages := sender.Messages("+33612345678")
+	require.Len(t, messages, 2)
+	require.Equal(t, "first", messages[0].Text)
+	require.Equal(t, "second", messages[1].Text)
+	require.Empty(t, sender.Messages("+41791234567"))
+}
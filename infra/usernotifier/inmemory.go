@@ -1,6 +1,8 @@
 package usernotifier
 
 import (
+	"sync"
+
 	"go-users-example/domain/users"
 )
 
@@ -10,7 +12,15 @@ const defaultChanSize = 500
 
 // InMemory is a basic implementation of an in memory notifier infra as kafka, nats, etc.
 type InMemory struct {
-	reader []chan *users.ChangeEvent
+	mu     sync.RWMutex
+	reader []subscription
+}
+
+// subscription is a chan returned by Listen or Watch
+type subscription struct {
+	c chan *users.ChangeEvent
+	// watch subscriptions are ended instead of blocking the notifications when their chan is full
+	watch bool
 }
 
 // NewInMemory will instantiate properly an InMemory
@@ -20,15 +30,55 @@ func NewInMemory() *InMemory {
 
 // Notify will send a notification of user change in the systems. implements users.ChangeNotifier
 func (i *InMemory) Notify(event *users.ChangeEvent) error {
-	for _, c := range i.reader {
-		c <- event
+	var slow []chan *users.ChangeEvent
+	i.mu.RLock()
+	for _, s := range i.reader {
+		if !s.watch {
+			s.c <- event
+			continue
+		}
+		select {
+		case s.c <- event:
+		default:
+			slow = append(slow, s.c)
+		}
+	}
+	i.mu.RUnlock()
+	for _, c := range slow {
+		i.Unlisten(c)
 	}
 	return nil
 }
 
-// Listen will generate a new subcription to the ChangeEvent notification
+// Listen will generate a new subcription to the ChangeEvent notification, every notification is received
 func (i *InMemory) Listen() chan *users.ChangeEvent {
+	return i.subscribe(false)
+}
+
+// Watch will generate a new subscription to the ChangeEvent notification for a watcher which can be slow, as a
+// client of the api: its chan is closed once it is full, the notifications aren't blocked by the watcher
+func (i *InMemory) Watch() chan *users.ChangeEvent {
+	return i.subscribe(true)
+}
+
+// Unlisten will end a subscription returned by Listen or Watch and close its chan, if not already done.
+// Note: the chan should be drained meanwhile, a notification blocked on a full chan would block Unlisten
+func (i *InMemory) Unlisten(c chan *users.ChangeEvent) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for idx, r := range i.reader {
+		if r.c == c {
+			i.reader = append(i.reader[:idx:idx], i.reader[idx+1:]...)
+			close(c)
+			return
+		}
+	}
+}
+
+func (i *InMemory) subscribe(watch bool) chan *users.ChangeEvent {
 	c := make(chan *users.ChangeEvent, defaultChanSize)
-	i.reader = append(i.reader, c)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.reader = append(i.reader, subscription{c: c, watch: watch})
 	return c
 }
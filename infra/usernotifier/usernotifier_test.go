@@ -12,6 +12,8 @@ import (
 type notifier interface {
 	users.ChangeNotifier
 	Listen() chan *users.ChangeEvent
+	Watch() chan *users.ChangeEvent
+	Unlisten(c chan *users.ChangeEvent)
 }
 
 func runTestSuite(t *testing.T, n notifier) {
@@ -28,5 +30,35 @@ func runTestSuite(t *testing.T, n notifier) {
 		case receivedEvt := <-l:
 			require.Equal(t, e.Op, receivedEvt.Op)
 		}
+		n.Unlisten(l)
+	})
+	t.Run("no notification after unlisten", func(t *testing.T) {
+		l := n.Listen()
+		n.Unlisten(l)
+		require.NoError(t, n.Notify(&users.ChangeEvent{Op: users.DeleteOp}))
+		_, open := <-l
+		require.False(t, open)
+	})
+	t.Run("a full watcher is ended", func(t *testing.T) {
+		w := n.Watch()
+		for i := 0; i < cap(w); i++ {
+			require.NoError(t, n.Notify(&users.ChangeEvent{Op: users.UpdateOp}))
+		}
+		done := make(chan struct{})
+		go func() {
+			_ = n.Notify(&users.ChangeEvent{Op: users.UpdateOp})
+			close(done)
+		}()
+		select {
+		case <-time.NewTimer(3 * time.Second).C:
+			t.Fatal("the notification is blocked by the watcher, time out after 3sec")
+		case <-done:
+		}
+		received := 0
+		for range w {
+			received++
+		}
+		require.Equal(t, cap(w), received, "the pending notifications are received before the end")
+		n.Unlisten(w)
 	})
 }
@@ -3,6 +3,9 @@ package userstore
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/satori/go.uuid"
 
@@ -10,71 +13,208 @@ import (
 )
 
 // InMemory is a user repo implementation which will store inmemory the users.
+//
+// Deleted users are kept as tombstones (with DeletedAt set) and keep their email and nickname reserved until they are purged.
+// The stored users are never changed in place, a change replaces the user: a scan keeps the users of its snapshot.
+// The changes are recorded with the function set by RecordChanges before they are committed.
 type InMemory struct {
+	mu          sync.RWMutex
+	now         func() time.Time
+	record      func(ctx context.Context, evt *users.ChangeEvent) error
 	dataByID    map[string]*users.User
 	dataEmailID map[string]string
+	dataNickID  map[string]string
+	dataPhoneID map[string]string
 }
 
 // NewInMemory will initialise the store
 func NewInMemory() *InMemory {
-	return &InMemory{dataByID: make(map[string]*users.User), dataEmailID: make(map[string]string)}
+	return &InMemory{
+		now:         time.Now,
+		dataByID:    make(map[string]*users.User),
+		dataEmailID: make(map[string]string),
+		dataNickID:  make(map[string]string),
+		dataPhoneID: make(map[string]string),
+	}
+}
+
+// RecordChanges will set the function recording each change of the store, it is called under the lock of the store
+// when the change, or the transaction holding it, is committed: in the order of the changes. A change which can't be
+// recorded isn't committed, the changes of its transaction recorded before it are kept by the record.
+// It should be set before the store is used.
+func (i *InMemory) RecordChanges(record func(ctx context.Context, evt *users.ChangeEvent) error) {
+	i.record = record
 }
 
 // Add implements users.Adder
-func (i *InMemory) Add(ctx context.Context, user *users.User) (*users.User, error) {
-	if _, ok := i.dataEmailID[user.Email]; ok {
+func (i *InMemory) Add(ctx context.Context, user *users.User) (_ *users.User, err error) {
+	tx, unlock := i.lock(ctx)
+	defer unlock(&err)
+
+	if _, ok := i.dataEmailID[emailKey(user)]; ok {
 		return nil, fmt.Errorf("email %s already created: %w", user.Email, ErrAlreadyExist)
 	}
+	if _, ok := i.dataNickID[nickNameKey(user)]; ok {
+		return nil, fmt.Errorf("nickname %s already used: %w", user.NickName, ErrNickNameAlreadyExist)
+	}
+	if _, ok := i.dataPhoneID[user.Phone]; ok && user.Phone != "" {
+		return nil, fmt.Errorf("phone %s already used: %w", user.Phone, ErrPhoneAlreadyExist)
+	}
 
 	user.ID = uuid.NewV4().String()
-	i.dataByID[user.ID] = &(*user) // nolint
-	i.dataEmailID[user.Email] = user.ID
+	user.Version = 1
+	tx.keep(user.ID, nil)
+	stored := *user
+	i.dataByID[user.ID] = &stored
+	i.dataEmailID[emailKey(user)] = user.ID
+	if key := nickNameKey(user); key != "" {
+		i.dataNickID[key] = user.ID
+	}
+	if user.Phone != "" {
+		i.dataPhoneID[user.Phone] = user.ID
+	}
+	i.changed(ctx, tx, users.CreateOp, nil, &stored)
 
 	return user, nil
 }
 
-// Delete will remove the user from the system
-func (i *InMemory) Delete(ctx context.Context, user *users.User) (*users.User, error) {
+// Delete will soft delete the user from the system, the user is hidden but can be restored until purged
+func (i *InMemory) Delete(ctx context.Context, user *users.User) (_ *users.User, err error) {
+	tx, unlock := i.lock(ctx)
+	defer unlock(&err)
+
+	usr, ok := i.dataByID[user.ID]
+	if !ok || usr.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	deletedAt := i.now()
+	deleted := *usr
+	deleted.DeletedAt = &deletedAt
+	deleted.Version++
+	tx.keep(usr.ID, usr)
+	i.dataByID[deleted.ID] = &deleted
+	i.changed(ctx, tx, users.DeleteOp, &deleted, nil)
+
+	return &deleted, nil
+}
+
+// Restore will bring back a user deleted after deletedSince. implements users.Restorer
+func (i *InMemory) Restore(ctx context.Context, user *users.User, deletedSince time.Time) (_ *users.User, err error) {
+	tx, unlock := i.lock(ctx)
+	defer unlock(&err)
+
+	usr, ok := i.dataByID[user.ID]
+	if !ok || usr.DeletedAt == nil || usr.DeletedAt.Before(deletedSince) {
+		return nil, ErrNotFound
+	}
+
+	restored := *usr
+	restored.DeletedAt = nil
+	restored.Version++
+	tx.keep(usr.ID, usr)
+	i.dataByID[restored.ID] = &restored
+	i.changed(ctx, tx, users.RestoreOp, nil, &restored)
+
+	return &restored, nil
+}
+
+// Purge will permanently remove the users deleted before deletedBefore and free their email. implements users.Purger
+func (i *InMemory) Purge(ctx context.Context, deletedBefore time.Time) (_ []*users.User, err error) {
+	tx, unlock := i.lock(ctx)
+	defer unlock(&err)
+
+	var purged []*users.User
+	for id, usr := range i.dataByID {
+		if usr.DeletedAt == nil || !usr.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		tx.keep(id, usr)
+		delete(i.dataEmailID, emailKey(usr))
+		delete(i.dataNickID, nickNameKey(usr))
+		delete(i.dataPhoneID, usr.Phone)
+		delete(i.dataByID, id)
+		i.changed(ctx, tx, users.PurgeOp, usr, nil)
+		purged = append(purged, usr)
+	}
+
+	return purged, nil
+}
+
+// Erase will permanently remove the user, deleted or not, and free its email. implements users.UserEraser
+func (i *InMemory) Erase(ctx context.Context, user *users.User) (_ *users.User, err error) {
+	tx, unlock := i.lock(ctx)
+	defer unlock(&err)
+
 	usr, ok := i.dataByID[user.ID]
 	if !ok {
 		return nil, ErrNotFound
 	}
 
-	delete(i.dataEmailID, usr.Email)
+	tx.keep(usr.ID, usr)
+	delete(i.dataEmailID, emailKey(usr))
+	delete(i.dataNickID, nickNameKey(usr))
+	delete(i.dataPhoneID, usr.Phone)
 	delete(i.dataByID, usr.ID)
+	// only the id of an erased user is kept
+	i.changed(ctx, tx, users.EraseOp, &users.User{ID: usr.ID}, nil)
 
 	return usr, nil
 }
 
-// Update will update user with same ID to the new value
-func (i *InMemory) Update(ctx context.Context, user *users.User) (*users.User, error) {
+// Update will replace the user with same ID, all the fields are stored as provided. the user is only replaced if it is
+// still at the version provided, it is then stored with the next version. implements users.Updater
+func (i *InMemory) Update(ctx context.Context, user *users.User) (_ *users.User, err error) {
+	tx, unlock := i.lock(ctx)
+	defer unlock(&err)
+
 	storedUser, ok := i.dataByID[user.ID]
-	if !ok {
+	if !ok || storedUser.DeletedAt != nil {
 		return nil, ErrNotFound
 	}
+	if user.Version != storedUser.Version {
+		return nil, fmt.Errorf("user %s is at version %d, not %d: %w", user.ID, storedUser.Version, user.Version, ErrVersionConflict)
+	}
 
-	if user.Email != "" {
-		delete(i.dataEmailID, storedUser.Email)
-		storedUser.Email = user.Email
-		i.dataEmailID[storedUser.Email] = storedUser.ID
+	if id, ok := i.dataEmailID[emailKey(user)]; ok && user.Email != "" && id != storedUser.ID {
+		return nil, fmt.Errorf("email %s already created: %w", user.Email, ErrAlreadyExist)
 	}
-	if user.FirstName != "" {
-		storedUser.FirstName = user.FirstName
+	if id, ok := i.dataNickID[nickNameKey(user)]; ok && user.NickName != "" && id != storedUser.ID {
+		return nil, fmt.Errorf("nickname %s already used: %w", user.NickName, ErrNickNameAlreadyExist)
 	}
-	if user.LastName != "" {
-		storedUser.LastName = user.LastName
+	if id, ok := i.dataPhoneID[user.Phone]; ok && user.Phone != "" && id != storedUser.ID {
+		return nil, fmt.Errorf("phone %s already used: %w", user.Phone, ErrPhoneAlreadyExist)
 	}
-	if user.NickName != "" {
-		storedUser.NickName = user.NickName
+
+	tx.keep(storedUser.ID, storedUser)
+	delete(i.dataEmailID, emailKey(storedUser))
+	delete(i.dataNickID, nickNameKey(storedUser))
+	delete(i.dataPhoneID, storedUser.Phone)
+
+	updated := *user
+	updated.DeletedAt = nil
+	updated.Version = storedUser.Version + 1
+	i.dataByID[updated.ID] = &updated
+	if key := emailKey(&updated); key != "" {
+		i.dataEmailID[key] = updated.ID
 	}
-	if user.Password != "" {
-		storedUser.Password = user.Password
+	if key := nickNameKey(&updated); key != "" {
+		i.dataNickID[key] = updated.ID
 	}
-	if user.Country != "" {
-		storedUser.Country = user.Country
+	if updated.Phone != "" {
+		i.dataPhoneID[updated.Phone] = updated.ID
 	}
+	i.changed(ctx, tx, users.UpdateOp, storedUser, &updated)
 
-	return storedUser, nil
+	return &updated, nil
+}
+
+// NickNameUsed will tell if a user, deleted or not, already use a nickname with the same key. implements users.NickNameChecker
+func (i *InMemory) NickNameUsed(ctx context.Context, key string) (bool, error) {
+	defer i.rlock(ctx)()
+
+	_, ok := i.dataNickID[key]
+	return ok, nil
 }
 
 // Query will create a query to search users. implements users.Queryier
@@ -88,8 +228,13 @@ func (i *InMemory) Search(ctx context.Context, q users.Queryer) ([]*users.User,
 	if !ok {
 		return nil, ErrQueryNotCompatible
 	}
+	defer i.rlock(ctx)()
+
 	var res []*users.User
 	for _, usr := range i.dataByID {
+		if usr.DeletedAt != nil && !sQuery.withDeleted {
+			continue
+		}
 		if sQuery.match(usr) {
 			res = append(res, usr)
 		}
@@ -97,15 +242,212 @@ func (i *InMemory) Search(ctx context.Context, q users.Queryer) ([]*users.User,
 	return res, nil
 }
 
+// Scan will call fn with the users matching the query as they were at the start of the scan, ordered by id: the
+// snapshot only holds the stored users, which are never changed in place. implements users.Scanner
+func (i *InMemory) Scan(ctx context.Context, q users.Queryer, fn func(usr *users.User) error) error {
+	sQuery, ok := q.(*query)
+	if !ok {
+		return ErrQueryNotCompatible
+	}
+	runlock := i.rlock(ctx)
+	snapshot := make([]*users.User, 0, len(i.dataByID))
+	for _, usr := range i.dataByID {
+		if usr.DeletedAt != nil && !sQuery.withDeleted {
+			continue
+		}
+		if sQuery.empty() || sQuery.match(usr) {
+			snapshot = append(snapshot, usr)
+		}
+	}
+	runlock()
+
+	sort.Slice(snapshot, func(a, b int) bool { return snapshot[a].ID < snapshot[b].ID })
+	for _, usr := range snapshot {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		scanned := *usr
+		if err := fn(&scanned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InTransaction will apply the changes made with the context of fn atomically, they are all reverted if fn returns an
+// error or if they can't be recorded. The store is locked until fn returns, the context of fn shouldn't be used by
+// another goroutine. A nested transaction is part of the first one. implements users.Transactor
+func (i *InMemory) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{store: i}).(*inMemoryTx); ok {
+		return fn(ctx)
+	}
+	i.mu.Lock()
+	tx := newInMemoryTx()
+	done := false
+	defer func() {
+		if !done {
+			// fn panicked
+			i.revert(tx)
+		}
+		i.mu.Unlock()
+	}()
+
+	err := i.commit(ctx, tx, fn(context.WithValue(ctx, txKey{store: i}, tx)))
+	done = true
+	return err
+}
+
 // -- internal implementation --
 
+// txKey is the context key of the transaction of a store
+type txKey struct {
+	store *InMemory
+}
+
+// inMemoryTx keeps the users as they were before their first change in the transaction, nil for the added ones, and
+// the changes to record on commit
+type inMemoryTx struct {
+	before  map[string]*users.User
+	changes []*users.ChangeEvent
+}
+
+func newInMemoryTx() *inMemoryTx {
+	return &inMemoryTx{before: make(map[string]*users.User)}
+}
+
+// keep will remember the stored user before its change, only the first one of the transaction is kept
+func (tx *inMemoryTx) keep(id string, usr *users.User) {
+	if _, ok := tx.before[id]; !ok {
+		tx.before[id] = usr
+	}
+}
+
+// lock will lock the store for a change, unless the context is in a transaction which already holds the lock. Outside
+// of a transaction the change has its own, committed by unlock with the error of the change: which is set if the
+// change can't be recorded
+func (i *InMemory) lock(ctx context.Context) (*inMemoryTx, func(err *error)) {
+	if tx, ok := ctx.Value(txKey{store: i}).(*inMemoryTx); ok {
+		return tx, func(*error) {}
+	}
+	i.mu.Lock()
+	tx := newInMemoryTx()
+	return tx, func(err *error) {
+		defer i.mu.Unlock()
+		*err = i.commit(ctx, tx, *err)
+	}
+}
+
+// changed will keep the change made in the transaction to record it on commit
+func (i *InMemory) changed(ctx context.Context, tx *inMemoryTx, op users.Operation, before, after *users.User) {
+	tx.changes = append(tx.changes, &users.ChangeEvent{
+		Time:   i.now(),
+		Op:     op,
+		Before: before,
+		After:  after,
+		Origin: users.RequestInfoFromContext(ctx),
+	})
+}
+
+// commit will record the changes of the transaction which succeeded, the changes are reverted if the transaction
+// failed or if one of them can't be recorded. the lock should be held
+func (i *InMemory) commit(ctx context.Context, tx *inMemoryTx, err error) error {
+	if err == nil && i.record != nil {
+		for _, evt := range tx.changes {
+			if err = i.record(ctx, evt); err != nil {
+				err = fmt.Errorf("can't record the change of user: %w", err)
+				break
+			}
+		}
+	}
+	if err != nil {
+		i.revert(tx)
+	}
+	return err
+}
+
+// rlock will lock the store for a read, unless the context is in a transaction which already holds the lock
+func (i *InMemory) rlock(ctx context.Context) func() {
+	if _, ok := ctx.Value(txKey{store: i}).(*inMemoryTx); ok {
+		return func() {}
+	}
+	i.mu.RLock()
+	return i.mu.RUnlock
+}
+
+// revert will bring back the users changed by the transaction, the lock should be held
+func (i *InMemory) revert(tx *inMemoryTx) {
+	for id := range tx.before {
+		if usr, ok := i.dataByID[id]; ok {
+			i.unindex(usr)
+			delete(i.dataByID, id)
+		}
+	}
+	for id, usr := range tx.before {
+		if usr != nil {
+			i.dataByID[id] = usr
+			i.index(usr)
+		}
+	}
+}
+
+// index will reserve the email, nickname and phone of the user
+func (i *InMemory) index(usr *users.User) {
+	if key := emailKey(usr); key != "" {
+		i.dataEmailID[key] = usr.ID
+	}
+	if key := nickNameKey(usr); key != "" {
+		i.dataNickID[key] = usr.ID
+	}
+	if usr.Phone != "" {
+		i.dataPhoneID[usr.Phone] = usr.ID
+	}
+}
+
+// unindex will free the email, nickname and phone reserved by the user
+func (i *InMemory) unindex(usr *users.User) {
+	if i.dataEmailID[emailKey(usr)] == usr.ID {
+		delete(i.dataEmailID, emailKey(usr))
+	}
+	if i.dataNickID[nickNameKey(usr)] == usr.ID {
+		delete(i.dataNickID, nickNameKey(usr))
+	}
+	if i.dataPhoneID[usr.Phone] == usr.ID {
+		delete(i.dataPhoneID, usr.Phone)
+	}
+}
+
+// emailKey returns the key used to check the uniqueness of the email, the canonical email when the domain provided it
+func emailKey(u *users.User) string {
+	if u.CanonicalEmail != "" {
+		return u.CanonicalEmail
+	}
+	return u.Email
+}
+
+// nickNameKey returns the key used to check the uniqueness of the nickname, the skeleton of the nickname when the domain provided it
+func nickNameKey(u *users.User) string {
+	if u.NickNameKey != "" {
+		return u.NickNameKey
+	}
+	return u.NickName
+}
+
+type attribute struct {
+	name  string
+	value interface{}
+}
+
 type query struct {
-	ids       []string
-	email     []string
-	firstName []string
-	lastName []string
-	nickName []string
-	country []string
+	ids         []string
+	email       []string
+	firstName   []string
+	lastName    []string
+	nickName    []string
+	nickNameKey []string
+	country     []string
+	phone       []string
+	attributes  []attribute
+	withDeleted bool
 }
 
 func (q *query) ByID(id string) users.Queryer {
@@ -133,11 +475,36 @@ func (q *query) ByNickName(nickName string) users.Queryer {
 	return q
 }
 
+func (q *query) ByNickNameKey(key string) users.Queryer {
+	q.nickNameKey = append(q.nickNameKey, key)
+	return q
+}
+
 func (q *query) ByCountry(country string) users.Queryer {
 	q.country = append(q.country, country)
 	return q
 }
 
+func (q *query) ByPhone(phone string) users.Queryer {
+	q.phone = append(q.phone, phone)
+	return q
+}
+
+func (q *query) ByAttribute(name string, value interface{}) users.Queryer {
+	q.attributes = append(q.attributes, attribute{name: name, value: value})
+	return q
+}
+
+func (q *query) WithDeleted() users.Queryer {
+	q.withDeleted = true
+	return q
+}
+
+// empty tells if the query has no criteria
+func (q *query) empty() bool {
+	return len(q.ids)+len(q.email)+len(q.firstName)+len(q.lastName)+len(q.nickName)+len(q.nickNameKey)+len(q.country)+len(q.phone)+len(q.attributes) == 0
+}
+
 func (q *query) match(u *users.User) bool {
 	for _, id := range q.ids {
 		if id == u.ID {
@@ -164,10 +531,35 @@ func (q *query) match(u *users.User) bool {
 			return true
 		}
 	}
+	for _, key := range q.nickNameKey {
+		if key == u.NickNameKey {
+			return true
+		}
+	}
 	for _, country := range q.country {
 		if country == u.Country {
 			return true
 		}
 	}
-	return false
+	for _, phone := range q.phone {
+		if phone == u.Phone {
+			return true
+		}
+	}
+	return len(q.attributes) > 0 && q.matchAttributes(u)
+}
+
+// matchAttributes tells if the user has one of the searched values of each searched attribute
+func (q *query) matchAttributes(u *users.User) bool {
+	matched := make(map[string]bool)
+	for _, attr := range q.attributes {
+		v, ok := u.Attributes[attr.name]
+		matched[attr.name] = matched[attr.name] || (ok && v == attr.value)
+	}
+	for _, ok := range matched {
+		if !ok {
+			return false
+		}
+	}
+	return true
 }
@@ -1,7 +1,100 @@
 package userstore
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+)
 
 func TestInMemory(t *testing.T) {
 	runTestSuite(t, NewInMemory())
 }
+
+func TestInMemory_RecordChanges(t *testing.T) {
+	store := NewInMemory()
+	var recorded []*users.ChangeEvent
+	var errRecord error
+	store.RecordChanges(func(ctx context.Context, evt *users.ChangeEvent) error {
+		if errRecord != nil {
+			return errRecord
+		}
+		recorded = append(recorded, evt)
+		return nil
+	})
+
+	t.Run("record the changes in their order", func(t *testing.T) {
+		recorded = nil
+		ctx := users.WithRequestInfo(context.Background(), users.RequestInfo{Actor: "user:admin", RequestID: "req-1"})
+		added, err := store.Add(ctx, &users.User{Email: "test-record-1"})
+		require.NoError(t, err)
+		updated, err := store.Update(ctx, &users.User{ID: added.ID, Email: "test-record-2", Version: added.Version})
+		require.NoError(t, err)
+		_, err = store.Delete(ctx, updated)
+		require.NoError(t, err)
+
+		require.Len(t, recorded, 3)
+		require.Equal(t, users.CreateOp, recorded[0].Op)
+		require.Equal(t, "test-record-1", recorded[0].After.Email)
+		require.Equal(t, users.UpdateOp, recorded[1].Op)
+		require.Equal(t, "test-record-1", recorded[1].Before.Email)
+		require.Equal(t, "test-record-2", recorded[1].After.Email)
+		require.Equal(t, users.DeleteOp, recorded[2].Op)
+		require.NotNil(t, recorded[2].Before.DeletedAt)
+		require.Equal(t, "req-1", recorded[2].Origin.RequestID)
+	})
+	t.Run("revert a change which can't be recorded", func(t *testing.T) {
+		recorded = nil
+		errRecord = errors.New("failed")
+		defer func() { errRecord = nil }()
+
+		_, err := store.Add(context.Background(), &users.User{Email: "test-record-3"})
+		require.ErrorIs(t, err, errRecord)
+		res, err := store.Search(context.Background(), store.Query().ByEmail("test-record-3"))
+		require.NoError(t, err)
+		require.Empty(t, res)
+		// the email is free again
+		errRecord = nil
+		_, err = store.Add(context.Background(), &users.User{Email: "test-record-3"})
+		require.NoError(t, err)
+	})
+	t.Run("record the changes of a transaction on commit", func(t *testing.T) {
+		recorded = nil
+		err := store.InTransaction(context.Background(), func(ctx context.Context) error {
+			_, err := store.Add(ctx, &users.User{Email: "test-record-4"})
+			require.NoError(t, err)
+			_, err = store.Add(ctx, &users.User{Email: "test-record-5"})
+			require.NoError(t, err)
+			require.Empty(t, recorded)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, recorded, 2)
+
+		recorded = nil
+		errFailed := errors.New("failed")
+		err = store.InTransaction(context.Background(), func(ctx context.Context) error {
+			_, err := store.Add(ctx, &users.User{Email: "test-record-6"})
+			require.NoError(t, err)
+			return errFailed
+		})
+		require.ErrorIs(t, err, errFailed)
+		require.Empty(t, recorded, "a reverted transaction isn't recorded")
+	})
+	t.Run("revert a transaction which can't be recorded", func(t *testing.T) {
+		errRecord = errors.New("failed")
+		defer func() { errRecord = nil }()
+
+		err := store.InTransaction(context.Background(), func(ctx context.Context) error {
+			_, err := store.Add(ctx, &users.User{Email: "test-record-7"})
+			return err
+		})
+		require.ErrorIs(t, err, errRecord)
+		res, err := store.Search(context.Background(), store.Query().ByEmail("test-record-7"))
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+}
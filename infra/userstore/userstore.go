@@ -7,8 +7,17 @@ import (
 // ErrAlreadyExist is returned if the email is already present in the store
 var ErrAlreadyExist = errors.New("user already exist")
 
+// ErrNickNameAlreadyExist is returned if the nickname is already used by another user of the store
+var ErrNickNameAlreadyExist = errors.New("nickname already used")
+
+// ErrPhoneAlreadyExist is returned if the phone is already used by another user of the store
+var ErrPhoneAlreadyExist = errors.New("phone already used")
+
 // ErrNotFound is returned if the id of the user isn't found in the store
 var ErrNotFound = errors.New("user not found")
 
+// ErrVersionConflict is returned if the user has been changed in the store since the version being updated was read
+var ErrVersionConflict = errors.New("user changed since it was read")
+
 // ErrQueryNotCompatible is returned if the email is already present in the store
 var ErrQueryNotCompatible = errors.New("the provided query is not compatible")
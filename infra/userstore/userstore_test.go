@@ -4,13 +4,14 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"go-users-example/domain/users"
 )
 
-type wrongQuery struct {}
+type wrongQuery struct{}
 
 func (w *wrongQuery) ByLastName(lastName string) users.Queryer {
 	panic("implement me")
@@ -20,6 +21,10 @@ func (w *wrongQuery) ByNickName(nickName string) users.Queryer {
 	panic("implement me")
 }
 
+func (w *wrongQuery) ByNickNameKey(key string) users.Queryer {
+	panic("implement me")
+}
+
 func (w *wrongQuery) ByCountry(country string) users.Queryer {
 	panic("implement me")
 }
@@ -36,11 +41,29 @@ func (w *wrongQuery) ByFirstName(firstName string) users.Queryer {
 	panic("implement me")
 }
 
+func (w *wrongQuery) ByPhone(phone string) users.Queryer {
+	panic("implement me")
+}
+
+func (w *wrongQuery) ByAttribute(name string, value interface{}) users.Queryer {
+	panic("implement me")
+}
+
+func (w *wrongQuery) WithDeleted() users.Queryer {
+	panic("implement me")
+}
+
 type userStore interface {
 	users.Adder
 	users.Updater
 	users.Deleter
 	users.Searcher
+	users.Restorer
+	users.Purger
+	users.UserEraser
+	users.NickNameChecker
+	users.Scanner
+	users.Transactor
 }
 
 func runTestSuite(t *testing.T, store userStore) {
@@ -48,6 +71,400 @@ func runTestSuite(t *testing.T, store userStore) {
 	runTestDelete(t, store)
 	runTestUpdate(t, store)
 	runTestSearch(t, store)
+	runTestRestore(t, store)
+	runTestPurge(t, store)
+	runTestErase(t, store)
+	runTestNickName(t, store)
+	runTestAttributes(t, store)
+	runTestPhone(t, store)
+	runTestScan(t, store)
+	runTestTransaction(t, store)
+}
+
+func runTestTransaction(t *testing.T, store userStore) {
+	t.Run("commit the changes", func(t *testing.T) {
+		updated, _ := store.Add(context.Background(), &users.User{FirstName: "test-tx", Email: "test-tx-commit-1"})
+		var added *users.User
+		err := store.InTransaction(context.Background(), func(ctx context.Context) error {
+			var err error
+			added, err = store.Add(ctx, &users.User{FirstName: "test-tx", Email: "test-tx-commit-2"})
+			require.NoError(t, err)
+			_, err = store.Update(ctx, &users.User{ID: updated.ID, FirstName: "test-tx", Email: "test-tx-commit-3", Version: updated.Version})
+			require.NoError(t, err)
+			// the changes are seen in the transaction
+			res, err := store.Search(ctx, store.Query().ByEmail("test-tx-commit-3"))
+			require.NoError(t, err)
+			require.Len(t, res, 1)
+			return nil
+		})
+		require.NoError(t, err)
+		res, err := store.Search(context.Background(), store.Query().ByID(added.ID).ByEmail("test-tx-commit-3"))
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+	})
+	t.Run("revert the changes", func(t *testing.T) {
+		updated, _ := store.Add(context.Background(), &users.User{FirstName: "test-tx", NickName: "test-tx-nick", Email: "test-tx-revert-1"})
+		deleted, _ := store.Add(context.Background(), &users.User{FirstName: "test-tx", Email: "test-tx-revert-2"})
+		var added *users.User
+		errFailed := errors.New("failed")
+		err := store.InTransaction(context.Background(), func(ctx context.Context) error {
+			var err error
+			added, err = store.Add(ctx, &users.User{FirstName: "test-tx", Email: "test-tx-revert-3"})
+			require.NoError(t, err)
+			_, err = store.Update(ctx, &users.User{ID: updated.ID, FirstName: "test-tx", NickName: "test-tx-nick-2", Email: "test-tx-revert-4", Version: updated.Version})
+			require.NoError(t, err)
+			_, err = store.Update(ctx, &users.User{ID: updated.ID, FirstName: "test-tx", Email: "test-tx-revert-5", Version: updated.Version + 1})
+			require.NoError(t, err)
+			_, err = store.Delete(ctx, deleted)
+			require.NoError(t, err)
+			return errFailed
+		})
+		require.ErrorIs(t, err, errFailed)
+
+		res, err := store.Search(context.Background(), store.Query().ByID(added.ID))
+		require.NoError(t, err)
+		require.Empty(t, res, "the added user is removed")
+		res, err = store.Search(context.Background(), store.Query().ByID(updated.ID).ByID(deleted.ID))
+		require.NoError(t, err)
+		require.Len(t, res, 2, "the deleted user is back")
+		for _, usr := range res {
+			require.Contains(t, []string{"test-tx-revert-1", "test-tx-revert-2"}, usr.Email)
+		}
+
+		// the email and nickname of the reverted changes are free again, the previous ones are still reserved
+		_, err = store.Add(context.Background(), &users.User{Email: "test-tx-revert-3"})
+		require.NoError(t, err)
+		_, err = store.Add(context.Background(), &users.User{Email: "test-tx-revert-4", NickName: "test-tx-nick-2"})
+		require.NoError(t, err)
+		_, err = store.Add(context.Background(), &users.User{Email: "test-tx-revert-1"})
+		require.ErrorIs(t, err, ErrAlreadyExist)
+		used, err := store.NickNameUsed(context.Background(), "test-tx-nick")
+		require.NoError(t, err)
+		require.True(t, used)
+	})
+}
+
+func runTestScan(t *testing.T, store userStore) {
+	t.Run("scan a snapshot", func(t *testing.T) {
+		first, _ := store.Add(context.Background(), &users.User{FirstName: "test-scan", Email: "test-scan-1"})
+		second, _ := store.Add(context.Background(), &users.User{FirstName: "test-scan", Email: "test-scan-2"})
+
+		var scanned []*users.User
+		err := store.Scan(context.Background(), store.Query().ByFirstName("test-scan"), func(usr *users.User) error {
+			if len(scanned) == 0 {
+				// the changes made during the scan aren't seen by it
+				for _, changed := range []*users.User{first, second} {
+					_, err := store.Update(context.Background(), &users.User{ID: changed.ID, FirstName: "test-scan", Email: changed.ID + "-updated", Version: changed.Version})
+					require.NoError(t, err)
+				}
+				_, err := store.Add(context.Background(), &users.User{FirstName: "test-scan", Email: "test-scan-3"})
+				require.NoError(t, err)
+			}
+			scanned = append(scanned, usr)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, scanned, 2)
+		require.True(t, scanned[0].ID < scanned[1].ID, "the users are ordered by id")
+		for _, usr := range scanned {
+			require.Contains(t, []string{"test-scan-1", "test-scan-2"}, usr.Email)
+		}
+	})
+	t.Run("scan all the users", func(t *testing.T) {
+		deleted, _ := store.Add(context.Background(), &users.User{Email: "test-scan-deleted"})
+		_, err := store.Delete(context.Background(), deleted)
+		require.NoError(t, err)
+
+		n := 0
+		require.NoError(t, store.Scan(context.Background(), store.Query(), func(usr *users.User) error {
+			require.Nil(t, usr.DeletedAt)
+			n++
+			return nil
+		}))
+		require.Greater(t, n, 3)
+		require.Error(t, store.Scan(context.Background(), store.Query(), func(usr *users.User) error {
+			return errors.New("stop")
+		}))
+	})
+}
+
+func runTestPhone(t *testing.T, store userStore) {
+	t.Run("add user with same phone", func(t *testing.T) {
+		_, err := store.Add(context.Background(), &users.User{Email: "test-phone-1", Phone: "+33612345601"})
+		require.NoError(t, err)
+		_, err = store.Add(context.Background(), &users.User{Email: "test-phone-2", Phone: "+33612345601"})
+		require.True(t, errors.Is(err, ErrPhoneAlreadyExist))
+	})
+
+	t.Run("search by phone", func(t *testing.T) {
+		usr, err := store.Add(context.Background(), &users.User{Email: "test-phone-3", Phone: "+33612345603"})
+		require.NoError(t, err)
+		res, err := store.Search(context.Background(), store.Query().ByPhone("+33612345603"))
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, usr.ID, res[0].ID)
+	})
+
+	t.Run("update replace the phone", func(t *testing.T) {
+		usr, err := store.Add(context.Background(), &users.User{Email: "test-phone-4", Phone: "+33612345604"})
+		require.NoError(t, err)
+		verifiedAt := time.Now()
+		updated, err := store.Update(context.Background(), &users.User{ID: usr.ID, Phone: "+33612345604", PhoneVerifiedAt: &verifiedAt, Version: usr.Version})
+		require.NoError(t, err)
+		require.NotNil(t, updated.PhoneVerifiedAt)
+
+		_, err = store.Update(context.Background(), &users.User{ID: usr.ID, Phone: "+33612345601", Version: updated.Version})
+		require.True(t, errors.Is(err, ErrPhoneAlreadyExist))
+
+		updated, err = store.Update(context.Background(), &users.User{ID: usr.ID, Phone: "+33612345605", Version: updated.Version})
+		require.NoError(t, err)
+		require.Nil(t, updated.PhoneVerifiedAt)
+		_, err = store.Add(context.Background(), &users.User{Email: "test-phone-6", Phone: "+33612345604"})
+		require.NoError(t, err)
+	})
+
+	t.Run("phone is freed by erase", func(t *testing.T) {
+		usr, err := store.Add(context.Background(), &users.User{Email: "test-phone-7", Phone: "+33612345607"})
+		require.NoError(t, err)
+		_, err = store.Erase(context.Background(), usr)
+		require.NoError(t, err)
+		_, err = store.Add(context.Background(), &users.User{Email: "test-phone-8", Phone: "+33612345607"})
+		require.NoError(t, err)
+	})
+}
+
+func runTestAttributes(t *testing.T, store userStore) {
+	t.Run("search by attribute", func(t *testing.T) {
+		usr, err := store.Add(context.Background(), &users.User{
+			Email:      "test-attributes-1",
+			Attributes: map[string]interface{}{"department": "sales", "level": float64(3)},
+		})
+		require.NoError(t, err)
+		_, err = store.Add(context.Background(), &users.User{
+			Email:      "test-attributes-2",
+			Attributes: map[string]interface{}{"department": "legal", "level": "3"},
+		})
+		require.NoError(t, err)
+
+		res, err := store.Search(context.Background(), store.Query().ByAttribute("level", float64(3)))
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, usr.ID, res[0].ID)
+
+		res, err = store.Search(context.Background(), store.Query().ByAttribute("department", "sales").ByAttribute("department", "legal"))
+		require.NoError(t, err)
+		require.Len(t, res, 2, "the values of an attribute are alternatives")
+
+		res, err = store.Search(context.Background(), store.Query().ByAttribute("department", "legal").ByAttribute("level", float64(3)))
+		require.NoError(t, err)
+		require.Empty(t, res, "all the attributes should match")
+	})
+
+	t.Run("update replace attributes", func(t *testing.T) {
+		usr, err := store.Add(context.Background(), &users.User{
+			Email:      "test-attributes-3",
+			Attributes: map[string]interface{}{"department": "sales", "level": float64(3)},
+		})
+		require.NoError(t, err)
+		before := usr.Attributes
+
+		updated, err := store.Update(context.Background(), &users.User{
+			ID:         usr.ID,
+			Email:      "test-attributes-3",
+			Attributes: map[string]interface{}{"department": "hr", "remote": true},
+			Version:    usr.Version,
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"department": "hr", "remote": true}, updated.Attributes)
+		require.Equal(t, map[string]interface{}{"department": "sales", "level": float64(3)}, before)
+
+		updated, err = store.Update(context.Background(), &users.User{ID: usr.ID, Email: "test-attributes-3", Version: updated.Version})
+		require.NoError(t, err)
+		require.Nil(t, updated.Attributes)
+		res, err := store.Search(context.Background(), store.Query().ByAttribute("department", "hr"))
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+}
+
+func runTestNickName(t *testing.T, store userStore) {
+	t.Run("add user with same nickname key", func(t *testing.T) {
+		usr, err := store.Add(context.Background(), &users.User{
+			Email:       "test-nick-1",
+			NickName:    "Bobby",
+			NickNameKey: "bobby",
+		})
+		require.NoError(t, err)
+		used, err := store.NickNameUsed(context.Background(), "bobby")
+		require.NoError(t, err)
+		require.True(t, used)
+
+		_, err = store.Add(context.Background(), &users.User{
+			Email:       "test-nick-2",
+			NickName:    "bοbby",
+			NickNameKey: "bobby",
+		})
+		require.True(t, errors.Is(err, ErrNickNameAlreadyExist))
+
+		_, err = store.Update(context.Background(), &users.User{ID: usr.ID, NickName: "BOBBY", NickNameKey: "bobby", Version: usr.Version})
+		require.NoError(t, err)
+
+		res, err := store.Search(context.Background(), store.Query().ByNickNameKey("bobby"))
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, usr.ID, res[0].ID)
+		res, err = store.Search(context.Background(), store.Query().ByNickName("bobby"))
+		require.NoError(t, err)
+		require.Empty(t, res, "the nickname is matched as provided")
+	})
+	t.Run("update to a nickname already used", func(t *testing.T) {
+		_, err := store.Add(context.Background(), &users.User{
+			Email:       "test-nick-3",
+			NickName:    "alice",
+			NickNameKey: "alice",
+		})
+		require.NoError(t, err)
+		usr, err := store.Add(context.Background(), &users.User{
+			Email:       "test-nick-4",
+			NickName:    "carol",
+			NickNameKey: "carol",
+		})
+		require.NoError(t, err)
+
+		_, err = store.Update(context.Background(), &users.User{ID: usr.ID, NickName: "Alice", NickNameKey: "alice", Version: usr.Version})
+		require.True(t, errors.Is(err, ErrNickNameAlreadyExist))
+
+		_, err = store.Update(context.Background(), &users.User{ID: usr.ID, NickName: "carole", NickNameKey: "carole", Version: usr.Version})
+		require.NoError(t, err)
+		used, _ := store.NickNameUsed(context.Background(), "carol")
+		require.False(t, used)
+
+		updated, err := store.Update(context.Background(), &users.User{ID: usr.ID, Email: "test-nick-4", Version: usr.Version + 1})
+		require.NoError(t, err)
+		require.Empty(t, updated.NickName)
+		used, _ = store.NickNameUsed(context.Background(), "carole")
+		require.False(t, used)
+	})
+	t.Run("nickname is freed by erase", func(t *testing.T) {
+		usr, err := store.Add(context.Background(), &users.User{
+			Email:       "test-nick-5",
+			NickName:    "dave",
+			NickNameKey: "dave",
+		})
+		require.NoError(t, err)
+		_, err = store.Erase(context.Background(), usr)
+		require.NoError(t, err)
+		used, _ := store.NickNameUsed(context.Background(), "dave")
+		require.False(t, used)
+	})
+}
+
+func runTestErase(t *testing.T, store userStore) {
+	t.Run("erase user", func(t *testing.T) {
+		usr, _ := store.Add(context.Background(), &users.User{
+			Email: "test-erase-1",
+		})
+		_, err := store.Erase(context.Background(), usr)
+		require.NoError(t, err)
+
+		res, _ := store.Search(context.Background(), store.Query().ByID(usr.ID).WithDeleted())
+		require.Empty(t, res)
+
+		_, err = store.Add(context.Background(), &users.User{
+			Email: "test-erase-1",
+		})
+		require.NoError(t, err)
+	})
+	t.Run("erase unknown user", func(t *testing.T) {
+		_, err := store.Erase(context.Background(), &users.User{ID: "unknown"})
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrNotFound))
+	})
+}
+
+func runTestRestore(t *testing.T, store userStore) {
+	t.Run("restore deleted user", func(t *testing.T) {
+		usr, _ := store.Add(context.Background(), &users.User{
+			Email: "test-restore-1",
+		})
+		_, err := store.Delete(context.Background(), usr)
+		require.NoError(t, err)
+
+		res, _ := store.Search(context.Background(), store.Query().ByEmail(usr.Email).WithDeleted())
+		require.NotEmpty(t, res)
+		require.NotNil(t, res[0].DeletedAt)
+
+		restored, err := store.Restore(context.Background(), &users.User{ID: usr.ID}, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.Nil(t, restored.DeletedAt)
+
+		res, _ = store.Search(context.Background(), store.Query().ByEmail(usr.Email))
+		require.NotEmpty(t, res)
+	})
+	t.Run("restore after window", func(t *testing.T) {
+		usr, _ := store.Add(context.Background(), &users.User{
+			Email: "test-restore-2",
+		})
+		_, err := store.Delete(context.Background(), usr)
+		require.NoError(t, err)
+
+		_, err = store.Restore(context.Background(), &users.User{ID: usr.ID}, time.Now().Add(time.Hour))
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrNotFound))
+	})
+	t.Run("restore not deleted user", func(t *testing.T) {
+		usr, _ := store.Add(context.Background(), &users.User{
+			Email: "test-restore-3",
+		})
+		_, err := store.Restore(context.Background(), &users.User{ID: usr.ID}, time.Now().Add(-time.Hour))
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrNotFound))
+	})
+	t.Run("deleted user keep its email reserved", func(t *testing.T) {
+		usr, _ := store.Add(context.Background(), &users.User{
+			Email: "test-restore-4",
+		})
+		_, err := store.Delete(context.Background(), usr)
+		require.NoError(t, err)
+
+		_, err = store.Add(context.Background(), &users.User{
+			Email: "test-restore-4",
+		})
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrAlreadyExist))
+	})
+}
+
+func runTestPurge(t *testing.T, store userStore) {
+	t.Run("purge only expired deleted users", func(t *testing.T) {
+		kept, _ := store.Add(context.Background(), &users.User{
+			Email: "test-purge-1",
+		})
+		deleted, _ := store.Add(context.Background(), &users.User{
+			Email: "test-purge-2",
+		})
+		_, err := store.Delete(context.Background(), deleted)
+		require.NoError(t, err)
+
+		purged, err := store.Purge(context.Background(), time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, purged)
+
+		purged, err = store.Purge(context.Background(), time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.NotEmpty(t, purged)
+		for _, usr := range purged {
+			require.NotEqual(t, kept.ID, usr.ID)
+		}
+
+		res, _ := store.Search(context.Background(), store.Query().ByEmail(deleted.Email).WithDeleted())
+		require.Empty(t, res)
+
+		_, err = store.Add(context.Background(), &users.User{
+			Email: "test-purge-2",
+		})
+		require.NoError(t, err)
+	})
 }
 
 func runTestSearch(t *testing.T, store userStore) {
@@ -95,7 +512,7 @@ func runTestUpdate(t *testing.T, store userStore) {
 		usr, err := store.Add(context.Background(), &users.User{
 			FirstName: "test",
 			LastName:  "test",
-			NickName:  "test",
+			NickName:  "test-1",
 			Password:  "test",
 			Email:     "test-update-1",
 		})
@@ -107,6 +524,7 @@ func runTestUpdate(t *testing.T, store userStore) {
 			NickName:  "updated",
 			Password:  "updated",
 			Email:     "test-update-1-updated",
+			Version:   usr.Version,
 		})
 		require.NoError(t, err)
 
@@ -120,6 +538,60 @@ func runTestUpdate(t *testing.T, store userStore) {
 		require.Equal(t, "updated", usrUpdated.NickName)
 		require.Equal(t, "updated", usrUpdated.Password)
 	})
+	t.Run("update to an email already used", func(t *testing.T) {
+		usr, err := store.Add(context.Background(), &users.User{
+			Email: "test-update-2",
+		})
+		require.NoError(t, err)
+		_, err = store.Add(context.Background(), &users.User{
+			Email: "test-update-3",
+		})
+		require.NoError(t, err)
+
+		_, err = store.Update(context.Background(), &users.User{
+			ID:      usr.ID,
+			Email:   "test-update-3",
+			Version: usr.Version,
+		})
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrAlreadyExist))
+
+		_, err = store.Update(context.Background(), &users.User{
+			ID:      usr.ID,
+			Email:   "test-update-2",
+			Version: usr.Version,
+		})
+		require.NoError(t, err)
+	})
+	t.Run("update a user changed since it was read", func(t *testing.T) {
+		usr, err := store.Add(context.Background(), &users.User{
+			Email: "test-update-4",
+		})
+		require.NoError(t, err)
+		updated, err := store.Update(context.Background(), &users.User{
+			ID:      usr.ID,
+			Email:   "test-update-4",
+			Version: usr.Version,
+		})
+		require.NoError(t, err)
+		require.Equal(t, usr.Version+1, updated.Version)
+
+		_, err = store.Update(context.Background(), &users.User{
+			ID:      usr.ID,
+			Email:   "test-update-5",
+			Version: usr.Version,
+		})
+		require.True(t, errors.Is(err, ErrVersionConflict))
+
+		deleted, err := store.Delete(context.Background(), updated)
+		require.NoError(t, err)
+		restored, err := store.Restore(context.Background(), deleted, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, updated.Version+2, restored.Version)
+		res, err := store.Search(context.Background(), store.Query().ByEmail("test-update-5"))
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
 }
 
 func runTestDelete(t *testing.T, store userStore) {
@@ -127,7 +599,7 @@ func runTestDelete(t *testing.T, store userStore) {
 		usr, err := store.Add(context.Background(), &users.User{
 			FirstName: "test",
 			LastName:  "test",
-			NickName:  "test",
+			NickName:  "test-2",
 			Password:  "test",
 			Email:     "test-delete-1",
 		})
@@ -146,7 +618,7 @@ func runTestDelete(t *testing.T, store userStore) {
 		_, err := store.Delete(context.Background(), &users.User{
 			FirstName: "test",
 			LastName:  "test",
-			NickName:  "test",
+			NickName:  "test-3",
 			Password:  "test",
 			Email:     "test-delete-unknown",
 		})
@@ -160,7 +632,7 @@ func runTestAdd(t *testing.T, store userStore) {
 		usr, err := store.Add(context.Background(), &users.User{
 			FirstName: "test",
 			LastName:  "test",
-			NickName:  "test",
+			NickName:  "test-4",
 			Password:  "test",
 			Email:     "test-add-1",
 		})
@@ -171,7 +643,7 @@ func runTestAdd(t *testing.T, store userStore) {
 		usr, err := store.Add(context.Background(), &users.User{
 			FirstName: "test",
 			LastName:  "test",
-			NickName:  "test",
+			NickName:  "test-5",
 			Password:  "test",
 			Email:     "test-add-2",
 		})
@@ -188,4 +660,17 @@ func runTestAdd(t *testing.T, store userStore) {
 		require.Error(t, err)
 		require.True(t, errors.Is(err, ErrAlreadyExist))
 	})
+	t.Run("add user with same canonical email", func(t *testing.T) {
+		_, err := store.Add(context.Background(), &users.User{
+			Email:          "Test.Add.3+news@gmail.com",
+			CanonicalEmail: "testadd3@gmail.com",
+		})
+		require.NoError(t, err)
+		_, err = store.Add(context.Background(), &users.User{
+			Email:          "testadd3@gmail.com",
+			CanonicalEmail: "testadd3@gmail.com",
+		})
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrAlreadyExist))
+	})
 }
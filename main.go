@@ -1,15 +1,34 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"go-users-example/domain/users"
+	"go-users-example/infra/apikeystore"
+	"go-users-example/infra/auditstore"
+	"go-users-example/infra/importstore"
 	"go-users-example/infra/logger"
+	"go-users-example/infra/mfastore"
+	"go-users-example/infra/oidcstore"
+	"go-users-example/infra/phonestore"
 	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/signer"
+	"go-users-example/infra/smssender"
 	"go-users-example/infra/usernotifier"
 	"go-users-example/infra/userstore"
+	"go-users-example/transport/grpc"
 	"go-users-example/transport/http"
 )
 
 func main() {
+	// The import subcommand is a client of a running server, see runImportCommand
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImportCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Load configuration from different sources
 	cfg := Load()
 
@@ -20,9 +39,74 @@ func main() {
 	}
 	log.Debug().Interface("config", cfg).Send()
 
+	// The background jobs run until the server is interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	// Initialise user store
 	usrStore := userstore.NewInMemory()
 
+	// Initialise second factor store
+	mfaStore := mfastore.NewInMemory()
+
+	// Initialise api key store
+	apiKeyStore := apikeystore.NewInMemory()
+
+	// Initialise audit log
+	auditStore := auditstore.NewInMemory()
+
+	// Initialise phone verification store
+	phoneStore := phonestore.NewInMemory()
+
+	// Initialise the store of the OpenID Connect clients, codes and refresh tokens
+	oidcStore := oidcstore.NewInMemory()
+
+	// Initialise the store of the import jobs and of their rows
+	importStore := importstore.NewInMemory()
+
+	// Initialise the sender of the text messages, a stand-in which only log them
+	smsSender := smssender.NewInMemory(log)
+
+	// Initialise the signer of the erasure receipts
+	receiptSigner, err := signer.NewEd25519(cfg.Signer)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't initialise signer")
+	}
+
+	// Initialise the signer of the OpenID Connect tokens
+	tokenSigner, err := signer.NewEd25519(signer.Config{Key: cfg.Signer.TokenKey})
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't initialise token signer")
+	}
+
+	// Initialise the policy of the accepted emails
+	emailPolicy, err := users.NewEmailPolicy(cfg.Users)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't initialise email policy")
+	}
+
+	// Initialise the policy of the accepted names
+	namePolicy, err := users.NewNamePolicy(cfg.Users)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't initialise name policy")
+	}
+
+	// Initialise the validation of the users, the rules and the attribute schema are reloaded on SIGHUP
+	rules, err := LoadRules(cfg.Users)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't load validation rules")
+	}
+	validator, err := users.NewValidator(emailPolicy, namePolicy, rules)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't initialise validator")
+	}
+	attributeSchema, err := LoadAttributeSchema(cfg.Users)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't load attribute schema")
+	}
+	validator.SetAttributeSchema(attributeSchema)
+	go reloadValidation(log, cfg.Users, validator)
+
 	// Initialise user notifier
 	usrNotifier := usernotifier.NewInMemory()
 	go func(c chan *users.ChangeEvent) {
@@ -31,15 +115,110 @@ func main() {
 		}
 	}(usrNotifier.Listen())
 
+	// Record all the changes in the audit log as they are committed, a change which can't be recorded fails
+	usrStore.RecordChanges(users.SetupRecordAudit(log, auditStore))
+
+	// Revoke the api keys of the deleted users
+	go func(c chan *users.ChangeEvent, revoke users.RevokeDeletedUserAPIKeys) {
+		for e := range c {
+			if err := revoke(context.Background(), e); err != nil {
+				log.Error().Err(err).Msg("can't revoke api keys of deleted user")
+			}
+		}
+	}(usrNotifier.Listen(), users.SetupRevokeDeletedUserAPIKeys(log, apiKeyStore, users.SystemClock))
+
+	// Run the purge of deleted users which can't be restored anymore
+	go users.SchedulePurge(context.Background(), log, users.SetupPurge(log, usrNotifier, usrStore, cfg.Users), cfg.Users.PurgeInterval)
+
 	// Build http server
+	hasher := pwdhasher.NewBcrypt()
+	// the v1 and v2 routes share the same use cases
+	createUser := users.SetupCreate(log, usrNotifier, usrStore, hasher, validator)
+	updateUser := users.SetupUpdate(log, usrNotifier, usrStore, hasher, validator)
+	deleteUser := users.SetupDelete(log, usrNotifier, usrStore)
+	restoreUser := users.SetupRestore(log, usrNotifier, usrStore, cfg.Users)
+	authenticateAPIKey := users.SetupAuthenticateAPIKey(log, apiKeyStore, cfg.Users, users.SystemClock)
+	searchUser := users.SetupSearch(log, usrStore, validator)
+	// the login page of the OpenID Connect provider checks the same credentials
+	login := users.SetupLogin(log, usrStore, hasher, mfaStore, mfaStore, mfaStore, cfg.Users, users.SystemClock)
+	loginMFA := users.SetupLoginMFA(log, usrStore, hasher, mfaStore, mfaStore, mfaStore, users.SystemClock)
+	// the import jobs are started and resumed in the background
+	runImport := users.SetupRunImport(log, usrNotifier, usrStore, hasher, validator, importStore, cfg.Users, users.SystemClock)
 	srv := http.NewBuilder(log, cfg.HTTP).
-		WithV1CreateUser(users.SetupCreate(log, usrNotifier, usrStore, pwdhasher.NewBcrypt())).
-		WithV1UpdateUser(users.SetupUpdate(log, usrNotifier, usrStore)).
-		WithV1DeleteUser(users.SetupDelete(log, usrNotifier, usrStore)).
-		WithV1SearchUser(users.SetupSearch(log, usrStore)).
+		WithAPIKeyAuth(authenticateAPIKey).
+		WithPasswordAuth(login, loginMFA).
+		WithOpenAPI().
+		WithV1CreateUser(createUser).
+		WithV1UpdateUser(updateUser).
+		WithV1PatchUser(updateUser).
+		WithV1DeleteUser(deleteUser).
+		WithV1SearchUser(searchUser).
+		WithV2CreateUser(createUser).
+		WithV2GetUser(searchUser).
+		WithV2UpdateUser(updateUser).
+		WithV2DeleteUser(deleteUser).
+		WithV2SearchUser(searchUser).
+		WithGraphQL(searchUser, createUser, updateUser, deleteUser, usrNotifier).
+		WithSCIM(searchUser, createUser, updateUser, deleteUser, restoreUser).
+		WithV1ImportUsers(
+			users.SetupStartImport(ctx, log, importStore, runImport, users.SystemClock),
+			users.SetupGetImportJob(log, importStore),
+			users.SetupResumeImport(ctx, log, importStore, runImport, users.SystemClock),
+		).
+		WithV1RestoreUser(restoreUser).
+		WithV1ListCountries(users.SetupListCountries(log)).
+		WithV1AttributeSchema(users.SetupGetAttributeSchema(log, validator)).
+		WithV1NickNameAvailability(users.SetupCheckNickNameAvailability(log, usrStore, validator)).
+		WithV1VerifyUserPhone(users.SetupVerifyPhone(log, usrStore, phoneStore, hasher, smsSender, cfg.Users, users.SystemClock)).
+		WithV1ConfirmUserPhone(users.SetupConfirmPhone(log, usrNotifier, usrStore, phoneStore, hasher, users.SystemClock)).
+		WithV1EnrollUserMFA(users.SetupEnrollMFA(log, usrStore, mfaStore, hasher, cfg.Users)).
+		WithV1ConfirmUserMFA(users.SetupConfirmMFA(log, mfaStore, users.SystemClock)).
+		WithV1Login(login).
+		WithV1LoginMFA(loginMFA).
+		WithV1CreateUserAPIKey(users.SetupCreateAPIKey(log, usrStore, apiKeyStore, cfg.Users, users.SystemClock)).
+		WithV1ListUserAPIKeys(users.SetupListAPIKeys(log, apiKeyStore)).
+		WithV1RevokeUserAPIKey(users.SetupRevokeAPIKey(log, apiKeyStore, users.SystemClock)).
+		WithV1UserAudit(users.SetupListUserAudit(log, auditStore)).
+		WithV1VerifyAudit(users.SetupVerifyAudit(log, auditStore)).
+		WithV1ExportUsers(users.SetupExportUsers(log, usrStore, validator)).
+		WithV1BatchUsers(users.SetupBatch(log, createUser, updateUser, deleteUser, usrStore, hasher, validator, cfg.Users)).
+		WithV1ExportUserData(users.SetupExportUserData(log, usrStore, mfaStore, apiKeyStore, auditStore, oidcStore, phoneStore, users.SystemClock)).
+		WithV1EraseUser(users.SetupEraseUser(log, usrNotifier, usrStore, map[string]users.UserDataEraser{
+			"mfa":      mfaStore,
+			"phone":    phoneStore,
+			"api_keys": apiKeyStore,
+			"audit":    auditStore,
+			"oidc":     oidcStore,
+		}, receiptSigner, users.SystemClock)).
+		WithV1RegisterOIDCClient(users.SetupRegisterOIDCClient(log, oidcStore, users.SystemClock)).
+		WithOIDC(
+			users.SetupDescribeOIDCProvider(log, tokenSigner, cfg.Users),
+			users.SetupPrepareAuthorization(log, oidcStore),
+			users.SetupAuthorize(log, oidcStore, usrStore, oidcStore, cfg.Users, users.SystemClock),
+			login,
+			loginMFA,
+			users.SetupExchangeToken(log, oidcStore, usrStore, oidcStore, oidcStore, tokenSigner, cfg.Users, users.SystemClock),
+			users.SetupGetUserInfo(log, usrStore, tokenSigner, cfg.Users, users.SystemClock),
+		).
 		WithHealthCheck().
 		Build()
 
+	// Build and run grpc server alongside, with the same use cases
+	grpcSrv := grpc.NewBuilder(log, cfg.GRPC).
+		WithAPIKeyAuth(authenticateAPIKey).
+		WithCreateUser(createUser).
+		WithUpdateUser(updateUser).
+		WithDeleteUser(deleteUser).
+		WithSearchUser(searchUser).
+		WithWatchChanges(usrNotifier).
+		Build()
+	go func() {
+		log.Info().Msg("running grpc server")
+		if err := grpcSrv.Run(); err != nil {
+			log.Fatal().Err(err).Msg("grpc server didn't end correctly")
+		}
+	}()
+
 	// Run HTTP server
 	log.Info().Msg("running http server")
 	if err := srv.Run(); err != nil {
@@ -47,3 +226,29 @@ func main() {
 	}
 	log.Info().Msg("done")
 }
+
+// reloadValidation will reload the validation rules and the attribute schema on each SIGHUP,
+// the current ones are kept if the new ones are invalid
+func reloadValidation(log logger.Logger, c users.Config, validator *users.Validator) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		rules, err := LoadRules(c)
+		if err == nil {
+			err = validator.SetRules(rules)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("can't reload validation rules, keep the current ones")
+		} else {
+			log.Info().Interface("rules", rules).Msg("validation rules reloaded")
+		}
+
+		schema, err := LoadAttributeSchema(c)
+		if err != nil {
+			log.Error().Err(err).Msg("can't reload attribute schema, keep the current one")
+			continue
+		}
+		validator.SetAttributeSchema(schema)
+		log.Info().Msg("attribute schema reloaded")
+	}
+}
@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/transport/grpc/userspb"
+)
+
+const (
+	authorizationMetadata = "authorization"
+	bearer                = "Bearer "
+)
+
+type apiKeyContextKey struct{}
+
+// methodAuthorization is what an api key needs to call a rpc
+type methodAuthorization struct {
+	scope users.Scope
+	// admin rpc act on all the users and need the users:admin scope too
+	admin bool
+}
+
+// methodAuthorizations are the authorizations of the rpc, an unknown rpc is refused
+var methodAuthorizations = map[string]methodAuthorization{
+	userspb.UserService_CreateUser_FullMethodName:   {scope: users.ScopeUsersWrite},
+	userspb.UserService_UpdateUser_FullMethodName:   {scope: users.ScopeUsersWrite},
+	userspb.UserService_DeleteUser_FullMethodName:   {scope: users.ScopeUsersWrite},
+	userspb.UserService_SearchUsers_FullMethodName:  {scope: users.ScopeUsersRead, admin: true},
+	userspb.UserService_WatchChanges_FullMethodName: {scope: users.ScopeUsersRead, admin: true},
+}
+
+// WithAPIKeyAuth will authenticate the calls with the api key of the `authorization: Bearer <key>` metadata, as the
+// http routes do. Every rpc needs a key: `users:write` for the changes and `users:read` otherwise, the search and the
+// changes stream need the `users:admin` scope too. A key without it only updates or deletes its own user.
+// Note: without it every call is refused as unauthenticated
+func (b *Builder) WithAPIKeyAuth(authenticate users.AuthenticateAPIKey) *Builder {
+	b.authenticate = authenticate
+	return b
+}
+
+// unaryAuth will authenticate the unary calls, see WithAPIKeyAuth
+func unaryAuth(log logger.Logger, authenticate users.AuthenticateAPIKey) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticateCall(ctx, log, authenticate, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamAuth is unaryAuth for the streaming calls
+func streamAuth(log logger.Logger, authenticate users.AuthenticateAPIKey) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticateCall(stream.Context(), log, authenticate, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &requestInfoStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authenticateCall will check the api key of the call is allowed to call the rpc and attach it to the context, the
+// key user is the actor of the call
+func authenticateCall(ctx context.Context, log logger.Logger, authenticate users.AuthenticateAPIKey, method string) (context.Context, error) {
+	header := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(authorizationMetadata)) > 0 {
+		header = md.Get(authorizationMetadata)[0]
+	}
+	if authenticate == nil || !strings.HasPrefix(header, bearer) {
+		return nil, status.Error(codes.Unauthenticated, "An api key is required.")
+	}
+	res, err := authenticate(ctx, &users.AuthenticateAPIKeyReq{Key: strings.TrimPrefix(header, bearer)})
+	if err != nil {
+		return nil, toStatus(log, err)
+	}
+	auth, ok := methodAuthorizations[method]
+	if !ok || !res.APIKey.HasScope(auth.scope) || (auth.admin && !res.APIKey.HasScope(users.ScopeUsersAdmin)) {
+		return nil, toStatus(log, fmt.Errorf("api key %s can't call %s: %w", res.APIKey.ID, method, users.ErrForbidden))
+	}
+	info := users.RequestInfoFromContext(ctx)
+	info.Actor = "user:" + res.APIKey.UserID
+	return users.WithRequestInfo(context.WithValue(ctx, apiKeyContextKey{}, res.APIKey), info), nil
+}
+
+// authorizeUser will check the api key of the call can act on the user: a key of the user or with the users:admin
+// scope
+func authorizeUser(ctx context.Context, userID string) error {
+	if key, ok := ctx.Value(apiKeyContextKey{}).(*users.APIKey); ok && (key.UserID == userID || key.HasScope(users.ScopeUsersAdmin)) {
+		return nil
+	}
+	return fmt.Errorf("can't act on user %s: %w", userID, users.ErrForbidden)
+}
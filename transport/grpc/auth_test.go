@@ -0,0 +1,49 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/transport/grpc/userspb"
+)
+
+func TestBuilder_WithAPIKeyAuth(t *testing.T) {
+	var actor string
+	client := newTestClient(t, NewBuilder(logger.Logger{}, Config{}).
+		WithUpdateUser(func(ctx context.Context, req *users.UpdateReq) (*users.UpdateResp, error) {
+			actor = users.RequestInfoFromContext(ctx).Actor
+			return &users.UpdateResp{User: &users.User{ID: req.ID}}, nil
+		}).
+		WithSearchUser(func(ctx context.Context, req *users.SearchReq) (*users.SearchResp, error) {
+			return &users.SearchResp{}, nil
+		}))
+	update := func(ctx context.Context, id string) error {
+		_, err := client.UpdateUser(ctx, &userspb.UpdateUserRequest{
+			User:       &userspb.User{Id: id, FirstName: "test"},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"first_name"}},
+		})
+		return err
+	}
+
+	require.Equal(t, codes.Unauthenticated, status.Code(update(withKey(context.Background(), ""), "testid")))
+	require.Equal(t, codes.Unauthenticated, status.Code(update(withKey(context.Background(), "uak_unknown"), "testid")))
+
+	// a key without the users:admin scope only acts on its own user
+	require.NoError(t, update(withKey(context.Background(), "uak_user"), "testid"))
+	require.Equal(t, "user:testid", actor)
+	require.Equal(t, codes.PermissionDenied, status.Code(update(withKey(context.Background(), "uak_user"), "otherid")))
+	_, err := client.SearchUsers(withKey(context.Background(), "uak_user"), &userspb.SearchUsersRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	require.NoError(t, update(context.Background(), "otherid"))
+	require.Equal(t, "user:adminid", actor)
+	_, err = client.SearchUsers(context.Background(), &userspb.SearchUsersRequest{})
+	require.NoError(t, err)
+}
@@ -0,0 +1,34 @@
+package grpc
+
+import (
+	"context"
+
+	"go-users-example/domain/users"
+	"go-users-example/transport/grpc/userspb"
+)
+
+// WithCreateUser will add the rpc to create new user
+func (b *Builder) WithCreateUser(createUser users.Create) *Builder {
+	b.service.createUser = createUser
+	return b
+}
+
+func (s *userService) CreateUser(ctx context.Context, req *userspb.CreateUserRequest) (*userspb.CreateUserResponse, error) {
+	if s.createUser == nil {
+		return s.UnimplementedUserServiceServer.CreateUser(ctx, req)
+	}
+	res, err := s.createUser(ctx, &users.CreateReq{
+		FirstName:   req.GetFirstName(),
+		LastName:    req.GetLastName(),
+		NickName:    req.GetNickName(),
+		Email:       req.GetEmail(),
+		Country:     req.GetCountry(),
+		RawPassword: req.GetPassword(),
+		Phone:       req.GetPhone(),
+		Attributes:  req.GetAttributes().AsMap(),
+	})
+	if err != nil {
+		return nil, toStatus(s.log, err)
+	}
+	return &userspb.CreateUserResponse{User: toUser(res.User)}, nil
+}
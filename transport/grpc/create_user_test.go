@@ -0,0 +1,45 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/transport/grpc/userspb"
+)
+
+func TestBuilder_WithCreateUser(t *testing.T) {
+	client := newTestClient(t, NewBuilder(logger.Logger{}, Config{}).WithCreateUser(func(ctx context.Context, req *users.CreateReq) (*users.CreateResp, error) {
+		require.Equal(t, "test-request", users.RequestInfoFromContext(ctx).RequestID)
+		if req.Email == "" {
+			verr := &users.ValidationError{Violations: []users.FieldViolation{{Field: "email", Code: users.CodeRequired, Message: "email is required"}}}
+			return nil, fmt.Errorf("can't validate user: %w", verr)
+		}
+		require.Equal(t, map[string]interface{}{"level": float64(3)}, req.Attributes)
+		return &users.CreateResp{User: &users.User{ID: "testid", Email: req.Email, Password: "hash", Attributes: req.Attributes}}, nil
+	}))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "test-request")
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{"level": 3})
+	require.NoError(t, err)
+	res, err := client.CreateUser(ctx, &userspb.CreateUserRequest{Email: "test@test.com", Password: "test", Attributes: attributes})
+	require.NoError(t, err)
+	require.Equal(t, "testid", res.GetUser().GetId())
+	require.Equal(t, "test@test.com", res.GetUser().GetEmail())
+	require.Equal(t, float64(3), res.GetUser().GetAttributes().AsMap()["level"])
+
+	_, err = client.CreateUser(ctx, &userspb.CreateUserRequest{})
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	require.Equal(t, "email", st.Details()[0].(*errdetails.BadRequest).GetFieldViolations()[0].GetField())
+}
@@ -0,0 +1,28 @@
+package grpc
+
+import (
+	"context"
+
+	"go-users-example/domain/users"
+	"go-users-example/transport/grpc/userspb"
+)
+
+// WithDeleteUser will add the rpc to delete a user
+func (b *Builder) WithDeleteUser(deleteUser users.Delete) *Builder {
+	b.service.deleteUser = deleteUser
+	return b
+}
+
+func (s *userService) DeleteUser(ctx context.Context, req *userspb.DeleteUserRequest) (*userspb.DeleteUserResponse, error) {
+	if s.deleteUser == nil {
+		return s.UnimplementedUserServiceServer.DeleteUser(ctx, req)
+	}
+	if err := authorizeUser(ctx, req.GetId()); err != nil {
+		return nil, toStatus(s.log, err)
+	}
+	res, err := s.deleteUser(ctx, &users.DeleteReq{ID: req.GetId()})
+	if err != nil {
+		return nil, toStatus(s.log, err)
+	}
+	return &userspb.DeleteUserResponse{User: toUser(res.User)}, nil
+}
@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+	"go-users-example/transport/grpc/userspb"
+)
+
+func TestBuilder_WithDeleteUser(t *testing.T) {
+	client := newTestClient(t, NewBuilder(logger.Logger{}, Config{}).WithDeleteUser(func(ctx context.Context, req *users.DeleteReq) (*users.DeleteResp, error) {
+		if req.ID != "testid" {
+			return nil, fmt.Errorf("can't delete user: %w", userstore.ErrNotFound)
+		}
+		return &users.DeleteResp{User: &users.User{ID: req.ID}}, nil
+	}))
+
+	res, err := client.DeleteUser(context.Background(), &userspb.DeleteUserRequest{Id: "testid"})
+	require.NoError(t, err)
+	require.Equal(t, "testid", res.GetUser().GetId())
+
+	_, err = client.DeleteUser(context.Background(), &userspb.DeleteUserRequest{Id: "unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestBuilder_Unimplemented(t *testing.T) {
+	client := newTestClient(t, NewBuilder(logger.Logger{}, Config{}))
+	_, err := client.DeleteUser(context.Background(), &userspb.DeleteUserRequest{Id: "testid"})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
@@ -0,0 +1,120 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/transport/grpc/userspb"
+)
+
+//go:generate buf generate
+
+// Config hold configuration for grpc server
+type Config struct {
+	Addr string `env:"GRPC_ADDR" env-default:"0.0.0.0:9090"`
+	// WatchSendTimeout is the time given to a client of WatchChanges to receive a change before its stream is ended
+	WatchSendTimeout time.Duration `env:"GRPC_WATCH_SEND_TIMEOUT" env-default:"10s"`
+}
+
+// ChangeListener will subscribe to the changes of the user base, as usernotifier.InMemory. The chan returned by Watch
+// is closed if the subscriber is too slow
+type ChangeListener interface {
+	Watch() chan *users.ChangeEvent
+	Unlisten(c chan *users.ChangeEvent)
+}
+
+// Builder will construct the grpc server, the rpc of the use cases not provided return Unimplemented
+type Builder struct {
+	c            Config
+	log          logger.Logger
+	service      *userService
+	authenticate users.AuthenticateAPIKey
+}
+
+// NewBuilder will initialise Builder
+func NewBuilder(log logger.Logger, c Config) *Builder {
+	return &Builder{c: c, log: log, service: &userService{log: log, watchSendTimeout: c.WatchSendTimeout}}
+}
+
+// Build will construct the final Server
+func (b *Builder) Build() *Server {
+	server := grpc.NewServer(
+		grpc.InTapHandle(cancelableStream),
+		grpc.ChainUnaryInterceptor(unaryRequestInfo, unaryAuth(b.log, b.authenticate)),
+		grpc.ChainStreamInterceptor(streamRequestInfo, streamAuth(b.log, b.authenticate)),
+	)
+	b.service.stopping = make(chan struct{})
+	userspb.RegisterUserServiceServer(server, b.service)
+	return &Server{
+		log:      b.log.With().Str("component", "grpc_server").Logger(),
+		addr:     b.c.Addr,
+		server:   server,
+		stopping: b.service.stopping,
+	}
+}
+
+// userService implements userspb.UserServiceServer with the use cases given to the Builder
+type userService struct {
+	userspb.UnimplementedUserServiceServer
+	log        logger.Logger
+	createUser users.Create
+	updateUser users.Update
+	deleteUser users.Delete
+	searchUser users.Search
+	listener   ChangeListener
+	// watchSendTimeout is the time to send a change to a watcher, 0 for no limit
+	watchSendTimeout time.Duration
+	// stopping is closed when the server stops, to end the streams
+	stopping chan struct{}
+}
+
+// Server is the configured grpc server to run
+type Server struct {
+	log      logger.Logger
+	addr     string
+	server   *grpc.Server
+	stopping chan struct{}
+	stop     sync.Once
+}
+
+// Run will block to accept grpc request and interrupt on os.Interrupt signal
+func (s *Server) Run() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("can't listen on %s: %w", s.addr, err)
+	}
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt)
+		<-sigint
+
+		// We received an interrupt signal, the pending calls are ended before to stop.
+		s.Stop()
+	}()
+	return s.Serve(lis)
+}
+
+// Serve will block to accept grpc request on the listener until the server is stopped
+func (s *Server) Serve(lis net.Listener) error {
+	if err := s.server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Stop will end the pending calls and stop the server, the streams of the changes are ended
+func (s *Server) Stop() {
+	s.stop.Do(func() {
+		close(s.stopping)
+	})
+	s.server.GracefulStop()
+}
@@ -0,0 +1,74 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"go-users-example/domain/users"
+	"go-users-example/transport/grpc/userspb"
+)
+
+// testKeys are the api keys known by the test servers, the calls of the test clients use testAdminKey by default
+var testKeys = map[string]*users.APIKey{
+	"uak_admin": {ID: "adminkey", UserID: "adminid", Scopes: []users.Scope{users.ScopeUsersRead, users.ScopeUsersWrite, users.ScopeUsersAdmin}},
+	"uak_user":  {ID: "userkey", UserID: "testid", Scopes: []users.Scope{users.ScopeUsersRead, users.ScopeUsersWrite}},
+}
+
+const testAdminKey = "uak_admin"
+
+func authenticateTestKey(_ context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+	key, ok := testKeys[req.Key]
+	if !ok {
+		return nil, users.ErrUnauthenticated
+	}
+	return &users.AuthenticateAPIKeyResp{APIKey: key}, nil
+}
+
+// withKey will make the call with the api key instead of testAdminKey, "" to call without key
+func withKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return metadata.AppendToOutgoingContext(ctx, authorizationMetadata, "")
+	}
+	return metadata.AppendToOutgoingContext(ctx, authorizationMetadata, bearer+key)
+}
+
+// defaultKey will add testAdminKey to the calls without authorization
+func defaultKey(ctx context.Context) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(authorizationMetadata)) > 0 {
+		return ctx
+	}
+	return withKey(ctx, testAdminKey)
+}
+
+// newTestClient will serve the built server on an in-process listener and return a client connected to it, the server
+// knows the testKeys
+func newTestClient(t *testing.T, b *Builder) userspb.UserServiceClient {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := b.WithAPIKeyAuth(authenticateTestKey).Build()
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(defaultKey(ctx), method, req, reply, cc, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(defaultKey(ctx), desc, cc, method, opts...)
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		srv.Stop()
+	})
+	return userspb.NewUserServiceClient(conn)
+}
@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"context"
+	"net"
+
+	"github.com/satori/go.uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"go-users-example/domain/users"
+)
+
+const requestIDMetadata = "x-request-id"
+
+// unaryRequestInfo will attach the origin of the call to its context, to trace the changes it does.
+// The request id is taken from the x-request-id metadata if provided or generated, and returned in the header.
+// Note: the source ip is the peer address
+func unaryRequestInfo(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withRequestInfo(ctx), req)
+}
+
+// streamRequestInfo is unaryRequestInfo for the streaming calls
+func streamRequestInfo(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &requestInfoStream{ServerStream: stream, ctx: withRequestInfo(stream.Context())})
+}
+
+func withRequestInfo(ctx context.Context) context.Context {
+	requestID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(requestIDMetadata)) > 0 {
+		requestID = md.Get(requestIDMetadata)[0]
+	}
+	if requestID == "" {
+		requestID = uuid.NewV4().String()
+	}
+	sourceIP := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		sourceIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(sourceIP); err == nil {
+			sourceIP = host
+		}
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, requestID))
+	return users.WithRequestInfo(ctx, users.RequestInfo{
+		Actor:     users.AnonymousActor,
+		RequestID: requestID,
+		SourceIP:  sourceIP,
+	})
+}
+
+// requestInfoStream replace the context of the stream by the one holding the request info
+type requestInfoStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestInfoStream) Context() context.Context {
+	return s.ctx
+}
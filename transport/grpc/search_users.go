@@ -0,0 +1,43 @@
+package grpc
+
+import (
+	"context"
+
+	"go-users-example/domain/users"
+	"go-users-example/transport/grpc/userspb"
+)
+
+// WithSearchUser will add the rpc to search users
+// Note: here pagination is not implemented, so too many users can break the response
+func (b *Builder) WithSearchUser(searchUser users.Search) *Builder {
+	b.service.searchUser = searchUser
+	return b
+}
+
+func (s *userService) SearchUsers(ctx context.Context, req *userspb.SearchUsersRequest) (*userspb.SearchUsersResponse, error) {
+	if s.searchUser == nil {
+		return s.UnimplementedUserServiceServer.SearchUsers(ctx, req)
+	}
+	var attributes map[string][]string
+	for name, values := range req.GetAttributes() {
+		if attributes == nil {
+			attributes = make(map[string][]string, len(req.GetAttributes()))
+		}
+		attributes[name] = values.GetValues()
+	}
+	res, err := s.searchUser(ctx, &users.SearchReq{
+		IDs:         req.GetIds(),
+		Emails:      req.GetEmails(),
+		FirstName:   req.GetFirstNames(),
+		LastName:    req.GetLastNames(),
+		NickName:    req.GetNickNames(),
+		Country:     req.GetCountries(),
+		Phones:      req.GetPhones(),
+		Attributes:  attributes,
+		WithDeleted: req.GetWithDeleted(),
+	})
+	if err != nil {
+		return nil, toStatus(s.log, err)
+	}
+	return &userspb.SearchUsersResponse{Users: toUsers(res.Users)}, nil
+}
@@ -0,0 +1,42 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/transport/grpc/userspb"
+)
+
+func TestBuilder_WithSearchUser(t *testing.T) {
+	client := newTestClient(t, NewBuilder(logger.Logger{}, Config{}).WithSearchUser(func(ctx context.Context, req *users.SearchReq) (*users.SearchResp, error) {
+		if req.WithDeleted {
+			return nil, errors.New("store unavailable")
+		}
+		require.Equal(t, &users.SearchReq{
+			Emails:     []string{"test@test.com"},
+			Attributes: map[string][]string{"level": {"3", "4"}},
+		}, req)
+		return &users.SearchResp{Users: []*users.User{{ID: "testid", Email: "test@test.com"}}}, nil
+	}))
+
+	res, err := client.SearchUsers(context.Background(), &userspb.SearchUsersRequest{
+		Emails:     []string{"test@test.com"},
+		Attributes: map[string]*userspb.AttributeValues{"level": {Values: []string{"3", "4"}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.GetUsers(), 1)
+	require.Equal(t, "testid", res.GetUsers()[0].GetId())
+
+	// the internal errors aren't exposed
+	_, err = client.SearchUsers(context.Background(), &userspb.SearchUsersRequest{WithDeleted: true})
+	st := status.Convert(err)
+	require.Equal(t, codes.Internal, st.Code())
+	require.NotContains(t, st.Message(), "store unavailable")
+}
@@ -0,0 +1,62 @@
+package grpc
+
+import (
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+// statusType define how a sentinel error is exposed
+type statusType struct {
+	err     error
+	code    codes.Code
+	message string
+}
+
+// statusTypes is the translation of the domain and store errors, the first matching error is used
+var statusTypes = []statusType{
+	{err: users.ErrInvalidUser, code: codes.InvalidArgument, message: "One or more fields of the user aren't valid."},
+	{err: users.ErrUserNotFound, code: codes.NotFound, message: "No user match the provided id."},
+	{err: userstore.ErrNotFound, code: codes.NotFound, message: "No user match the provided id."},
+	{err: userstore.ErrAlreadyExist, code: codes.AlreadyExists, message: "A user with the same email already exist."},
+	{err: userstore.ErrNickNameAlreadyExist, code: codes.AlreadyExists, message: "A user with a similar nickname already exist."},
+	{err: userstore.ErrPhoneAlreadyExist, code: codes.AlreadyExists, message: "A user with the same phone already exist."},
+	{err: users.ErrPreconditionFailed, code: codes.FailedPrecondition, message: "The user isn't at the expected version anymore."},
+	{err: userstore.ErrVersionConflict, code: codes.Aborted, message: "The user has been changed by another call, the change should be retried."},
+	{err: users.ErrUnauthenticated, code: codes.Unauthenticated, message: "The provided api key is unknown, expired or revoked."},
+	{err: users.ErrForbidden, code: codes.PermissionDenied, message: "The api key doesn't allow this call."},
+}
+
+// toStatus will translate the error to its status, the violations of a validation error are given as BadRequest details.
+// Unknown errors are returned as internal errors, the internal error is never exposed
+func toStatus(log logger.Logger, err error) error {
+	for _, st := range statusTypes {
+		if !errors.Is(err, st.err) {
+			continue
+		}
+		s := status.New(st.code, st.message)
+		var verr *users.ValidationError
+		if errors.As(err, &verr) {
+			details := &errdetails.BadRequest{}
+			for _, v := range verr.Violations {
+				details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+					Field:       v.Field,
+					Description: v.Message,
+				})
+			}
+			if withDetails, err := s.WithDetails(details); err == nil {
+				s = withDetails
+			}
+		}
+		log.Debug().Err(err).Str("code", st.code.String()).Msg("call failed")
+		return s.Err()
+	}
+	log.Error().Err(err).Send()
+	return status.Error(codes.Internal, "Internal error.")
+}
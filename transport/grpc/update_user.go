@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go-users-example/domain/users"
+	"go-users-example/transport/grpc/userspb"
+)
+
+// WithUpdateUser will add the rpc to update a user
+func (b *Builder) WithUpdateUser(updateUser users.Update) *Builder {
+	b.service.updateUser = updateUser
+	return b
+}
+
+func (s *userService) UpdateUser(ctx context.Context, req *userspb.UpdateUserRequest) (*userspb.UpdateUserResponse, error) {
+	if s.updateUser == nil {
+		return s.UnimplementedUserServiceServer.UpdateUser(ctx, req)
+	}
+	if err := authorizeUser(ctx, req.GetUser().GetId()); err != nil {
+		return nil, toStatus(s.log, err)
+	}
+	updateReq, err := parseUpdateRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	res, err := s.updateUser(ctx, updateReq)
+	if err != nil {
+		return nil, toStatus(s.log, err)
+	}
+	return &userspb.UpdateUserResponse{User: toUser(res.User)}, nil
+}
+
+// parseUpdateRequest will only set the fields of the update mask, a field of the mask is set even if empty
+func parseUpdateRequest(req *userspb.UpdateUserRequest) (*users.UpdateReq, error) {
+	usr := req.GetUser()
+	updateReq := &users.UpdateReq{ID: usr.GetId()}
+	fields := map[string]struct {
+		field *users.OptionalString
+		value string
+	}{
+		"first_name": {&updateReq.FirstName, usr.GetFirstName()},
+		"last_name":  {&updateReq.LastName, usr.GetLastName()},
+		"nick_name":  {&updateReq.NickName, usr.GetNickName()},
+		"email":      {&updateReq.Email, usr.GetEmail()},
+		"password":   {&updateReq.RawPassword, req.GetPassword()},
+		"country":    {&updateReq.Country, usr.GetCountry()},
+		"phone":      {&updateReq.Phone, usr.GetPhone()},
+	}
+	attributes := usr.GetAttributes().AsMap()
+	for _, path := range req.GetUpdateMask().GetPaths() {
+		if f, ok := fields[path]; ok {
+			*f.field = users.SetString(f.value)
+			continue
+		}
+		switch name := strings.TrimPrefix(path, "attributes."); {
+		case path == "attributes":
+			if updateReq.Attributes.Set {
+				return nil, errors.New("attributes can't be removed and changed at once")
+			}
+			updateReq.Attributes = users.NullAttributes()
+		case name != path && name != "":
+			if updateReq.Attributes.Null {
+				return nil, errors.New("attributes can't be removed and changed at once")
+			}
+			if !updateReq.Attributes.Set {
+				updateReq.Attributes = users.SetAttributes(map[string]interface{}{})
+			}
+			// an attribute absent from the user is removed
+			updateReq.Attributes.Value[name] = attributes[name]
+		default:
+			return nil, fmt.Errorf("unknown field %q in the update mask", path)
+		}
+	}
+	return updateReq, nil
+}
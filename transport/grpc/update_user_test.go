@@ -0,0 +1,55 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/transport/grpc/userspb"
+)
+
+func TestBuilder_WithUpdateUser(t *testing.T) {
+	var got *users.UpdateReq
+	client := newTestClient(t, NewBuilder(logger.Logger{}, Config{}).WithUpdateUser(func(ctx context.Context, req *users.UpdateReq) (*users.UpdateResp, error) {
+		got = req
+		return &users.UpdateResp{User: &users.User{ID: req.ID}}, nil
+	}))
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{"department": "sales"})
+	require.NoError(t, err)
+	_, err = client.UpdateUser(context.Background(), &userspb.UpdateUserRequest{
+		User:       &userspb.User{Id: "testid", FirstName: "test", LastName: "ignored", Attributes: attributes},
+		Password:   "secret",
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"first_name", "nick_name", "password", "attributes.department", "attributes.level"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &users.UpdateReq{
+		ID:          "testid",
+		FirstName:   users.SetString("test"),
+		NickName:    users.SetString(""),
+		RawPassword: users.SetString("secret"),
+		Attributes:  users.SetAttributes(map[string]interface{}{"department": "sales", "level": nil}),
+	}, got)
+
+	_, err = client.UpdateUser(context.Background(), &userspb.UpdateUserRequest{
+		User:       &userspb.User{Id: "testid"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"attributes"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &users.UpdateReq{ID: "testid", Attributes: users.NullAttributes()}, got)
+
+	for _, paths := range [][]string{{"id"}, {"unknown"}, {"attributes", "attributes.level"}} {
+		_, err = client.UpdateUser(context.Background(), &userspb.UpdateUserRequest{
+			User:       &userspb.User{Id: "testid"},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: paths},
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err), paths)
+	}
+}
@@ -0,0 +1,49 @@
+package grpc
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go-users-example/domain/users"
+	"go-users-example/transport/grpc/userspb"
+)
+
+// toUser will convert the user to its protobuf message, the password is never exposed
+func toUser(usr *users.User) *userspb.User {
+	if usr == nil {
+		return nil
+	}
+	res := &userspb.User{
+		Id:              usr.ID,
+		FirstName:       usr.FirstName,
+		LastName:        usr.LastName,
+		NickName:        usr.NickName,
+		Email:           usr.Email,
+		Country:         usr.Country,
+		Phone:           usr.Phone,
+		PhoneVerifiedAt: toTimestamp(usr.PhoneVerifiedAt),
+		DeletedAt:       toTimestamp(usr.DeletedAt),
+	}
+	if len(usr.Attributes) > 0 {
+		// the attributes are scalar json values, they are always accepted by structpb
+		res.Attributes, _ = structpb.NewStruct(usr.Attributes)
+	}
+	return res
+}
+
+func toUsers(usrs []*users.User) []*userspb.User {
+	res := make([]*userspb.User, 0, len(usrs))
+	for _, usr := range usrs {
+		res = append(res, toUser(usr))
+	}
+	return res
+}
+
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,117 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/tap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go-users-example/domain/users"
+	"go-users-example/transport/grpc/userspb"
+)
+
+// WithWatchChanges will add the rpc to stream the changes of the user base, each call subscribe to the listener.
+// the header is sent once the call is subscribed, the changes happening before aren't sent. A client which doesn't
+// receive the changes fast enough is disconnected, the stream ends with ResourceExhausted
+func (b *Builder) WithWatchChanges(listener ChangeListener) *Builder {
+	b.service.listener = listener
+	return b
+}
+
+func (s *userService) WatchChanges(req *userspb.WatchChangesRequest, stream userspb.UserService_WatchChangesServer) error {
+	if s.listener == nil {
+		return s.UnimplementedUserServiceServer.WatchChanges(req, stream)
+	}
+	ops := make(map[users.Operation]bool, len(req.GetOps()))
+	for _, op := range req.GetOps() {
+		ops[users.Operation(op)] = true
+	}
+
+	c := s.listener.Watch()
+	defer func() {
+		// the pending notifications are dropped, Unlisten close the chan
+		go func() {
+			for range c {
+			}
+		}()
+		s.listener.Unlisten(c)
+	}()
+	// the header tell the client the changes are watched from now
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, "The server is stopping.")
+		case evt, ok := <-c:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "The changes aren't received fast enough.")
+			}
+			if len(ops) > 0 && !ops[evt.Op] {
+				continue
+			}
+			if err := s.sendChange(stream, toChangeEvent(evt)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendChange will send the change unless the client doesn't receive it before the send timeout, the stream should be
+// ended on error. A send which doesn't end in time is stopped by canceling the stream, sendChange returns once it ended
+func (s *userService) sendChange(stream userspb.UserService_WatchChangesServer, evt *userspb.ChangeEvent) error {
+	if s.watchSendTimeout <= 0 {
+		return stream.Send(evt)
+	}
+	sent := make(chan error, 1)
+	go func() {
+		sent <- stream.Send(evt)
+	}()
+	timer := time.NewTimer(s.watchSendTimeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case err := <-sent:
+		return err
+	case <-timer.C:
+		err = status.Error(codes.ResourceExhausted, "The changes aren't received fast enough.")
+	case <-s.stopping:
+		err = status.Error(codes.Unavailable, "The server is stopping.")
+	}
+	cancelStream(stream.Context())
+	<-sent
+	return err
+}
+
+// streamCancelKey is the context key of the function canceling the stream of a call, see cancelableStream
+type streamCancelKey struct{}
+
+// cancelableStream is the tap of the calls which let a handler cancel its stream with cancelStream, the pending Send
+// of a canceled stream ends
+func cancelableStream(ctx context.Context, _ *tap.Info) (context.Context, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return context.WithValue(ctx, streamCancelKey{}, cancel), nil
+}
+
+// cancelStream will cancel the stream of the call, its context should come from cancelableStream
+func cancelStream(ctx context.Context) {
+	if cancel, ok := ctx.Value(streamCancelKey{}).(context.CancelFunc); ok {
+		cancel()
+	}
+}
+
+func toChangeEvent(evt *users.ChangeEvent) *userspb.ChangeEvent {
+	return &userspb.ChangeEvent{
+		Time:      timestamppb.New(evt.Time),
+		Op:        string(evt.Op),
+		Before:    toUser(evt.Before),
+		After:     toUser(evt.After),
+		RequestId: evt.Origin.RequestID,
+	}
+}
@@ -0,0 +1,150 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/transport/grpc/userspb"
+)
+
+// countingListener count the subscriptions in progress
+type countingListener struct {
+	*usernotifier.InMemory
+	subscriptions int32
+}
+
+func (l *countingListener) Watch() chan *users.ChangeEvent {
+	atomic.AddInt32(&l.subscriptions, 1)
+	return l.InMemory.Watch()
+}
+
+func (l *countingListener) Unlisten(c chan *users.ChangeEvent) {
+	l.InMemory.Unlisten(c)
+	atomic.AddInt32(&l.subscriptions, -1)
+}
+
+func TestBuilder_WithWatchChanges(t *testing.T) {
+	notifier := &countingListener{InMemory: usernotifier.NewInMemory()}
+	client := newTestClient(t, NewBuilder(logger.Logger{}, Config{}).WithWatchChanges(notifier))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.WatchChanges(ctx, &userspb.WatchChangesRequest{Ops: []string{string(users.DeleteOp)}})
+	require.NoError(t, err)
+	// the subscription is done once the stream is open
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	require.NoError(t, notifier.Notify(&users.ChangeEvent{Op: users.CreateOp, After: &users.User{ID: "created"}}))
+	require.NoError(t, notifier.Notify(&users.ChangeEvent{
+		Time:   time.Now(),
+		Op:     users.DeleteOp,
+		Before: &users.User{ID: "deleted", Password: "hash"},
+		Origin: users.RequestInfo{RequestID: "test-request"},
+	}))
+
+	evt, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "delete", evt.GetOp())
+	require.Equal(t, "deleted", evt.GetBefore().GetId())
+	require.Nil(t, evt.GetAfter())
+	require.Equal(t, "test-request", evt.GetRequestId())
+
+	// the subscription ends with the stream
+	cancel()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&notifier.subscriptions) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// closedListener is a listener which disconnected its subscriber
+type closedListener struct{}
+
+func (closedListener) Watch() chan *users.ChangeEvent {
+	c := make(chan *users.ChangeEvent)
+	close(c)
+	return c
+}
+
+func (closedListener) Unlisten(chan *users.ChangeEvent) {}
+
+func TestBuilder_WithWatchChanges_End(t *testing.T) {
+	t.Run("end a disconnected watcher", func(t *testing.T) {
+		client := newTestClient(t, NewBuilder(logger.Logger{}, Config{}).WithWatchChanges(closedListener{}))
+		stream, err := client.WatchChanges(context.Background(), &userspb.WatchChangesRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+	t.Run("end the streams on stop", func(t *testing.T) {
+		lis := bufconn.Listen(1024 * 1024)
+		srv := NewBuilder(logger.Logger{}, Config{}).WithAPIKeyAuth(authenticateTestKey).WithWatchChanges(usernotifier.NewInMemory()).Build()
+		go func() {
+			_ = srv.Serve(lis)
+		}()
+		conn, err := grpc.Dial("bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		require.NoError(t, err)
+		defer conn.Close()
+		stream, err := userspb.NewUserServiceClient(conn).WatchChanges(withKey(context.Background(), testAdminKey), &userspb.WatchChangesRequest{})
+		require.NoError(t, err)
+		_, err = stream.Header()
+		require.NoError(t, err)
+
+		stopped := make(chan struct{})
+		go func() {
+			srv.Stop()
+			close(stopped)
+		}()
+		select {
+		case <-time.NewTimer(3 * time.Second).C:
+			t.Fatal("the server didn't stop with an open stream, time out after 3sec")
+		case <-stopped:
+		}
+		_, err = stream.Recv()
+		require.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+// blockedStream is a stream whose Send is blocked until the stream is canceled
+type blockedStream struct {
+	userspb.UserService_WatchChangesServer
+	ctx      context.Context
+	returned int32
+}
+
+func (s *blockedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *blockedStream) Send(*userspb.ChangeEvent) error {
+	<-s.ctx.Done()
+	atomic.StoreInt32(&s.returned, 1)
+	return s.ctx.Err()
+}
+
+func TestUserService_sendChange(t *testing.T) {
+	ctx, err := cancelableStream(context.Background(), nil)
+	require.NoError(t, err)
+	stream := &blockedStream{ctx: ctx}
+	s := &userService{watchSendTimeout: 10 * time.Millisecond, stopping: make(chan struct{})}
+
+	err = s.sendChange(stream, &userspb.ChangeEvent{})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	// the send is stopped before sendChange returns
+	require.Equal(t, int32(1), atomic.LoadInt32(&stream.returned))
+}
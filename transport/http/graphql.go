@@ -0,0 +1,203 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"go-users-example/domain/users"
+)
+
+const (
+	defaultGraphQLMaxDepth      = 10
+	defaultGraphQLMaxComplexity = 1000
+	// graphqlListFactor is the number of times the fields selected in a list are counted in the complexity
+	graphqlListFactor = 10
+)
+
+// ChangeListener will subscribe to the changes of the user base, as usernotifier.InMemory. The chan returned by Watch
+// is closed if the subscriber is too slow
+type ChangeListener interface {
+	Watch() chan *users.ChangeEvent
+	Unlisten(c chan *users.ChangeEvent)
+}
+
+// graphqlRequest is a graphql query sent over http or websocket
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	// Extensions are accepted for the clients sending them, they aren't used
+	Extensions map[string]interface{} `json:"extensions"`
+}
+
+// graphqlResponse is the response of a query refused before its execution
+type graphqlResponse struct {
+	Errors gqlerror.List `json:"errors"`
+}
+
+// graphqlResult describe the body of the graphql responses in the openapi document
+type graphqlResult struct {
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Errors     []graphqlResultError   `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type graphqlResultError struct {
+	Message   string `json:"message"`
+	Locations []struct {
+		Line   int `json:"line,omitempty"`
+		Column int `json:"column,omitempty"`
+	} `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// graphqlHandler execute the graphql queries once checked against the depth and complexity limits
+type graphqlHandler struct {
+	schema        *graphql.Schema
+	astSchema     *ast.Schema
+	maxComplexity int
+	// authenticate checks the api keys of the connection_init messages of the websockets
+	authenticate users.AuthenticateAPIKey
+}
+
+// WithGraphQL will add the graphql endpoint, the queries are mapped onto the search and the mutations onto the
+// create, update and delete use cases. the subscriptions are served over websocket with the graphql-transport-ws protocol.
+// The queries need an api key, as the rest routes: a key without the users:admin scope only reads, updates or deletes
+// its own user and can't search nor subscribe. The websockets are authenticated by their connection_init message
+func (b *Builder) WithGraphQL(searchUser users.Search, createUser users.Create, updateUser users.Update, deleteUser users.Delete, listener ChangeListener) *Builder {
+	maxDepth, maxComplexity := b.c.GraphQLMaxDepth, b.c.GraphQLMaxComplexity
+	if maxDepth <= 0 {
+		maxDepth = defaultGraphQLMaxDepth
+	}
+	if maxComplexity <= 0 {
+		maxComplexity = defaultGraphQLMaxComplexity
+	}
+	resolver := &graphqlResolver{
+		log:        b.log.With().Str("component", "graphql").Logger(),
+		searchUser: searchUser,
+		createUser: createUser,
+		updateUser: updateUser,
+		deleteUser: deleteUser,
+		listener:   listener,
+	}
+	h := &graphqlHandler{
+		schema:        graphql.MustParseSchema(graphqlSchema, resolver, graphql.MaxDepth(maxDepth)),
+		astSchema:     gqlparser.MustLoadSchema(&ast.Source{Name: "users.graphql", Input: graphqlSchema}),
+		maxComplexity: maxComplexity,
+		authenticate: func(ctx context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+			// the api keys are checked by the builder, WithAPIKeyAuth can be called after WithGraphQL
+			if b.authenticate == nil {
+				return nil, fmt.Errorf("api keys aren't checked: %w", users.ErrUnauthenticated)
+			}
+			return b.authenticate(ctx, req)
+		},
+	}
+
+	b.handle(operation{
+		method: http.MethodPost, path: "/graphql", id: "graphql", summary: "Execute a graphql query or mutation",
+		description:   "The schema is introspectable. The errors of the operation are returned with a 200 status.",
+		request:       jsonContent(graphqlRequest{}),
+		responses:     []response{{status: http.StatusOK, content: jsonContent(graphqlResult{})}},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req graphqlRequest
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		h.serve(writer, request, &req, false)
+	})
+	b.handle(operation{
+		method: http.MethodGet, path: "/graphql", id: "graphqlQuery", summary: "Execute a graphql query",
+		description: "The mutations are refused. The subscriptions are served on this path with a websocket upgrade, " +
+			"with the " + graphqlWSProtocol + " protocol: the payload of its connection_init message gives the api key " +
+			"as {\"authorization\": \"Bearer <key>\"}, unless the upgrade request has one.",
+		params: []parameter{
+			{name: "query", in: "query"},
+			{name: "operationName", in: "query"},
+			{name: "variables", in: "query", description: "The variables as a json object."},
+		},
+		responses:     []response{{status: http.StatusOK, content: jsonContent(graphqlResult{})}},
+		problems:      []int{http.StatusBadRequest, http.StatusMethodNotAllowed},
+		authenticated: true,
+		websocket: func(writer http.ResponseWriter, request *http.Request) {
+			h.serveWebSocket(b.log, writer, request)
+		},
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		req := graphqlRequest{Query: request.URL.Query().Get("query"), OperationName: request.URL.Query().Get("operationName")}
+		if variables := request.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeStatusProblem(writer, request, http.StatusBadRequest)
+				return
+			}
+		}
+		h.serve(writer, request, &req, true)
+	})
+	return b
+}
+
+// serve will execute a query or a mutation, a GET request only accept the queries
+func (h *graphqlHandler) serve(writer http.ResponseWriter, request *http.Request, req *graphqlRequest, readOnly bool) {
+	op, errs := h.check(req)
+	if errs != nil {
+		writeJSON(writer, http.StatusOK, graphqlResponse{Errors: errs})
+		return
+	}
+	switch {
+	case readOnly && op != ast.Query:
+		writer.Header().Set("Allow", http.MethodPost)
+		writeStatusProblem(writer, request, http.StatusMethodNotAllowed)
+		return
+	case op == ast.Subscription:
+		writeJSON(writer, http.StatusOK, graphqlResponse{Errors: gqlerror.List{gqlerror.Errorf("subscriptions are only served over websocket")}})
+		return
+	}
+	writeJSON(writer, http.StatusOK, h.schema.Exec(request.Context(), req.Query, req.OperationName, req.Variables))
+}
+
+// check will parse and validate the query and return its operation if its complexity is in the limit,
+// the depth is checked by the execution
+func (h *graphqlHandler) check(req *graphqlRequest) (ast.Operation, gqlerror.List) {
+	doc, errs := gqlparser.LoadQuery(h.astSchema, req.Query)
+	if errs != nil {
+		return "", errs
+	}
+	op := doc.Operations.ForName(req.OperationName)
+	if op == nil {
+		return "", gqlerror.List{gqlerror.Errorf("operation %q not found", req.OperationName)}
+	}
+	if complexity := graphqlComplexity(op.SelectionSet); complexity > h.maxComplexity {
+		return "", gqlerror.List{gqlerror.Errorf("query complexity %d exceeds the limit of %d", complexity, h.maxComplexity)}
+	}
+	return op.Operation, nil
+}
+
+// graphqlComplexity count the fields of the selection, the fields selected in a list count graphqlListFactor times
+func graphqlComplexity(set ast.SelectionSet) int {
+	complexity := 0
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			children := graphqlComplexity(s.SelectionSet)
+			if s.Definition != nil && s.Definition.Type.Elem != nil {
+				children *= graphqlListFactor
+			}
+			complexity += 1 + children
+		case *ast.InlineFragment:
+			complexity += graphqlComplexity(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				complexity += graphqlComplexity(s.Definition.SelectionSet)
+			}
+		}
+	}
+	return complexity
+}
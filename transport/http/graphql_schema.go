@@ -0,0 +1,458 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+// graphqlSchema is the graphql representation of the users, the queries and mutations are mapped onto the use cases
+const graphqlSchema = `
+schema {
+	query: Query
+	mutation: Mutation
+	subscription: Subscription
+}
+
+scalar Time
+
+# Attributes are the custom attributes of a user, a json object of scalar values defined by the attribute schema
+scalar Attributes
+
+type User {
+	id: ID!
+	firstName: String!
+	lastName: String!
+	nickName: String!
+	email: String!
+	# country is the ISO 3166-1 alpha-2 code of the country of the user
+	country: String!
+	# phone is the E.164 representation of the phone of the user
+	phone: String
+	phoneVerifiedAt: Time
+	attributes: Attributes
+	# deletedAt is set when the user has been soft deleted
+	deletedAt: Time
+}
+
+input AttributeFilter {
+	name: String!
+	values: [String!]!
+}
+
+type Query {
+	# user returns the user with the id, null if it doesn't exist or is deleted
+	user(id: ID!): User
+	# users returns the users having one of the values of each provided filter
+	users(
+		ids: [ID!]
+		emails: [String!]
+		firstNames: [String!]
+		lastNames: [String!]
+		nickNames: [String!]
+		countries: [String!]
+		phones: [String!]
+		attributes: [AttributeFilter!]
+		withDeleted: Boolean = false
+	): [User!]!
+}
+
+input CreateUserInput {
+	firstName: String
+	lastName: String
+	nickName: String
+	email: String
+	country: String
+	password: String
+	phone: String
+	attributes: Attributes
+}
+
+# UpdateUserInput only change the provided fields, a null field is cleared.
+# the provided attributes are changed one by one, a null attribute is removed and null attributes remove them all
+input UpdateUserInput {
+	id: ID!
+	firstName: String
+	lastName: String
+	nickName: String
+	email: String
+	country: String
+	password: String
+	phone: String
+	attributes: Attributes
+}
+
+type Mutation {
+	createUser(input: CreateUserInput!): User!
+	updateUser(input: UpdateUserInput!): User!
+	deleteUser(id: ID!): User!
+}
+
+type ChangeEvent {
+	time: Time!
+	# op is the operation of the change: create, update, delete, restore, purge or erase
+	op: String!
+	before: User
+	after: User
+	requestId: String
+}
+
+type Subscription {
+	# userChanged stream the changes of the user base, optionally filtered by operation
+	userChanged(ops: [String!]): ChangeEvent!
+}
+`
+
+// graphqlResolver is the root resolver of graphqlSchema
+type graphqlResolver struct {
+	log        logger.Logger
+	searchUser users.Search
+	createUser users.Create
+	updateUser users.Update
+	deleteUser users.Delete
+	listener   ChangeListener
+}
+
+func (r *graphqlResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	if err := authorizeContext(ctx, string(args.ID)); err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	res, err := r.searchUser(ctx, &users.SearchReq{IDs: []string{string(args.ID)}})
+	if err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	if len(res.Users) != 1 {
+		return nil, nil
+	}
+	return &userResolver{res.Users[0]}, nil
+}
+
+type usersArgs struct {
+	IDs        *[]graphql.ID
+	Emails     *[]string
+	FirstNames *[]string
+	LastNames  *[]string
+	NickNames  *[]string
+	Countries  *[]string
+	Phones     *[]string
+	Attributes *[]struct {
+		Name   string
+		Values []string
+	}
+	WithDeleted bool
+}
+
+func (r *graphqlResolver) Users(ctx context.Context, args usersArgs) ([]*userResolver, error) {
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	req := &users.SearchReq{
+		Emails:      stringsOrNil(args.Emails),
+		FirstName:   stringsOrNil(args.FirstNames),
+		LastName:    stringsOrNil(args.LastNames),
+		NickName:    stringsOrNil(args.NickNames),
+		Country:     stringsOrNil(args.Countries),
+		Phones:      stringsOrNil(args.Phones),
+		WithDeleted: args.WithDeleted,
+	}
+	if args.IDs != nil {
+		for _, id := range *args.IDs {
+			req.IDs = append(req.IDs, string(id))
+		}
+	}
+	if args.Attributes != nil {
+		req.Attributes = make(map[string][]string, len(*args.Attributes))
+		for _, filter := range *args.Attributes {
+			req.Attributes[filter.Name] = append(req.Attributes[filter.Name], filter.Values...)
+		}
+	}
+	res, err := r.searchUser(ctx, req)
+	if err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	resolvers := make([]*userResolver, 0, len(res.Users))
+	for _, usr := range res.Users {
+		resolvers = append(resolvers, &userResolver{usr})
+	}
+	return resolvers, nil
+}
+
+type createUserInput struct {
+	FirstName  *string
+	LastName   *string
+	NickName   *string
+	Email      *string
+	Country    *string
+	Password   *string
+	Phone      *string
+	Attributes *graphqlAttributes
+}
+
+func (r *graphqlResolver) CreateUser(ctx context.Context, args struct{ Input createUserInput }) (*userResolver, error) {
+	in := args.Input
+	req := &users.CreateReq{
+		FirstName:   stringOrEmpty(in.FirstName),
+		LastName:    stringOrEmpty(in.LastName),
+		NickName:    stringOrEmpty(in.NickName),
+		Email:       stringOrEmpty(in.Email),
+		Country:     stringOrEmpty(in.Country),
+		RawPassword: stringOrEmpty(in.Password),
+		Phone:       stringOrEmpty(in.Phone),
+	}
+	if in.Attributes != nil {
+		req.Attributes = in.Attributes.Value
+	}
+	res, err := r.createUser(ctx, req)
+	if err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	return &userResolver{res.User}, nil
+}
+
+type updateUserInput struct {
+	ID         graphql.ID
+	FirstName  graphql.NullString
+	LastName   graphql.NullString
+	NickName   graphql.NullString
+	Email      graphql.NullString
+	Country    graphql.NullString
+	Password   graphql.NullString
+	Phone      graphql.NullString
+	Attributes graphqlAttributes
+}
+
+func (r *graphqlResolver) UpdateUser(ctx context.Context, args struct{ Input updateUserInput }) (*userResolver, error) {
+	in := args.Input
+	if err := authorizeScope(ctx, users.ScopeUsersWrite); err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	if err := authorizeContext(ctx, string(in.ID)); err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	res, err := r.updateUser(ctx, &users.UpdateReq{
+		ID:          string(in.ID),
+		FirstName:   toOptionalString(in.FirstName),
+		LastName:    toOptionalString(in.LastName),
+		NickName:    toOptionalString(in.NickName),
+		Email:       toOptionalString(in.Email),
+		RawPassword: toOptionalString(in.Password),
+		Country:     toOptionalString(in.Country),
+		Phone:       toOptionalString(in.Phone),
+		Attributes:  users.OptionalAttributes(in.Attributes),
+	})
+	if err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	return &userResolver{res.User}, nil
+}
+
+func (r *graphqlResolver) DeleteUser(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	if err := authorizeScope(ctx, users.ScopeUsersWrite); err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	if err := authorizeContext(ctx, string(args.ID)); err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	res, err := r.deleteUser(ctx, &users.DeleteReq{ID: string(args.ID)})
+	if err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	return &userResolver{res.User}, nil
+}
+
+// UserChanged subscribe to the listener until the subscription ends, or until the listener disconnects a subscriber
+// which doesn't receive the changes fast enough
+func (r *graphqlResolver) UserChanged(ctx context.Context, args struct{ Ops *[]string }) (<-chan *changeEventResolver, error) {
+	if r.listener == nil {
+		return nil, errors.New("subscriptions aren't available")
+	}
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, graphqlError(r.log, err)
+	}
+	ops := make(map[users.Operation]bool)
+	for _, op := range stringsOrNil(args.Ops) {
+		ops[users.Operation(op)] = true
+	}
+
+	c := r.listener.Watch()
+	events := make(chan *changeEventResolver)
+	go func() {
+		defer close(events)
+		defer func() {
+			// the pending notifications are dropped, Unlisten close the chan
+			go func() {
+				for range c {
+				}
+			}()
+			r.listener.Unlisten(c)
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt, ok := <-c:
+				if !ok {
+					return
+				}
+				if len(ops) > 0 && !ops[evt.Op] {
+					continue
+				}
+				select {
+				case events <- &changeEventResolver{evt}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// userResolver expose the user, its password is never exposed
+type userResolver struct {
+	usr *users.User
+}
+
+func (r *userResolver) ID() graphql.ID    { return graphql.ID(r.usr.ID) }
+func (r *userResolver) FirstName() string { return r.usr.FirstName }
+func (r *userResolver) LastName() string  { return r.usr.LastName }
+func (r *userResolver) NickName() string  { return r.usr.NickName }
+func (r *userResolver) Email() string     { return r.usr.Email }
+func (r *userResolver) Country() string   { return r.usr.Country }
+func (r *userResolver) Phone() *string    { return emptyOrNil(r.usr.Phone) }
+func (r *userResolver) PhoneVerifiedAt() *graphql.Time {
+	if r.usr.PhoneVerifiedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.usr.PhoneVerifiedAt}
+}
+func (r *userResolver) Attributes() *graphqlAttributes {
+	if len(r.usr.Attributes) == 0 {
+		return nil
+	}
+	return &graphqlAttributes{Set: true, Value: r.usr.Attributes}
+}
+func (r *userResolver) DeletedAt() *graphql.Time {
+	if r.usr.DeletedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.usr.DeletedAt}
+}
+
+type changeEventResolver struct {
+	evt *users.ChangeEvent
+}
+
+func (r *changeEventResolver) Time() graphql.Time { return graphql.Time{Time: r.evt.Time} }
+func (r *changeEventResolver) Op() string         { return string(r.evt.Op) }
+func (r *changeEventResolver) Before() *userResolver {
+	if r.evt.Before == nil {
+		return nil
+	}
+	return &userResolver{r.evt.Before}
+}
+func (r *changeEventResolver) After() *userResolver {
+	if r.evt.After == nil {
+		return nil
+	}
+	return &userResolver{r.evt.After}
+}
+func (r *changeEventResolver) RequestID() *string { return emptyOrNil(r.evt.Origin.RequestID) }
+
+// graphqlAttributes is the Attributes scalar, it has the same fields as users.OptionalAttributes to tell
+// absent attributes from null ones
+type graphqlAttributes struct {
+	Set   bool
+	Null  bool
+	Value map[string]interface{}
+}
+
+// ImplementsGraphQLType maps this type to the Attributes scalar
+func (graphqlAttributes) ImplementsGraphQLType(name string) bool {
+	return name == "Attributes"
+}
+
+// UnmarshalGraphQL is only called when the attributes are provided
+func (a *graphqlAttributes) UnmarshalGraphQL(input interface{}) error {
+	*a = graphqlAttributes{Set: true}
+	switch v := input.(type) {
+	case nil:
+		a.Null = true
+	case map[string]interface{}:
+		a.Value = v
+	default:
+		return fmt.Errorf("wrong type for Attributes: %T", v)
+	}
+	return nil
+}
+
+// Nullable allow the null attributes to be unmarshalled
+func (a *graphqlAttributes) Nullable() {}
+
+// MarshalJSON will write the attributes as a json object
+func (a graphqlAttributes) MarshalJSON() ([]byte, error) {
+	return users.OptionalAttributes(a).MarshalJSON()
+}
+
+// graphqlErr is a resolver error exposing the problem matching the error in its extensions
+type graphqlErr struct {
+	message    string
+	extensions map[string]interface{}
+}
+
+func (e *graphqlErr) Error() string                      { return e.message }
+func (e *graphqlErr) Extensions() map[string]interface{} { return e.extensions }
+
+// graphqlError will translate the error with the problemTypes, unknown errors are returned as internal errors
+func graphqlError(log logger.Logger, err error) error {
+	for _, pt := range problemTypes {
+		if !errors.Is(err, pt.err) {
+			continue
+		}
+		ext := map[string]interface{}{"type": problemTypeBase + pt.slug, "status": pt.status, "detail": pt.detail}
+		var verr *users.ValidationError
+		if errors.As(err, &verr) {
+			ext["violations"] = verr.Violations
+		}
+		log.Debug().Err(err).Int("status", pt.status).Msg("graphql resolver failed")
+		return &graphqlErr{message: pt.title, extensions: ext}
+	}
+	log.Error().Err(err).Send()
+	return &graphqlErr{message: "Internal Server Error", extensions: map[string]interface{}{"type": "about:blank", "status": 500}}
+}
+
+func toOptionalString(s graphql.NullString) users.OptionalString {
+	switch {
+	case !s.Set:
+		return users.OptionalString{}
+	case s.Value == nil:
+		return users.NullString()
+	}
+	return users.SetString(*s.Value)
+}
+
+func stringsOrNil(s *[]string) []string {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func emptyOrNil(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
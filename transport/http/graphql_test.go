@@ -0,0 +1,316 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/usernotifier"
+)
+
+type graphqlTestResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// authenticateGraphQLKey knows the "admin" key with the users:admin scope and the "user" key of the user testid
+func authenticateGraphQLKey(_ context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+	switch req.Key {
+	case "admin":
+		return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "adminkey", UserID: "adminid", Scopes: []users.Scope{users.ScopeUsersRead, users.ScopeUsersWrite, users.ScopeUsersAdmin}}}, nil
+	case "user":
+		return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "userkey", UserID: "testid", Scopes: []users.Scope{users.ScopeUsersRead, users.ScopeUsersWrite}}}, nil
+	case "reader":
+		return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "readerkey", UserID: "testid", Scopes: []users.Scope{users.ScopeUsersRead}}}, nil
+	}
+	return nil, users.ErrUnauthenticated
+}
+
+func newGraphQLTestBuilder(t *testing.T, c Config, notifier ChangeListener) *Builder {
+	return NewBuilder(logger.Logger{}, c).WithAPIKeyAuth(authenticateGraphQLKey).WithGraphQL(
+		func(ctx context.Context, req *users.SearchReq) (*users.SearchResp, error) {
+			if len(req.IDs) == 1 && req.IDs[0] == "unknown" {
+				return &users.SearchResp{}, nil
+			}
+			require.Equal(t, map[string][]string{"level": {"3"}}, req.Attributes)
+			return &users.SearchResp{Users: []*users.User{
+				{ID: "testid", Email: "test@test.com", Password: "hash", Attributes: map[string]interface{}{"level": float64(3)}},
+			}}, nil
+		},
+		func(ctx context.Context, req *users.CreateReq) (*users.CreateResp, error) {
+			if req.Email == "" {
+				verr := &users.ValidationError{Violations: []users.FieldViolation{{Field: "email", Code: users.CodeRequired, Message: "email is required"}}}
+				return nil, fmt.Errorf("can't validate user: %w", verr)
+			}
+			return &users.CreateResp{User: &users.User{ID: "testid", Email: req.Email}}, nil
+		},
+		func(ctx context.Context, req *users.UpdateReq) (*users.UpdateResp, error) {
+			require.Equal(t, &users.UpdateReq{
+				ID:         "testid",
+				FirstName:  users.SetString("test"),
+				NickName:   users.NullString(),
+				Attributes: users.SetAttributes(map[string]interface{}{"level": nil}),
+			}, req)
+			return &users.UpdateResp{User: &users.User{ID: req.ID, FirstName: "test"}}, nil
+		},
+		func(ctx context.Context, req *users.DeleteReq) (*users.DeleteResp, error) {
+			return &users.DeleteResp{User: &users.User{ID: req.ID}}, nil
+		},
+		notifier,
+	)
+}
+
+func postGraphQL(t *testing.T, router http.Handler, query string, variables map[string]interface{}) *graphqlTestResponse {
+	return postGraphQLWithKey(t, router, "admin", query, variables)
+}
+
+func postGraphQLWithKey(t *testing.T, router http.Handler, key string, query string, variables map[string]interface{}) *graphqlTestResponse {
+	body, err := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	require.NoError(t, err)
+	req := httptest.NewRequest("POST", "http://localhost/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Equal(t, "application/json", w.Result().Header.Get("Content-Type"))
+
+	var res graphqlTestResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	return &res
+}
+
+func TestBuilder_WithGraphQL(t *testing.T) {
+	router := newGraphQLTestBuilder(t, Config{}, nil).handler()
+
+	res := postGraphQL(t, router, `{ users(attributes: [{name: "level", values: ["3"]}]) { id email attributes } unknown: user(id: "unknown") { id } }`, nil)
+	require.Empty(t, res.Errors)
+	require.JSONEq(t, `[{"id": "testid", "email": "test@test.com", "attributes": {"level": 3}}]`, string(res.Data["users"]))
+	require.JSONEq(t, `null`, string(res.Data["unknown"]))
+
+	res = postGraphQL(t, router, `mutation { createUser(input: {email: "test@test.com"}) { id } }`, nil)
+	require.Empty(t, res.Errors)
+	require.JSONEq(t, `{"id": "testid"}`, string(res.Data["createUser"]))
+
+	res = postGraphQL(t, router, `mutation($input: UpdateUserInput!) { updateUser(input: $input) { firstName } }`, map[string]interface{}{
+		"input": map[string]interface{}{"id": "testid", "firstName": "test", "nickName": nil, "attributes": map[string]interface{}{"level": nil}},
+	})
+	require.Empty(t, res.Errors)
+	require.JSONEq(t, `{"firstName": "test"}`, string(res.Data["updateUser"]))
+
+	res = postGraphQL(t, router, `mutation { deleteUser(id: "testid") { id } }`, nil)
+	require.Empty(t, res.Errors)
+
+	// the errors are translated as problems
+	res = postGraphQL(t, router, `mutation { createUser(input: {}) { id } }`, nil)
+	require.Len(t, res.Errors, 1)
+	require.Equal(t, "Invalid user", res.Errors[0].Message)
+	require.Equal(t, "/problems/invalid-user", res.Errors[0].Extensions["type"])
+	require.Len(t, res.Errors[0].Extensions["violations"], 1)
+
+	// the password isn't exposed
+	res = postGraphQL(t, router, `{ users { password } }`, nil)
+	require.NotEmpty(t, res.Errors)
+}
+
+func TestBuilder_WithGraphQL_Auth(t *testing.T) {
+	router := newGraphQLTestBuilder(t, Config{}, nil).handler()
+
+	req := httptest.NewRequest("POST", "http://localhost/graphql", strings.NewReader(`{"query": "{ user(id: \"testid\") { id } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+
+	req = httptest.NewRequest("GET", "http://localhost/graphql?query="+url.QueryEscape(`{ user(id: "testid") { id } }`), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+
+	// a key without the users:admin scope only acts on its own user
+	res := postGraphQLWithKey(t, router, "user", `mutation { deleteUser(id: "testid") { id } }`, nil)
+	require.Empty(t, res.Errors)
+	for _, query := range []string{
+		`{ user(id: "otherid") { id } }`,
+		`{ users { id } }`,
+		`mutation { deleteUser(id: "otherid") { id } }`,
+	} {
+		res = postGraphQLWithKey(t, router, "user", query, nil)
+		require.Len(t, res.Errors, 1, query)
+		require.Equal(t, "/problems/forbidden", res.Errors[0].Extensions["type"], query)
+	}
+
+	// the mutations require the users:write scope whatever the transport
+	ctx := contextWithAPIKey(context.Background(), &users.APIKey{UserID: "testid", Scopes: []users.Scope{users.ScopeUsersRead}})
+	resolver := &graphqlResolver{}
+	_, err := resolver.DeleteUser(ctx, struct{ ID graphql.ID }{ID: "testid"})
+	require.ErrorContains(t, err, "Forbidden", "the key acts on its own user but can only read")
+	_, err = resolver.UpdateUser(ctx, struct{ Input updateUserInput }{Input: updateUserInput{ID: "testid"}})
+	require.ErrorContains(t, err, "Forbidden", "the key acts on its own user but can only read")
+}
+
+func TestBuilder_WithGraphQL_Get(t *testing.T) {
+	router := newGraphQLTestBuilder(t, Config{}, nil).handler()
+
+	req := httptest.NewRequest("GET", "http://localhost/graphql?query="+url.QueryEscape(`query($id: ID!) { user(id: $id) { id } }`)+
+		"&variables="+url.QueryEscape(`{"id": "unknown"}`), nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.JSONEq(t, `{"data": {"user": null}}`, w.Body.String())
+
+	// the mutations are only accepted with POST
+	req = httptest.NewRequest("GET", "http://localhost/graphql?query="+url.QueryEscape(`mutation { deleteUser(id: "testid") { id } }`), nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusMethodNotAllowed, w.Result().StatusCode)
+}
+
+func TestBuilder_WithGraphQL_Limits(t *testing.T) {
+	router := newGraphQLTestBuilder(t, Config{GraphQLMaxComplexity: 25}, nil).handler()
+
+	res := postGraphQL(t, router, `{ users(attributes: [{name: "level", values: ["3"]}]) { id email } }`, nil)
+	require.Empty(t, res.Errors)
+
+	// the fields selected in a list count ten times
+	res = postGraphQL(t, router, `{ users { ...fields } } fragment fields on User { id email nickName }`, nil)
+	require.Len(t, res.Errors, 1)
+	require.Contains(t, res.Errors[0].Message, "complexity 31")
+
+	router = newGraphQLTestBuilder(t, Config{GraphQLMaxDepth: 1}, nil).handler()
+	res = postGraphQL(t, router, `{ users { id } }`, nil)
+	require.NotEmpty(t, res.Errors)
+	require.Contains(t, res.Errors[0].Message, "depth")
+}
+
+func TestBuilder_WithGraphQL_Subscription(t *testing.T) {
+	notifier := usernotifier.NewInMemory()
+	srv := httptest.NewServer(newGraphQLTestBuilder(t, Config{}, notifier).handler())
+	defer srv.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{graphqlWSProtocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/graphql", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	var msg graphqlWSMessage
+	require.NoError(t, conn.WriteJSON(graphqlWSMessage{Type: "connection_init", Payload: json.RawMessage(`{"authorization": "Bearer admin"}`)}))
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.Equal(t, "connection_ack", msg.Type)
+
+	payload, _ := json.Marshal(graphqlRequest{Query: `subscription { userChanged(ops: ["delete"]) { op before { id } requestId } }`})
+	require.NoError(t, conn.WriteJSON(graphqlWSMessage{ID: "1", Type: "subscribe", Payload: payload}))
+
+	// the subscription is done asynchronously, the events are sent until one is received
+	received := make(chan graphqlWSMessage)
+	go func() {
+		var msg graphqlWSMessage
+		if err := conn.ReadJSON(&msg); err == nil {
+			received <- msg
+		}
+		close(received)
+	}()
+	require.Eventually(t, func() bool {
+		_ = notifier.Notify(&users.ChangeEvent{Op: users.CreateOp, After: &users.User{ID: "created"}})
+		_ = notifier.Notify(&users.ChangeEvent{Op: users.DeleteOp, Before: &users.User{ID: "deleted"}, Origin: users.RequestInfo{RequestID: "test-request"}})
+		select {
+		case msg = <-received:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 3*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, "next", msg.Type)
+	require.Equal(t, "1", msg.ID)
+	require.JSONEq(t, `{"data": {"userChanged": {"op": "delete", "before": {"id": "deleted"}, "requestId": "test-request"}}}`, string(msg.Payload))
+
+	require.NoError(t, conn.WriteJSON(graphqlWSMessage{ID: "1", Type: "complete"}))
+	require.NoError(t, conn.WriteJSON(graphqlWSMessage{Type: "ping"}))
+	for {
+		require.NoError(t, conn.ReadJSON(&msg))
+		if msg.Type != "next" {
+			break
+		}
+	}
+	require.Equal(t, "pong", msg.Type)
+}
+
+// closedListener is a listener which disconnected its subscriber
+type closedListener struct{}
+
+func (closedListener) Watch() chan *users.ChangeEvent {
+	c := make(chan *users.ChangeEvent)
+	close(c)
+	return c
+}
+
+func (closedListener) Unlisten(chan *users.ChangeEvent) {}
+
+func TestBuilder_WithGraphQL_SubscriptionAuth(t *testing.T) {
+	srv := httptest.NewServer(newGraphQLTestBuilder(t, Config{}, closedListener{}).handler())
+	defer srv.Close()
+	dial := func(t *testing.T, init string) *websocket.Conn {
+		dialer := websocket.Dialer{Subprotocols: []string{graphqlWSProtocol}}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/graphql", nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		require.NoError(t, conn.WriteJSON(graphqlWSMessage{Type: "connection_init", Payload: json.RawMessage(init)}))
+		return conn
+	}
+	run := func(t *testing.T, conn *websocket.Conn, query string) graphqlWSMessage {
+		var msg graphqlWSMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		require.Equal(t, "connection_ack", msg.Type)
+		payload, _ := json.Marshal(graphqlRequest{Query: query})
+		require.NoError(t, conn.WriteJSON(graphqlWSMessage{ID: "1", Type: "subscribe", Payload: payload}))
+		require.NoError(t, conn.ReadJSON(&msg))
+		return msg
+	}
+	subscribe := func(t *testing.T, conn *websocket.Conn) graphqlWSMessage {
+		return run(t, conn, `subscription { userChanged { op } }`)
+	}
+
+	for name, init := range map[string]string{"no key": `{}`, "unknown key": `{"authorization": "Bearer unknown"}`} {
+		t.Run(name, func(t *testing.T) {
+			var msg graphqlWSMessage
+			err := dial(t, init).ReadJSON(&msg)
+			var closeErr *websocket.CloseError
+			require.ErrorAs(t, err, &closeErr)
+			require.Equal(t, wsForbidden, closeErr.Code)
+		})
+	}
+	t.Run("not admin", func(t *testing.T) {
+		msg := subscribe(t, dial(t, `{"authorization": "Bearer user"}`))
+		require.Equal(t, "next", msg.Type)
+		require.Contains(t, string(msg.Payload), "Forbidden")
+	})
+	t.Run("mutation with a read only key", func(t *testing.T) {
+		msg := run(t, dial(t, `{"authorization": "Bearer reader"}`), `mutation { deleteUser(id: "testid") { id } }`)
+		require.Equal(t, "error", msg.Type)
+		require.Contains(t, string(msg.Payload), "only the subscriptions")
+	})
+	t.Run("disconnected subscriber", func(t *testing.T) {
+		msg := subscribe(t, dial(t, `{"authorization": "Bearer admin"}`))
+		require.Equal(t, "complete", msg.Type)
+	})
+}
@@ -0,0 +1,250 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+// graphqlWSProtocol is the websocket sub protocol of https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const graphqlWSProtocol = "graphql-transport-ws"
+
+// graphqlWSInitTimeout is the time given to the client to send its connection_init message
+const graphqlWSInitTimeout = 10 * time.Second
+
+// graphqlWSWriteTimeout is the time given to the client to receive a message, a slower client is disconnected
+const graphqlWSWriteTimeout = 10 * time.Second
+
+// the close codes of the protocol
+const (
+	wsInvalidMessage      = 4400
+	wsUnauthorized        = 4401
+	wsForbidden           = 4403
+	wsInitTimeout         = 4408
+	wsSubscriberExists    = 4409
+	wsTooManyInitRequests = 4429
+	wsProtocolNotAccepted = 4406
+)
+
+type graphqlWSMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// graphqlWSInit is the payload of the connection_init message
+type graphqlWSInit struct {
+	// Authorization is the api key of the connection as `Bearer <key>`, the key of the upgrade request if empty
+	Authorization string `json:"authorization"`
+}
+
+var graphqlUpgrader = websocket.Upgrader{Subprotocols: []string{graphqlWSProtocol}}
+
+func isWebSocketUpgrade(request *http.Request) bool {
+	return strings.EqualFold(request.Header.Get("Upgrade"), "websocket")
+}
+
+// serveWebSocket will serve the websocket upgrades with upgrade and the other requests with next
+func serveWebSocket(upgrade http.HandlerFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if isWebSocketUpgrade(request) {
+			upgrade(writer, request)
+			return
+		}
+		next(writer, request)
+	}
+}
+
+// graphqlWSConn is a websocket connection running graphql operations, the writes are serialized
+type graphqlWSConn struct {
+	log  logger.Logger
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+// write will send the message, the connection is closed if the client doesn't receive it in time: the reads fail and
+// the operations are stopped
+func (c *graphqlWSConn) write(msg graphqlWSMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(graphqlWSWriteTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		c.log.Debug().Err(err).Msg("can't write graphql websocket message")
+		_ = c.conn.Close()
+	}
+}
+
+func (c *graphqlWSConn) close(code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+}
+
+// serveWebSocket will run the operations sent over the websocket until the connection is closed,
+// each operation run in its own goroutine until it completes or the client stop it
+func (h *graphqlHandler) serveWebSocket(log logger.Logger, writer http.ResponseWriter, request *http.Request) {
+	ws, err := graphqlUpgrader.Upgrade(writer, request, nil)
+	if err != nil {
+		// the upgrader already replied with an error
+		log.Debug().Err(err).Msg("can't upgrade to websocket")
+		return
+	}
+	defer ws.Close()
+	conn := &graphqlWSConn{log: log, conn: ws}
+	if ws.Subprotocol() != graphqlWSProtocol {
+		conn.close(wsProtocolNotAccepted, "Subprotocol not acceptable")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(request.Context())
+	defer cancel()
+	var (
+		mu         sync.Mutex
+		operations = make(map[string]context.CancelFunc)
+		initDone   bool
+	)
+	_ = ws.SetReadDeadline(time.Now().Add(graphqlWSInitTimeout))
+	for {
+		var msg graphqlWSMessage
+		if err := ws.ReadJSON(&msg); err != nil {
+			var (
+				netErr   net.Error
+				closeErr *websocket.CloseError
+			)
+			if !initDone && errors.As(err, &netErr) && netErr.Timeout() {
+				conn.close(wsInitTimeout, "Connection initialisation timeout")
+			} else if !errors.As(err, &closeErr) {
+				conn.close(wsInvalidMessage, "Invalid message")
+			}
+			return
+		}
+		switch msg.Type {
+		case "connection_init":
+			if initDone {
+				conn.close(wsTooManyInitRequests, "Too many initialisation requests")
+				return
+			}
+			initDone = true
+			authCtx, err := h.authenticateWS(ctx, msg.Payload)
+			if err != nil {
+				log.Debug().Err(err).Msg("can't authenticate graphql websocket")
+				conn.close(wsForbidden, "Forbidden")
+				return
+			}
+			ctx = authCtx
+			_ = ws.SetReadDeadline(time.Time{})
+			conn.write(graphqlWSMessage{Type: "connection_ack"})
+		case "ping":
+			conn.write(graphqlWSMessage{Type: "pong"})
+		case "pong":
+		case "subscribe":
+			if !initDone {
+				conn.close(wsUnauthorized, "Unauthorized")
+				return
+			}
+			var req graphqlRequest
+			if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil {
+				conn.close(wsInvalidMessage, "Invalid message")
+				return
+			}
+			mu.Lock()
+			_, exists := operations[msg.ID]
+			opCtx, opCancel := context.WithCancel(ctx)
+			if !exists {
+				operations[msg.ID] = opCancel
+			}
+			mu.Unlock()
+			if exists {
+				opCancel()
+				conn.close(wsSubscriberExists, "Subscriber for "+msg.ID+" already exists")
+				return
+			}
+			go func(id string) {
+				completed := h.runWS(opCtx, conn, id, &req)
+				mu.Lock()
+				delete(operations, id)
+				mu.Unlock()
+				opCancel()
+				if completed {
+					conn.write(graphqlWSMessage{ID: id, Type: "complete"})
+				}
+			}(msg.ID)
+		case "complete":
+			mu.Lock()
+			if opCancel, ok := operations[msg.ID]; ok {
+				opCancel()
+				delete(operations, msg.ID)
+			}
+			mu.Unlock()
+		default:
+			conn.close(wsInvalidMessage, "Invalid message")
+			return
+		}
+	}
+}
+
+// authenticateWS will return the context of the operations of the websocket, authenticated by the api key of the
+// connection_init payload or of the upgrade request
+func (h *graphqlHandler) authenticateWS(ctx context.Context, payload json.RawMessage) (context.Context, error) {
+	var init graphqlWSInit
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &init); err != nil {
+			return nil, fmt.Errorf("can't read connection_init payload: %w", err)
+		}
+	}
+	if init.Authorization == "" {
+		if _, ok := APIKeyFromContext(ctx); ok {
+			return ctx, nil
+		}
+		return nil, fmt.Errorf("api key required: %w", users.ErrUnauthenticated)
+	}
+	if !strings.HasPrefix(init.Authorization, bearer) {
+		return nil, fmt.Errorf("authorization isn't an api key: %w", users.ErrUnauthenticated)
+	}
+	res, err := h.authenticate(ctx, &users.AuthenticateAPIKeyReq{Key: strings.TrimPrefix(init.Authorization, bearer)})
+	if err != nil {
+		return nil, err
+	}
+	if !res.APIKey.HasScope(users.ScopeUsersRead) {
+		return nil, fmt.Errorf("%s scope required: %w", users.ScopeUsersRead, users.ErrForbidden)
+	}
+	return contextWithAPIKey(ctx, res.APIKey), nil
+}
+
+// runWS will send the results of the subscription, it returns false if the operation failed or has been stopped. The
+// queries and the mutations are refused, they are run by POST /graphql which checks the scope of the request
+func (h *graphqlHandler) runWS(ctx context.Context, conn *graphqlWSConn, id string, req *graphqlRequest) bool {
+	op, errs := h.check(req)
+	if errs == nil && op != ast.Subscription {
+		errs = gqlerror.List{gqlerror.Errorf("only the subscriptions are run over the websocket, not a %s", op)}
+	}
+	if errs != nil {
+		payload, _ := json.Marshal(errs)
+		conn.write(graphqlWSMessage{ID: id, Type: "error", Payload: payload})
+		return false
+	}
+	responses, err := h.schema.Subscribe(ctx, req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		payload, _ := json.Marshal(gqlerror.List{gqlerror.Errorf("%s", err)})
+		conn.write(graphqlWSMessage{ID: id, Type: "error", Payload: payload})
+		return false
+	}
+	for res := range responses {
+		payload, _ := json.Marshal(res)
+		conn.write(graphqlWSMessage{ID: id, Type: "next", Payload: payload})
+	}
+	return ctx.Err() == nil
+}
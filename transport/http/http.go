@@ -10,32 +10,68 @@ import (
 
 	"github.com/go-chi/chi"
 
+	"go-users-example/domain/users"
 	"go-users-example/infra/logger"
 )
 
 // Config hold configuration for http server
 type Config struct {
 	Addr string `env:"HTTP_ADDR" env-default:"0.0.0.0:8080"`
+	// GraphQLMaxDepth is the maximum nesting of the selections of a graphql query
+	GraphQLMaxDepth int `env:"HTTP_GRAPHQL_MAX_DEPTH" env-default:"10"`
+	// GraphQLMaxComplexity is the maximum complexity of a graphql query, each field count for one and the fields
+	// selected in a list count for ten
+	GraphQLMaxComplexity int `env:"HTTP_GRAPHQL_MAX_COMPLEXITY" env-default:"1000"`
+	// MaxBodySize is the size limit in bytes of the request bodies
+	MaxBodySize int64 `env:"HTTP_MAX_BODY_SIZE" env-default:"1048576"`
+	// MaxImportSize is the size limit in bytes of the rows of a user import, they are streamed to the import store
+	MaxImportSize int64 `env:"HTTP_MAX_IMPORT_SIZE" env-default:"67108864"`
 }
 
 // Builder will construct the all http server, setup middleware correctly, etc.
 type Builder struct {
-	c      Config
-	log    logger.Logger
-	router chi.Router
+	c           Config
+	log         logger.Logger
+	router      chi.Router
+	middlewares []func(http.Handler) http.Handler
+	// operations are the routes described in the openapi document
+	operations []operation
+	// authorizations are the routes reading the Authorization header themselves, by method and path: they can't
+	// have path parameters
+	authorizations map[string]bool
+	// login and loginMFA check the password of the users on the routes accepting it, see WithPasswordAuth
+	login    users.Login
+	loginMFA users.LoginMFA
+	// authenticate checks the api keys given outside of the Authorization header, see WithAPIKeyAuth
+	authenticate users.AuthenticateAPIKey
 }
 
 // NewBuilder will initialise Builder
 func NewBuilder(log logger.Logger, c Config) *Builder {
-	return &Builder{c: c, log: log, router: chi.NewRouter()}
+	return &Builder{
+		c:              c,
+		log:            log,
+		router:         chi.NewRouter(),
+		middlewares:    []func(http.Handler) http.Handler{requestInfo},
+		authorizations: make(map[string]bool),
+	}
 }
 
 // Build will construct the final Server
 func (b *Builder) Build() *Server {
 	return &Server{
 		log:    b.log.With().Str("component", "http_server").Logger(),
-		server: &http.Server{Addr: b.c.Addr, Handler: b.router},
+		server: &http.Server{Addr: b.c.Addr, Handler: b.handler()},
+	}
+}
+
+// handler will wrap the router with the middlewares, the first added is the first executed
+func (b *Builder) handler() http.Handler {
+	var h http.Handler = b.router
+	for i := len(b.middlewares) - 1; i >= 0; i-- {
+		h = b.middlewares[i](h)
 	}
+	return h
 }
 
 // Server is the configured http server to run
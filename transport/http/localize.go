@@ -0,0 +1,81 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"go-users-example/domain/users"
+)
+
+// localizedUser is a user as returned by the endpoints, without its password hash, and with the name of its country
+// in the language of the reader
+type localizedUser struct {
+	ID              string                 `json:"id"`
+	FirstName       string                 `json:"first_name"`
+	LastName        string                 `json:"last_name"`
+	NickName        string                 `json:"nick_name"`
+	Email           string                 `json:"email"`
+	Country         string                 `json:"country"`
+	CountryName     string                 `json:"country_name,omitempty"`
+	Phone           string                 `json:"phone,omitempty"`
+	PhoneVerifiedAt *time.Time             `json:"phone_verified_at,omitempty"`
+	Attributes      map[string]interface{} `json:"attributes,omitempty"`
+	DeletedAt       *time.Time             `json:"deleted_at,omitempty"`
+}
+
+// toLocalizedUser will return the user without its password, the name of its country is set if names are provided
+func toLocalizedUser(usr *users.User, names *users.CountryNames) localizedUser {
+	res := localizedUser{
+		ID:              usr.ID,
+		FirstName:       usr.FirstName,
+		LastName:        usr.LastName,
+		NickName:        usr.NickName,
+		Email:           usr.Email,
+		Country:         usr.Country,
+		Phone:           usr.Phone,
+		PhoneVerifiedAt: usr.PhoneVerifiedAt,
+		Attributes:      usr.Attributes,
+		DeletedAt:       usr.DeletedAt,
+	}
+	if names != nil {
+		res.CountryName = names.Name(usr.Country)
+	}
+	return res
+}
+
+// userBody is the body of the endpoints returning a single user
+type userBody struct {
+	User interface{} `json:"user"`
+}
+
+// usersBody is the body of the endpoints returning a list of users
+type usersBody struct {
+	Users interface{} `json:"users"`
+}
+
+// localizeUsers will return the users without their password, with the name of their country when the request ask
+// for a language with Accept-Language
+func localizeUsers(writer http.ResponseWriter, request *http.Request, usrs []*users.User) interface{} {
+	if usrs == nil {
+		return usrs
+	}
+	var names *users.CountryNames
+	if languages := request.Header.Get("Accept-Language"); languages != "" {
+		names = users.NewCountryNames(languages)
+		writer.Header().Set("Content-Language", names.Language())
+	}
+
+	res := make([]localizedUser, 0, len(usrs))
+	for _, usr := range usrs {
+		res = append(res, toLocalizedUser(usr, names))
+	}
+	return res
+}
+
+// localizeUser is localizeUsers for a single user
+func localizeUser(writer http.ResponseWriter, request *http.Request, usr *users.User) interface{} {
+	if usr == nil {
+		return usr
+	}
+	return localizeUsers(writer, request, []*users.User{usr}).([]localizedUser)[0]
+}
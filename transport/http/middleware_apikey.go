@@ -0,0 +1,141 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+type contextKey string
+
+const (
+	apiKeyContextKey contextKey = "api_key"
+	bearer                      = "Bearer "
+	basic                       = "Basic "
+)
+
+// WithAPIKeyAuth will authenticate the requests providing an api key through the `Authorization: Bearer <key>` header.
+// Requests authenticated by an api key are limited to its scopes: `users:read` for GET requests and `users:write`
+// for the others. A key without the `users:admin` scope only acts on its own user, see authorizeUser.
+// The routes reading the Authorization header themselves (ex: the OpenID Connect ones) are left untouched, the basic
+// credentials are left to the routes accepting the password of the user, see WithPasswordAuth.
+// Note: requests without Authorization header are left untouched, the authenticated routes refuse them
+func (b *Builder) WithAPIKeyAuth(authenticate users.AuthenticateAPIKey) *Builder {
+	b.authenticate = authenticate
+	b.middlewares = append(b.middlewares, apiKeyAuth(b.log, authenticate, b.authorizations))
+	return b
+}
+
+// APIKeyFromContext will return the api key which authenticated the request, if any
+func APIKeyFromContext(ctx context.Context) (*users.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(*users.APIKey)
+	return key, ok
+}
+
+// apiKeyAuth will authenticate the api keys, except on the routes of the authorizations which read the header
+// themselves. The routes are added to authorizations until the server is built
+func apiKeyAuth(log logger.Logger, authenticate users.AuthenticateAPIKey, authorizations map[string]bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			header := request.Header.Get("Authorization")
+			if header == "" || authorizations[request.Method+" "+request.URL.Path] || strings.HasPrefix(header, basic) {
+				next.ServeHTTP(writer, request)
+				return
+			}
+			if !strings.HasPrefix(header, bearer) {
+				writeStatusProblem(writer, request, http.StatusUnauthorized)
+				return
+			}
+			res, err := authenticate(request.Context(), &users.AuthenticateAPIKeyReq{Key: strings.TrimPrefix(header, bearer)})
+			if err != nil {
+				writeError(log, writer, request, err)
+				return
+			}
+			if !res.APIKey.HasScope(requiredScope(request)) {
+				writeStatusProblem(writer, request, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(writer, request.WithContext(contextWithAPIKey(request.Context(), res.APIKey)))
+		})
+	}
+}
+
+// contextWithAPIKey will attach the api key authenticating the request to the context, its user is the actor
+func contextWithAPIKey(ctx context.Context, key *users.APIKey) context.Context {
+	info := users.RequestInfoFromContext(ctx)
+	info.Actor = "user:" + key.UserID
+	return users.WithRequestInfo(context.WithValue(ctx, apiKeyContextKey, key), info)
+}
+
+// requireCredentials will refuse the requests which weren't authenticated by an api key or by the password of a user
+func requireCredentials(log logger.Logger, write errorWriter, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		_, hasKey := APIKeyFromContext(request.Context())
+		_, hasPassword := passwordUserFromContext(request.Context())
+		if !hasKey && !hasPassword {
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			write(log, writer, request, fmt.Errorf("credentials required: %w", users.ErrUnauthenticated))
+			return
+		}
+		next(writer, request)
+	}
+}
+
+// requireAdmin will refuse the requests which weren't authenticated by an api key with the users:admin scope, the
+// request should be authenticated
+func requireAdmin(log logger.Logger, write errorWriter, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := authorizeAdmin(request.Context()); err != nil {
+			write(log, writer, request, err)
+			return
+		}
+		next(writer, request)
+	}
+}
+
+// authorizeAdmin will check the caller has an api key with the users:admin scope
+func authorizeAdmin(ctx context.Context) error {
+	if key, ok := APIKeyFromContext(ctx); !ok || !key.HasScope(users.ScopeUsersAdmin) {
+		return fmt.Errorf("%s scope required: %w", users.ScopeUsersAdmin, users.ErrForbidden)
+	}
+	return nil
+}
+
+// authorizeUser will check the caller can act on the user: with an api key of the user or with the users:admin
+// scope, or with the password of the user
+func authorizeUser(request *http.Request, userID string) error {
+	return authorizeContext(request.Context(), userID)
+}
+
+// authorizeContext is authorizeUser for the context of the request, as given to the graphql resolvers
+func authorizeContext(ctx context.Context, userID string) error {
+	if key, ok := APIKeyFromContext(ctx); ok && (key.UserID == userID || key.HasScope(users.ScopeUsersAdmin)) {
+		return nil
+	}
+	if id, ok := passwordUserFromContext(ctx); ok && id == userID {
+		return nil
+	}
+	return fmt.Errorf("can't act on user %s: %w", userID, users.ErrForbidden)
+}
+
+// authorizeScope will check the api key of the context has the scope, as required by the route for a request. The
+// password of a user isn't limited to scopes
+func authorizeScope(ctx context.Context, scope users.Scope) error {
+	if key, ok := APIKeyFromContext(ctx); ok && !key.HasScope(scope) {
+		return fmt.Errorf("%s scope required: %w", scope, users.ErrForbidden)
+	}
+	return nil
+}
+
+func requiredScope(request *http.Request) users.Scope {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return users.ScopeUsersRead
+	default:
+		return users.ScopeUsersWrite
+	}
+}
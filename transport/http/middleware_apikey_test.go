@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithAPIKeyAuth(t *testing.T) {
+	handler := NewBuilder(logger.Logger{}, Config{}).
+		WithAPIKeyAuth(func(ctx context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+			switch req.Key {
+			case "valid":
+				return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "key", UserID: "ownerid", Scopes: []users.Scope{users.ScopeUsersRead, users.ScopeUsersWrite}}}, nil
+			case "read":
+				return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "key", UserID: "ownerid", Scopes: []users.Scope{users.ScopeUsersRead}}}, nil
+			case "admin":
+				return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "key", UserID: "adminid", Scopes: []users.Scope{users.ScopeUsersRead, users.ScopeUsersWrite, users.ScopeUsersAdmin}}}, nil
+			}
+			return nil, users.ErrUnauthenticated
+		}).
+		WithV1SearchUser(func(ctx context.Context, req *users.SearchReq) (*users.SearchResp, error) {
+			return &users.SearchResp{}, nil
+		}).
+		WithV1DeleteUser(func(ctx context.Context, req *users.DeleteReq) (*users.DeleteResp, error) {
+			return &users.DeleteResp{User: &users.User{}}, nil
+		}).
+		handler()
+
+	for name, tc := range map[string]struct {
+		method string
+		auth   string
+		id     string
+		status int
+	}{
+		"no header":       {method: "DELETE", id: "ownerid", status: http.StatusUnauthorized},
+		"owner key":       {method: "DELETE", auth: "Bearer valid", id: "ownerid", status: http.StatusOK},
+		"other user":      {method: "DELETE", auth: "Bearer valid", id: "otherid", status: http.StatusForbidden},
+		"admin key":       {method: "DELETE", auth: "Bearer admin", id: "otherid", status: http.StatusOK},
+		"invalid key":     {method: "DELETE", auth: "Bearer invalid", id: "ownerid", status: http.StatusUnauthorized},
+		"wrong scheme":    {method: "DELETE", auth: "Token valid", id: "ownerid", status: http.StatusUnauthorized},
+		"missing scope":   {method: "DELETE", auth: "Bearer read", id: "ownerid", status: http.StatusForbidden},
+		"search no key":   {method: "GET", status: http.StatusUnauthorized},
+		"search no admin": {method: "GET", auth: "Bearer valid", status: http.StatusForbidden},
+		"search admin":    {method: "GET", auth: "Bearer admin", status: http.StatusOK},
+	} {
+		url, body := "http://localhost/v1/users", ""
+		if tc.method == "DELETE" {
+			url, body = "http://localhost/v1/user", `{"id":"`+tc.id+`"}`
+		}
+		req := httptest.NewRequest(tc.method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, tc.status, w.Result().StatusCode, name)
+	}
+}
+
+func TestBuilder_WithPasswordAuth(t *testing.T) {
+	handler := NewBuilder(logger.Logger{}, Config{}).
+		WithPasswordAuth(func(ctx context.Context, req *users.LoginReq) (*users.LoginResp, error) {
+			switch {
+			case req.RawPassword != "secret":
+				return nil, users.ErrInvalidCredentials
+			case req.Email == "mfa@test.com":
+				return &users.LoginResp{ChallengeID: "challengeid"}, nil
+			}
+			return &users.LoginResp{User: &users.User{ID: "ownerid"}}, nil
+		}, func(ctx context.Context, req *users.LoginMFAReq) (*users.LoginResp, error) {
+			if req.ChallengeID != "challengeid" || req.Code != "123456" {
+				return nil, users.ErrInvalidCredentials
+			}
+			return &users.LoginResp{User: &users.User{ID: "mfaid"}}, nil
+		}).
+		WithV1CreateUserAPIKey(func(ctx context.Context, req *users.CreateAPIKeyReq) (*users.CreateAPIKeyResp, error) {
+			return &users.CreateAPIKeyResp{APIKey: &users.APIKey{ID: "keyid", UserID: req.UserID}, Key: "uak_key"}, nil
+		}).
+		handler()
+
+	for name, tc := range map[string]struct {
+		email, password, code, id string
+		status                    int
+	}{
+		"no credentials": {id: "ownerid", status: http.StatusUnauthorized},
+		"password":       {email: "owner@test.com", password: "secret", id: "ownerid", status: http.StatusOK},
+		"wrong password": {email: "owner@test.com", password: "wrong", id: "ownerid", status: http.StatusUnauthorized},
+		"other user":     {email: "owner@test.com", password: "secret", id: "otherid", status: http.StatusForbidden},
+		"mfa code":       {email: "mfa@test.com", password: "secret", code: "123456", id: "mfaid", status: http.StatusOK},
+		"missing mfa":    {email: "mfa@test.com", password: "secret", id: "mfaid", status: http.StatusUnauthorized},
+		"wrong mfa code": {email: "mfa@test.com", password: "secret", code: "000000", id: "mfaid", status: http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("POST", "http://localhost/v1/user/api-keys", strings.NewReader(`{"id":"`+tc.id+`","name":"ci","scopes":["users:read"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.email != "" {
+			req.SetBasicAuth(tc.email, tc.password)
+		}
+		if tc.code != "" {
+			req.Header.Set(mfaCodeHeader, tc.code)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, tc.status, w.Result().StatusCode, name)
+	}
+}
+
+// withAPIKey will authenticate the request as if an api key of the user with the scopes was given
+func withAPIKey(req *http.Request, userID string, scopes ...users.Scope) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), apiKeyContextKey, &users.APIKey{ID: "keyid", UserID: userID, Scopes: scopes}))
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+const (
+	passwordUserContextKey contextKey = "password_user"
+	// mfaCodeHeader is the header of the second factor code of the users who enabled it, with their password
+	mfaCodeHeader = "X-MFA-Code"
+)
+
+// WithPasswordAuth will let the routes accepting the password of the user (ex: the creation of the first api key)
+// authenticate it through the `Authorization: Basic <email:password>` header. The users who enabled their second
+// factor also give its code in the X-MFA-Code header
+func (b *Builder) WithPasswordAuth(login users.Login, loginMFA users.LoginMFA) *Builder {
+	b.login, b.loginMFA = login, loginMFA
+	return b
+}
+
+// passwordUserFromContext will return the id of the user who gave its password, if any
+func passwordUserFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(passwordUserContextKey).(string)
+	return id, ok
+}
+
+// passwordAuth will authenticate the basic credentials of the request as the email and password of a user, the
+// requests with other credentials are left untouched
+func (b *Builder) passwordAuth(write errorWriter, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		email, password, ok := request.BasicAuth()
+		if !ok || b.login == nil {
+			next(writer, request)
+			return
+		}
+		usr, err := b.authenticatePassword(request.Context(), email, password, request.Header.Get(mfaCodeHeader))
+		if err != nil {
+			writer.Header().Set("WWW-Authenticate", "Basic")
+			write(b.log, writer, request, err)
+			return
+		}
+		info := users.RequestInfoFromContext(request.Context())
+		info.Actor = "user:" + usr.ID
+		ctx := users.WithRequestInfo(context.WithValue(request.Context(), passwordUserContextKey, usr.ID), info)
+		next(writer, request.WithContext(ctx))
+	}
+}
+
+func (b *Builder) authenticatePassword(ctx context.Context, email, password, code string) (*users.User, error) {
+	res, err := b.login(ctx, &users.LoginReq{Email: email, RawPassword: password})
+	if err != nil {
+		return nil, err
+	}
+	if res.ChallengeID == "" {
+		return res.User, nil
+	}
+	if code == "" {
+		return nil, fmt.Errorf("the second factor code is required: %w", users.ErrInvalidCredentials)
+	}
+	res, err = b.loginMFA(ctx, &users.LoginMFAReq{ChallengeID: res.ChallengeID, Code: code})
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
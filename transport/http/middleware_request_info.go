@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/satori/go.uuid"
+
+	"go-users-example/domain/users"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// requestInfo will attach the origin of the request to its context, to trace the changes it does.
+// The request id is taken from the X-Request-ID header if provided or generated, and returned in the response.
+// Note: the source ip is the peer address, proxies headers aren't trusted
+func requestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requestID := request.Header.Get(requestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewV4().String()
+		}
+		sourceIP, _, err := net.SplitHostPort(request.RemoteAddr)
+		if err != nil {
+			sourceIP = request.RemoteAddr
+		}
+		writer.Header().Set(requestIDHeader, requestID)
+		ctx := users.WithRequestInfo(request.Context(), users.RequestInfo{
+			Actor:     users.AnonymousActor,
+			RequestID: requestID,
+			SourceIP:  sourceIP,
+		})
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
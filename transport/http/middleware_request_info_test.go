@@ -0,0 +1,43 @@
+package http
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_RequestInfo(t *testing.T) {
+	var info users.RequestInfo
+	handler := NewBuilder(logger.Logger{}, Config{}).
+		WithAPIKeyAuth(func(ctx context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+			return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "keyid", UserID: "userid", Scopes: []users.Scope{users.ScopeUsersRead}}}, nil
+		}).
+		WithV1ListCountries(func(ctx context.Context, req *users.ListCountriesReq) (*users.ListCountriesResp, error) {
+			info = users.RequestInfoFromContext(ctx)
+			return &users.ListCountriesResp{}, nil
+		}).
+		handler()
+
+	req := httptest.NewRequest("GET", "http://localhost/v1/countries", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, users.RequestInfo{Actor: users.AnonymousActor, RequestID: "req-1", SourceIP: "10.0.0.1"}, info)
+	require.Equal(t, "req-1", w.Result().Header.Get("X-Request-ID"))
+
+	req = httptest.NewRequest("GET", "http://localhost/v1/countries", nil)
+	req.Header.Set("Authorization", "Bearer key")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, "user:userid", info.Actor)
+	require.NotEmpty(t, info.RequestID)
+	require.NotEmpty(t, w.Result().Header.Get("X-Request-ID"))
+}
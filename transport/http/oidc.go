@@ -0,0 +1,460 @@
+package http
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+const (
+	htmlContentType = "text/html"
+	// pageSecurityPolicy forbid the scripts and the framing of the login page
+	pageSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'"
+)
+
+// errAccessDenied is returned to the client when the user denied its authorization request
+var errAccessDenied = errors.New("access denied")
+
+// oauthError is the OAuth 2.0 representation of an error (RFC 6749 section 5.2)
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// oauthErrorTypes is the translation of the errors to their OAuth 2.0 code, the first matching error is used. The
+// message of the detailed errors is given as description. The other errors are described by their problemType
+var oauthErrorTypes = []struct {
+	err         error
+	code        string
+	status      int
+	description string
+	detailed    bool
+}{
+	{err: users.ErrInvalidClient, code: "invalid_client", status: http.StatusUnauthorized, description: "The client is unknown or its authentication failed."},
+	{err: users.ErrInvalidRedirectURI, code: "invalid_request", status: http.StatusBadRequest, detailed: true},
+	{err: users.ErrInvalidOIDCRequest, code: "invalid_request", status: http.StatusBadRequest, detailed: true},
+	{err: users.ErrInvalidScope, code: "invalid_scope", status: http.StatusBadRequest, detailed: true},
+	{err: users.ErrUnsupportedGrantType, code: "unsupported_grant_type", status: http.StatusBadRequest, detailed: true},
+	{err: users.ErrInvalidGrant, code: "invalid_grant", status: http.StatusBadRequest, description: "The grant is invalid, expired or was issued to another client."},
+	{err: users.ErrInvalidToken, code: "invalid_token", status: http.StatusUnauthorized, description: "The access token is invalid or expired."},
+	{err: errAccessDenied, code: "access_denied", status: http.StatusForbidden, description: "The user denied the authorization request."},
+}
+
+// oauthFormat is the format of the errors of the token and userinfo endpoints, see writeOAuthError
+var oauthFormat = &errorFormat{contentType: jsonContentType, body: oauthError{}, write: writeOAuthError}
+
+// authorizeFormat is the format of the errors of the authorization endpoint: an html page for the errors which can't
+// be sent to the client, see writeAuthorizeError
+var authorizeFormat = &errorFormat{contentType: htmlContentType, write: writeAuthorizeError}
+
+// newOAuthError will translate the error to its OAuth 2.0 representation and its status. Unknown errors are returned
+// as server errors
+func newOAuthError(err error) (int, *oauthError) {
+	for _, t := range oauthErrorTypes {
+		if !errors.Is(err, t.err) {
+			continue
+		}
+		e := &oauthError{Code: t.code, Description: t.description}
+		if t.detailed {
+			e.Description = err.Error()
+		}
+		return t.status, e
+	}
+	for _, pt := range problemTypes {
+		if !errors.Is(err, pt.err) {
+			continue
+		}
+		e := &oauthError{Code: "invalid_request", Description: pt.detail}
+		if detail := violationsDetail(err); detail != "" {
+			e.Description = detail
+		}
+		return pt.status, e
+	}
+	return http.StatusInternalServerError, &oauthError{Code: "server_error", Description: http.StatusText(http.StatusInternalServerError)}
+}
+
+// writeOAuthError will translate the error to its OAuth 2.0 representation and write it, with the challenge of the
+// authentication errors
+func writeOAuthError(log logger.Logger, writer http.ResponseWriter, request *http.Request, err error) {
+	status, e := newOAuthError(err)
+	logOIDCError(log, err, status)
+	switch {
+	case e.Code == "invalid_token":
+		writer.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	case e.Code == "invalid_client" && request.Header.Get("Authorization") != "":
+		writer.Header().Set("WWW-Authenticate", "Basic")
+	}
+	writer.Header().Set("Cache-Control", "no-store")
+	writeJSON(writer, status, e)
+}
+
+// redirectError is an error of a valid authorization request, it is sent to the redirect uri of the client
+type redirectError struct {
+	redirectURI string
+	state       string
+	err         error
+}
+
+func (e *redirectError) Error() string { return e.err.Error() }
+func (e *redirectError) Unwrap() error { return e.err }
+
+// authorizationError will return the error to send to the client of the authorization request. The request is only
+// trusted once its client and redirect uri are validated, the other errors are shown to the user
+func authorizationError(req users.AuthorizationReq, err error) error {
+	if errors.Is(err, users.ErrInvalidOIDCRequest) || errors.Is(err, users.ErrInvalidScope) {
+		return &redirectError{redirectURI: req.RedirectURI, state: req.State, err: err}
+	}
+	return err
+}
+
+// writeAuthorizeError will redirect the user to the client with the redirect errors, the other errors are shown on
+// an html page
+func writeAuthorizeError(log logger.Logger, writer http.ResponseWriter, request *http.Request, err error) {
+	status, e := newOAuthError(err)
+	logOIDCError(log, err, status)
+	var rerr *redirectError
+	if errors.As(err, &rerr) {
+		params := url.Values{"error": {e.Code}, "error_description": {e.Description}}
+		if rerr.state != "" {
+			params.Set("state", rerr.state)
+		}
+		redirectTo(writer, rerr.redirectURI, params)
+		return
+	}
+	// an unknown client isn't an authentication failure of the user
+	if status == http.StatusUnauthorized {
+		status = http.StatusBadRequest
+	}
+	writePage(log, writer, status, "error", e.Description)
+}
+
+func logOIDCError(log logger.Logger, err error, status int) {
+	if status >= http.StatusInternalServerError {
+		log.Error().Err(err).Send()
+		return
+	}
+	log.Debug().Err(err).Int("status", status).Msg("oidc request failed")
+}
+
+// redirectTo will redirect to the uri with the params added to its query, without body
+func redirectTo(writer http.ResponseWriter, uri string, params url.Values) {
+	u, _ := url.Parse(uri)
+	query := u.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	u.RawQuery = query.Encode()
+	writer.Header().Set("Location", u.String())
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(http.StatusFound)
+}
+
+// pages are the html templates of the authorization endpoint
+//
+//go:embed oidc_authorize.html
+var pagesSource string
+
+var pages = template.Must(template.New("pages").Parse(pagesSource))
+
+// authorizePage is the login and consent page of an authorization request, the second factor is asked once the
+// password is checked when ChallengeID is set
+type authorizePage struct {
+	Request     users.AuthorizationReq
+	Client      string
+	Scopes      []string
+	Email       string
+	ChallengeID string
+	Error       string
+}
+
+// writePage will render the template with the data, the page can't be framed or cached
+func writePage(log logger.Logger, writer http.ResponseWriter, status int, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Error().Err(err).Str("template", name).Msg("can't render page")
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", htmlContentType+"; charset=utf-8")
+	writer.Header().Set("Content-Security-Policy", pageSecurityPolicy)
+	writer.Header().Set("X-Frame-Options", "DENY")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(status)
+	_, _ = writer.Write(buf.Bytes())
+}
+
+// authorizeForm is the form of the login page, Consent is the button clicked by the user
+type authorizeForm struct {
+	users.AuthorizationReq
+	Email       string `json:"email,omitempty"`
+	Password    string `json:"password,omitempty"`
+	ChallengeID string `json:"challenge_id,omitempty"`
+	Code        string `json:"code,omitempty"`
+	Consent     string `json:"consent,omitempty"`
+}
+
+// openIDConfiguration is the discovery document of the provider (OpenID Connect Discovery section 3)
+type openIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+}
+
+func newOpenIDConfiguration(provider *users.DescribeOIDCProviderResp) *openIDConfiguration {
+	issuer := strings.TrimSuffix(provider.Issuer, "/")
+	return &openIDConfiguration{
+		Issuer:                            provider.Issuer,
+		AuthorizationEndpoint:             issuer + "/oauth2/authorize",
+		TokenEndpoint:                     issuer + "/oauth2/token",
+		UserInfoEndpoint:                  issuer + "/oauth2/userinfo",
+		JWKSURI:                           issuer + "/oauth2/jwks",
+		ScopesSupported:                   provider.Scopes,
+		ClaimsSupported:                   provider.Claims,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{users.GrantAuthorizationCode, users.GrantRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{provider.SigningAlg},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+	}
+}
+
+// jwkSet is the set of the keys verifying the tokens (RFC 7517 section 5)
+type jwkSet struct {
+	Keys []users.JWK `json:"keys"`
+}
+
+// authorizationParams are the query parameters of an authorization request
+var authorizationParams = []parameter{
+	{name: "client_id", in: "query", description: "The id of the registered client.", example: "testid"},
+	{name: "redirect_uri", in: "query", description: "A redirect uri registered by the client."},
+	{name: "response_type", in: "query", description: "Only `code` is supported."},
+	{name: "scope", in: "query", description: "The space separated scopes, `openid` is required."},
+	{name: "state", in: "query", description: "An opaque value sent back to the redirect uri."},
+	{name: "nonce", in: "query", description: "An opaque value added to the id token."},
+	{name: "code_challenge", in: "query", description: "The PKCE challenge of the code verifier."},
+	{name: "code_challenge_method", in: "query", description: "Only `S256` is supported."},
+}
+
+// WithOIDC will add the endpoints of an OpenID Connect provider: the discovery document, the keys of the tokens, the
+// authorization code flow with PKCE (RFC 7636) and the userinfo endpoint. The users log in on the authorization
+// page with their password, and their second factor when they enabled it.
+// Note: no session is kept, the users log in on each authorization
+func (b *Builder) WithOIDC(describe users.DescribeOIDCProvider, prepare users.PrepareAuthorization, authorize users.Authorize, login users.Login, loginMFA users.LoginMFA, exchange users.ExchangeToken, userInfo users.GetUserInfo) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/.well-known/openid-configuration", id: "oidcDiscovery",
+		summary:   "Get the OpenID Connect discovery document",
+		responses: []response{{status: http.StatusOK, content: jsonContent(openIDConfiguration{})}},
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		res, err := describe(request.Context())
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, newOpenIDConfiguration(res))
+	})
+	b.handle(operation{
+		method: http.MethodGet, path: "/oauth2/jwks", id: "oidcKeys", summary: "Get the public keys verifying the tokens",
+		responses: []response{{status: http.StatusOK, content: jsonContent(jwkSet{})}},
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		res, err := describe(request.Context())
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, jwkSet{Keys: res.Keys})
+	})
+
+	b.handle(operation{
+		method: http.MethodGet, path: "/oauth2/authorize", id: "oidcAuthorize", summary: "Show the login page of an authorization request",
+		description: "The errors of a valid request are sent to its redirect uri, the others are shown to the user.",
+		params:      authorizationParams,
+		responses: []response{
+			{status: http.StatusOK, description: "The login page.", content: []content{{contentType: htmlContentType}}},
+			{status: http.StatusFound, headers: map[string]string{"Location": "The redirect uri with the error."}},
+		},
+		problems: []int{http.StatusBadRequest},
+		errs:     authorizeFormat,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		req := users.AuthorizationReq{
+			ClientID:            query.Get("client_id"),
+			RedirectURI:         query.Get("redirect_uri"),
+			ResponseType:        query.Get("response_type"),
+			Scope:               query.Get("scope"),
+			State:               query.Get("state"),
+			Nonce:               query.Get("nonce"),
+			CodeChallenge:       query.Get("code_challenge"),
+			CodeChallengeMethod: query.Get("code_challenge_method"),
+		}
+		res, err := prepare(request.Context(), &req)
+		if err != nil {
+			writeAuthorizeError(b.log, writer, request, authorizationError(req, err))
+			return
+		}
+		writePage(b.log, writer, http.StatusOK, "authorize", authorizePage{Request: req, Client: res.Client.Name, Scopes: res.Scopes})
+	})
+	b.handle(operation{
+		method: http.MethodPost, path: "/oauth2/authorize", id: "oidcAuthorizeLogin", summary: "Log in and consent to an authorization request",
+		description: "The user is redirected with a code when the credentials are valid and the request allowed, the page is shown again otherwise.",
+		request:     formContent(authorizeForm{}),
+		responses: []response{
+			{status: http.StatusOK, description: "The login page, with the error or the second factor step.", content: []content{{contentType: htmlContentType}}},
+			{status: http.StatusFound, headers: map[string]string{"Location": "The redirect uri with the code or the error."}},
+		},
+		errs: authorizeFormat,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var form authorizeForm
+		if err := decodeBody(request, &form); err != nil {
+			writeAuthorizeError(b.log, writer, request, err)
+			return
+		}
+		res, err := prepare(request.Context(), &form.AuthorizationReq)
+		if err != nil {
+			writeAuthorizeError(b.log, writer, request, authorizationError(form.AuthorizationReq, err))
+			return
+		}
+		redirectErr := func(err error) error {
+			return &redirectError{redirectURI: form.RedirectURI, state: form.State, err: err}
+		}
+		page := authorizePage{Request: form.AuthorizationReq, Client: res.Client.Name, Scopes: res.Scopes, Email: form.Email}
+		switch form.Consent {
+		case "allow":
+		case "deny":
+			writeAuthorizeError(b.log, writer, request, redirectErr(errAccessDenied))
+			return
+		default:
+			writePage(b.log, writer, http.StatusOK, "authorize", page)
+			return
+		}
+
+		var logged *users.LoginResp
+		if form.ChallengeID != "" {
+			logged, err = loginMFA(request.Context(), &users.LoginMFAReq{ChallengeID: form.ChallengeID, Code: form.Code, Binding: form.AuthorizationReq.Binding()})
+		} else {
+			logged, err = login(request.Context(), &users.LoginReq{Email: form.Email, RawPassword: form.Password, Binding: form.AuthorizationReq.Binding()})
+		}
+		switch {
+		case errors.Is(err, users.ErrInvalidCredentials):
+			// a challenge is consumed by its first attempt, the user logs in again
+			page.Error = "The credentials can't be verified."
+			writePage(b.log, writer, http.StatusOK, "authorize", page)
+			return
+		case errors.Is(err, users.ErrTooManyLoginAttempts):
+			page.Error = "Too many logins failed recently, try again later."
+			writePage(b.log, writer, http.StatusTooManyRequests, "authorize", page)
+			return
+		case err != nil:
+			writeAuthorizeError(b.log, writer, request, redirectErr(err))
+			return
+		case logged.ChallengeID != "":
+			page.ChallengeID = logged.ChallengeID
+			writePage(b.log, writer, http.StatusOK, "authorize", page)
+			return
+		}
+
+		code, err := authorize(request.Context(), &users.AuthorizeReq{AuthorizationReq: form.AuthorizationReq, UserID: logged.User.ID})
+		if err != nil {
+			writeAuthorizeError(b.log, writer, request, redirectErr(err))
+			return
+		}
+		params := url.Values{"code": {code.Code}}
+		if form.State != "" {
+			params.Set("state", form.State)
+		}
+		redirectTo(writer, form.RedirectURI, params)
+	})
+
+	b.handle(operation{
+		method: http.MethodPost, path: "/oauth2/token", id: "oidcToken", summary: "Exchange a code or a refresh token for tokens",
+		description: "The confidential clients authenticate with their secret, through the basic scheme or the form. " +
+			"The refresh token is rotated on each exchange, the reuse of a rotated token revokes the tokens issued from the same code.",
+		request:       formContent(users.TokenReq{}),
+		responses:     []response{{status: http.StatusOK, content: jsonContent(users.TokenResp{})}},
+		problems:      []int{http.StatusUnauthorized},
+		errs:          oauthFormat,
+		authorization: "oidcClient",
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.TokenReq
+		if err := decodeBody(request, &req); err != nil {
+			writeOAuthError(b.log, writer, request, err)
+			return
+		}
+		if err := basicClientAuth(request, &req); err != nil {
+			writeOAuthError(b.log, writer, request, err)
+			return
+		}
+		res, err := exchange(request.Context(), &req)
+		if err != nil {
+			writeOAuthError(b.log, writer, request, err)
+			return
+		}
+		writer.Header().Set("Cache-Control", "no-store")
+		writer.Header().Set("Pragma", "no-cache")
+		writeJSON(writer, http.StatusOK, res)
+	})
+
+	for method, id := range map[string]string{http.MethodGet: "oidcUserInfo", http.MethodPost: "oidcUserInfoPost"} {
+		b.handle(operation{
+			method: method, path: "/oauth2/userinfo", id: id,
+			summary:       "Get the claims of the user of an access token",
+			responses:     []response{{status: http.StatusOK, content: jsonContent(map[string]interface{}{"sub": "testid"})}},
+			errs:          oauthFormat,
+			authenticated: true,
+			authorization: "oidcAccessToken",
+		}, func(writer http.ResponseWriter, request *http.Request) {
+			const bearer = "Bearer "
+			header := request.Header.Get("Authorization")
+			if !strings.HasPrefix(header, bearer) {
+				writeOAuthError(b.log, writer, request, fmt.Errorf("access token required: %w", users.ErrInvalidToken))
+				return
+			}
+			res, err := userInfo(request.Context(), &users.UserInfoReq{AccessToken: strings.TrimPrefix(header, bearer)})
+			if err != nil {
+				writeOAuthError(b.log, writer, request, err)
+				return
+			}
+			writer.Header().Set("Cache-Control", "no-store")
+			writeJSON(writer, http.StatusOK, res.Claims)
+		})
+	}
+	return b
+}
+
+// basicClientAuth will read the credentials of the client from the basic scheme, their values are form encoded
+// (RFC 6749 section 2.3.1). A client can't use both the basic scheme and the form
+func basicClientAuth(request *http.Request, req *users.TokenReq) error {
+	id, secret, ok := request.BasicAuth()
+	if !ok {
+		return nil
+	}
+	if req.ClientSecret != "" {
+		return fmt.Errorf("the client authenticates with a single method: %w", users.ErrInvalidOIDCRequest)
+	}
+	var err error
+	if req.ClientID, err = url.QueryUnescape(id); err != nil {
+		return fmt.Errorf("client id isn't form encoded: %w", users.ErrInvalidClient)
+	}
+	if req.ClientSecret, err = url.QueryUnescape(secret); err != nil {
+		return fmt.Errorf("client secret isn't form encoded: %w", users.ErrInvalidClient)
+	}
+	return nil
+}
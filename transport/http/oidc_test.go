@@ -0,0 +1,258 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/mfastore"
+	"go-users-example/infra/oidcstore"
+	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/signer"
+	"go-users-example/infra/userstore"
+)
+
+const testVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r-wW1gFWFkEjXk"
+
+// newOIDCRouter will return the provider with a user test-oidc@test.com and a confidential client
+func newOIDCRouter(t *testing.T) (http.Handler, *users.OIDCClient, string) {
+	log := logger.Logger{}
+	c := users.Config{OIDCIssuer: "https://id.test", OIDCCodeTTL: time.Minute, OIDCTokenTTL: time.Minute, OIDCRefreshTokenTTL: time.Hour}
+	usrStore, oidcStore, mfaStore := userstore.NewInMemory(), oidcstore.NewInMemory(), mfastore.NewInMemory()
+	hasher := pwdhasher.NewBcryptWithCost(4)
+	tokenSigner, _ := signer.NewEd25519(signer.Config{})
+
+	password, _ := hasher.Hash("password")
+	_, err := usrStore.Add(context.Background(), &users.User{Email: "test-oidc@test.com", Password: password, FirstName: "test"})
+	require.NoError(t, err)
+	registered, err := users.SetupRegisterOIDCClient(log, oidcStore, users.SystemClock)(context.Background(), &users.RegisterOIDCClientReq{
+		Name: "app", RedirectURIs: []string{"https://app.test/callback"}, Confidential: true,
+	})
+	require.NoError(t, err)
+
+	router := NewBuilder(log, Config{}).
+		WithAPIKeyAuth(func(ctx context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+			return nil, users.ErrUnauthenticated
+		}).
+		WithOIDC(
+			users.SetupDescribeOIDCProvider(log, tokenSigner, c),
+			users.SetupPrepareAuthorization(log, oidcStore),
+			users.SetupAuthorize(log, oidcStore, usrStore, oidcStore, c, users.SystemClock),
+			users.SetupLogin(log, usrStore, hasher, mfaStore, mfaStore, mfaStore, c, users.SystemClock),
+			users.SetupLoginMFA(log, usrStore, hasher, mfaStore, mfaStore, mfaStore, users.SystemClock),
+			users.SetupExchangeToken(log, oidcStore, usrStore, oidcStore, oidcStore, tokenSigner, c, users.SystemClock),
+			users.SetupGetUserInfo(log, usrStore, tokenSigner, c, users.SystemClock),
+		).handler()
+	return router, registered.Client, registered.Secret
+}
+
+func authorizationQuery(clientID string) url.Values {
+	challenge := sha256.Sum256([]byte(testVerifier))
+	return url.Values{
+		"client_id":             {clientID},
+		"redirect_uri":          {"https://app.test/callback"},
+		"response_type":         {"code"},
+		"scope":                 {"openid email"},
+		"state":                 {"state"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func postForm(router http.Handler, path string, form url.Values, prepare func(req *http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "http://localhost"+path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if prepare != nil {
+		prepare(req)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestBuilder_WithOIDC(t *testing.T) {
+	router, client, secret := newOIDCRouter(t)
+
+	req := httptest.NewRequest("GET", "http://localhost/.well-known/openid-configuration", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var config openIDConfiguration
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&config))
+	require.Equal(t, "https://id.test/oauth2/token", config.TokenEndpoint)
+	require.Equal(t, []string{"S256"}, config.CodeChallengeMethodsSupported)
+
+	// the login page keeps the authorization request
+	query := authorizationQuery(client.ID)
+	req = httptest.NewRequest("GET", "http://localhost/oauth2/authorize?"+query.Encode(), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	require.Contains(t, w.Body.String(), `name="code_challenge" value="`+query.Get("code_challenge")+`"`)
+
+	form := authorizationQuery(client.ID)
+	form.Set("email", "test-oidc@test.com")
+	form.Set("password", "wrong")
+	form.Set("consent", "allow")
+	w = postForm(router, "/oauth2/authorize", form, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "The credentials can&#39;t be verified.")
+
+	form.Set("password", "password")
+	w = postForm(router, "/oauth2/authorize", form, nil)
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "app.test", location.Host)
+	require.Equal(t, "state", location.Query().Get("state"))
+
+	// the client authenticates with the basic scheme, an api key isn't expected
+	tokenForm := url.Values{
+		"grant_type": {"authorization_code"}, "code": {location.Query().Get("code")},
+		"redirect_uri": {"https://app.test/callback"}, "code_verifier": {testVerifier},
+	}
+	w = postForm(router, "/oauth2/token", tokenForm, func(req *http.Request) { req.SetBasicAuth(client.ID, secret) })
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	var tokens users.TokenResp
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tokens))
+
+	// a code is exchanged once
+	w = postForm(router, "/oauth2/token", tokenForm, func(req *http.Request) { req.SetBasicAuth(client.ID, secret) })
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.JSONEq(t, `{"error": "invalid_grant", "error_description": "The grant is invalid, expired or was issued to another client."}`, w.Body.String())
+
+	req = httptest.NewRequest("GET", "http://localhost/oauth2/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"email":"test-oidc@test.com"`)
+
+	w = postForm(router, "/oauth2/token", url.Values{
+		"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}, "client_id": {client.ID}, "client_secret": {secret},
+	}, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestBuilder_WithOIDC_MFA(t *testing.T) {
+	// the challenge is bound to the authorization request of the login, as the domain does
+	challenges := map[string]string{}
+	handler := NewBuilder(logger.Logger{}, Config{}).
+		WithAPIKeyAuth(func(ctx context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+			return nil, users.ErrUnauthenticated
+		}).
+		WithOIDC(
+			nil,
+			func(ctx context.Context, req *users.AuthorizationReq) (*users.PrepareAuthorizationResp, error) {
+				return &users.PrepareAuthorizationResp{Client: &users.OIDCClient{ID: req.ClientID, Name: "app"}}, nil
+			},
+			func(ctx context.Context, req *users.AuthorizeReq) (*users.AuthorizeResp, error) {
+				return &users.AuthorizeResp{Code: "code"}, nil
+			},
+			func(ctx context.Context, req *users.LoginReq) (*users.LoginResp, error) {
+				id := fmt.Sprintf("challenge-%d", len(challenges))
+				challenges[id] = req.Binding
+				return &users.LoginResp{ChallengeID: id}, nil
+			},
+			func(ctx context.Context, req *users.LoginMFAReq) (*users.LoginResp, error) {
+				if binding, ok := challenges[req.ChallengeID]; !ok || binding != req.Binding {
+					return nil, users.ErrInvalidCredentials
+				}
+				return &users.LoginResp{User: &users.User{ID: "testid"}}, nil
+			},
+			nil, nil,
+		).handler()
+	challengeID := regexp.MustCompile(`name="challenge_id" value="([^"]+)"`)
+	challenge := func(t *testing.T, form url.Values) string {
+		form.Set("email", "test-oidc@test.com")
+		form.Set("password", "password")
+		form.Set("consent", "allow")
+		w := postForm(handler, "/oauth2/authorize", form, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		match := challengeID.FindStringSubmatch(w.Body.String())
+		require.Len(t, match, 2, "the second factor is asked")
+		return match[1]
+	}
+
+	// the challenge of an authorization request isn't completed by another one
+	other := authorizationQuery("clientid")
+	other.Set("state", "other")
+	form := authorizationQuery("clientid")
+	form.Set("challenge_id", challenge(t, other))
+	form.Set("code", "123456")
+	form.Set("consent", "allow")
+	w := postForm(handler, "/oauth2/authorize", form, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "The credentials can&#39;t be verified.")
+
+	form.Set("challenge_id", challenge(t, authorizationQuery("clientid")))
+	w = postForm(handler, "/oauth2/authorize", form, nil)
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "code", location.Query().Get("code"))
+}
+
+func TestBuilder_WithOIDC_Errors(t *testing.T) {
+	router, client, secret := newOIDCRouter(t)
+
+	// the unknown clients aren't redirected to
+	query := authorizationQuery("unknown")
+	req := httptest.NewRequest("GET", "http://localhost/oauth2/authorize?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "text/html")
+
+	query = authorizationQuery(client.ID)
+	query.Del("code_challenge")
+	req = httptest.NewRequest("GET", "http://localhost/oauth2/authorize?"+query.Encode(), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+	location, _ := url.Parse(w.Header().Get("Location"))
+	require.Equal(t, "invalid_request", location.Query().Get("error"))
+	require.Equal(t, "state", location.Query().Get("state"))
+
+	form := authorizationQuery(client.ID)
+	form.Set("consent", "deny")
+	w = postForm(router, "/oauth2/authorize", form, nil)
+	require.Equal(t, http.StatusFound, w.Code)
+	location, _ = url.Parse(w.Header().Get("Location"))
+	require.Equal(t, "access_denied", location.Query().Get("error"))
+
+	w = postForm(router, "/oauth2/token", url.Values{"grant_type": {"authorization_code"}}, func(req *http.Request) { req.SetBasicAuth(client.ID, "wrong") })
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, "Basic", w.Header().Get("WWW-Authenticate"))
+	require.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+
+	w = postForm(router, "/oauth2/token", url.Values{"grant_type": {"authorization_code"}, "client_secret": {secret}}, func(req *http.Request) { req.SetBasicAuth(client.ID, secret) })
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), `"error":"invalid_request"`)
+
+	w = postForm(router, "/oauth2/token", url.Values{"grant_type": {"password"}, "grant": {"x"}}, nil)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), `"error":"invalid_request"`, "the unknown fields are refused")
+
+	req = httptest.NewRequest("GET", "http://localhost/oauth2/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+}
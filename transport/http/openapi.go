@@ -0,0 +1,355 @@
+package http
+
+import (
+	_ "embed"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+
+	"go-users-example/infra/logger"
+)
+
+const (
+	openAPIVersion = "3.1.0"
+	openAPITitle   = "go-users-example"
+	// openAPIAPIVersion is the version of the api described by the document
+	openAPIAPIVersion = "1.0.0"
+)
+
+// operation describe a route of the builder in the openapi document, see Builder.handle
+type operation struct {
+	method      string
+	path        string
+	id          string
+	summary     string
+	description string
+	// params are the query and header parameters, the path parameters are deduced from the path
+	params []parameter
+	// request are the accepted bodies by content type, the route doesn't read its body if empty
+	request   []content
+	responses []response
+	// maxBodySize is the size limit of the request bodies, Config.MaxBodySize if zero
+	maxBodySize int64
+	// problems are the statuses of the problems the route can return, besides the internal error and the problems of
+	// the request bodies and of the authentication
+	problems []int
+	// errs is the format of the errors of the route, problems if nil
+	errs *errorFormat
+	// authenticated routes refuse the requests without credentials, an api key unless authorization is set, see
+	// WithAPIKeyAuth. The handlers check the caller can act on the user with authorizeUser
+	authenticated bool
+	// admin routes act on all the users, they are authenticated and require an api key with the users:admin scope
+	admin bool
+	// passwordAuth routes also accept the password of the user as credentials, see WithPasswordAuth
+	passwordAuth bool
+	// authorization is the security scheme of the routes reading the Authorization header themselves, the header
+	// isn't taken as an api key for them
+	authorization string
+	// websocket serves the websocket upgrades of the route, they authenticate their connection themselves
+	websocket http.HandlerFunc
+}
+
+// errorFormat is how the errors of a route are written and described, the body isn't described if it is nil
+type errorFormat struct {
+	contentType string
+	body        interface{}
+	write       errorWriter
+}
+
+// errorWriter will translate the error to the response of the route
+type errorWriter func(log logger.Logger, writer http.ResponseWriter, request *http.Request, err error)
+
+// problemFormat is the format of the errors of most of the routes, see writeError
+var problemFormat = &errorFormat{contentType: problemContentType, body: problem{}, write: writeError}
+
+func (op operation) errorFormat() *errorFormat {
+	if op.errs == nil {
+		return problemFormat
+	}
+	return op.errs
+}
+
+type parameter struct {
+	name        string
+	in          string
+	description string
+	// array is set for the parameters which can be repeated
+	array bool
+	// typ is the json type of the value, string by default
+	typ string
+	// example is an example of value, it is used by the conformance test
+	example string
+}
+
+type response struct {
+	status      int
+	description string
+	// headers are the descriptions of the response headers by name
+	headers map[string]string
+	content []content
+}
+
+// content is a body and its media type, the schema of the body is reflected from its go value.
+// The body isn't described if it is nil, a request body without schema is streamed to the handler, see streamContent
+type content struct {
+	contentType string
+	body        interface{}
+}
+
+// jsonContent is the content of the json bodies
+func jsonContent(body interface{}) []content {
+	return []content{{contentType: jsonContentType, body: body}}
+}
+
+// formContent is the content of the form bodies, the fields of the body should be strings
+func formContent(body interface{}) []content {
+	return []content{{contentType: formContentType, body: body}}
+}
+
+// streamContent is the content of the request bodies read by the handler as a stream: they are neither buffered nor
+// checked against a schema, only their content type and their size limit are checked
+func streamContent(contentTypes ...string) []content {
+	contents := make([]content, 0, len(contentTypes))
+	for _, contentType := range contentTypes {
+		contents = append(contents, content{contentType: contentType})
+	}
+	return contents
+}
+
+// acceptLanguage is the parameter of the routes returning the users with the name of their country
+var acceptLanguage = parameter{name: "Accept-Language", in: "header", description: "Add the name of the country of the users in this language."}
+
+// handle will register the handler of the operation and describe it in the openapi document.
+// The request bodies are checked against the schemas of the operation before the handler is called, and after the
+// api key for the authenticated operations
+func (b *Builder) handle(op operation, handler http.HandlerFunc) {
+	errs := op.errorFormat()
+	if len(op.request) > 0 {
+		maxSize := op.maxBodySize
+		if maxSize == 0 {
+			maxSize = b.c.MaxBodySize
+		}
+		handler = newBodyValidator(op, maxSize).middleware(b.log, errs.write, handler)
+	}
+	if op.admin {
+		op.authenticated = true
+		handler = requireAdmin(b.log, errs.write, handler)
+	}
+	if op.authenticated && op.authorization == "" {
+		handler = requireCredentials(b.log, errs.write, handler)
+	}
+	if op.passwordAuth {
+		handler = b.passwordAuth(errs.write, handler)
+	}
+	if op.websocket != nil {
+		handler = serveWebSocket(op.websocket, handler)
+	}
+	if op.authorization != "" {
+		b.authorizations[op.method+" "+op.path] = true
+	}
+	b.operations = append(b.operations, op)
+	b.router.Method(op.method, op.path, handler)
+}
+
+// WithOpenAPI will add the openapi document describing the routes of the builder on /openapi.json and a swagger ui
+// to browse it on /docs/. The document is generated on the first request, once all the routes are added
+func (b *Builder) WithOpenAPI() *Builder {
+	var (
+		once sync.Once
+		doc  *openAPIDocument
+	)
+	b.router.Get("/openapi.json", func(writer http.ResponseWriter, request *http.Request) {
+		once.Do(func() { doc = newOpenAPIDocument(b.operations) })
+		writeJSON(writer, http.StatusOK, doc)
+	})
+	b.router.Get("/docs", func(writer http.ResponseWriter, request *http.Request) {
+		http.Redirect(writer, request, "/docs/", http.StatusMovedPermanently)
+	})
+	b.router.Get("/docs/swagger-initializer.js", func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		_, _ = writer.Write(swaggerInitializer)
+	})
+	b.router.Handle("/docs/*", http.StripPrefix("/docs/", http.FileServer(http.FS(swaggerFiles.FS))))
+	return b
+}
+
+// swaggerInitializer will configure the swagger ui bundled by swaggerFiles to load the document of the server
+//
+//go:embed openapi_swagger_initializer.js
+var swaggerInitializer []byte
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+	Security   []map[string][]string                   `json:"security"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*schema               `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *schema `json:"schema"`
+	Example     string  `json:"example,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Headers     map[string]openAPIHeader    `json:"headers,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIHeader struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema *schema `json:"schema,omitempty"`
+}
+
+// pathParamPattern match the parameters of the chi patterns, which are written as the openapi ones
+var pathParamPattern = regexp.MustCompile(`{([^}]+)}`)
+
+// newOpenAPIDocument will describe the operations, the schemas of their bodies are reflected from the declared values
+func newOpenAPIDocument(operations []operation) *openAPIDocument {
+	g := newSchemaGenerator()
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    openAPIInfo{Title: openAPITitle, Version: openAPIAPIVersion},
+		Paths:   make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: g.components,
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"apiKey":          {Type: "http", Scheme: "bearer", Description: "An api key of a user, limited to its scopes and to its user without the users:admin scope."},
+				"userPassword":    {Type: "http", Scheme: "basic", Description: "The email and the password of the user, with its second factor code in X-MFA-Code if enabled."},
+				"oidcClient":      {Type: "http", Scheme: "basic", Description: "The id and the secret of a confidential OpenID Connect client."},
+				"oidcAccessToken": {Type: "http", Scheme: "bearer", Description: "An access token issued by the OpenID Connect token endpoint."},
+			},
+		},
+		// the public routes (ex: the sign up, the login) accept the requests without api key, the others override it
+		Security: []map[string][]string{{}, {"apiKey": {}}},
+	}
+	for _, op := range operations {
+		o := &openAPIOperation{
+			OperationID: op.id,
+			Summary:     op.summary,
+			Description: op.description,
+			Responses:   make(map[string]*openAPIResponse),
+		}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(op.path, -1) {
+			o.Parameters = append(o.Parameters, openAPIParameter{Name: match[1], In: "path", Required: true, Schema: &schema{Type: "string"}})
+		}
+		for _, p := range op.params {
+			s := &schema{Type: p.typ}
+			if s.Type == "" {
+				s.Type = "string"
+			}
+			if p.array {
+				s = &schema{Type: "array", Items: s}
+			}
+			o.Parameters = append(o.Parameters, openAPIParameter{Name: p.name, In: p.in, Description: p.description, Schema: s, Example: p.example})
+		}
+		if len(op.request) > 0 {
+			o.RequestBody = &openAPIRequestBody{Required: true, Content: make(map[string]openAPIMediaType)}
+			for _, c := range op.request {
+				o.RequestBody.Content[c.contentType] = openAPIMediaType{}
+				if c.body != nil {
+					o.RequestBody.Content[c.contentType] = openAPIMediaType{Schema: g.requestSchema(c.body)}
+				}
+			}
+		}
+		for _, r := range op.responses {
+			res := &openAPIResponse{Description: r.description}
+			if res.Description == "" {
+				res.Description = http.StatusText(r.status)
+			}
+			for name, description := range r.headers {
+				if res.Headers == nil {
+					res.Headers = make(map[string]openAPIHeader)
+				}
+				res.Headers[name] = openAPIHeader{Description: description, Schema: &schema{Type: "string"}}
+			}
+			for _, c := range r.content {
+				if res.Content == nil {
+					res.Content = make(map[string]openAPIMediaType)
+				}
+				res.Content[c.contentType] = openAPIMediaType{}
+				if c.body != nil {
+					res.Content[c.contentType] = openAPIMediaType{Schema: g.responseSchema(c.body)}
+				}
+			}
+			o.Responses[strconv.Itoa(r.status)] = res
+		}
+		problems := op.problems
+		if len(op.request) > 0 {
+			problems = append([]int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType}, problems...)
+		}
+		scheme := "apiKey"
+		if op.authorization != "" {
+			scheme = op.authorization
+			o.Security = []map[string][]string{{}, {scheme: {}}}
+		}
+		if op.authenticated {
+			problems = append([]int{http.StatusUnauthorized, http.StatusForbidden}, problems...)
+			o.Security = []map[string][]string{{scheme: {}}}
+			if op.passwordAuth {
+				o.Security = append(o.Security, map[string][]string{"userPassword": {}})
+				// the password logins are throttled
+				problems = append(problems, http.StatusTooManyRequests)
+			}
+		}
+		errs := op.errorFormat()
+		errorContent := map[string]openAPIMediaType{errs.contentType: {}}
+		if errs.body != nil {
+			errorContent[errs.contentType] = openAPIMediaType{Schema: g.responseSchema(errs.body)}
+		}
+		for _, status := range problems {
+			o.Responses[strconv.Itoa(status)] = &openAPIResponse{Description: http.StatusText(status), Content: errorContent}
+		}
+		o.Responses["default"] = &openAPIResponse{Description: "Unexpected error", Content: errorContent}
+
+		if doc.Paths[op.path] == nil {
+			doc.Paths[op.path] = make(map[string]*openAPIOperation)
+		}
+		doc.Paths[op.path][strings.ToLower(op.method)] = o
+	}
+	return doc
+}
@@ -0,0 +1,198 @@
+package http
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-users-example/domain/users"
+)
+
+// schema is a JSON Schema (draft 2020-12) as used by OpenAPI 3.1, the empty schema accept any value
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	AnyOf                []*schema          `json:"anyOf,omitempty"`
+}
+
+// nullable will accept null besides the values of the schema, as encoding/json does for nil pointers, slices and maps
+func nullable(s *schema) *schema {
+	return &schema{AnyOf: []*schema{s, {Type: "null"}}}
+}
+
+// knownSchemas are the types whose json representation isn't the one of their go type
+var knownSchemas = map[reflect.Type]func() *schema{
+	reflect.TypeOf(time.Time{}):            func() *schema { return &schema{Type: "string", Format: "date-time"} },
+	reflect.TypeOf(json.RawMessage{}):      func() *schema { return &schema{} },
+	reflect.TypeOf(users.OptionalString{}): func() *schema { return nullable(&schema{Type: "string"}) },
+	reflect.TypeOf(users.OptionalAttributes{}): func() *schema {
+		return nullable(&schema{Type: "object", AdditionalProperties: &schema{}})
+	},
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// schemaGenerator will reflect the json representation of the go values, the objects don't allow other fields.
+// The response schemas are strict: the fields without omitempty are required, their named structs are shared as
+// components. The request schemas are inlined and their fields are optional
+type schemaGenerator struct {
+	components map[string]*schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{components: make(map[string]*schema), names: make(map[reflect.Type]string)}
+}
+
+// requestSchema will return the schema of the value decoded from a request body
+func (g *schemaGenerator) requestSchema(v interface{}) *schema {
+	return g.valueSchema(reflect.ValueOf(v), false)
+}
+
+// responseSchema will return the schema of the value written in a response body, interface fields are described
+// by the value they hold
+func (g *schemaGenerator) responseSchema(v interface{}) *schema {
+	return g.valueSchema(reflect.ValueOf(v), true)
+}
+
+func (g *schemaGenerator) valueSchema(v reflect.Value, strict bool) *schema {
+	if !v.IsValid() {
+		return &schema{}
+	}
+	t := v.Type()
+	if known, ok := knownSchemas[t]; ok {
+		return known()
+	}
+	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && t.Implements(jsonMarshalerType) {
+		return &schema{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(g.valueSchema(elemValue(v), strict))
+	case reflect.Interface:
+		if v.IsNil() {
+			return &schema{}
+		}
+		return nullable(g.valueSchema(v.Elem(), strict))
+	case reflect.Struct:
+		return g.structSchema(v, strict)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return nullable(&schema{Type: "string", Format: "byte"})
+		}
+		return nullable(&schema{Type: "array", Items: g.valueSchema(reflect.Zero(t.Elem()), strict)})
+	case reflect.Array:
+		return &schema{Type: "array", Items: g.valueSchema(reflect.Zero(t.Elem()), strict)}
+	case reflect.Map:
+		return nullable(&schema{Type: "object", AdditionalProperties: g.valueSchema(reflect.Zero(t.Elem()), strict)})
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0
+		return &schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	default:
+		return &schema{}
+	}
+}
+
+// structSchema will return a reference to the component of a named struct in a response, the anonymous structs,
+// the structs holding interfaces and the structs of the requests are inlined
+func (g *schemaGenerator) structSchema(v reflect.Value, strict bool) *schema {
+	t := v.Type()
+	if !strict || t.Name() == "" || hasInterfaceField(t) {
+		return g.objectSchema(v, strict)
+	}
+	name, ok := g.names[t]
+	if !ok {
+		name = g.componentName(t)
+		g.names[t] = name
+		// the component is registered before its properties to stop on recursive types
+		g.components[name] = &schema{}
+		*g.components[name] = *g.objectSchema(reflect.Zero(t), strict)
+	}
+	return &schema{Ref: "#/components/schemas/" + name}
+}
+
+// componentName is the exported name of the type, prefixed by its package on conflict
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	if _, taken := g.components[string(name)]; !taken {
+		return string(name)
+	}
+	pkg := []rune(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:])
+	pkg[0] = unicode.ToUpper(pkg[0])
+	return string(pkg) + string(name)
+}
+
+func (g *schemaGenerator) objectSchema(v reflect.Value, strict bool) *schema {
+	s := &schema{Type: "object", Properties: make(map[string]*schema)}
+	g.addFields(s, v, strict)
+	s.AdditionalProperties = false
+	return s
+}
+
+// addFields will add the fields of the struct as encoding/json marshal them, the embedded structs are flattened
+func (g *schemaGenerator) addFields(s *schema, v reflect.Value, strict bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := elemValue(v.Field(i))
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(s, embedded, strict)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = g.valueSchema(v.Field(i), strict)
+		if strict && !strings.Contains(","+opts+",", ",omitempty,") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// elemValue will return the value pointed by v, or the zero value of its type if v is nil
+func elemValue(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Ptr {
+		return v
+	}
+	if v.IsNil() {
+		return reflect.Zero(v.Type().Elem())
+	}
+	return v.Elem()
+}
+
+func hasInterfaceField(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() == reflect.Interface {
+			return true
+		}
+	}
+	return false
+}
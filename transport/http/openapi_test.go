@@ -0,0 +1,373 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithOpenAPI(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithOpenAPI().WithHealthCheck().router
+
+	req := httptest.NewRequest("GET", "http://localhost/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Equal(t, "application/json", w.Result().Header.Get("Content-Type"))
+
+	// the routes added after WithOpenAPI are described
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	require.Equal(t, openAPIVersion, doc["openapi"])
+	require.Contains(t, doc["paths"], "/ping")
+
+	req = httptest.NewRequest("GET", "http://localhost/docs/", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), "swagger-ui")
+
+	req = httptest.NewRequest("GET", "http://localhost/docs/swagger-initializer.js", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), `"/openapi.json"`)
+}
+
+// stub is a use case returning the response, or the error when it is set
+func stub[Req, Resp any](err *error, resp *Resp) func(context.Context, *Req) (*Resp, error) {
+	return func(ctx context.Context, req *Req) (*Resp, error) {
+		if *err != nil {
+			return nil, *err
+		}
+		return resp, nil
+	}
+}
+
+// newContractBuilder will add all the routes with use cases returning fully populated responses, or the error when
+// it is set. A route added to the server should be added here to be checked against its description
+func newContractBuilder(err *error) *Builder {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	usr := &users.User{
+		ID: "testid", FirstName: "test", LastName: "test", NickName: "tester", Password: "hash", Email: "test@test.com",
+		Country: "FR", Phone: "+33612345678", PhoneVerifiedAt: &now, Attributes: map[string]interface{}{"level": 3}, DeletedAt: &now,
+	}
+	key := &users.APIKey{
+		ID: "keyid", UserID: "testid", Name: "ci", Scopes: []users.Scope{users.ScopeUsersRead},
+		CreatedAt: now, ExpiresAt: &now, LastUsedAt: &now, RevokedAt: &now,
+	}
+	entry := &users.AuditEntry{
+		Seq: 1, UserID: "testid", Time: now, Op: users.UpdateOp, Actor: "system", RequestID: "requestid", SourceIP: "127.0.0.1",
+		Changes: []users.FieldChange{{Field: "email", Before: "old@test.com", After: "test@test.com"}}, Erased: true,
+		DataDigest: "digest", PrevHash: "prev", Hash: "hash",
+	}
+	client := &users.OIDCClient{ID: "clientid", Name: "app", RedirectURIs: []string{"https://app.test/callback"}, Confidential: true, CreatedAt: now}
+	job := &users.ImportJob{
+		ID: "jobid", Format: users.ImportCSV, Status: users.ImportCompleted, Processed: 2, Created: 1, Failed: 1,
+		Errors: []users.ImportRowError{{Row: 2, Message: "invalid", Violations: []users.FieldViolation{{Field: "email", Code: users.CodeRequired}}}},
+		Error:  "failed", CreatedAt: now, UpdatedAt: now, FinishedAt: &now,
+	}
+	violation := &users.FieldViolation{Field: "nick_name", Code: users.CodeTooLong, Message: "too long", Params: map[string]interface{}{"max": 20}}
+
+	return NewBuilder(logger.Logger{}, Config{}).
+		WithOpenAPI().
+		WithHealthCheck().
+		WithV1CreateUser(stub[users.CreateReq](err, &users.CreateResp{User: usr})).
+		WithV1UpdateUser(stub[users.UpdateReq](err, &users.UpdateResp{User: usr})).
+		WithV1PatchUser(stub[users.UpdateReq](err, &users.UpdateResp{User: usr})).
+		WithV1DeleteUser(stub[users.DeleteReq](err, &users.DeleteResp{User: usr})).
+		WithV1SearchUser(stub[users.SearchReq](err, &users.SearchResp{Users: []*users.User{usr}})).
+		WithV1RestoreUser(stub[users.RestoreReq](err, &users.RestoreResp{User: usr})).
+		WithV2CreateUser(stub[users.CreateReq](err, &users.CreateResp{User: usr})).
+		WithV2GetUser(stub[users.SearchReq](err, &users.SearchResp{Users: []*users.User{usr}})).
+		WithV2UpdateUser(stub[users.UpdateReq](err, &users.UpdateResp{User: usr})).
+		WithV2DeleteUser(stub[users.DeleteReq](err, &users.DeleteResp{User: usr})).
+		WithV2SearchUser(stub[users.SearchReq](err, &users.SearchResp{})).
+		WithGraphQL(
+			stub[users.SearchReq](err, &users.SearchResp{Users: []*users.User{usr}}),
+			stub[users.CreateReq](err, &users.CreateResp{User: usr}),
+			stub[users.UpdateReq](err, &users.UpdateResp{User: usr}),
+			stub[users.DeleteReq](err, &users.DeleteResp{User: usr}),
+			nil,
+		).
+		WithV1ListCountries(stub[users.ListCountriesReq](err, &users.ListCountriesResp{
+			Language: "fr", Countries: []users.LocalizedCountry{{Country: users.Country{Alpha2: "FR", Alpha3: "FRA", Numeric: "250", Name: "France"}, LocalName: "France"}},
+		})).
+		WithV1AttributeSchema(stub[users.GetAttributeSchemaReq](err, &users.GetAttributeSchemaResp{Schema: json.RawMessage(`{"type": "object"}`)})).
+		WithV1NickNameAvailability(stub[users.NickNameAvailabilityReq](err, &users.NickNameAvailabilityResp{
+			NickName: "tester", Reason: "invalid", Violation: violation, Suggestions: []string{"tester1"},
+		})).
+		WithV1VerifyUserPhone(stub[users.VerifyPhoneReq](err, &users.VerifyPhoneResp{Phone: usr.Phone, ExpiresAt: now})).
+		WithV1ConfirmUserPhone(stub[users.ConfirmPhoneReq](err, &users.ConfirmPhoneResp{User: usr})).
+		WithV1EnrollUserMFA(stub[users.EnrollMFAReq](err, &users.EnrollMFAResp{Secret: "secret", URI: "otpauth://totp", RecoveryCodes: []string{"code"}})).
+		WithV1ConfirmUserMFA(stub[users.ConfirmMFAReq](err, &users.ConfirmMFAResp{Enabled: true})).
+		WithV1Login(stub[users.LoginReq](err, &users.LoginResp{User: usr})).
+		WithV1LoginMFA(stub[users.LoginMFAReq](err, &users.LoginResp{ChallengeID: "challengeid"})).
+		WithV1CreateUserAPIKey(stub[users.CreateAPIKeyReq](err, &users.CreateAPIKeyResp{APIKey: key, Key: "key"})).
+		WithV1ListUserAPIKeys(stub[users.ListAPIKeysReq](err, &users.ListAPIKeysResp{APIKeys: []*users.APIKey{key}})).
+		WithV1RevokeUserAPIKey(stub[users.RevokeAPIKeyReq](err, &users.RevokeAPIKeyResp{APIKey: key})).
+		WithV1UserAudit(stub[users.ListUserAuditReq](err, &users.ListUserAuditResp{Entries: []*users.AuditEntry{entry}})).
+		WithV1VerifyAudit(func(ctx context.Context) (*users.VerifyAuditResp, error) {
+			if *err != nil {
+				return nil, *err
+			}
+			return &users.VerifyAuditResp{Entries: 2, BrokenAt: 1}, nil
+		}).
+		WithV1ExportUserData(stub[users.ExportUserDataReq](err, &users.ExportUserDataResp{
+			ExportedAt: now, User: usr, MFAEnabled: true, APIKeys: []*users.APIKey{key}, Audit: []*users.AuditEntry{entry},
+		})).
+		WithV1EraseUser(stub[users.EraseUserReq](err, &users.EraseUserResp{Receipt: &users.ErasureReceipt{
+			UserID: "testid", ErasedAt: now, Erased: map[string]int{"users": 1}, Signature: "signature",
+		}})).
+		WithSCIM(
+			stub[users.SearchReq](err, &users.SearchResp{Users: []*users.User{usr}}),
+			stub[users.CreateReq](err, &users.CreateResp{User: usr}),
+			stub[users.UpdateReq](err, &users.UpdateResp{User: usr}),
+			stub[users.DeleteReq](err, &users.DeleteResp{User: usr}),
+			stub[users.RestoreReq](err, &users.RestoreResp{User: usr}),
+		).
+		WithV1RegisterOIDCClient(stub[users.RegisterOIDCClientReq](err, &users.RegisterOIDCClientResp{Client: client, Secret: "secret"})).
+		WithOIDC(
+			func(ctx context.Context) (*users.DescribeOIDCProviderResp, error) {
+				if *err != nil {
+					return nil, *err
+				}
+				return &users.DescribeOIDCProviderResp{
+					Issuer: "https://id.test", SigningAlg: "EdDSA", Scopes: []string{"openid"}, Claims: []string{"sub"},
+					Keys: []users.JWK{{KeyType: "OKP", Curve: "Ed25519", X: "x", KeyID: "kid", Use: "sig", Algorithm: "EdDSA"}},
+				}, nil
+			},
+			stub[users.AuthorizationReq](err, &users.PrepareAuthorizationResp{Client: client, Scopes: []string{"openid"}}),
+			stub[users.AuthorizeReq](err, &users.AuthorizeResp{Code: "code"}),
+			stub[users.LoginReq](err, &users.LoginResp{User: usr}),
+			stub[users.LoginMFAReq](err, &users.LoginResp{User: usr}),
+			stub[users.TokenReq](err, &users.TokenResp{
+				AccessToken: "access", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "refresh", IDToken: "id", Scope: "openid",
+			}),
+			stub[users.UserInfoReq](err, &users.UserInfoResp{Claims: map[string]interface{}{"sub": "testid"}}),
+		).
+		WithV1ImportUsers(
+			stub[users.StartImportReq](err, &users.StartImportResp{Job: job}),
+			stub[users.GetImportJobReq](err, &users.GetImportJobResp{Job: job}),
+			stub[users.ResumeImportReq](err, &users.ResumeImportResp{Job: job}),
+		).
+		WithV1ExportUsers(stub[users.ExportUsersReq](err, &users.ExportUsersResp{Exported: 1})).
+		WithV1BatchUsers(stub[users.BatchReq](err, &users.BatchResp{
+			Results: []*users.BatchResult{{User: usr}, {Err: users.ErrBatchAborted}}, Failed: 1,
+		}))
+}
+
+// TestBuilder_OpenAPIConformance will call every described operation and check the response is described by the
+// document: its status, its content type and its body
+func TestBuilder_OpenAPIConformance(t *testing.T) {
+	var fail error
+	b := newContractBuilder(&fail)
+
+	req := httptest.NewRequest("GET", "http://localhost/openapi.json", nil)
+	w := httptest.NewRecorder()
+	b.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	var doc openAPIDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	require.NoError(t, compiler.AddResource("openapi.json", strings.NewReader(w.Body.String())))
+
+	// all the routes are described
+	described := make(map[string]bool)
+	for _, op := range b.operations {
+		described[op.method+" "+op.path] = true
+	}
+	require.NoError(t, chi.Walk(b.router, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if route != "/openapi.json" && route != "/docs" && !strings.HasPrefix(route, "/docs/") {
+			require.True(t, described[method+" "+route], "%s %s isn't described", method, route)
+		}
+		return nil
+	}))
+
+	for _, tc := range []struct {
+		name     string
+		language string
+		err      error
+	}{
+		{name: "success"},
+		{name: "localized", language: "fr"},
+		{name: "internal error", err: errors.New("test")},
+	} {
+		fail = tc.err
+		for _, op := range b.operations {
+			checkOperation(t, b.router, compiler, &doc, op, tc.name, func(req *http.Request) {
+				if tc.language != "" {
+					req.Header.Set("Accept-Language", tc.language)
+				}
+			})
+		}
+	}
+
+	// the described problems are returned for their errors, the problems without use case error (ex: invalid body) are
+	// checked by the tests of the routes
+	for _, op := range b.operations {
+		for _, status := range op.problems {
+			for _, pt := range problemTypes {
+				if pt.status != status || isBodyError(pt.err) {
+					continue
+				}
+				fail = pt.err
+				require.Equal(t, status, checkOperation(t, b.router, compiler, &doc, op, pt.slug, nil), "%s %s: %s", op.method, op.path, pt.slug)
+				break
+			}
+		}
+	}
+
+	// the authenticated operations refuse the requests without api key
+	fail = nil
+	for _, op := range b.operations {
+		if !op.authenticated {
+			continue
+		}
+		status := checkOperation(t, b.router, compiler, &doc, op, "unauthenticated", func(req *http.Request) {
+			*req = *req.WithContext(context.Background())
+		})
+		require.Equal(t, http.StatusUnauthorized, status, "%s %s: unauthenticated", op.method, op.path)
+	}
+
+	// the admin operations refuse the api keys without the users:admin scope
+	for _, op := range b.operations {
+		if !op.admin {
+			continue
+		}
+		status := checkOperation(t, b.router, compiler, &doc, op, "not admin", func(req *http.Request) {
+			*req = *withAPIKey(req, "testid", users.ScopeUsersRead, users.ScopeUsersWrite)
+		})
+		require.Equal(t, http.StatusForbidden, status, "%s %s: not admin", op.method, op.path)
+	}
+
+	// the bodies are refused before the use case with the problems added to the operations reading them, the stream
+	// bodies are only refused for their content type: they are read by the use cases
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		stream      bool
+	}{
+		{name: "unsupported media type", contentType: "text/plain", body: "{}", status: http.StatusUnsupportedMediaType, stream: true},
+		{name: "body too large", body: "{}" + strings.Repeat(" ", defaultMaxBodySize), status: http.StatusRequestEntityTooLarge},
+		{name: "invalid json", body: "{", status: http.StatusBadRequest},
+	} {
+		for _, op := range b.operations {
+			if len(op.request) == 0 || (op.request[0].body == nil && !tc.stream) {
+				continue
+			}
+			status := checkOperation(t, b.router, compiler, &doc, op, tc.name, func(req *http.Request) {
+				if tc.contentType != "" {
+					req.Header.Set("Content-Type", tc.contentType)
+				}
+				req.Body = io.NopCloser(strings.NewReader(tc.body))
+			})
+			require.Equal(t, tc.status, status, "%s %s: %s", op.method, op.path, tc.name)
+		}
+	}
+}
+
+func isBodyError(err error) bool {
+	return errors.Is(err, errInvalidBody) || errors.Is(err, errBodyTooLarge) || errors.Is(err, errUnsupportedMediaType)
+}
+
+// checkOperation will call the operation with an empty body, the examples of its parameters and an api key, changed by
+// prepare if given, and check its response is described by the document, the status is returned
+func checkOperation(t *testing.T, router http.Handler, compiler *jsonschema.Compiler, doc *openAPIDocument, op operation, name string, prepare func(req *http.Request)) int {
+	name += " " + op.method + " " + op.path
+	described := doc.Paths[op.path][strings.ToLower(op.method)]
+	require.NotNil(t, described, name)
+
+	reqType, reqBody := exampleBody(described)
+	req := httptest.NewRequest(op.method, "http://localhost"+pathParamPattern.ReplaceAllString(op.path, "testid"), strings.NewReader(reqBody))
+	if reqType != "" {
+		req.Header.Set("Content-Type", reqType)
+	}
+	query := req.URL.Query()
+	for _, p := range op.params {
+		if p.in == "query" && p.example != "" {
+			query.Set(p.name, p.example)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+	req = req.WithContext(context.WithValue(req.Context(), apiKeyContextKey, &users.APIKey{ID: "keyid", UserID: "testid", Scopes: []users.Scope{users.ScopeUsersAdmin}}))
+	if prepare != nil {
+		prepare(req)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	status := w.Result().StatusCode
+
+	key := strconv.Itoa(status)
+	res, ok := described.Responses[key]
+	if !ok {
+		require.GreaterOrEqual(t, status, http.StatusInternalServerError, "%s: status %d isn't described", name, status)
+		key, res = "default", described.Responses["default"]
+	}
+	if len(res.Content) == 0 {
+		require.Empty(t, w.Body.String(), name)
+		return status
+	}
+	contentType, _, err := mime.ParseMediaType(w.Result().Header.Get("Content-Type"))
+	require.NoError(t, err, name)
+	mediaType, ok := res.Content[contentType]
+	require.True(t, ok, "%s: content type %s isn't described", name, contentType)
+	if mediaType.Schema == nil {
+		return status
+	}
+
+	body := w.Body.Bytes()
+	var v interface{} = string(body)
+	if strings.HasSuffix(contentType, "json") {
+		require.NoError(t, json.Unmarshal(body, &v), name)
+	}
+	s, err := compiler.Compile("openapi.json#/paths/" + jsonPointerEscape(op.path) + "/" + strings.ToLower(op.method) +
+		"/responses/" + key + "/content/" + jsonPointerEscape(contentType) + "/schema")
+	require.NoError(t, err, name)
+	require.NoError(t, s.Validate(v), "%s: %s", name, body)
+	return status
+}
+
+// exampleBody will return an empty body of the first request content type, an empty form or stream has no field
+func exampleBody(op *openAPIOperation) (string, string) {
+	if op.RequestBody == nil {
+		return "", ""
+	}
+	contentTypes := make([]string, 0, len(op.RequestBody.Content))
+	for contentType := range op.RequestBody.Content {
+		contentTypes = append(contentTypes, contentType)
+	}
+	sort.Strings(contentTypes)
+	if contentTypes[0] == formContentType || op.RequestBody.Content[contentTypes[0]].Schema == nil {
+		return contentTypes[0], ""
+	}
+	if s := op.RequestBody.Content[contentTypes[0]].Schema; s.AnyOf != nil && s.AnyOf[0].Type == "array" {
+		return contentTypes[0], "[]"
+	}
+	return contentTypes[0], "{}"
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func jsonPointerEscape(s string) string {
+	return jsonPointerEscaper.Replace(s)
+}
@@ -0,0 +1,302 @@
+package http
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// parquetMagic starts and ends a parquet file
+const parquetMagic = "PAR1"
+
+// the parquet enums used by parquetWriter, see https://github.com/apache/parquet-format/blob/master/src/main/thrift/parquet.thrift
+const (
+	parquetInt64     int32 = 2
+	parquetByteArray int32 = 6
+
+	parquetUTF8            int32 = 0
+	parquetTimestampMillis int32 = 9
+	parquetJSON            int32 = 19
+
+	parquetOptional int32 = 1
+
+	parquetPlain        int32 = 0
+	parquetRLE          int32 = 3
+	parquetDataPage     int32 = 0
+	parquetUncompressed int32 = 0
+)
+
+// parquetColumn is an optional column of a parquet file and the values of its current row group
+type parquetColumn struct {
+	name string
+	// typ is the physical type, int64 or byte array, and converted its logical type
+	typ       int32
+	converted int32
+	// defined tells if the value of each row is set, values are the plain encoding of the set values
+	defined []bool
+	values  []byte
+}
+
+// parquetWriter will write a parquet file of optional columns, without compression. The rows are buffered until
+// their row group is full, the row groups have a single page by column
+type parquetWriter struct {
+	w         io.Writer
+	offset    int64
+	columns   []*parquetColumn
+	groupSize int
+	rows      int
+	groups    []parquetRowGroup
+	total     int64
+	err       error
+}
+
+// parquetRowGroup is the metadata of a written row group
+type parquetRowGroup struct {
+	rows    int64
+	size    int64
+	columns []parquetColumnChunk
+}
+
+type parquetColumnChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+// newParquetWriter will start the file, it should be closed to write its footer
+func newParquetWriter(w io.Writer, columns []*parquetColumn, groupSize int) *parquetWriter {
+	p := &parquetWriter{w: w, columns: columns, groupSize: groupSize}
+	p.write([]byte(parquetMagic))
+	return p
+}
+
+func (p *parquetWriter) write(data []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(data)
+	p.offset += int64(n)
+	p.err = err
+}
+
+// appendBytes will add a byte array value to the current row of the column
+func (c *parquetColumn) appendBytes(v []byte, set bool) {
+	c.defined = append(c.defined, set)
+	if set {
+		c.values = appendUint32(c.values, uint32(len(v)))
+		c.values = append(c.values, v...)
+	}
+}
+
+// appendInt64 will add an int64 value to the current row of the column
+func (c *parquetColumn) appendInt64(v int64, set bool) {
+	c.defined = append(c.defined, set)
+	if set {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		c.values = append(c.values, b[:]...)
+	}
+}
+
+// endRow is called once the values of the row are added to all the columns, the row group is written once full
+func (p *parquetWriter) endRow() error {
+	p.rows++
+	if p.rows >= p.groupSize {
+		p.writeRowGroup()
+	}
+	return p.err
+}
+
+func (p *parquetWriter) writeRowGroup() {
+	group := parquetRowGroup{rows: int64(p.rows)}
+	for _, c := range p.columns {
+		data := definitionLevels(c.defined)
+		data = append(data, c.values...)
+
+		header := newThriftEncoder()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.beginStruct(5)
+		header.i32(1, int32(len(c.defined)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := parquetColumnChunk{offset: p.offset, size: int64(len(header.buf) + len(data)), values: int64(len(c.defined))}
+		p.write(header.buf)
+		p.write(data)
+		group.columns = append(group.columns, chunk)
+		group.size += chunk.size
+		c.defined, c.values = c.defined[:0], c.values[:0]
+	}
+	p.groups = append(p.groups, group)
+	p.total += group.rows
+	p.rows = 0
+}
+
+// definitionLevels will encode the levels of the optional values as a single bit-packed run, prefixed by its length
+func definitionLevels(defined []bool) []byte {
+	groups := (len(defined) + 7) / 8
+	run := appendUvarint(nil, uint64(groups)<<1|1)
+	packed := make([]byte, groups)
+	for i, set := range defined {
+		if set {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	run = append(run, packed...)
+	return append(appendUint32(nil, uint32(len(run))), run...)
+}
+
+// Close will write the rows left and the footer describing the file
+func (p *parquetWriter) Close() error {
+	if p.rows > 0 {
+		p.writeRowGroup()
+	}
+
+	meta := newThriftEncoder()
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(p.columns)+1)
+	meta.beginElement()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(p.columns)))
+	meta.endStruct()
+	for _, c := range p.columns {
+		meta.beginElement()
+		meta.i32(1, c.typ)
+		meta.i32(3, parquetOptional)
+		meta.binary(4, c.name)
+		meta.i32(6, c.converted)
+		meta.endStruct()
+	}
+	meta.i64(3, p.total)
+	meta.list(4, thriftStruct, len(p.groups))
+	for _, group := range p.groups {
+		meta.beginElement()
+		meta.list(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			meta.beginElement()
+			meta.i64(2, chunk.offset)
+			meta.beginStruct(3)
+			meta.i32(1, p.columns[i].typ)
+			meta.list(2, thriftI32, 2)
+			meta.varint(int64(parquetPlain))
+			meta.varint(int64(parquetRLE))
+			meta.list(3, thriftBinary, 1)
+			meta.string(p.columns[i].name)
+			meta.i32(4, parquetUncompressed)
+			meta.i64(5, chunk.values)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.i64(2, group.size)
+		meta.i64(3, group.rows)
+		meta.endStruct()
+	}
+	meta.binary(6, "go-users-example")
+	meta.endStruct()
+
+	p.write(meta.buf)
+	p.write(appendUint32(nil, uint32(len(meta.buf))))
+	p.write([]byte(parquetMagic))
+	return p.err
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var le [4]byte
+	binary.LittleEndian.PutUint32(le[:], v)
+	return append(b, le[:]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var varint [binary.MaxVarintLen64]byte
+	return append(b, varint[:binary.PutUvarint(varint[:], v)]...)
+}
+
+// the types of the thrift compact protocol
+const (
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// thriftEncoder will encode the parquet metadata with the thrift compact protocol, the encoded value is a struct
+type thriftEncoder struct {
+	buf []byte
+	// fields are the last field ids of the structs being encoded
+	fields []int16
+}
+
+func newThriftEncoder() *thriftEncoder {
+	return &thriftEncoder{fields: []int16{0}}
+}
+
+func (e *thriftEncoder) field(id int16, typ byte) {
+	last := &e.fields[len(e.fields)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		e.buf = append(e.buf, byte(delta)<<4|typ)
+	} else {
+		e.buf = append(e.buf, typ)
+		e.varint(int64(id))
+	}
+	*last = id
+}
+
+// varint will encode a zigzag varint, the encoding of the integers and of the i32 list elements
+func (e *thriftEncoder) varint(v int64) {
+	e.buf = appendUvarint(e.buf, uint64(v<<1^v>>63))
+}
+
+// string will encode a binary, the encoding of the list elements
+func (e *thriftEncoder) string(s string) {
+	e.buf = appendUvarint(e.buf, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *thriftEncoder) i32(id int16, v int32) {
+	e.field(id, thriftI32)
+	e.varint(int64(v))
+}
+
+func (e *thriftEncoder) i64(id int16, v int64) {
+	e.field(id, thriftI64)
+	e.varint(v)
+}
+
+func (e *thriftEncoder) binary(id int16, s string) {
+	e.field(id, thriftBinary)
+	e.string(s)
+}
+
+// list will encode the header of a list, followed by its n elements
+func (e *thriftEncoder) list(id int16, elem byte, n int) {
+	e.field(id, thriftList)
+	if n < 15 {
+		e.buf = append(e.buf, byte(n)<<4|elem)
+		return
+	}
+	e.buf = append(e.buf, 0xf0|elem)
+	e.buf = appendUvarint(e.buf, uint64(n))
+}
+
+func (e *thriftEncoder) beginStruct(id int16) {
+	e.field(id, thriftStruct)
+	e.fields = append(e.fields, 0)
+}
+
+// beginElement will start a struct element of a list
+func (e *thriftEncoder) beginElement() {
+	e.fields = append(e.fields, 0)
+}
+
+func (e *thriftEncoder) endStruct() {
+	e.buf = append(e.buf, 0)
+	e.fields = e.fields[:len(e.fields)-1]
+}
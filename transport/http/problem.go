@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/apikeystore"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypeBase    = "/problems/"
+)
+
+// problem is the RFC 7807 representation of an error.
+// Note: the internal error is never exposed, only the information of the matching problemType
+type problem struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Violations []users.FieldViolation `json:"violations,omitempty"`
+}
+
+// problemType define how a sentinel error is exposed
+type problemType struct {
+	err    error
+	slug   string
+	title  string
+	detail string
+	status int
+}
+
+// problemTypes is the translation of the domain and store errors, the first matching error is used
+var problemTypes = []problemType{
+	{err: errInvalidBody, slug: "invalid-body", status: http.StatusBadRequest,
+		title: "Invalid body", detail: "The body isn't valid json or doesn't match the schema of the route."},
+	{err: errBodyTooLarge, slug: "body-too-large", status: http.StatusRequestEntityTooLarge,
+		title: "Body too large", detail: "The body exceeds the size limit of the server."},
+	{err: errUnsupportedMediaType, slug: "unsupported-media-type", status: http.StatusUnsupportedMediaType,
+		title: "Unsupported media type", detail: "The content type of the body isn't accepted by the route."},
+	{err: users.ErrInvalidUser, slug: "invalid-user", status: http.StatusUnprocessableEntity,
+		title: "Invalid user", detail: "One or more fields of the user aren't valid."},
+	{err: users.ErrInvalidAPIKey, slug: "invalid-api-key", status: http.StatusUnprocessableEntity,
+		title: "Invalid api key", detail: "The api key definition isn't valid."},
+	{err: users.ErrInvalidOIDCClient, slug: "invalid-oidc-client", status: http.StatusUnprocessableEntity,
+		title: "Invalid OpenID Connect client", detail: "The client definition isn't valid."},
+	{err: users.ErrInvalidImport, slug: "invalid-import", status: http.StatusUnprocessableEntity,
+		title: "Invalid import", detail: "The format of the import isn't supported or its csv header isn't valid."},
+	{err: users.ErrInvalidExport, slug: "invalid-export", status: http.StatusUnprocessableEntity,
+		title: "Invalid export", detail: "An exported field is unknown or can't be exported."},
+	{err: users.ErrInvalidBatch, slug: "invalid-batch", status: http.StatusUnprocessableEntity,
+		title: "Invalid batch", detail: "The batch is empty, has too many operations or an operation isn't exactly one change."},
+	{err: users.ErrAtomicBatchUnsupported, slug: "atomic-batch-unsupported", status: http.StatusUnprocessableEntity,
+		title: "Atomic batch unsupported", detail: "The store can't apply the operations of a batch atomically."},
+	{err: users.ErrBatchAborted, slug: "batch-aborted", status: http.StatusFailedDependency,
+		title: "Batch aborted", detail: "Another operation of the atomic batch failed, the operation isn't applied."},
+	{err: users.ErrInvalidCode, slug: "invalid-code", status: http.StatusUnprocessableEntity,
+		title: "Invalid code", detail: "The provided code isn't valid."},
+	{err: users.ErrUserNotFound, slug: "user-not-found", status: http.StatusNotFound,
+		title: "User not found", detail: "No user match the provided id."},
+	{err: userstore.ErrNotFound, slug: "user-not-found", status: http.StatusNotFound,
+		title: "User not found", detail: "No user match the provided id."},
+	{err: apikeystore.ErrNotFound, slug: "api-key-not-found", status: http.StatusNotFound,
+		title: "Api key not found", detail: "No api key of the user match the provided id."},
+	{err: users.ErrImportJobNotFound, slug: "import-job-not-found", status: http.StatusNotFound,
+		title: "Import job not found", detail: "No import job match the provided id."},
+	{err: userstore.ErrAlreadyExist, slug: "user-already-exist", status: http.StatusConflict,
+		title: "User already exist", detail: "A user with the same email already exist."},
+	{err: userstore.ErrNickNameAlreadyExist, slug: "nickname-already-exist", status: http.StatusConflict,
+		title: "Nickname already exist", detail: "A user with a similar nickname already exist."},
+	{err: userstore.ErrPhoneAlreadyExist, slug: "phone-already-exist", status: http.StatusConflict,
+		title: "Phone already exist", detail: "A user with the same phone already exist."},
+	{err: userstore.ErrVersionConflict, slug: "user-changed", status: http.StatusConflict,
+		title: "User changed", detail: "The user has been changed by another request, the change should be retried."},
+	{err: users.ErrPreconditionFailed, slug: "precondition-failed", status: http.StatusPreconditionFailed,
+		title: "Precondition failed", detail: "The user isn't at the version of the If-Match header anymore."},
+	{err: users.ErrPhoneAlreadyVerified, slug: "phone-already-verified", status: http.StatusConflict,
+		title: "Phone already verified", detail: "The phone of the user is already verified."},
+	{err: users.ErrNoPhone, slug: "phone-missing", status: http.StatusConflict,
+		title: "Phone missing", detail: "The user should have a phone to verify it."},
+	{err: users.ErrPhoneVerificationNotFound, slug: "phone-verification-not-found", status: http.StatusConflict,
+		title: "Phone verification not found", detail: "No code is pending for the phone of the user, a new one should be sent."},
+	{err: users.ErrTooManyPhoneCodes, slug: "too-many-phone-codes", status: http.StatusTooManyRequests,
+		title: "Too many phone codes", detail: "No other code can be sent to the phone of the user for now, the resend cooldown or the daily limit is reached."},
+	{err: users.ErrImportNotResumable, slug: "import-not-resumable", status: http.StatusConflict,
+		title: "Import not resumable", detail: "Only a failed import job can be resumed."},
+	{err: users.ErrMFAAlreadyEnabled, slug: "mfa-already-enabled", status: http.StatusConflict,
+		title: "Second factor already enabled", detail: "The second factor of the user is already enabled."},
+	{err: users.ErrMFANotEnrolled, slug: "mfa-not-enrolled", status: http.StatusConflict,
+		title: "Second factor not enrolled", detail: "The second factor enrolment should be started first."},
+	{err: users.ErrTooManyLoginAttempts, slug: "too-many-login-attempts", status: http.StatusTooManyRequests,
+		title: "Too many login attempts", detail: "Too many logins failed recently for the user or the client, the login should be retried later."},
+	{err: users.ErrInvalidCredentials, slug: "invalid-credentials", status: http.StatusUnauthorized,
+		title: "Invalid credentials", detail: "The provided credentials can't be verified."},
+	{err: users.ErrUnauthenticated, slug: "unauthenticated", status: http.StatusUnauthorized,
+		title: "Unauthenticated", detail: "The provided api key is unknown, expired or revoked."},
+	{err: users.ErrForbidden, slug: "forbidden", status: http.StatusForbidden,
+		title: "Forbidden", detail: "The credentials don't allow this request."},
+}
+
+// writeError will translate the error to its problem and write it. Unknown errors are returned as internal errors
+func writeError(log logger.Logger, writer http.ResponseWriter, request *http.Request, err error) {
+	writeProblem(writer, errorProblem(log, request, err))
+}
+
+// errorProblem will translate the error to its problem, unknown errors are logged and translated as internal errors
+func errorProblem(log logger.Logger, request *http.Request, err error) *problem {
+	for _, pt := range problemTypes {
+		if !errors.Is(err, pt.err) {
+			continue
+		}
+		p := newProblem(request, pt.status)
+		p.Type, p.Title, p.Detail = problemTypeBase+pt.slug, pt.title, pt.detail
+		var (
+			verr *users.ValidationError
+			berr *bodyError
+		)
+		if errors.As(err, &verr) {
+			p.Violations = verr.Violations
+		} else if errors.As(err, &berr) {
+			p.Violations = berr.violations
+		}
+		log.Debug().Err(err).Int("status", pt.status).Msg("request failed")
+		return p
+	}
+	log.Error().Err(err).Send()
+	return newProblem(request, http.StatusInternalServerError)
+}
+
+// writeStatusProblem will write a problem without more semantic than the status
+func writeStatusProblem(writer http.ResponseWriter, request *http.Request, status int) {
+	writeProblem(writer, newProblem(request, status))
+}
+
+// newProblem will return a problem of type "about:blank" as defined in RFC 7807
+func newProblem(request *http.Request, status int) *problem {
+	return &problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Instance:  request.URL.Path,
+		RequestID: users.RequestInfoFromContext(request.Context()).RequestID,
+	}
+}
+
+func writeProblem(writer http.ResponseWriter, p *problem) {
+	data, _ := json.Marshal(p)
+	writer.Header().Set("Content-Type", problemContentType)
+	writer.WriteHeader(p.Status)
+	_, _ = writer.Write(data)
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+func TestWriteError(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err    error
+		status int
+		typ    string
+	}{
+		"not found":      {err: fmt.Errorf("can't save new user: %w", userstore.ErrNotFound), status: http.StatusNotFound, typ: "/problems/user-not-found"},
+		"already exist":  {err: fmt.Errorf("email test already created: %w", userstore.ErrAlreadyExist), status: http.StatusConflict, typ: "/problems/user-already-exist"},
+		"not enrolled":   {err: users.ErrMFANotEnrolled, status: http.StatusConflict, typ: "/problems/mfa-not-enrolled"},
+		"invalid user":   {err: &users.ValidationError{Violations: []users.FieldViolation{{Field: "email", Code: users.CodeInvalidFormat, Message: "email invalid"}}}, status: http.StatusUnprocessableEntity, typ: "/problems/invalid-user"},
+		"internal error": {err: errors.New("db password is hunter2"), status: http.StatusInternalServerError, typ: "about:blank"},
+	} {
+		req := httptest.NewRequest("PUT", "http://localhost/v1/user", nil)
+		req = req.WithContext(users.WithRequestInfo(req.Context(), users.RequestInfo{RequestID: "req-1"}))
+		w := httptest.NewRecorder()
+
+		writeError(logger.Logger{}, w, req, tc.err)
+
+		resp := w.Result()
+		var p problem
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&p), name)
+		require.Equal(t, tc.status, resp.StatusCode, name)
+		require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"), name)
+		require.Equal(t, tc.typ, p.Type, name)
+		require.Equal(t, tc.status, p.Status, name)
+		require.Equal(t, "req-1", p.RequestID, name)
+		require.Equal(t, "/v1/user", p.Instance, name)
+		require.NotContains(t, p.Detail, tc.err.Error(), name)
+	}
+}
+
+func TestBuilder_MalformedRequest(t *testing.T) {
+	handler := NewBuilder(logger.Logger{}, Config{}).WithV1CreateUser(func(ctx context.Context, req *users.CreateReq) (*users.CreateResp, error) {
+		return &users.CreateResp{}, nil
+	}).handler()
+
+	req := httptest.NewRequest("POST", "http://localhost/v1/user", strings.NewReader(`{"first_name": `))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	resp := w.Result()
+	var p problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "/problems/invalid-body", p.Type)
+	require.Equal(t, "Invalid body", p.Title)
+	require.NotEmpty(t, p.RequestID)
+}
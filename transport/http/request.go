@@ -0,0 +1,239 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+const (
+	// defaultMaxBodySize is the size limit of the request bodies when it isn't configured
+	defaultMaxBodySize = 1 << 20
+	// formContentType is the content type of the html forms, their fields are read as an object of strings
+	formContentType = "application/x-www-form-urlencoded"
+)
+
+// the violation codes of the bodies which can't be decoded
+const (
+	codeInvalidJSON = "invalid_json"
+	codeInvalidBody = "invalid_body"
+)
+
+var (
+	errInvalidBody          = errors.New("invalid body")
+	errBodyTooLarge         = errors.New("body too large")
+	errUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+// bodyError is a request body refused before reaching the use case, with the violations explaining why
+type bodyError struct {
+	err        error
+	violations []users.FieldViolation
+}
+
+func (e *bodyError) Error() string {
+	msgs := make([]string, 0, len(e.violations))
+	for _, v := range e.violations {
+		msgs = append(msgs, v.Message)
+	}
+	return e.err.Error() + ": " + strings.Join(msgs, ", ")
+}
+
+func (e *bodyError) Unwrap() error {
+	return e.err
+}
+
+// invalidBody is the error of a body with a single violation
+func invalidBody(field, code, message string) error {
+	return &bodyError{err: errInvalidBody, violations: []users.FieldViolation{{Field: field, Code: code, Message: message}}}
+}
+
+// bodyValidator will check the request bodies of a route against the schemas of its accepted content types
+type bodyValidator struct {
+	maxSize int64
+	schemas map[string]*jsonschema.Schema
+}
+
+// newBodyValidator will compile the request schemas of the operation, an unknown field is refused
+func newBodyValidator(op operation, maxSize int64) *bodyValidator {
+	if maxSize <= 0 {
+		maxSize = defaultMaxBodySize
+	}
+	v := &bodyValidator{maxSize: maxSize, schemas: make(map[string]*jsonschema.Schema)}
+	g := newSchemaGenerator()
+	for _, c := range op.request {
+		if c.body == nil {
+			v.schemas[c.contentType] = nil
+			continue
+		}
+		data, _ := json.Marshal(g.requestSchema(c.body))
+		compiler := jsonschema.NewCompiler()
+		compiler.Draft = jsonschema.Draft2020
+		if err := compiler.AddResource("body.json", bytes.NewReader(data)); err != nil {
+			panic(fmt.Sprintf("can't add the %s schema of %s %s: %s", c.contentType, op.method, op.path, err))
+		}
+		v.schemas[c.contentType] = compiler.MustCompile("body.json")
+	}
+	return v
+}
+
+// middleware will refuse the requests whose body doesn't have an accepted content type, is too large, isn't json (or
+// a form) or doesn't match the schema. The checked body is given to the handler, to be read with decodeBody.
+// A stream body is given as is, its read fails with errBodyTooLarge once the size limit is exceeded
+func (v *bodyValidator) middleware(log logger.Logger, write errorWriter, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := v.check(request); err != nil {
+			write(log, writer, request, err)
+			return
+		}
+		next(writer, request)
+	}
+}
+
+func (v *bodyValidator) check(request *http.Request) error {
+	contentType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("can't parse content type: %w", errUnsupportedMediaType)
+	}
+	schema, ok := v.schemas[contentType]
+	if !ok {
+		return fmt.Errorf("content type %s: %w", contentType, errUnsupportedMediaType)
+	}
+	if schema == nil {
+		request.Body = &limitedBody{ReadCloser: request.Body, remaining: v.maxSize}
+		return nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(request.Body, v.maxSize+1))
+	if err != nil {
+		return invalidBody("", codeInvalidBody, "the body can't be read")
+	}
+	if int64(len(data)) > v.maxSize {
+		return fmt.Errorf("body exceeds %d bytes: %w", v.maxSize, errBodyTooLarge)
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	var body interface{}
+	if contentType == formContentType {
+		values, err := url.ParseQuery(string(data))
+		if err != nil {
+			return invalidBody("", codeInvalidBody, "the body isn't a valid form: "+err.Error())
+		}
+		body = formObject(values)
+	} else if err := json.Unmarshal(data, &body); err != nil {
+		return invalidBody("", codeInvalidJSON, "the body isn't valid json: "+err.Error())
+	}
+	var verr *jsonschema.ValidationError
+	if err := schema.Validate(body); !errors.As(err, &verr) {
+		return nil
+	}
+	berr := &bodyError{err: errInvalidBody}
+	seen := make(map[string]bool)
+	add := func(field, code, keyword, message string) {
+		// a nullable field report both of its types, only the first is kept
+		if seen[field+keyword] {
+			return
+		}
+		seen[field+keyword] = true
+		if field != "" {
+			message = field + ": " + message
+		}
+		berr.violations = append(berr.violations, users.FieldViolation{
+			Field:   field,
+			Code:    code,
+			Message: message,
+			Params:  map[string]interface{}{"keyword": keyword},
+		})
+	}
+	for _, leaf := range users.ValidationLeaves(verr) {
+		keyword := leaf.KeywordLocation[strings.LastIndex(leaf.KeywordLocation, "/")+1:]
+		field := strings.TrimPrefix(strings.ReplaceAll(leaf.InstanceLocation, "/", "."), ".")
+		if keyword != "additionalProperties" {
+			add(field, codeInvalidBody, keyword, leaf.Message)
+			continue
+		}
+		// the unknown fields are reported on their object, with their names quoted in the message
+		for _, match := range quotedPattern.FindAllStringSubmatch(leaf.Message, -1) {
+			name := match[1]
+			if field != "" {
+				name = field + "." + name
+			}
+			add(name, users.CodeUnknown, keyword, "unknown field")
+		}
+	}
+	return berr
+}
+
+// limitedBody is a stream body which fails once more than its size limit is read
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n - 1, errBodyTooLarge
+	}
+	return n, err
+}
+
+// decodeBody will decode the json or form body of the request, already checked against the route schema by
+// Builder.handle
+func decodeBody(request *http.Request, v interface{}) error {
+	var body io.Reader = request.Body
+	if contentType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type")); contentType == formContentType {
+		data, _ := ioutil.ReadAll(request.Body)
+		values, err := url.ParseQuery(string(data))
+		if err != nil {
+			return invalidBody("", codeInvalidBody, "the body isn't a valid form: "+err.Error())
+		}
+		encoded, _ := json.Marshal(formObject(values))
+		body = bytes.NewReader(encoded)
+	}
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return invalidBody("", codeInvalidJSON, "the body can't be decoded: "+err.Error())
+	}
+	return nil
+}
+
+// formObject will read the form as an object of strings, the repeated fields are arrays
+func formObject(values url.Values) map[string]interface{} {
+	object := make(map[string]interface{}, len(values))
+	for name, v := range values {
+		if len(v) == 1 {
+			object[name] = v[0]
+			continue
+		}
+		items := make([]interface{}, len(v))
+		for i := range v {
+			items[i] = v[i]
+		}
+		object[name] = items
+	}
+	return object
+}
+
+// quotedPattern match the field names quoted in the messages of the schema validation
+var quotedPattern = regexp.MustCompile(`'([^']*)'`)
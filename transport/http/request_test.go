@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_handle_Body(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{MaxBodySize: 128}).WithV1CreateUser(func(ctx context.Context, req *users.CreateReq) (*users.CreateResp, error) {
+		require.Equal(t, "test", req.FirstName)
+		return &users.CreateResp{User: &users.User{ID: "newid"}}, nil
+	}).router
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		slug        string
+		violations  []users.FieldViolation
+	}{
+		{name: "valid", contentType: "application/json", body: `{"first_name": "test"}`, status: http.StatusOK},
+		{name: "charset", contentType: "application/json; charset=utf-8", body: `{"first_name": "test"}`, status: http.StatusOK},
+		{name: "missing content type", body: `{"first_name": "test"}`, status: http.StatusUnsupportedMediaType, slug: "unsupported-media-type"},
+		{name: "unsupported content type", contentType: "text/plain", body: `{"first_name": "test"}`, status: http.StatusUnsupportedMediaType, slug: "unsupported-media-type"},
+		{name: "too large", contentType: "application/json", body: `{"first_name": "` + strings.Repeat("t", 128) + `"}`, status: http.StatusRequestEntityTooLarge, slug: "body-too-large"},
+		{name: "invalid json", contentType: "application/json", body: `{"first_name": `, status: http.StatusBadRequest, slug: "invalid-body",
+			violations: []users.FieldViolation{{Code: codeInvalidJSON}}},
+		{name: "unknown field", contentType: "application/json", body: `{"first_name": "test", "firstname": "test"}`, status: http.StatusBadRequest, slug: "invalid-body",
+			violations: []users.FieldViolation{{Field: "firstname", Code: users.CodeUnknown}}},
+		{name: "wrong type", contentType: "application/json", body: `{"first_name": 12, "attributes": []}`, status: http.StatusBadRequest, slug: "invalid-body",
+			violations: []users.FieldViolation{{Field: "attributes", Code: codeInvalidBody}, {Field: "first_name", Code: codeInvalidBody}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://localhost/v1/user", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			resp := w.Result()
+			require.Equal(t, tc.status, resp.StatusCode)
+			if tc.slug == "" {
+				return
+			}
+			var p problem
+			require.Equal(t, problemContentType, resp.Header.Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+			require.Equal(t, "/problems/"+tc.slug, p.Type)
+			require.Len(t, p.Violations, len(tc.violations))
+			for _, expected := range tc.violations {
+				found := false
+				for _, v := range p.Violations {
+					if v.Field == expected.Field && v.Code == expected.Code {
+						found = true
+						require.NotEmpty(t, v.Message)
+					}
+				}
+				require.True(t, found, "violation %s %s not found in %+v", expected.Field, expected.Code, p.Violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const jsonContentType = "application/json"
+
+// writeJSON will write the body as json with its content type and the status
+func writeJSON(writer http.ResponseWriter, status int, body interface{}) {
+	data, _ := json.Marshal(body)
+	writer.Header().Set("Content-Type", jsonContentType)
+	writer.WriteHeader(status)
+	_, _ = writer.Write(data)
+}
@@ -0,0 +1,318 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"go-users-example/domain/users"
+)
+
+// scimRequest are the accepted bodies of the SCIM routes
+func scimRequest(body interface{}) []content {
+	return []content{{contentType: scimContentType, body: body}, {contentType: jsonContentType, body: body}}
+}
+
+func scimResponse(body interface{}) []content {
+	return []content{{contentType: scimContentType, body: body}}
+}
+
+// WithSCIM will add the SCIM 2.0 endpoints (RFC 7644) to provision the users from an identity provider, on /scim/v2.
+// The users routes require an api key, see WithAPIKeyAuth, their errors are SCIM errors. The users are listed
+// through users.Search: the filter should narrow the users with the equality of an id, a userName, a name, an email,
+// a phone or a country, the other filters are refused as too many. All the users are listed, by page, without filter.
+// The deleted users are listed as inactive.
+// Note: an inactive user is deleted, it is restored once set active again during the restore window
+func (b *Builder) WithSCIM(searchUser users.Search, createUser users.Create, updateUser users.Update, deleteUser users.Delete, restoreUser users.Restore) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/scim/v2/ServiceProviderConfig", id: "scimGetServiceProviderConfig",
+		summary:   "Get the SCIM features of the server",
+		responses: []response{{status: http.StatusOK, content: scimResponse(serviceProviderConfig)}},
+		errs:      scimFormat,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		writeSCIM(writer, http.StatusOK, serviceProviderConfig)
+	})
+	b.handle(operation{
+		method: http.MethodGet, path: "/scim/v2/Schemas", id: "scimListSchemas", summary: "List the SCIM schemas",
+		responses: []response{{status: http.StatusOK, content: scimResponse(newSCIMList(scimSchemas, 1, len(scimSchemas)))}},
+		errs:      scimFormat,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		writeSCIM(writer, http.StatusOK, newSCIMList(scimSchemas, 1, len(scimSchemas)))
+	})
+	b.handle(operation{
+		method: http.MethodGet, path: "/scim/v2/Schemas/{id}", id: "scimGetSchema", summary: "Get a SCIM schema",
+		responses: []response{{status: http.StatusOK, content: scimResponse(scimSchemas[0])}},
+		problems:  []int{http.StatusNotFound},
+		errs:      scimFormat,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		id := chi.URLParam(request, "id")
+		for _, s := range scimSchemas {
+			if s.ID == id {
+				writeSCIM(writer, http.StatusOK, s)
+				return
+			}
+		}
+		writeSCIMError(b.log, writer, request, fmt.Errorf("schema %q: %w", id, errSCIMSchemaNotFound))
+	})
+
+	b.handle(operation{
+		method: http.MethodGet, path: scimUsersPath, id: "scimListUsers", summary: "List the users matching a SCIM filter",
+		params: []parameter{
+			{name: "filter", in: "query", description: "The SCIM filter of the users, it should narrow the users with the equality of an attribute. All the users are listed without filter.", example: `userName eq "tester"`},
+			{name: "startIndex", in: "query", typ: "integer", description: "The 1-based index of the first user."},
+			{name: "count", in: "query", typ: "integer", description: fmt.Sprintf("The maximum number of users, %d by default.", scimMaxResults)},
+		},
+		responses: []response{{status: http.StatusOK, content: scimResponse(newSCIMList([]scimUser{}, 1, scimMaxResults))}},
+		problems:  []int{http.StatusBadRequest},
+		errs:      scimFormat,
+		admin:     true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		list, err := listSCIMUsers(request, searchUser)
+		if err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		writeSCIM(writer, http.StatusOK, list)
+	})
+	b.handle(operation{
+		method: http.MethodPost, path: scimUsersPath, id: "scimCreateUser", summary: "Create a user from its SCIM representation",
+		request: scimRequest(scimUser{}),
+		responses: []response{{
+			status:  http.StatusCreated,
+			headers: map[string]string{"Location": "The path of the created user."},
+			content: scimResponse(scimUser{}),
+		}},
+		problems: []int{http.StatusConflict},
+		errs:     scimFormat,
+		admin:    true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var su scimUser
+		if err := decodeBody(request, &su); err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		if su.deactivated() {
+			writeSCIMError(b.log, writer, request, fmt.Errorf("a user is created active: %w", errSCIMInvalidValue))
+			return
+		}
+		res, err := createUser(request.Context(), su.createRequest())
+		if err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		writer.Header().Set("Location", scimUsersPath+"/"+url.PathEscape(res.User.ID))
+		writeSCIM(writer, http.StatusCreated, newSCIMUser(res.User))
+	})
+	b.handle(operation{
+		method: http.MethodGet, path: scimUsersPath + "/{id}", id: "scimGetUser", summary: "Get the SCIM representation of a user",
+		responses: []response{{status: http.StatusOK, content: scimResponse(scimUser{})}},
+		problems:  []int{http.StatusNotFound},
+		errs:      scimFormat,
+		admin:     true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		usr, err := getSCIMUser(request, searchUser)
+		if err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		writeSCIM(writer, http.StatusOK, newSCIMUser(usr))
+	})
+	b.handle(operation{
+		method: http.MethodPut, path: scimUsersPath + "/{id}", id: "scimReplaceUser", summary: "Replace a user by its SCIM representation",
+		description: "The absent attributes are cleared, except the password which is kept. An inactive user is deleted, and restored once active again.",
+		request:     scimRequest(scimUser{}),
+		responses:   []response{{status: http.StatusOK, content: scimResponse(scimUser{})}},
+		problems:    []int{http.StatusNotFound, http.StatusConflict},
+		errs:        scimFormat,
+		admin:       true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var su scimUser
+		if err := decodeBody(request, &su); err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		id := chi.URLParam(request, "id")
+		if su.ID != "" && su.ID != id {
+			writeSCIMError(b.log, writer, request, fmt.Errorf("the id can't be changed: %w", errSCIMMutability))
+			return
+		}
+		usr, err := getSCIMUser(request, searchUser)
+		if err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		usr, err = replaceSCIMUser(request, usr, su, updateUser, deleteUser, restoreUser)
+		if err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		writeSCIM(writer, http.StatusOK, newSCIMUser(usr))
+	})
+	b.handle(operation{
+		method: http.MethodPatch, path: scimUsersPath + "/{id}", id: "scimPatchUser", summary: "Patch the SCIM representation of a user",
+		description: "The add, replace and remove operations are applied to the current representation, which then replaces the user.",
+		request:     scimRequest(scimPatchRequest{}),
+		responses:   []response{{status: http.StatusOK, content: scimResponse(scimUser{})}},
+		problems:    []int{http.StatusNotFound, http.StatusConflict},
+		errs:        scimFormat,
+		admin:       true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var patch scimPatchRequest
+		if err := decodeBody(request, &patch); err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		usr, err := getSCIMUser(request, searchUser)
+		if err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		su, err := applySCIMPatch(newSCIMUser(usr), patch.Operations)
+		if err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		usr, err = replaceSCIMUser(request, usr, su, updateUser, deleteUser, restoreUser)
+		if err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		writeSCIM(writer, http.StatusOK, newSCIMUser(usr))
+	})
+	b.handle(operation{
+		method: http.MethodDelete, path: scimUsersPath + "/{id}", id: "scimDeleteUser", summary: "Delete a user",
+		responses: []response{{status: http.StatusNoContent}},
+		problems:  []int{http.StatusNotFound},
+		errs:      scimFormat,
+		admin:     true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		if _, err := deleteUser(request.Context(), &users.DeleteReq{ID: chi.URLParam(request, "id")}); err != nil {
+			writeSCIMError(b.log, writer, request, err)
+			return
+		}
+		writer.WriteHeader(http.StatusNoContent)
+	})
+	return b
+}
+
+func newSCIMList(resources interface{}, startIndex, count int) scimListResponse {
+	return scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: count,
+		StartIndex:   startIndex,
+		ItemsPerPage: count,
+		Resources:    resources,
+	}
+}
+
+// listSCIMUsers will search the users narrowed by the filter, then keep the ones matching the filter, all the users are
+// listed without filter. The users are sorted by id to paginate them
+func listSCIMUsers(request *http.Request, searchUser users.Search) (scimListResponse, error) {
+	query := request.URL.Query()
+	startIndex, err := scimQueryInt(query, "startIndex", 1)
+	if err != nil {
+		return scimListResponse{}, err
+	}
+	count, err := scimQueryInt(query, "count", scimMaxResults)
+	if err != nil {
+		return scimListResponse{}, err
+	}
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > scimMaxResults {
+		count = scimMaxResults
+	}
+
+	var filter scimFilter
+	req := &users.SearchReq{}
+	if query.Get("filter") != "" {
+		if filter, err = parseSCIMFilter(query.Get("filter")); err != nil {
+			return scimListResponse{}, err
+		}
+		var ok bool
+		if req, ok = narrowSearch(filter, nil); !ok {
+			return scimListResponse{}, fmt.Errorf("the filter should narrow the users with the equality of an attribute: %w", errSCIMTooMany)
+		}
+	}
+	req.WithDeleted = true
+	res, err := searchUser(request.Context(), req)
+	if err != nil {
+		return scimListResponse{}, err
+	}
+
+	matched := []scimUser{}
+	for _, usr := range res.Users {
+		su := newSCIMUser(usr)
+		if filter == nil || filter.match(scimRepresentation(su)) {
+			matched = append(matched, su)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+
+	page := []scimUser{}
+	if startIndex <= len(matched) {
+		page = matched[startIndex-1:]
+	}
+	if len(page) > count {
+		page = page[:count]
+	}
+	list := newSCIMList(page, startIndex, len(page))
+	list.TotalResults = len(matched)
+	return list, nil
+}
+
+func scimQueryInt(query url.Values, name string, byDefault int) (int, error) {
+	if query.Get(name) == "" {
+		return byDefault, nil
+	}
+	v, err := strconv.Atoi(query.Get(name))
+	if err != nil {
+		return 0, fmt.Errorf("%s %q isn't an integer: %w", name, query.Get(name), errSCIMInvalidValue)
+	}
+	return v, nil
+}
+
+// getSCIMUser will return the user of the route, even if it is deleted
+func getSCIMUser(request *http.Request, searchUser users.Search) (*users.User, error) {
+	res, err := searchUser(request.Context(), &users.SearchReq{IDs: []string{chi.URLParam(request, "id")}, WithDeleted: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Users) != 1 {
+		return nil, fmt.Errorf("can't get user: %w", users.ErrUserNotFound)
+	}
+	return res.Users[0], nil
+}
+
+// replaceSCIMUser will replace the user by its SCIM representation, or delete it if it is inactive. A deleted user is
+// restored before its replacement if it is active, it is kept as is while it stays inactive
+func replaceSCIMUser(request *http.Request, usr *users.User, su scimUser, updateUser users.Update, deleteUser users.Delete, restoreUser users.Restore) (*users.User, error) {
+	deleted := usr.DeletedAt != nil
+	switch {
+	case deleted && (su.Active == nil || !*su.Active):
+		return usr, nil
+	case su.deactivated():
+		res, err := deleteUser(request.Context(), &users.DeleteReq{ID: usr.ID})
+		if err != nil {
+			return nil, err
+		}
+		return res.User, nil
+	case deleted:
+		if _, err := restoreUser(request.Context(), &users.RestoreReq{ID: usr.ID}); err != nil {
+			return nil, err
+		}
+	}
+	res, err := updateUser(request.Context(), su.updateRequest(usr.ID))
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
@@ -0,0 +1,532 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go-users-example/domain/users"
+)
+
+// scimFilter is a filter of the SCIM filter grammar (RFC 7644 section 3.4.2.2), it is evaluated on the json
+// representation of the resources
+type scimFilter interface {
+	match(v interface{}) bool
+}
+
+type scimAnd struct{ left, right scimFilter }
+
+func (f scimAnd) match(v interface{}) bool { return f.left.match(v) && f.right.match(v) }
+
+type scimOr struct{ left, right scimFilter }
+
+func (f scimOr) match(v interface{}) bool { return f.left.match(v) || f.right.match(v) }
+
+type scimNot struct{ filter scimFilter }
+
+func (f scimNot) match(v interface{}) bool { return !f.filter.match(v) }
+
+// scimCompare is an attribute expression, the value is a json scalar or nil for the presence operator
+type scimCompare struct {
+	// path is the attribute and its sub attributes, in lower case
+	path  []string
+	op    string
+	value interface{}
+}
+
+func (f scimCompare) match(v interface{}) bool {
+	// the id is the only case exact attribute of the representation
+	caseExact := len(f.path) > 0 && f.path[0] == "id"
+	if f.op == "pr" {
+		return scimPresent(v, f.path)
+	}
+	for _, actual := range scimValues(v, f.path) {
+		if scimCompareValue(actual, f.op, f.value, caseExact) {
+			return true
+		}
+	}
+	return false
+}
+
+// scimValuePath match the resources with a value of the multi-valued attribute matching the filter
+type scimValuePath struct {
+	path   []string
+	filter scimFilter
+}
+
+func (f scimValuePath) match(v interface{}) bool {
+	for _, elem := range scimElements(v, f.path) {
+		if f.filter.match(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+// scimPath is the target of a patch operation: an attribute, the filter of its values if it is multi-valued and a
+// sub attribute of the filtered values
+type scimPath struct {
+	attr   []string
+	filter scimFilter
+	sub    string
+}
+
+// parseSCIMFilter will parse the filter, the attribute names are case insensitive and can be prefixed by the urn of
+// the user schema
+func parseSCIMFilter(s string) (scimFilter, error) {
+	p, err := newSCIMParser(s)
+	if err != nil {
+		return nil, err
+	}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.at(scimEOF) {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	return f, nil
+}
+
+// parseSCIMPath will parse the path of a patch operation (`attr`, `attr.sub`, `attr[filter]` or `attr[filter].sub`)
+func parseSCIMPath(s string) (scimPath, error) {
+	p, err := newSCIMParser(s)
+	if err != nil {
+		return scimPath{}, fmt.Errorf("can't parse path: %w", errSCIMInvalidPath)
+	}
+	if !p.at(scimWord) {
+		return scimPath{}, fmt.Errorf("path %q should start by an attribute: %w", s, errSCIMInvalidPath)
+	}
+	path := scimPath{attr: scimAttrPath(p.next().text)}
+	if p.at(scimLBracket) {
+		p.next()
+		if path.filter, err = p.parseOr(); err != nil {
+			return scimPath{}, err
+		}
+		if !p.at(scimRBracket) {
+			return scimPath{}, fmt.Errorf("path %q isn't closed: %w", s, errSCIMInvalidPath)
+		}
+		p.next()
+		if p.at(scimWord) && strings.HasPrefix(p.peek().text, ".") {
+			path.sub = strings.ToLower(strings.TrimPrefix(p.next().text, "."))
+		}
+	}
+	if !p.at(scimEOF) || (path.filter != nil && len(path.attr) != 1) {
+		return scimPath{}, fmt.Errorf("path %q isn't valid: %w", s, errSCIMInvalidPath)
+	}
+	return path, nil
+}
+
+type scimTokenKind int
+
+const (
+	scimEOF scimTokenKind = iota
+	scimWord
+	scimQuoted
+	scimLParen
+	scimRParen
+	scimLBracket
+	scimRBracket
+)
+
+var scimDelimiters = map[byte]scimTokenKind{'(': scimLParen, ')': scimRParen, '[': scimLBracket, ']': scimRBracket}
+
+type scimToken struct {
+	kind scimTokenKind
+	text string
+}
+
+type scimParser struct {
+	input  string
+	tokens []scimToken
+	pos    int
+}
+
+func newSCIMParser(s string) (*scimParser, error) {
+	p := &scimParser{input: s}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case scimDelimiters[c] != scimEOF:
+			p.tokens = append(p.tokens, scimToken{kind: scimDelimiters[c], text: string(c)})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, p.errorf("unterminated string")
+			}
+			p.tokens = append(p.tokens, scimToken{kind: scimQuoted, text: s[i : end+1]})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t()[]\"", rune(s[end])) {
+				end++
+			}
+			p.tokens = append(p.tokens, scimToken{kind: scimWord, text: s[i:end]})
+			i = end
+		}
+	}
+	return p, nil
+}
+
+func (p *scimParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("can't parse filter %q: %s: %w", p.input, fmt.Sprintf(format, args...), errSCIMInvalidFilter)
+}
+
+func (p *scimParser) peek() scimToken {
+	if p.pos >= len(p.tokens) {
+		return scimToken{kind: scimEOF, text: "end of filter"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *scimParser) next() scimToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *scimParser) at(kind scimTokenKind) bool {
+	return p.peek().kind == kind
+}
+
+// atKeyword is true if the next token is the keyword, the keywords are case insensitive
+func (p *scimParser) atKeyword(keyword string) bool {
+	return p.at(scimWord) && strings.EqualFold(p.peek().text, keyword)
+}
+
+// parseOr will parse a filter, "or" has the lowest precedence
+func (p *scimParser) parseOr() (scimFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.atKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = scimOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *scimParser) parseAnd() (scimFilter, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.atKeyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = scimAnd{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *scimParser) parseNot() (scimFilter, error) {
+	if !p.atKeyword("not") {
+		return p.parsePrimary()
+	}
+	p.next()
+	if !p.at(scimLParen) {
+		return nil, p.errorf("not should be followed by a parenthesis")
+	}
+	f, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return scimNot{filter: f}, nil
+}
+
+func (p *scimParser) parsePrimary() (scimFilter, error) {
+	if p.at(scimLParen) {
+		p.next()
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.at(scimRParen) {
+			return nil, p.errorf("expected ) instead of %q", p.peek().text)
+		}
+		p.next()
+		return f, nil
+	}
+	if !p.at(scimWord) {
+		return nil, p.errorf("expected an attribute instead of %q", p.peek().text)
+	}
+	path := scimAttrPath(p.next().text)
+	if p.at(scimLBracket) {
+		p.next()
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.at(scimRBracket) {
+			return nil, p.errorf("expected ] instead of %q", p.peek().text)
+		}
+		p.next()
+		return scimValuePath{path: path, filter: f}, nil
+	}
+	if !p.at(scimWord) {
+		return nil, p.errorf("expected an operator instead of %q", p.peek().text)
+	}
+	op := strings.ToLower(p.next().text)
+	switch op {
+	case "pr":
+		return scimCompare{path: path, op: op}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "lt", "ge", "le":
+	default:
+		return nil, p.errorf("unknown operator %q", op)
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return scimCompare{path: path, op: op, value: value}, nil
+}
+
+// parseValue will parse a json string, number, boolean or null
+func (p *scimParser) parseValue() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case scimQuoted:
+		var s string
+		if err := json.Unmarshal([]byte(t.text), &s); err != nil {
+			return nil, p.errorf("invalid string %s", t.text)
+		}
+		return s, nil
+	case scimWord:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return f, nil
+		}
+	}
+	return nil, p.errorf("expected a value instead of %q", t.text)
+}
+
+// scimAttrPath will split the attribute in lower case, without the urn of the user schema
+func scimAttrPath(s string) []string {
+	if strings.HasPrefix(strings.ToLower(s), strings.ToLower(scimUserSchema)+":") {
+		s = s[len(scimUserSchema)+1:]
+	}
+	return strings.Split(strings.ToLower(s), ".")
+}
+
+// scimAttr will return the attribute of the object, the attribute names are case insensitive
+func scimAttr(obj map[string]interface{}, name string) (string, interface{}, bool) {
+	for key, v := range obj {
+		if strings.EqualFold(key, name) {
+			return key, v, true
+		}
+	}
+	return "", nil, false
+}
+
+// scimValues will return the values of the attribute, the values of the multi-valued attributes are flattened and
+// the value of a complex attribute is its "value" sub attribute
+func scimValues(v interface{}, path []string) []interface{} {
+	if arr, ok := v.([]interface{}); ok {
+		var values []interface{}
+		for _, elem := range arr {
+			values = append(values, scimValues(elem, path)...)
+		}
+		return values
+	}
+	obj, isObj := v.(map[string]interface{})
+	if len(path) == 0 {
+		if isObj {
+			if _, value, ok := scimAttr(obj, "value"); ok {
+				return scimValues(value, nil)
+			}
+			return nil
+		}
+		if v == nil {
+			return nil
+		}
+		return []interface{}{v}
+	}
+	if !isObj {
+		return nil
+	}
+	_, child, ok := scimAttr(obj, path[0])
+	if !ok {
+		return nil
+	}
+	return scimValues(child, path[1:])
+}
+
+// scimPresent is true if the attribute has a non empty value, or is a complex attribute
+func scimPresent(v interface{}, path []string) bool {
+	for _, value := range scimValues(v, path) {
+		if s, ok := value.(string); !ok || s != "" {
+			return true
+		}
+	}
+	for _, elem := range scimElements(v, path) {
+		if _, ok := elem.(map[string]interface{}); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scimElements will return the values of a multi-valued attribute, or the attribute if it is single valued
+func scimElements(v interface{}, path []string) []interface{} {
+	for _, name := range path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if _, v, ok = scimAttr(obj, name); !ok {
+			return nil
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
+
+func scimCompareValue(actual interface{}, op string, expected interface{}, caseExact bool) bool {
+	switch e := expected.(type) {
+	case string:
+		a, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		if !caseExact {
+			a, e = strings.ToLower(a), strings.ToLower(e)
+		}
+		switch op {
+		case "co":
+			return strings.Contains(a, e)
+		case "sw":
+			return strings.HasPrefix(a, e)
+		case "ew":
+			return strings.HasSuffix(a, e)
+		}
+		return scimOrder(op, strings.Compare(a, e))
+	case float64:
+		a, ok := actual.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case a < e:
+			return scimOrder(op, -1)
+		case a > e:
+			return scimOrder(op, 1)
+		}
+		return scimOrder(op, 0)
+	case bool:
+		a, ok := actual.(bool)
+		if !ok {
+			return false
+		}
+		return (op == "eq" && a == e) || (op == "ne" && a != e)
+	default:
+		return false
+	}
+}
+
+// scimOrder will check the operator against the result of the comparison
+func scimOrder(op string, cmp int) bool {
+	switch op {
+	case "eq":
+		return cmp == 0
+	case "ne":
+		return cmp != 0
+	case "gt":
+		return cmp > 0
+	case "ge":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	case "le":
+		return cmp <= 0
+	}
+	return false
+}
+
+// narrowSearch will return the search of the users which may match the filter, the search is false if the filter
+// can't be narrowed to the equality of attributes known by users.Search
+func narrowSearch(f scimFilter, prefix []string) (*users.SearchReq, bool) {
+	switch f := f.(type) {
+	case scimAnd:
+		if req, ok := narrowSearch(f.left, prefix); ok {
+			return req, true
+		}
+		return narrowSearch(f.right, prefix)
+	case scimOr:
+		left, ok := narrowSearch(f.left, prefix)
+		if !ok {
+			return nil, false
+		}
+		right, ok := narrowSearch(f.right, prefix)
+		if !ok {
+			return nil, false
+		}
+		left.IDs = append(left.IDs, right.IDs...)
+		left.SimilarNickNames = append(left.SimilarNickNames, right.SimilarNickNames...)
+		left.FirstName = append(left.FirstName, right.FirstName...)
+		left.LastName = append(left.LastName, right.LastName...)
+		left.Emails = append(left.Emails, right.Emails...)
+		left.Phones = append(left.Phones, right.Phones...)
+		left.Country = append(left.Country, right.Country...)
+		return left, true
+	case scimValuePath:
+		return narrowSearch(f.filter, append(append([]string{}, prefix...), f.path...))
+	case scimCompare:
+		value, ok := f.value.(string)
+		if f.op != "eq" || !ok {
+			return nil, false
+		}
+		req := &users.SearchReq{}
+		switch strings.Join(append(append([]string{}, prefix...), f.path...), ".") {
+		case "id":
+			req.IDs = []string{value}
+		case "username":
+			// the userName isn't case exact, the similar nicknames are searched and the filter keeps the same ones
+			req.SimilarNickNames = []string{value}
+		case "name.givenname":
+			req.FirstName = []string{value}
+		case "name.familyname":
+			req.LastName = []string{value}
+		case "emails", "emails.value":
+			req.Emails = []string{value}
+		case "phonenumbers", "phonenumbers.value":
+			req.Phones = []string{value}
+		case "addresses.country":
+			req.Country = []string{value}
+		default:
+			return nil, false
+		}
+		return req, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+)
+
+func TestParseSCIMFilter(t *testing.T) {
+	resource := map[string]interface{}{
+		"id":       "AbC",
+		"userName": "Tester",
+		"name":     map[string]interface{}{"givenName": "Barbara", "familyName": "Jensen"},
+		"emails": []interface{}{
+			map[string]interface{}{"value": "bjensen@home.com", "type": "home"},
+			map[string]interface{}{"value": "bjensen@work.com", "type": "work", "primary": true},
+		},
+		"active": true,
+		"level":  float64(3),
+	}
+	for filter, expected := range map[string]bool{
+		`userName eq "tester"`: true,
+		`USERNAME Eq "TESTER"`: true,
+		`id eq "abc"`:          false,
+		`id eq "AbC"`:          true,
+		`userName ne "tester"`: false,
+		`name.givenName sw "bar" and name.familyName ew "sen"`: true,
+		`emails co "work.com"`:                                 true,
+		`emails[type eq "home" and value co "work"]`:           false,
+		`emails[type eq "work" and primary eq true]`:           true,
+		`emails.type eq "home"`:                                true,
+		`title pr`:                                             false,
+		`name pr`:                                              true,
+		`active eq false`:                                      false,
+		`level ge 3 and level lt 4`:                            true,
+		`level gt 3`:                                           false,
+		`userName gt "a" and userName le "z"`:                  true,
+		// and has precedence over or
+		`userName eq "x" and id eq "AbC" or level eq 3`:   true,
+		`userName eq "x" and (id eq "AbC" or level eq 3)`: false,
+		`not (userName eq "x") and active eq true`:        true,
+	} {
+		f, err := parseSCIMFilter(filter)
+		require.NoError(t, err, filter)
+		require.Equal(t, expected, f.match(resource), filter)
+	}
+
+	for _, filter := range []string{``, `userName`, `userName eq`, `userName eq "x" and`, `(userName eq "x"`, `userName eq "x")`,
+		`emails[type eq "work"`, `not userName eq "x"`, `userName eq "x`, `userName eq x`, `userName regex "x"`} {
+		_, err := parseSCIMFilter(filter)
+		require.ErrorIs(t, err, errSCIMInvalidFilter, filter)
+	}
+}
+
+func TestNarrowSearch(t *testing.T) {
+	for filter, expected := range map[string]*users.SearchReq{
+		`userName eq "tester"`:                                         {SimilarNickNames: []string{"tester"}},
+		`userName eq "tester" and name.givenName co "b"`:               {SimilarNickNames: []string{"tester"}},
+		`name.givenName co "b" and name.familyName eq "jensen"`:        {LastName: []string{"jensen"}},
+		`id eq "a" or emails[value eq "b@test.com"]`:                   {IDs: []string{"a"}, Emails: []string{"b@test.com"}},
+		`phoneNumbers eq "+33612345678" or addresses[country eq "FR"]`: {Phones: []string{"+33612345678"}, Country: []string{"FR"}},
+		`userName co "test"`:                                           nil,
+		`userName eq "a" or name.givenName pr`:                         nil,
+		`not (userName eq "a")`:                                        nil,
+		`emails[type eq "work"]`:                                       nil,
+		`active eq true`:                                               nil,
+	} {
+		f, err := parseSCIMFilter(filter)
+		require.NoError(t, err, filter)
+		req, ok := narrowSearch(f, nil)
+		require.Equal(t, expected != nil, ok, filter)
+		if ok {
+			require.Equal(t, expected, req, filter)
+		}
+	}
+}
+
+func TestParseSCIMPath(t *testing.T) {
+	path, err := parseSCIMPath(`emails[type eq "work"].value`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"emails"}, path.attr)
+	require.Equal(t, "value", path.sub)
+	require.True(t, path.filter.match(map[string]interface{}{"type": "work"}))
+
+	path, err = parseSCIMPath(`urn:ietf:params:scim:schemas:core:2.0:User:name.givenName`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"name", "givenname"}, path.attr)
+	require.Nil(t, path.filter)
+
+	for _, p := range []string{``, `[type eq "work"]`, `name.emails[type eq "work"]`, `emails[type eq "work"] value`} {
+		_, err := parseSCIMPath(p)
+		require.ErrorIs(t, err, errSCIMInvalidPath, p)
+	}
+}
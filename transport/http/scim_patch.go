@@ -0,0 +1,227 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// scimPatchRequest is the PatchOp message (RFC 7644 section 3.5.2)
+type scimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+type scimPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// applySCIMPatch will apply the operations to the SCIM user and return the patched user. The operations are applied
+// on its json representation, the id can't be changed
+func applySCIMPatch(su scimUser, ops []scimPatchOperation) (scimUser, error) {
+	resource := scimRepresentation(su)
+	for i, op := range ops {
+		var err error
+		switch strings.ToLower(op.Op) {
+		case "add":
+			err = scimSet(resource, op.Path, op.Value, false)
+		case "replace":
+			err = scimSet(resource, op.Path, op.Value, true)
+		case "remove":
+			err = scimRemove(resource, op.Path)
+		default:
+			err = invalidBody(fmt.Sprintf("Operations.%d.op", i), codeInvalidBody, fmt.Sprintf("the op %q isn't one of add, replace or remove", op.Op))
+		}
+		if err != nil {
+			return scimUser{}, fmt.Errorf("can't apply operation %d: %w", i, err)
+		}
+	}
+	// the booleans are sent as strings by some identity providers
+	if key, active, ok := scimAttr(resource, "active"); ok {
+		if s, isString := active.(string); isString {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return scimUser{}, fmt.Errorf("active %q isn't a boolean: %w", s, errSCIMInvalidValue)
+			}
+			resource[key] = b
+		}
+	}
+
+	data, _ := json.Marshal(resource)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var patched scimUser
+	if err := decoder.Decode(&patched); err != nil {
+		return scimUser{}, fmt.Errorf("can't read patched user: %s: %w", err, errSCIMInvalidValue)
+	}
+	if patched.ID != su.ID {
+		return scimUser{}, fmt.Errorf("the id can't be changed: %w", errSCIMMutability)
+	}
+	return patched, nil
+}
+
+// scimSet will add or replace the value at the path, the attributes of the value are set one by one without path.
+// Adding to a multi-valued attribute appends the values
+func scimSet(resource map[string]interface{}, rawPath string, value interface{}, replace bool) error {
+	if rawPath == "" {
+		attrs, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("the value without path should be an object: %w", errSCIMInvalidValue)
+		}
+		for name, v := range attrs {
+			if err := scimSet(resource, name, v, replace); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	path, err := parseSCIMPath(rawPath)
+	if err != nil {
+		return err
+	}
+	if path.filter == nil {
+		parent := scimParent(resource, path.attr, true)
+		if parent == nil {
+			return fmt.Errorf("path %q doesn't target an object: %w", rawPath, errSCIMInvalidPath)
+		}
+		name := path.attr[len(path.attr)-1]
+		key, current, ok := scimAttr(parent, name)
+		if !ok {
+			key = name
+		}
+		if existing, isArray := current.([]interface{}); isArray && !replace {
+			if values, ok := value.([]interface{}); ok {
+				parent[key] = append(existing, values...)
+			} else {
+				parent[key] = append(existing, value)
+			}
+			return nil
+		}
+		parent[key] = value
+		return nil
+	}
+
+	key, current, _ := scimAttr(resource, path.attr[0])
+	elems, _ := current.([]interface{})
+	matched := false
+	for _, elem := range elems {
+		obj, ok := elem.(map[string]interface{})
+		if !ok || !path.filter.match(obj) {
+			continue
+		}
+		matched = true
+		if err := scimSetElement(obj, path.sub, value); err != nil {
+			return err
+		}
+	}
+	if matched {
+		return nil
+	}
+	// a value is added to a multi-valued attribute from the equality of its filter, ex: emails[type eq "work"].value
+	compare, ok := path.filter.(scimCompare)
+	if replace || !ok || compare.op != "eq" || len(compare.path) != 1 {
+		return fmt.Errorf("no value of %q match the filter: %w", rawPath, errSCIMNoTarget)
+	}
+	obj := map[string]interface{}{compare.path[0]: compare.value}
+	if err := scimSetElement(obj, path.sub, value); err != nil {
+		return err
+	}
+	if key == "" {
+		key = path.attr[0]
+	}
+	resource[key] = append(elems, obj)
+	return nil
+}
+
+// scimSetElement will set the sub attribute of the value of a multi-valued attribute, or the attributes of the
+// given object without sub attribute
+func scimSetElement(elem map[string]interface{}, sub string, value interface{}) error {
+	if sub != "" {
+		key, _, ok := scimAttr(elem, sub)
+		if !ok {
+			key = sub
+		}
+		elem[key] = value
+		return nil
+	}
+	attrs, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("the value of a filtered path should be an object: %w", errSCIMInvalidValue)
+	}
+	for name, v := range attrs {
+		key, _, ok := scimAttr(elem, name)
+		if !ok {
+			key = name
+		}
+		elem[key] = v
+	}
+	return nil
+}
+
+// scimRemove will remove the attribute at the path, or the values of a multi-valued attribute matching the filter
+func scimRemove(resource map[string]interface{}, rawPath string) error {
+	if rawPath == "" {
+		return fmt.Errorf("a path is required to remove: %w", errSCIMNoTarget)
+	}
+	path, err := parseSCIMPath(rawPath)
+	if err != nil {
+		return err
+	}
+	if path.filter == nil {
+		if parent := scimParent(resource, path.attr, false); parent != nil {
+			if key, _, ok := scimAttr(parent, path.attr[len(path.attr)-1]); ok {
+				delete(parent, key)
+			}
+		}
+		return nil
+	}
+
+	key, current, _ := scimAttr(resource, path.attr[0])
+	elems, _ := current.([]interface{})
+	kept := make([]interface{}, 0, len(elems))
+	for _, elem := range elems {
+		obj, ok := elem.(map[string]interface{})
+		if !ok || !path.filter.match(obj) {
+			kept = append(kept, elem)
+			continue
+		}
+		if path.sub != "" {
+			if subKey, _, ok := scimAttr(obj, path.sub); ok {
+				delete(obj, subKey)
+			}
+			kept = append(kept, obj)
+		}
+	}
+	if len(kept) == 0 {
+		delete(resource, key)
+		return nil
+	}
+	resource[key] = kept
+	return nil
+}
+
+// scimParent will return the object holding the last attribute of the path, the missing objects are created if
+// create is set
+func scimParent(resource map[string]interface{}, attr []string, create bool) map[string]interface{} {
+	parent := resource
+	for _, name := range attr[:len(attr)-1] {
+		key, child, ok := scimAttr(parent, name)
+		if !ok || child == nil {
+			if !create {
+				return nil
+			}
+			key, child = name, make(map[string]interface{})
+			parent[key] = child
+		}
+		obj, ok := child.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		parent = obj
+	}
+	return parent
+}
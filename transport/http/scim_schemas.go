@@ -0,0 +1,144 @@
+package http
+
+const (
+	scimServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimSchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// scimServiceProviderConfig describe the SCIM features supported by the server (RFC 7643 section 5)
+type scimServiceProviderConfig struct {
+	Schemas               []string                   `json:"schemas"`
+	Patch                 scimSupported              `json:"patch"`
+	Bulk                  scimBulk                   `json:"bulk"`
+	Filter                scimFilterSupport          `json:"filter"`
+	ChangePassword        scimSupported              `json:"changePassword"`
+	Sort                  scimSupported              `json:"sort"`
+	ETag                  scimSupported              `json:"etag"`
+	AuthenticationSchemes []scimAuthenticationScheme `json:"authenticationSchemes"`
+	Meta                  scimMeta                   `json:"meta"`
+}
+
+type scimSupported struct {
+	Supported bool `json:"supported"`
+}
+
+type scimBulk struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type scimFilterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type scimAuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary,omitempty"`
+}
+
+// scimSchema is the definition of a resource schema (RFC 7643 section 7)
+type scimSchema struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Attributes  []scimAttribute `json:"attributes"`
+	Meta        scimMeta        `json:"meta"`
+}
+
+type scimAttribute struct {
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	MultiValued   bool            `json:"multiValued"`
+	Description   string          `json:"description,omitempty"`
+	Required      bool            `json:"required"`
+	CaseExact     bool            `json:"caseExact"`
+	Mutability    string          `json:"mutability"`
+	Returned      string          `json:"returned"`
+	Uniqueness    string          `json:"uniqueness"`
+	SubAttributes []scimAttribute `json:"subAttributes,omitempty"`
+}
+
+var serviceProviderConfig = scimServiceProviderConfig{
+	Schemas:        []string{scimServiceProviderConfigSchema},
+	Patch:          scimSupported{Supported: true},
+	Filter:         scimFilterSupport{Supported: true, MaxResults: scimMaxResults},
+	ChangePassword: scimSupported{Supported: true},
+	AuthenticationSchemes: []scimAuthenticationScheme{{
+		Type: "oauthbearertoken", Name: "API key",
+		Description: "An api key of a user given as bearer token, with the users:read scope to read the users and users:write to change them.",
+		Primary:     true,
+	}},
+	Meta: scimMeta{ResourceType: "ServiceProviderConfig", Location: "/scim/v2/ServiceProviderConfig"},
+}
+
+// scimString is a singular string attribute, readWrite and returned by default
+func scimString(name, description string, required bool) scimAttribute {
+	return scimAttribute{
+		Name: name, Type: "string", Description: description, Required: required,
+		Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+	}
+}
+
+// scimUnique will set the attribute as unique across the users
+func scimUnique(a scimAttribute) scimAttribute {
+	a.Uniqueness = "server"
+	return a
+}
+
+// scimReadOnly will set the attribute as computed by the server
+func scimReadOnly(a scimAttribute) scimAttribute {
+	a.Mutability = "readOnly"
+	return a
+}
+
+// scimMultiValued is a multi-valued complex attribute of typed values, only the primary value is stored
+func scimMultiValued(name, description string, value scimAttribute) scimAttribute {
+	return scimAttribute{
+		Name: name, Type: "complex", MultiValued: true, Description: description,
+		Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+		SubAttributes: []scimAttribute{
+			value,
+			scimString("type", "A label indicating the function of the value.", false),
+			{Name: "primary", Type: "boolean", Description: "The value stored for the user.", Mutability: "readWrite", Returned: "default", Uniqueness: "none"},
+		},
+	}
+}
+
+// scimSchemas are the schemas of the resources, only the attributes mapped on the users are described
+var scimSchemas = []scimSchema{{
+	Schemas:     []string{scimSchemaSchema},
+	ID:          scimUserSchema,
+	Name:        "User",
+	Description: "User Account",
+	Attributes: []scimAttribute{
+		scimUnique(scimString("userName", "The nickname of the user.", true)),
+		{
+			Name: "name", Type: "complex", Description: "The components of the name of the user.",
+			Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+			SubAttributes: []scimAttribute{
+				scimReadOnly(scimString("formatted", "The full name of the user.", false)),
+				scimString("familyName", "The last name of the user.", false),
+				scimString("givenName", "The first name of the user.", false),
+			},
+		},
+		scimReadOnly(scimString("displayName", "The full name of the user.", false)),
+		scimMultiValued("emails", "The email of the user.", scimUnique(scimString("value", "The email.", false))),
+		scimMultiValued("phoneNumbers", "The phone of the user, read in the region of the country if it isn't an international number.",
+			scimUnique(scimString("value", "The phone in E.164 format.", false))),
+		scimMultiValued("addresses", "The country of the user.", scimString("country", "The ISO 3166-1 alpha-2 code of the country.", false)),
+		{
+			Name: "password", Type: "string", Description: "The password of the user.",
+			Mutability: "writeOnly", Returned: "never", Uniqueness: "none",
+		},
+		{
+			Name: "active", Type: "boolean", Description: "An inactive user is deleted, it can be restored during the restore window.",
+			Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+		},
+	},
+	Meta: scimMeta{ResourceType: "Schema", Location: "/scim/v2/Schemas/" + scimUserSchema},
+}}
@@ -0,0 +1,275 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+func newSCIMTestHandler(t *testing.T, usr *users.User) http.Handler {
+	return NewBuilder(logger.Logger{}, Config{}).
+		WithAPIKeyAuth(func(ctx context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+			return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "keyid", UserID: "adminid", Scopes: []users.Scope{users.ScopeUsersRead, users.ScopeUsersWrite, users.ScopeUsersAdmin}}}, nil
+		}).
+		WithSCIM(
+			func(ctx context.Context, req *users.SearchReq) (*users.SearchResp, error) {
+				if len(req.IDs) == 1 && req.IDs[0] != usr.ID {
+					return &users.SearchResp{}, nil
+				}
+				return &users.SearchResp{Users: []*users.User{usr}}, nil
+			},
+			func(ctx context.Context, req *users.CreateReq) (*users.CreateResp, error) {
+				if req.Email == "taken@test.com" {
+					return nil, fmt.Errorf("can't create user: %w", userstore.ErrAlreadyExist)
+				}
+				require.Equal(t, &users.CreateReq{
+					FirstName: "Barbara", LastName: "Jensen", NickName: "bjensen", Email: "bjensen@test.com",
+					Country: "US", Phone: "+15555550100", RawPassword: "secret",
+				}, req)
+				return &users.CreateResp{User: &users.User{ID: "newid", FirstName: req.FirstName, LastName: req.LastName, NickName: req.NickName, Email: req.Email}}, nil
+			},
+			func(ctx context.Context, req *users.UpdateReq) (*users.UpdateResp, error) {
+				if req.FirstName.Value == "" {
+					verr := &users.ValidationError{Violations: []users.FieldViolation{{Field: "first_name", Code: users.CodeRequired, Message: "firstname is required"}}}
+					return nil, fmt.Errorf("can't validate user: %w", verr)
+				}
+				updated := *usr
+				updated.FirstName, updated.LastName, updated.NickName = req.FirstName.Value, req.LastName.Value, req.NickName.Value
+				updated.Email, updated.Phone, updated.Country = req.Email.Value, req.Phone.Value, req.Country.Value
+				return &users.UpdateResp{User: &updated}, nil
+			},
+			func(ctx context.Context, req *users.DeleteReq) (*users.DeleteResp, error) {
+				if req.ID != usr.ID {
+					return nil, fmt.Errorf("can't delete user: %w", userstore.ErrNotFound)
+				}
+				now := time.Now()
+				usr.DeletedAt = &now
+				deleted := *usr
+				return &users.DeleteResp{User: &deleted}, nil
+			},
+			func(ctx context.Context, req *users.RestoreReq) (*users.RestoreResp, error) {
+				if req.ID != usr.ID || usr.DeletedAt == nil {
+					return nil, fmt.Errorf("can't restore user: %w", userstore.ErrNotFound)
+				}
+				usr.DeletedAt = nil
+				restored := *usr
+				return &users.RestoreResp{User: &restored}, nil
+			},
+		).
+		handler()
+}
+
+func scimCall(t *testing.T, handler http.Handler, method, target, body string) (*http.Response, map[string]interface{}) {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, "http://localhost"+target, nil)
+	} else {
+		req = httptest.NewRequest(method, "http://localhost"+target, strings.NewReader(body))
+		req.Header.Set("Content-Type", scimContentType)
+	}
+	req.Header.Set("Authorization", "Bearer key")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if w.Body.Len() == 0 {
+		return resp, nil
+	}
+	require.Equal(t, scimContentType, resp.Header.Get("Content-Type"))
+	var res map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	return resp, res
+}
+
+func TestBuilder_WithSCIM(t *testing.T) {
+	usr := &users.User{ID: "testid", FirstName: "test", LastName: "test", NickName: "Tester", Email: "test@test.com", Country: "FR"}
+	handler := newSCIMTestHandler(t, usr)
+
+	resp, res := scimCall(t, handler, "POST", "/scim/v2/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"externalId": "701984",
+		"userName": "bjensen",
+		"name": {"givenName": "Barbara", "familyName": "Jensen"},
+		"emails": [{"value": "bjensen@home.com", "type": "home"}, {"value": "bjensen@test.com", "type": "work", "primary": true}],
+		"phoneNumbers": [{"value": "+15555550100", "type": "mobile"}],
+		"addresses": [{"country": "US", "type": "work"}],
+		"password": "secret",
+		"active": true
+	}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, "/scim/v2/Users/newid", resp.Header.Get("Location"))
+	require.Equal(t, "newid", res["id"])
+	require.Equal(t, "bjensen", res["userName"])
+	require.Equal(t, map[string]interface{}{"formatted": "Barbara Jensen", "givenName": "Barbara", "familyName": "Jensen"}, res["name"])
+	require.NotContains(t, res, "password")
+
+	resp, res = scimCall(t, handler, "GET", "/scim/v2/Users/testid", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []interface{}{map[string]interface{}{"value": "test@test.com", "type": "work", "primary": true}}, res["emails"])
+	require.Equal(t, true, res["active"])
+	require.Equal(t, map[string]interface{}{"resourceType": "User", "location": "/scim/v2/Users/testid"}, res["meta"])
+
+	resp, res = scimCall(t, handler, "GET", "/scim/v2/Users/unknown", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, "404", res["status"])
+	require.Equal(t, []interface{}{scimErrorSchema}, res["schemas"])
+
+	// the filter is evaluated on the users narrowed by the search
+	for filter, total := range map[string]float64{
+		`userName eq "tester"`: 1,
+		`userName eq "tester" and emails[type eq "work" and value ew "@test.com"]`:                      1,
+		`emails.value eq "test@test.com" and not (name.givenName sw "x")`:                               1,
+		`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "Tester" or name.familyName eq "other"`: 1,
+		`userName eq "tester" and addresses[country eq "US"]`:                                           0,
+	} {
+		resp, res = scimCall(t, handler, "GET", "/scim/v2/Users?filter="+url.QueryEscape(filter), "")
+		require.Equal(t, http.StatusOK, resp.StatusCode, filter)
+		require.Equal(t, total, res["totalResults"], filter)
+		require.Equal(t, []interface{}{scimListResponseSchema}, res["schemas"])
+	}
+	resp, res = scimCall(t, handler, "GET", "/scim/v2/Users?filter="+url.QueryEscape(`userName eq "tester"`)+"&startIndex=2&count=1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, float64(1), res["totalResults"])
+	require.Equal(t, float64(2), res["startIndex"])
+	require.Empty(t, res["Resources"])
+
+	// all the users are listed by page without filter
+	resp, res = scimCall(t, handler, "GET", "/scim/v2/Users?count=1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, float64(1), res["totalResults"])
+	require.Len(t, res["Resources"], 1)
+	resp, res = scimCall(t, handler, "GET", "/scim/v2/Users?startIndex=2", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, res["Resources"])
+
+	for filter, scimType := range map[string]string{
+		`userName co "test"`:                "tooMany",
+		`userName eq "a" or displayName pr`: "tooMany",
+		`userName eq`:                       "invalidFilter",
+		`(userName eq "test"`:               "invalidFilter",
+		`userName like "test"`:              "invalidFilter",
+	} {
+		resp, res = scimCall(t, handler, "GET", "/scim/v2/Users?filter="+url.QueryEscape(filter), "")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, filter)
+		require.Equal(t, scimType, res["scimType"], filter)
+	}
+
+	resp, res = scimCall(t, handler, "PUT", "/scim/v2/Users/testid", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "tester2", "name": {"givenName": "new", "familyName": "name"}, "emails": [{"value": "new@test.com"}]
+	}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "tester2", res["userName"])
+	require.NotContains(t, res, "addresses")
+
+	// the patch is applied on the representation of the user
+	resp, res = scimCall(t, handler, "PATCH", "/scim/v2/Users/testid", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "name.givenName", "value": "patched"},
+			{"op": "add", "path": "phoneNumbers[type eq \"mobile\"].value", "value": "+33612345678"},
+			{"op": "replace", "value": {"userName": "patcher", "emails[type eq \"work\"].value": "patched@test.com"}},
+			{"op": "remove", "path": "addresses"}
+		]
+	}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "patcher", res["userName"])
+	require.Equal(t, "patched", res["name"].(map[string]interface{})["givenName"])
+	require.Equal(t, []interface{}{map[string]interface{}{"value": "patched@test.com", "type": "work", "primary": true}}, res["emails"])
+	require.Equal(t, []interface{}{map[string]interface{}{"value": "+33612345678", "type": "mobile", "primary": true}}, res["phoneNumbers"])
+	require.NotContains(t, res, "addresses")
+
+	for body, scimType := range map[string]string{
+		`{"Operations": [{"op": "remove", "path": "name.givenName"}]}`:                         "invalidValue",
+		`{"Operations": [{"op": "remove"}]}`:                                                   "noTarget",
+		`{"Operations": [{"op": "replace", "path": "emails[type eq \"home\"]", "value": {}}]}`: "noTarget",
+		`{"Operations": [{"op": "replace", "path": "id", "value": "other"}]}`:                  "mutability",
+		`{"Operations": [{"op": "replace", "path": "title", "value": "boss"}]}`:                "invalidValue",
+		`{"Operations": [{"op": "replace", "path": "emails[type eq]", "value": "x"}]}`:         "invalidFilter",
+		`{"Operations": [{"op": "move", "path": "userName"}]}`:                                 "invalidSyntax",
+		`{"Operations": [], "unknown": true}`:                                                  "invalidSyntax",
+	} {
+		resp, res = scimCall(t, handler, "PATCH", "/scim/v2/Users/testid", body)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		require.Equal(t, scimType, res["scimType"], body)
+	}
+
+	// an inactive user is deleted
+	resp, res = scimCall(t, handler, "PATCH", "/scim/v2/Users/testid", `{"Operations": [{"op": "replace", "path": "active", "value": "False"}]}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, false, res["active"])
+	// a deleted user is kept as is while it stays inactive
+	resp, res = scimCall(t, handler, "PATCH", "/scim/v2/Users/testid", `{"Operations": [{"op": "replace", "path": "name.givenName", "value": "ignored"}]}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, false, res["active"])
+	require.NotEqual(t, "ignored", res["name"].(map[string]interface{})["givenName"])
+	// and restored once active again
+	resp, res = scimCall(t, handler, "PATCH", "/scim/v2/Users/testid", `{"Operations": [{"op": "replace", "path": "active", "value": true}]}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, true, res["active"])
+
+	resp, res = scimCall(t, handler, "POST", "/scim/v2/Users", `{"userName": "taken", "emails": [{"value": "taken@test.com"}]}`)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	require.Equal(t, "uniqueness", res["scimType"])
+	require.Equal(t, "409", res["status"])
+
+	resp, _ = scimCall(t, handler, "DELETE", "/scim/v2/Users/testid", "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, res = scimCall(t, handler, "DELETE", "/scim/v2/Users/unknown", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, "404", res["status"])
+}
+
+func TestBuilder_WithSCIM_Unauthenticated(t *testing.T) {
+	handler := newSCIMTestHandler(t, &users.User{ID: "testid"})
+
+	req := httptest.NewRequest("GET", "http://localhost/scim/v2/Users/testid", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	resp := w.Result()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+	require.Equal(t, scimContentType, resp.Header.Get("Content-Type"))
+	require.JSONEq(t, `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:Error"], "status": "401",
+		"detail": "The provided api key is unknown, expired or revoked."}`, w.Body.String())
+
+	// the discovery endpoints don't require an api key
+	req = httptest.NewRequest("GET", "http://localhost/scim/v2/ServiceProviderConfig", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestBuilder_WithSCIM_Discovery(t *testing.T) {
+	handler := newSCIMTestHandler(t, &users.User{ID: "testid"})
+
+	resp, res := scimCall(t, handler, "GET", "/scim/v2/ServiceProviderConfig", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, map[string]interface{}{"supported": true}, res["patch"])
+	require.Equal(t, map[string]interface{}{"supported": true, "maxResults": float64(scimMaxResults)}, res["filter"])
+
+	resp, res = scimCall(t, handler, "GET", "/scim/v2/Schemas", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, float64(1), res["totalResults"])
+
+	resp, res = scimCall(t, handler, "GET", "/scim/v2/Schemas/"+scimUserSchema, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "User", res["name"])
+
+	resp, res = scimCall(t, handler, "GET", "/scim/v2/Schemas/unknown", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, "404", res["status"])
+}
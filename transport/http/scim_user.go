@@ -0,0 +1,293 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+const (
+	scimContentType        = "application/scim+json"
+	scimUserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimUsersPath          = "/scim/v2/Users"
+	// scimMaxResults is the maximum number of resources returned by a list
+	scimMaxResults = 200
+)
+
+var (
+	errSCIMInvalidFilter  = errors.New("invalid scim filter")
+	errSCIMTooMany        = errors.New("too many scim results")
+	errSCIMInvalidPath    = errors.New("invalid scim path")
+	errSCIMNoTarget       = errors.New("no scim target")
+	errSCIMInvalidValue   = errors.New("invalid scim value")
+	errSCIMMutability     = errors.New("immutable scim attribute")
+	errSCIMSchemaNotFound = errors.New("scim schema not found")
+)
+
+// scimUser is the representation of a user in the SCIM core user schema (RFC 7643 section 4.1).
+// The userName is the nickname of the user, the password is never returned and the externalId isn't stored
+type scimUser struct {
+	Schemas      []string         `json:"schemas"`
+	ID           string           `json:"id,omitempty"`
+	ExternalID   string           `json:"externalId,omitempty"`
+	UserName     string           `json:"userName"`
+	Name         *scimName        `json:"name,omitempty"`
+	DisplayName  string           `json:"displayName,omitempty"`
+	Emails       []scimMultiValue `json:"emails,omitempty"`
+	PhoneNumbers []scimMultiValue `json:"phoneNumbers,omitempty"`
+	Addresses    []scimAddress    `json:"addresses,omitempty"`
+	Password     string           `json:"password,omitempty"`
+	Active       *bool            `json:"active,omitempty"`
+	Meta         *scimMeta        `json:"meta,omitempty"`
+}
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+type scimMultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// scimAddress only hold the country of the user, as ISO 3166-1 alpha-2 code
+type scimAddress struct {
+	Country string `json:"country"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+type scimListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// scimError is the representation of an error (RFC 7644 section 3.12)
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+	Status   string   `json:"status"`
+}
+
+// scimFormat is the format of the errors of the SCIM routes
+var scimFormat = &errorFormat{contentType: scimContentType, body: scimError{}, write: writeSCIMError}
+
+// scimErrorTypes is the translation of the errors to their scimType, the first matching error is used. The errors
+// of the SCIM routes are exposed with their message
+var scimErrorTypes = []struct {
+	err      error
+	scimType string
+	status   int
+}{
+	{err: errSCIMInvalidFilter, scimType: "invalidFilter", status: http.StatusBadRequest},
+	{err: errSCIMTooMany, scimType: "tooMany", status: http.StatusBadRequest},
+	{err: errSCIMInvalidPath, scimType: "invalidPath", status: http.StatusBadRequest},
+	{err: errSCIMNoTarget, scimType: "noTarget", status: http.StatusBadRequest},
+	{err: errSCIMInvalidValue, scimType: "invalidValue", status: http.StatusBadRequest},
+	{err: errSCIMMutability, scimType: "mutability", status: http.StatusBadRequest},
+	{err: errSCIMSchemaNotFound, status: http.StatusNotFound},
+}
+
+// scimProblemTypes are the scimType of the domain errors, their status is the one of their problem if not set
+var scimProblemTypes = []struct {
+	err      error
+	scimType string
+	status   int
+}{
+	{err: errInvalidBody, scimType: "invalidSyntax"},
+	{err: users.ErrInvalidUser, scimType: "invalidValue", status: http.StatusBadRequest},
+	{err: userstore.ErrAlreadyExist, scimType: "uniqueness"},
+	{err: userstore.ErrNickNameAlreadyExist, scimType: "uniqueness"},
+	{err: userstore.ErrPhoneAlreadyExist, scimType: "uniqueness"},
+}
+
+// writeSCIMError will translate the error to a SCIM error, the domain errors have the status and the detail of their
+// problem. Unknown errors are returned as internal errors
+func writeSCIMError(log logger.Logger, writer http.ResponseWriter, request *http.Request, err error) {
+	e := scimError{Schemas: []string{scimErrorSchema}, Status: strconv.Itoa(http.StatusInternalServerError)}
+	status := 0
+	for _, t := range scimErrorTypes {
+		if errors.Is(err, t.err) {
+			e.ScimType, e.Detail, status = t.scimType, err.Error(), t.status
+			break
+		}
+	}
+	if status == 0 {
+		for _, pt := range problemTypes {
+			if errors.Is(err, pt.err) {
+				e.Detail, status = pt.detail, pt.status
+				break
+			}
+		}
+		for _, t := range scimProblemTypes {
+			if status != 0 && errors.Is(err, t.err) {
+				e.ScimType = t.scimType
+				if t.status != 0 {
+					status = t.status
+				}
+				break
+			}
+		}
+		if detail := violationsDetail(err); detail != "" {
+			e.Detail = detail
+		}
+	}
+	if status == 0 {
+		log.Error().Err(err).Send()
+		e.Detail = http.StatusText(http.StatusInternalServerError)
+		writeSCIM(writer, http.StatusInternalServerError, e)
+		return
+	}
+	log.Debug().Err(err).Int("status", status).Msg("scim request failed")
+	e.Status = strconv.Itoa(status)
+	writeSCIM(writer, status, e)
+}
+
+// violationsDetail will join the messages of the violations of the error, if any
+func violationsDetail(err error) string {
+	var (
+		verr       *users.ValidationError
+		berr       *bodyError
+		violations []users.FieldViolation
+	)
+	if errors.As(err, &verr) {
+		violations = verr.Violations
+	} else if errors.As(err, &berr) {
+		violations = berr.violations
+	}
+	msgs := make([]string, 0, len(violations))
+	for _, v := range violations {
+		msgs = append(msgs, v.Message)
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// writeSCIM will write the body as json with the SCIM content type and the status
+func writeSCIM(writer http.ResponseWriter, status int, body interface{}) {
+	data, _ := json.Marshal(body)
+	writer.Header().Set("Content-Type", scimContentType)
+	writer.WriteHeader(status)
+	_, _ = writer.Write(data)
+}
+
+// newSCIMUser will represent the user, a deleted user is inactive
+func newSCIMUser(u *users.User) scimUser {
+	active := u.DeletedAt == nil
+	su := scimUser{
+		Schemas:  []string{scimUserSchema},
+		ID:       u.ID,
+		UserName: u.NickName,
+		Name: &scimName{
+			Formatted:  strings.TrimSpace(u.FirstName + " " + u.LastName),
+			FamilyName: u.LastName,
+			GivenName:  u.FirstName,
+		},
+		DisplayName: strings.TrimSpace(u.FirstName + " " + u.LastName),
+		Active:      &active,
+		Meta:        &scimMeta{ResourceType: "User", Location: scimUsersPath + "/" + u.ID},
+	}
+	if u.Email != "" {
+		su.Emails = []scimMultiValue{{Value: u.Email, Type: "work", Primary: true}}
+	}
+	if u.Phone != "" {
+		su.PhoneNumbers = []scimMultiValue{{Value: u.Phone, Type: "mobile", Primary: true}}
+	}
+	if u.Country != "" {
+		su.Addresses = []scimAddress{{Country: u.Country, Type: "work", Primary: true}}
+	}
+	return su
+}
+
+// createRequest will map the SCIM user to the creation of a user, the primary email, phone and address are used
+func (su scimUser) createRequest() *users.CreateReq {
+	req := &users.CreateReq{
+		NickName:    su.UserName,
+		Email:       primaryValue(su.Emails),
+		Phone:       primaryValue(su.PhoneNumbers),
+		Country:     primaryCountry(su.Addresses),
+		RawPassword: su.Password,
+	}
+	if su.Name != nil {
+		req.FirstName, req.LastName = su.Name.GivenName, su.Name.FamilyName
+	}
+	return req
+}
+
+// updateRequest will map the SCIM user to the replacement of the user, the absent attributes are cleared and the
+// password is only changed if it is provided
+func (su scimUser) updateRequest(id string) *users.UpdateReq {
+	req := &users.UpdateReq{
+		ID:        id,
+		FirstName: users.SetString(""),
+		LastName:  users.SetString(""),
+		NickName:  users.SetString(su.UserName),
+		Email:     users.SetString(primaryValue(su.Emails)),
+		Phone:     users.SetString(primaryValue(su.PhoneNumbers)),
+		Country:   users.SetString(primaryCountry(su.Addresses)),
+	}
+	if su.Name != nil {
+		req.FirstName, req.LastName = users.SetString(su.Name.GivenName), users.SetString(su.Name.FamilyName)
+	}
+	if su.Password != "" {
+		req.RawPassword = users.SetString(su.Password)
+	}
+	return req
+}
+
+// deactivated is true when the user is explicitly set as inactive
+func (su scimUser) deactivated() bool {
+	return su.Active != nil && !*su.Active
+}
+
+// primaryValue will return the primary value, or the first one if none is primary
+func primaryValue(values []scimMultiValue) string {
+	for _, v := range values {
+		if v.Primary {
+			return v.Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
+
+func primaryCountry(addresses []scimAddress) string {
+	for _, a := range addresses {
+		if a.Primary {
+			return a.Country
+		}
+	}
+	if len(addresses) > 0 {
+		return addresses[0].Country
+	}
+	return ""
+}
+
+// scimRepresentation will return the json representation of the value, on which the filters and the patches apply
+func scimRepresentation(v interface{}) map[string]interface{} {
+	data, _ := json.Marshal(v)
+	var representation map[string]interface{}
+	_ = json.Unmarshal(data, &representation)
+	return representation
+}
@@ -0,0 +1,27 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1AttributeSchema will add http endpoint to get the JSON Schema of the custom attributes of the users
+func (b *Builder) WithV1AttributeSchema(getSchema users.GetAttributeSchema) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v1/attributes/schema", id: "v1GetAttributeSchema",
+		summary:   "Get the JSON Schema validating the custom attributes of the users",
+		responses: []response{{status: http.StatusOK, content: []content{{contentType: "application/schema+json", body: json.RawMessage{}}}}},
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		res, err := getSchema(request.Context(), &users.GetAttributeSchemaReq{})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/schema+json")
+		data, _ := json.Marshal(res.Schema)
+		_, _ = writer.Write(data)
+	})
+	return b
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1AttributeSchema(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1AttributeSchema(func(ctx context.Context, req *users.GetAttributeSchemaReq) (*users.GetAttributeSchemaResp, error) {
+		return &users.GetAttributeSchemaResp{Schema: json.RawMessage(`{"type": "object", "properties": {"department": {"type": "string"}}}`)}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v1/attributes/schema", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/schema+json", resp.Header.Get("Content-Type"))
+	require.JSONEq(t, `{"type": "object", "properties": {"department": {"type": "string"}}}`, w.Body.String())
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1ListCountries will add http endpoint to list the countries which can be set on the users,
+// the names are localized with the Accept-Language header
+func (b *Builder) WithV1ListCountries(listCountries users.ListCountries) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v1/countries", id: "v1ListCountries", summary: "List the countries with their local name",
+		params: []parameter{{name: "Accept-Language", in: "header", description: "The language of the local names, english by default."}},
+		responses: []response{{
+			status:  http.StatusOK,
+			headers: map[string]string{"Content-Language": "The language of the local names."},
+			content: jsonContent(users.ListCountriesResp{}),
+		}},
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		res, err := listCountries(request.Context(), &users.ListCountriesReq{Languages: request.Header.Get("Accept-Language")})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writer.Header().Set("Content-Language", res.Language)
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
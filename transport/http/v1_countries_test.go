@@ -0,0 +1,35 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1ListCountries(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1ListCountries(func(ctx context.Context, req *users.ListCountriesReq) (*users.ListCountriesResp, error) {
+		require.Equal(t, "fr-CH, fr;q=0.9", req.Languages)
+		return &users.ListCountriesResp{Language: "fr", Countries: []users.LocalizedCountry{
+			{Country: users.Country{Alpha2: "CH", Alpha3: "CHE", Numeric: "756", Name: "Switzerland"}, LocalName: "Suisse"},
+		}}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v1/countries", nil)
+	req.Header.Set("Accept-Language", "fr-CH, fr;q=0.9")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "fr", resp.Header.Get("Content-Language"))
+	require.JSONEq(t, `{"language": "fr", "countries": [
+		{"alpha2": "CH", "alpha3": "CHE", "numeric": "756", "name": "Switzerland", "local_name": "Suisse"}
+	]}`, w.Body.String())
+}
@@ -6,10 +6,16 @@ import (
 
 // WithHealthCheck will add default endpoints to check its status
 func (b *Builder) WithHealthCheck() *Builder {
-	b.router.Get("/", func(writer http.ResponseWriter, request *http.Request) {
+	b.handle(operation{
+		method: http.MethodGet, path: "/", id: "healthCheck", summary: "Check the server is up",
+		responses: []response{{status: http.StatusOK}},
+	}, func(writer http.ResponseWriter, request *http.Request) {
 		writer.WriteHeader(http.StatusOK)
 	})
-	b.router.Get("/ping", func(writer http.ResponseWriter, request *http.Request) {
+	b.handle(operation{
+		method: http.MethodGet, path: "/ping", id: "ping", summary: "Check the server is up",
+		responses: []response{{status: http.StatusOK, content: []content{{contentType: "text/plain", body: "pong"}}}},
+	}, func(writer http.ResponseWriter, request *http.Request) {
 		writer.Write([]byte("pong"))
 	})
 	return b
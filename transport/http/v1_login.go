@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1Login will add http endpoint to check the credentials of a user.
+// If the user enabled its second factor, a challenge id is returned to be completed on the mfa endpoint
+func (b *Builder) WithV1Login(login users.Login) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/login", id: "v1Login", summary: "Check the credentials of a user",
+		description: "If the user enabled its second factor, a challenge id is returned to be completed on /v1/login/mfa.",
+		request:     jsonContent(users.LoginReq{}),
+		responses:   []response{{status: http.StatusOK, content: jsonContent(users.LoginResp{User: &users.User{}})}},
+		problems:    []int{http.StatusUnauthorized, http.StatusTooManyRequests},
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.LoginReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := login(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1LoginMFA will add http endpoint to complete a login challenge with the second factor of the user
+func (b *Builder) WithV1LoginMFA(loginMFA users.LoginMFA) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/login/mfa", id: "v1LoginMFA", summary: "Complete a login challenge with a second factor code",
+		request:   jsonContent(users.LoginMFAReq{}),
+		responses: []response{{status: http.StatusOK, content: jsonContent(users.LoginResp{User: &users.User{}})}},
+		problems:  []int{http.StatusUnauthorized, http.StatusTooManyRequests},
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.LoginMFAReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := loginMFA(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1LoginMFA(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1LoginMFA(func(ctx context.Context, req *users.LoginMFAReq) (*users.LoginResp, error) {
+		require.Equal(t, "challenge", req.ChallengeID)
+		require.Equal(t, "123456", req.Code)
+		return &users.LoginResp{User: &users.User{ID: "testid"}}, nil
+	}).router
+
+	req := httptest.NewRequest("POST", "http://localhost/v1/login/mfa", strings.NewReader(`{"challenge_id": "challenge", "code": "123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
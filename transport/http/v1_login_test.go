@@ -0,0 +1,41 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1Login(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1Login(func(ctx context.Context, req *users.LoginReq) (*users.LoginResp, error) {
+		if req.RawPassword != "test" {
+			return nil, users.ErrInvalidCredentials
+		}
+		return &users.LoginResp{ChallengeID: "challenge"}, nil
+	}).router
+
+	req := httptest.NewRequest("POST", "http://localhost/v1/login", strings.NewReader(`{"email": "test@test.com", "password": "test"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "challenge")
+
+	req = httptest.NewRequest("POST", "http://localhost/v1/login", strings.NewReader(`{"email": "test@test.com", "password": "wrong"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+}
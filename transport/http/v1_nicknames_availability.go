@@ -0,0 +1,26 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1NickNameAvailability will add http endpoint to check if a nickname can be used, with suggestions if it's taken
+func (b *Builder) WithV1NickNameAvailability(checkNickName users.CheckNickNameAvailability) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v1/nicknames/{nick}/availability", id: "v1CheckNickNameAvailability",
+		summary:   "Check a nickname can be used, with suggestions when it can't",
+		responses: []response{{status: http.StatusOK, content: jsonContent(users.NickNameAvailabilityResp{})}},
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		res, err := checkNickName(request.Context(), &users.NickNameAvailabilityReq{NickName: chi.URLParam(request, "nick")})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
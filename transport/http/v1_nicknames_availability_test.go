@@ -0,0 +1,29 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1NickNameAvailability(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1NickNameAvailability(func(ctx context.Context, req *users.NickNameAvailabilityReq) (*users.NickNameAvailabilityResp, error) {
+		require.Equal(t, "bóbby", req.NickName)
+		return &users.NickNameAvailabilityResp{NickName: req.NickName, Reason: users.NickNameTaken, Suggestions: []string{"bóbby1"}}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v1/nicknames/b%C3%B3bby/availability", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.JSONEq(t, `{"nick_name": "bóbby", "available": false, "reason": "taken", "suggestions": ["bóbby1"]}`, w.Body.String())
+}
@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1RegisterOIDCClient will add http endpoint to register a client of the OpenID Connect provider
+func (b *Builder) WithV1RegisterOIDCClient(register users.RegisterOIDCClient) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/oidc/clients", id: "v1RegisterOIDCClient", summary: "Register a client of the OpenID Connect provider",
+		description: "The secret of a confidential client is only returned in this response.",
+		request:     jsonContent(users.RegisterOIDCClientReq{}),
+		responses:   []response{{status: http.StatusCreated, content: jsonContent(users.RegisterOIDCClientResp{})}},
+		problems:    []int{http.StatusUnprocessableEntity},
+		admin:       true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.RegisterOIDCClientReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := register(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusCreated, res)
+	})
+	return b
+}
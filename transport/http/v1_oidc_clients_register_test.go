@@ -0,0 +1,45 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1RegisterOIDCClient(t *testing.T) {
+	handler := NewBuilder(logger.Logger{}, Config{}).
+		WithAPIKeyAuth(authenticateGraphQLKey).
+		WithV1RegisterOIDCClient(func(ctx context.Context, req *users.RegisterOIDCClientReq) (*users.RegisterOIDCClientResp, error) {
+			require.Equal(t, []string{"https://app.test/callback"}, req.RedirectURIs)
+			require.True(t, req.Confidential)
+			return &users.RegisterOIDCClientResp{Client: &users.OIDCClient{ID: "clientid", Name: req.Name}, Secret: "ucs_secret"}, nil
+		}).handler()
+
+	for key, status := range map[string]int{"": http.StatusUnauthorized, "user": http.StatusForbidden, "admin": http.StatusCreated} {
+		req := httptest.NewRequest("POST", "http://localhost/v1/oidc/clients", strings.NewReader(`
+		{"name": "app", "redirect_uris": ["https://app.test/callback"], "confidential": true}
+		`))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+		resp := w.Result()
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		require.Equal(t, status, resp.StatusCode, key)
+		if status == http.StatusCreated {
+			require.Contains(t, string(body), "ucs_secret")
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1CreateUserAPIKey will add http endpoint to create an api key for a user
+func (b *Builder) WithV1CreateUserAPIKey(createAPIKey users.CreateAPIKey) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/user/api-keys", id: "v1CreateUserAPIKey", summary: "Create an api key for a user",
+		description:   "The key is only returned in this response.",
+		request:       jsonContent(users.CreateAPIKeyReq{}),
+		responses:     []response{{status: http.StatusOK, content: jsonContent(users.CreateAPIKeyResp{})}},
+		problems:      []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+		authenticated: true,
+		passwordAuth:  true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.CreateAPIKeyReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err := authorizeUser(request, req.UserID); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := createAPIKey(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1CreateUserAPIKey(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1CreateUserAPIKey(func(ctx context.Context, req *users.CreateAPIKeyReq) (*users.CreateAPIKeyResp, error) {
+		require.Equal(t, "testid", req.UserID)
+		require.Equal(t, []users.Scope{users.ScopeUsersRead}, req.Scopes)
+		return &users.CreateAPIKeyResp{APIKey: &users.APIKey{ID: "keyid", Name: req.Name}, Key: "uak_key"}, nil
+	}).router
+
+	req := httptest.NewRequest("POST", "http://localhost/v1/user/api-keys", strings.NewReader(`
+	{"id": "testid", "name": "ci", "scopes": ["users:read"]}
+	`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "uak_key")
+}
@@ -0,0 +1,29 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1ListUserAPIKeys will add http endpoint to list the api keys of a user
+func (b *Builder) WithV1ListUserAPIKeys(listAPIKeys users.ListAPIKeys) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v1/user/api-keys", id: "v1ListUserAPIKeys", summary: "List the api keys of a user",
+		params:        []parameter{{name: "id", in: "query", description: "The id of the user."}},
+		responses:     []response{{status: http.StatusOK, content: jsonContent(users.ListAPIKeysResp{})}},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		if err := authorizeUser(request, request.URL.Query().Get("id")); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := listAPIKeys(request.Context(), &users.ListAPIKeysReq{UserID: request.URL.Query().Get("id")})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
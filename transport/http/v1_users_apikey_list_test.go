@@ -0,0 +1,29 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1ListUserAPIKeys(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1ListUserAPIKeys(func(ctx context.Context, req *users.ListAPIKeysReq) (*users.ListAPIKeysResp, error) {
+		require.Equal(t, "testid", req.UserID)
+		return &users.ListAPIKeysResp{}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v1/user/api-keys?id=testid", nil)
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1RevokeUserAPIKey will add http endpoint to revoke an api key of a user
+func (b *Builder) WithV1RevokeUserAPIKey(revokeAPIKey users.RevokeAPIKey) *Builder {
+	b.handle(operation{
+		method: http.MethodDelete, path: "/v1/user/api-keys", id: "v1RevokeUserAPIKey", summary: "Revoke an api key of a user",
+		request:       jsonContent(users.RevokeAPIKeyReq{}),
+		responses:     []response{{status: http.StatusOK, content: jsonContent(users.RevokeAPIKeyResp{})}},
+		problems:      []int{http.StatusNotFound},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.RevokeAPIKeyReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err := authorizeUser(request, req.UserID); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := revokeAPIKey(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/apikeystore"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1RevokeUserAPIKey(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1RevokeUserAPIKey(func(ctx context.Context, req *users.RevokeAPIKeyReq) (*users.RevokeAPIKeyResp, error) {
+		if req.KeyID != "keyid" {
+			return nil, apikeystore.ErrNotFound
+		}
+		return &users.RevokeAPIKeyResp{APIKey: &users.APIKey{ID: req.KeyID}}, nil
+	}).router
+
+	for keyID, status := range map[string]int{"keyid": http.StatusOK, "unknown": http.StatusNotFound} {
+		req := httptest.NewRequest("DELETE", "http://localhost/v1/user/api-keys", strings.NewReader(`{"id": "testid", "key_id": "`+keyID+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req = withAPIKey(req, "testid")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, status, w.Result().StatusCode)
+	}
+}
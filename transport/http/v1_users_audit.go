@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1UserAudit will add http endpoint to retrieve the audit log of a user
+func (b *Builder) WithV1UserAudit(listUserAudit users.ListUserAudit) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v1/users/{id}/audit", id: "v1ListUserAudit", summary: "List the audit entries of a user",
+		responses:     []response{{status: http.StatusOK, content: jsonContent(users.ListUserAuditResp{})}},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		if err := authorizeUser(request, chi.URLParam(request, "id")); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := listUserAudit(request.Context(), &users.ListUserAuditReq{UserID: chi.URLParam(request, "id")})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
+
+// WithV1VerifyAudit will add http endpoint to check the integrity of the audit log
+func (b *Builder) WithV1VerifyAudit(verifyAudit users.VerifyAudit) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v1/audit/verify", id: "v1VerifyAudit", summary: "Verify the hash chain of the audit log",
+		responses: []response{{status: http.StatusOK, content: jsonContent(users.VerifyAuditResp{})}},
+		admin:     true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		res, err := verifyAudit(request.Context())
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
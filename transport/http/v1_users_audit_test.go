@@ -0,0 +1,49 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1UserAudit(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1UserAudit(func(ctx context.Context, req *users.ListUserAuditReq) (*users.ListUserAuditResp, error) {
+		require.Equal(t, "testid", req.UserID)
+		return &users.ListUserAuditResp{Entries: []*users.AuditEntry{{Seq: 1, UserID: req.UserID, Op: users.CreateOp}}}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v1/users/testid/audit", nil)
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), `"op":"create"`)
+}
+
+func TestBuilder_WithV1VerifyAudit(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1VerifyAudit(func(ctx context.Context) (*users.VerifyAuditResp, error) {
+		return &users.VerifyAuditResp{Valid: true, Entries: 2}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v1/audit/verify", nil)
+	req = withAPIKey(req, "adminid", users.ScopeUsersAdmin)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), `"valid":true`)
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// batchResult is the result of an operation of a batch, a failed operation has its problem instead of the user.
+// the user is returned without its password
+type batchResult struct {
+	Status  int            `json:"status"`
+	User    *localizedUser `json:"user,omitempty"`
+	Problem *problem       `json:"problem,omitempty"`
+}
+
+// batchBody is the body returned by a batch, with a result for each operation in their order
+type batchBody struct {
+	Results []batchResult `json:"results"`
+	Failed  int           `json:"failed"`
+}
+
+// WithV1BatchUsers will add http endpoint to create, update and delete several users in one request
+func (b *Builder) WithV1BatchUsers(batch users.Batch) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/users:batch", id: "v1BatchUsers", summary: "Create, update and delete users",
+		description: "The operations are applied in their order, as POST, PUT and DELETE /v1/user do: the empty and " +
+			"null fields of an update are kept. Each operation has its status and its user or problem. An atomic " +
+			"batch stops at the first failed operation and none of its operations is applied, the other ones fail " +
+			"with the batch-aborted problem.",
+		request:   jsonContent(users.BatchReq{}),
+		responses: []response{{status: http.StatusOK, content: jsonContent(batchBody{})}},
+		problems:  []int{http.StatusUnprocessableEntity},
+		admin:     true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.BatchReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		for _, op := range req.Operations {
+			if op != nil && op.Update != nil {
+				keepEmptyFields(op.Update)
+			}
+		}
+		res, err := batch(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+
+		body := batchBody{Results: make([]batchResult, 0, len(res.Results)), Failed: res.Failed}
+		for _, result := range res.Results {
+			if result.Err != nil {
+				p := errorProblem(b.log, request, result.Err)
+				body.Results = append(body.Results, batchResult{Status: p.Status, Problem: p})
+				continue
+			}
+			usr := toLocalizedUser(result.User, nil)
+			body.Results = append(body.Results, batchResult{Status: http.StatusOK, User: &usr})
+		}
+		writeJSON(writer, http.StatusOK, body)
+	})
+	return b
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/infra/userstore"
+)
+
+func newBatchTestHandler(t *testing.T) (http.Handler, *users.User) {
+	log, notifier, store := logger.Logger{}, usernotifier.NewInMemory(), userstore.NewInMemory()
+	emails, _ := users.NewEmailPolicy(users.Config{})
+	names, _ := users.NewNamePolicy(users.Config{})
+	validator, err := users.NewValidator(emails, names, users.DefaultRules())
+	require.NoError(t, err)
+	usr, err := store.Add(context.Background(), &users.User{FirstName: "test", LastName: "test", NickName: "test-batch", Email: "test-batch@test.com", Country: "FR"})
+	require.NoError(t, err)
+
+	hasher := pwdhasher.NewBcryptWithCost(bcrypt.MinCost)
+	batch := users.SetupBatch(log,
+		users.SetupCreate(log, notifier, store, hasher, validator),
+		users.SetupUpdate(log, notifier, store, hasher, validator),
+		users.SetupDelete(log, notifier, store),
+		store, hasher, validator, users.Config{BatchMaxSize: 3})
+	return NewBuilder(log, Config{}).
+		WithAPIKeyAuth(func(ctx context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+			return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "keyid", UserID: "adminid", Scopes: []users.Scope{users.ScopeUsersWrite, users.ScopeUsersAdmin}}}, nil
+		}).
+		WithV1BatchUsers(batch).
+		handler(), usr
+}
+
+func TestBuilder_WithV1BatchUsers(t *testing.T) {
+	handler, usr := newBatchTestHandler(t)
+
+	w := importCall(handler, "POST", "/v1/users:batch", "application/json", `{"operations": [
+		{"create": {"first_name": "test", "last_name": "test", "nick_name": "test-batch-1", "email": "test-batch-1@test.com", "password": "password"}},
+		{"update": {"id": "`+usr.ID+`", "last_name": "updated", "country": ""}},
+		{"delete": {"id": "unknown"}}
+	]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotContains(t, w.Body.String(), "password", "the password hashes aren't returned")
+	var body batchBody
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	require.Equal(t, 1, body.Failed)
+	require.Len(t, body.Results, 3)
+	require.Equal(t, http.StatusOK, body.Results[0].Status)
+	require.Equal(t, "test-batch-1@test.com", body.Results[0].User.Email)
+	require.Equal(t, "updated", body.Results[1].User.LastName)
+	require.Equal(t, "FR", body.Results[1].User.Country, "the empty fields of an update are kept")
+	require.Equal(t, http.StatusNotFound, body.Results[2].Status)
+	require.Equal(t, "/problems/user-not-found", body.Results[2].Problem.Type)
+	require.Nil(t, body.Results[2].User)
+}
+
+func TestBuilder_WithV1BatchUsers_Atomic(t *testing.T) {
+	handler, usr := newBatchTestHandler(t)
+
+	w := importCall(handler, "POST", "/v1/users:batch", "application/json", `{"atomic": true, "operations": [
+		{"delete": {"id": "`+usr.ID+`"}},
+		{"create": {"first_name": "test", "last_name": "test", "nick_name": "test-batch-2", "email": "test-batch@test.com", "password": "password"}}
+	]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body batchBody
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	require.Equal(t, 2, body.Failed)
+	require.Equal(t, http.StatusFailedDependency, body.Results[0].Status)
+	require.Equal(t, "/problems/batch-aborted", body.Results[0].Problem.Type)
+	require.Equal(t, http.StatusConflict, body.Results[1].Status)
+
+	// the deleted user was restored with the batch, it can be deleted again
+	w = importCall(handler, "POST", "/v1/users:batch", "application/json", `{"atomic": true, "operations": [{"delete": {"id": "`+usr.ID+`"}}]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"failed":0`)
+}
+
+func TestBuilder_WithV1BatchUsers_Errors(t *testing.T) {
+	handler, _ := newBatchTestHandler(t)
+
+	w := importCall(handler, "POST", "/v1/users:batch", "application/json", `{"operations": [
+		{"delete": {"id": "1"}}, {"delete": {"id": "2"}}, {"delete": {"id": "3"}}, {"delete": {"id": "4"}}
+	]}`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Contains(t, w.Body.String(), "/problems/invalid-batch")
+
+	w = importCall(handler, "POST", "/v1/users:batch", "application/json", `{"operations": [{"delete": {"id": "1"}, "create": {}}]}`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = importCall(handler, "POST", "/v1/users:batch", "application/json", `{"operations": [{"replace": {"id": "1"}}]}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, "the unknown operations are refused by the schema")
+}
@@ -0,0 +1,268 @@
+package http
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-users-example/domain/users"
+)
+
+// exportRowGroupSize is the number of users buffered in a row group of a parquet export
+const exportRowGroupSize = 10000
+
+// exportFormat is a format of the export of the users
+type exportFormat struct {
+	contentType string
+	newWriter   func(w io.Writer) exportWriter
+}
+
+// exportWriter will write the users in a format, Close is called once all the users are written
+type exportWriter interface {
+	users.ExportWriter
+	Close() error
+}
+
+// exportFormats are the formats of the export by name
+var exportFormats = map[string]exportFormat{
+	"ndjson":  {contentType: "application/x-ndjson", newWriter: func(w io.Writer) exportWriter { return &ndjsonExportWriter{w: bufio.NewWriter(w)} }},
+	"csv":     {contentType: "text/csv", newWriter: func(w io.Writer) exportWriter { return &csvExportWriter{w: csv.NewWriter(w)} }},
+	"parquet": {contentType: "application/vnd.apache.parquet", newWriter: func(w io.Writer) exportWriter { return &parquetExportWriter{w: w} }},
+}
+
+// WithV1ExportUsers will add http endpoint to download the users matching the search parameters, from a snapshot of
+// the user base. The users are streamed as ndjson, csv or parquet
+func (b *Builder) WithV1ExportUsers(exportUsers users.ExportUsers) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v1/users:export", id: "v1ExportUsers", summary: "Download the users",
+		description: "All the users are exported without search parameter, the changes made during the export aren't " +
+			"exported. The password hashes are never exported. " + searchDescription,
+		params: append([]parameter{
+			{name: "format", in: "query", description: "`ndjson`, `csv` or `parquet`, ndjson by default."},
+			{name: "fields", in: "query", description: "The comma separated json names of the exported fields in their order, all the fields by default.", example: "id,email"},
+		}, searchParams...),
+		responses: []response{{
+			status:  http.StatusOK,
+			headers: map[string]string{"Content-Disposition": "The name of the downloaded file."},
+			content: streamContent(exportFormats["ndjson"].contentType, exportFormats["csv"].contentType, exportFormats["parquet"].contentType),
+		}},
+		problems: []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+		admin:    true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		name := request.URL.Query().Get("format")
+		if name == "" {
+			name = "ndjson"
+		}
+		format, ok := exportFormats[name]
+		if !ok {
+			writeStatusProblem(writer, request, http.StatusBadRequest)
+			return
+		}
+		req, status, err := parseSearchRequest(request)
+		if err != nil {
+			writeStatusProblem(writer, request, status)
+			return
+		}
+		var fields []string
+		if list := request.URL.Query().Get("fields"); list != "" {
+			fields = strings.Split(list, ",")
+		}
+
+		stream := &exportStream{writer: writer, contentType: format.contentType, filename: "users." + name}
+		out := format.newWriter(stream)
+		_, err = exportUsers(request.Context(), &users.ExportUsersReq{SearchReq: *req, Fields: fields, Writer: out})
+		if err == nil {
+			err = out.Close()
+		}
+		if err != nil && !stream.started {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err != nil {
+			// the response is aborted so that the client doesn't take the truncated export as complete
+			b.log.Error().Err(err).Msg("can't write users export")
+			panic(http.ErrAbortHandler)
+		}
+		stream.start()
+	})
+	return b
+}
+
+// exportStream is the response of an export, it is started by the first bytes of the export so that an error
+// before them is returned as a problem
+type exportStream struct {
+	writer      http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (s *exportStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.writer.Header().Set("Content-Type", s.contentType)
+	s.writer.Header().Set("Content-Disposition", `attachment; filename="`+s.filename+`"`)
+	s.writer.WriteHeader(http.StatusOK)
+}
+
+func (s *exportStream) Write(p []byte) (int, error) {
+	s.start()
+	return s.writer.Write(p)
+}
+
+// ndjsonExportWriter will write a json object by user, the fields are in the exported order
+type ndjsonExportWriter struct {
+	w    *bufio.Writer
+	keys [][]byte
+}
+
+func (n *ndjsonExportWriter) Begin(fields []users.ExportField) error {
+	for i, field := range fields {
+		key, _ := json.Marshal(field.Name)
+		if i > 0 {
+			key = append([]byte(","), key...)
+		}
+		n.keys = append(n.keys, append(key, ':'))
+	}
+	return nil
+}
+
+func (n *ndjsonExportWriter) Write(values []interface{}) error {
+	_ = n.w.WriteByte('{')
+	for i, v := range values {
+		data, err := json.Marshal(exportValue(v))
+		if err != nil {
+			return err
+		}
+		_, _ = n.w.Write(n.keys[i])
+		_, _ = n.w.Write(data)
+	}
+	_, err := n.w.WriteString("}\n")
+	return err
+}
+
+func (n *ndjsonExportWriter) Close() error {
+	return n.w.Flush()
+}
+
+// csvExportWriter will write a header with the exported fields and a row by user, the times are RFC 3339 and the
+// attributes a json object. An unset value is empty, and a string which a spreadsheet would run as a formula is
+// prefixed by a quote
+type csvExportWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvExportWriter) Begin(fields []users.ExportField) error {
+	c.record = make([]string, len(fields))
+	for i, field := range fields {
+		c.record[i] = field.Name
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvExportWriter) Write(values []interface{}) error {
+	for i, v := range values {
+		switch v := exportValue(v).(type) {
+		case nil:
+			c.record[i] = ""
+		case string:
+			c.record[i] = escapeFormula(v)
+		case time.Time:
+			c.record[i] = v.Format(time.RFC3339Nano)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			c.record[i] = string(data)
+		}
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvExportWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula will prefix by a quote a value starting like a formula, so that a spreadsheet shows it as text
+func escapeFormula(v string) string {
+	if v != "" && strings.ContainsRune("=+-@", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// parquetExportWriter will write an optional column by field: the strings are UTF8, the times are UTC timestamps in
+// milliseconds and the attributes are JSON
+type parquetExportWriter struct {
+	w       io.Writer
+	file    *parquetWriter
+	columns []*parquetColumn
+}
+
+func (p *parquetExportWriter) Begin(fields []users.ExportField) error {
+	for _, field := range fields {
+		c := &parquetColumn{name: field.Name, typ: parquetByteArray, converted: parquetUTF8}
+		switch field.Type {
+		case users.ExportTime:
+			c.typ, c.converted = parquetInt64, parquetTimestampMillis
+		case users.ExportObject:
+			c.converted = parquetJSON
+		}
+		p.columns = append(p.columns, c)
+	}
+	p.file = newParquetWriter(p.w, p.columns, exportRowGroupSize)
+	return p.file.err
+}
+
+func (p *parquetExportWriter) Write(values []interface{}) error {
+	for i, v := range values {
+		switch v := exportValue(v).(type) {
+		case nil:
+			if p.columns[i].typ == parquetInt64 {
+				p.columns[i].appendInt64(0, false)
+			} else {
+				p.columns[i].appendBytes(nil, false)
+			}
+		case string:
+			p.columns[i].appendBytes([]byte(v), true)
+		case time.Time:
+			p.columns[i].appendInt64(v.UnixNano()/int64(time.Millisecond), true)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			p.columns[i].appendBytes(data, true)
+		}
+	}
+	return p.file.endRow()
+}
+
+func (p *parquetExportWriter) Close() error {
+	return p.file.Close()
+}
+
+// exportValue will return the value of an exported field with the unset times and objects as nil
+func exportValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.UTC()
+	case map[string]interface{}:
+		if v == nil {
+			return nil
+		}
+	}
+	return v
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+// testVerifiedAt is the verification time of the phone of the exported user test-export-2
+var testVerifiedAt = time.Date(2024, 1, 2, 3, 4, 5, 6e6, time.UTC)
+
+func newExportTestHandler(t *testing.T) http.Handler {
+	log := logger.Logger{}
+	emails, _ := users.NewEmailPolicy(users.Config{})
+	names, _ := users.NewNamePolicy(users.Config{})
+	validator, err := users.NewValidator(emails, names, users.DefaultRules())
+	require.NoError(t, err)
+	store := userstore.NewInMemory()
+	verifiedAt := testVerifiedAt
+	for _, usr := range []*users.User{
+		{FirstName: "test", NickName: "test-export-1", Email: "test-export-1@test.com", Password: "hash", Country: "FR"},
+		{FirstName: "test", NickName: "test-export-2", Email: "test-export-2@test.com", Password: "hash", Country: "US", Phone: "+15555550100", PhoneVerifiedAt: &verifiedAt},
+		{FirstName: "=HYPERLINK(\"https://evil.test\")", NickName: "test-export-3", Email: "test-export-3@test.com", Password: "hash", Country: "GB"},
+	} {
+		_, err := store.Add(context.Background(), usr)
+		require.NoError(t, err)
+	}
+
+	return NewBuilder(log, Config{}).
+		WithAPIKeyAuth(func(ctx context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+			return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "keyid", UserID: "adminid", Scopes: []users.Scope{users.ScopeUsersRead, users.ScopeUsersAdmin}}}, nil
+		}).
+		WithV1ExportUsers(users.SetupExportUsers(log, store, validator)).
+		handler()
+}
+
+func TestBuilder_WithV1ExportUsers(t *testing.T) {
+	handler := newExportTestHandler(t)
+
+	w := importCall(handler, "GET", "/v1/users:export?format=ndjson&fields=email,country&country=france", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	require.Equal(t, `{"email":"test-export-1@test.com","country":"FR"}`+"\n", w.Body.String())
+
+	w = importCall(handler, "GET", "/v1/users:export?format=csv&fields=nick_name,deleted_at&nick_name=test-export-1&nick_name=test-export-2", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Equal(t, "nick_name,deleted_at", lines[0])
+	require.ElementsMatch(t, []string{"test-export-1,", "test-export-2,"}, lines[1:])
+
+	// the values which would be run as formulas are kept as text
+	w = importCall(handler, "GET", "/v1/users:export?format=csv&fields=first_name,phone&country=GB&country=US", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	lines = strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.ElementsMatch(t, []string{`"'=HYPERLINK(""https://evil.test"")",`, "test,'+15555550100"}, lines[1:])
+
+	w = importCall(handler, "GET", "/v1/users:export?format=parquet&first_name=test", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	body := w.Body.Bytes()
+	require.True(t, bytes.HasPrefix(body, []byte("PAR1")) && bytes.HasSuffix(body, []byte("PAR1")))
+	footer := int(binary.LittleEndian.Uint32(body[len(body)-8:]))
+	require.Less(t, footer, len(body)-12, "the footer follows the row group")
+	require.NotContains(t, string(body), "hash", "the password hashes are never exported")
+}
+
+func TestBuilder_WithV1ExportUsers_ParquetRoundTrip(t *testing.T) {
+	handler := newExportTestHandler(t)
+
+	w := importCall(handler, "GET", "/v1/users:export?format=parquet&fields=email,phone,phone_verified_at,attributes&first_name=test", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	file, err := buffer.NewBufferFile(w.Body.Bytes())
+	require.NoError(t, err)
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), pr.GetNumRows())
+
+	schema := pr.Footer.Schema[1:]
+	require.Len(t, schema, 4)
+	for i, name := range []string{"email", "phone", "phone_verified_at", "attributes"} {
+		require.Equal(t, name, pr.SchemaHandler.GetExName(i+1))
+		require.Equal(t, parquet.FieldRepetitionType_OPTIONAL, schema[i].GetRepetitionType())
+	}
+	require.Equal(t, parquet.ConvertedType_UTF8, schema[0].GetConvertedType())
+	require.Equal(t, parquet.Type_INT64, schema[2].GetType())
+	require.Equal(t, parquet.ConvertedType_TIMESTAMP_MILLIS, schema[2].GetConvertedType())
+	require.Equal(t, parquet.ConvertedType_JSON, schema[3].GetConvertedType())
+
+	emails, _, _, err := pr.ReadColumnByIndex(0, 2)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []interface{}{"test-export-1@test.com", "test-export-2@test.com"}, emails)
+	phones, _, _, err := pr.ReadColumnByIndex(1, 2)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []interface{}{"", "+15555550100"}, phones, "the phone isn't escaped out of csv")
+	verified, _, levels, err := pr.ReadColumnByIndex(2, 2)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []interface{}{nil, testVerifiedAt.UnixNano() / int64(time.Millisecond)}, verified)
+	require.ElementsMatch(t, []int32{0, 1}, levels, "an unset time isn't defined")
+	attributes, _, _, err := pr.ReadColumnByIndex(3, 2)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{nil, nil}, attributes)
+}
+
+func TestBuilder_WithV1ExportUsers_Errors(t *testing.T) {
+	handler := newExportTestHandler(t)
+
+	w := importCall(handler, "GET", "/v1/users:export?format=xml", "", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = importCall(handler, "GET", "/v1/users:export?format=csv&fields=email,password", "", "")
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Body.String(), "/problems/invalid-export")
+	require.Empty(t, w.Header().Get("Content-Disposition"))
+}
+
+func TestParquetWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newParquetWriter(&buf, []*parquetColumn{
+		{name: "email", typ: parquetByteArray, converted: parquetUTF8},
+		{name: "deleted_at", typ: parquetInt64, converted: parquetTimestampMillis},
+	}, 2)
+	for i := 0; i < 5; i++ {
+		writer.columns[0].appendBytes([]byte("test@test.com"), true)
+		writer.columns[1].appendInt64(int64(i), i%2 == 0)
+		require.NoError(t, writer.endRow())
+	}
+	require.NoError(t, writer.Close())
+
+	body := buf.Bytes()
+	require.Equal(t, "PAR1", string(body[:4]))
+	require.Equal(t, "PAR1", string(body[len(body)-4:]))
+	footer := int(binary.LittleEndian.Uint32(body[len(body)-8:]))
+	require.Greater(t, footer, 0)
+	metadata := body[len(body)-8-footer : len(body)-8]
+	require.Contains(t, string(metadata), "deleted_at")
+	require.Equal(t, 5, bytes.Count(body[:len(body)-8-footer], []byte("test@test.com")), "every row is flushed, the last group isn't full")
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -33,6 +34,7 @@ func TestBuilder_WithV1CreateUser(t *testing.T) {
 	req := httptest.NewRequest("POST", "http://localhost/v1/user", strings.NewReader(`
 	{"first_name": "test", "last_name": "test", "email": "test@test.com", "nick_name": "test", "password": "test"}
 	`))
+	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -43,3 +45,29 @@ func TestBuilder_WithV1CreateUser(t *testing.T) {
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 	require.Contains(t, string(body), "newid")
 }
+
+func TestBuilder_WithV1CreateUser_Invalid(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1CreateUser(func(ctx context.Context, req *users.CreateReq) (*users.CreateResp, error) {
+		return nil, fmt.Errorf("can't validate user: %w", &users.ValidationError{Violations: []users.FieldViolation{
+			{Field: "first_name", Code: users.CodeTooShort, Message: "firstname too short", Params: map[string]interface{}{"min": 2, "max": 20}},
+			{Field: "email", Code: users.CodeInvalidFormat, Message: "email invalid"},
+		}})
+	}).router
+
+	req := httptest.NewRequest("POST", "http://localhost/v1/user", strings.NewReader(`{"first_name": "t", "email": "test"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	require.JSONEq(t, `{"type": "/problems/invalid-user", "title": "Invalid user", "status": 422,
+		"detail": "One or more fields of the user aren't valid.", "instance": "/v1/user", "violations": [
+		{"field": "first_name", "code": "too_short", "message": "firstname too short", "params": {"min": 2, "max": 20}},
+		{"field": "email", "code": "invalid_format", "message": "email invalid"}
+	]}`, string(body))
+}
@@ -1,9 +1,6 @@
 package http
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"net/http"
 
 	"go-users-example/domain/users"
@@ -11,34 +8,29 @@ import (
 
 // WithV1DeleteUser will add http endpoint to delete new user
 func (b *Builder) WithV1DeleteUser(deleteUser users.Delete) *Builder {
-	b.router.Delete("/v1/user", func(writer http.ResponseWriter, request *http.Request) {
-		req, status, err := parseDeleteRequest(request)
-		if err != nil {
-			writer.WriteHeader(status)
+	b.handle(operation{
+		method: http.MethodDelete, path: "/v1/user", id: "v1DeleteUser", summary: "Delete a user",
+		description:   "The user is soft deleted, it can be restored until it is purged.",
+		request:       jsonContent(users.DeleteReq{}),
+		responses:     []response{{status: http.StatusOK, content: jsonContent(userBody{User: localizedUser{}})}},
+		problems:      []int{http.StatusNotFound},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.DeleteReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
 			return
 		}
-		res, err := deleteUser(request.Context(), req)
-		switch {
-		case err != nil:
-			b.log.Error().Err(err).Send()
-			writer.WriteHeader(http.StatusInternalServerError)
-			writer.Write([]byte(err.Error()))
-		default:
-			data, _ := json.Marshal(res)
-			_, _ = writer.Write(data)
+		if err := authorizeUser(request, req.ID); err != nil {
+			writeError(b.log, writer, request, err)
+			return
 		}
+		res, err := deleteUser(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, userBody{User: localizeUser(writer, request, res.User)})
 	})
 	return b
 }
-
-func parseDeleteRequest(request *http.Request) (*users.DeleteReq, int, error) {
-	data, err := ioutil.ReadAll(request.Body)
-	if err != nil {
-		return nil, http.StatusBadRequest, fmt.Errorf("can't read body: %w", err)
-	}
-	var req users.DeleteReq
-	if err := json.Unmarshal(data, &req); err != nil {
-		return nil, http.StatusBadRequest, fmt.Errorf("can't parse json body: %w", err)
-	}
-	return &req, 0, nil
-}
@@ -24,6 +24,8 @@ func TestBuilder_WithV1DeleteUser(t *testing.T) {
 	req := httptest.NewRequest("DELETE", "http://localhost/v1/user", strings.NewReader(`
 	{"id": "testid"}
 	`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAPIKey(req, "testid")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1EraseUser will add http endpoint to irreversibly erase all the data held about a user
+func (b *Builder) WithV1EraseUser(eraseUser users.EraseUser) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/users/{id}/erase", id: "v1EraseUser", summary: "Erase all the data held about a user",
+		description:   "The erasure can't be undone, the signed receipt list the records erased by store.",
+		responses:     []response{{status: http.StatusOK, content: jsonContent(users.EraseUserResp{})}},
+		problems:      []int{http.StatusNotFound},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		if err := authorizeUser(request, chi.URLParam(request, "id")); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := eraseUser(request.Context(), &users.EraseUserReq{UserID: chi.URLParam(request, "id")})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
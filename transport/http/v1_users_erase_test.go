@@ -0,0 +1,41 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+func TestBuilder_WithV1EraseUser(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1EraseUser(func(ctx context.Context, req *users.EraseUserReq) (*users.EraseUserResp, error) {
+		if req.UserID != "testid" {
+			return nil, userstore.ErrNotFound
+		}
+		return &users.EraseUserResp{Receipt: &users.ErasureReceipt{UserID: req.UserID, Signature: "signature"}}, nil
+	}).router
+
+	req := httptest.NewRequest("POST", "http://localhost/v1/users/testid/erase", nil)
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "signature")
+
+	req = httptest.NewRequest("POST", "http://localhost/v1/users/unknown/erase", nil)
+	req = withAPIKey(req, "adminid", users.ScopeUsersAdmin)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1ExportUserData will add http endpoint to download all the data held about a user.
+// The data are returned as json, or as a zip bundle with `format=zip`
+func (b *Builder) WithV1ExportUserData(exportUserData users.ExportUserData) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v1/users/{id}/export", id: "v1ExportUserData", summary: "Download all the data held about a user",
+		params: []parameter{{name: "format", in: "query", description: "`zip` to get the data as a zip bundle, json by default."}},
+		responses: []response{{
+			status:  http.StatusOK,
+			headers: map[string]string{"Content-Disposition": "The name of the downloaded file."},
+			content: append(jsonContent(users.ExportUserDataResp{}), content{contentType: "application/zip"}),
+		}},
+		problems:      []int{http.StatusNotFound},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		if err := authorizeUser(request, chi.URLParam(request, "id")); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		userID := chi.URLParam(request, "id")
+		res, err := exportUserData(request.Context(), &users.ExportUserDataReq{UserID: userID})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if request.URL.Query().Get("format") != "zip" {
+			writer.Header().Set("Content-Disposition", `attachment; filename="user-`+userID+`.json"`)
+			writeJSON(writer, http.StatusOK, res)
+			return
+		}
+		data, _ := json.MarshalIndent(res, "", "  ")
+		writer.Header().Set("Content-Type", "application/zip")
+		writer.Header().Set("Content-Disposition", `attachment; filename="user-`+userID+`.zip"`)
+		zw := zip.NewWriter(writer)
+		f, err := zw.Create("user.json")
+		if err == nil {
+			_, err = f.Write(data)
+		}
+		if err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			b.log.Error().Err(err).Msg("can't write export bundle")
+		}
+	})
+	return b
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1ExportUserData(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1ExportUserData(func(ctx context.Context, req *users.ExportUserDataReq) (*users.ExportUserDataResp, error) {
+		require.Equal(t, "testid", req.UserID)
+		return &users.ExportUserDataResp{User: &users.User{ID: req.UserID, Email: "test@test.com"}}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v1/users/testid/export", nil)
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "test@test.com")
+
+	req = httptest.NewRequest("GET", "http://localhost/v1/users/testid/export?format=zip", nil)
+	req = withAPIKey(req, "testid")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp = w.Result()
+	body, _ = ioutil.ReadAll(resp.Body)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1)
+	f, _ := zr.File[0].Open()
+	content, _ := ioutil.ReadAll(f)
+	require.Contains(t, string(content), "test@test.com")
+}
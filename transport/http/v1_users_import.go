@@ -0,0 +1,89 @@
+package http
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"go-users-example/domain/users"
+)
+
+const importJobsPath = "/v1/users:import"
+
+// importFormats are the import formats by the content type of their rows
+var importFormats = map[string]users.ImportFormat{
+	"text/csv":             users.ImportCSV,
+	"application/x-ndjson": users.ImportNDJSON,
+}
+
+// WithV1ImportUsers will add http endpoints to import users from csv or ndjson rows as background jobs, to follow
+// their progress and to resume the failed ones
+func (b *Builder) WithV1ImportUsers(startImport users.StartImport, getImportJob users.GetImportJob, resumeImport users.ResumeImport) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: importJobsPath, id: "v1ImportUsers", summary: "Import users from csv or ndjson rows",
+		description: "The rows are validated and created as the users of `POST /v1/user` by a background job, the job " +
+			"reports the errors of the rows. The csv header names the columns, as the json fields of a user " +
+			"(`first_name`, `email`, `password`, ...), the `attributes` column is a json object.",
+		params:      []parameter{{name: "dry_run", in: "query", typ: "boolean", description: "Validate the rows without creating the users."}},
+		request:     streamContent("text/csv", "application/x-ndjson"),
+		maxBodySize: b.c.MaxImportSize,
+		responses: []response{{
+			status:  http.StatusAccepted,
+			headers: map[string]string{"Location": "The path of the import job."},
+			content: jsonContent(users.StartImportResp{}),
+		}},
+		problems: []int{http.StatusUnprocessableEntity},
+		admin:    true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		contentType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+		dryRun, _ := strconv.ParseBool(request.URL.Query().Get("dry_run"))
+		res, err := startImport(request.Context(), &users.StartImportReq{
+			Format: importFormats[contentType],
+			DryRun: dryRun,
+			Source: request.Body,
+		})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writer.Header().Set("Location", importJobsPath+"/"+url.PathEscape(res.Job.ID))
+		writeJSON(writer, http.StatusAccepted, res)
+	})
+	b.handle(operation{
+		method: http.MethodGet, path: importJobsPath + "/{id}", id: "v1GetImportJob", summary: "Get the progress of an import job",
+		description: "The first 1000 errors of the rows are kept, the others are only counted.",
+		responses:   []response{{status: http.StatusOK, content: jsonContent(users.GetImportJobResp{})}},
+		problems:    []int{http.StatusNotFound},
+		admin:       true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		res, err := getImportJob(request.Context(), &users.GetImportJobReq{ID: chi.URLParam(request, "id")})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	b.handle(operation{
+		method: http.MethodPost, path: importJobsPath + "/{id}:resume", id: "v1ResumeImportJob", summary: "Resume a failed import job",
+		description: "The job is resumed after the last rows it processed.",
+		responses: []response{{
+			status:  http.StatusAccepted,
+			headers: map[string]string{"Location": "The path of the import job."},
+			content: jsonContent(users.ResumeImportResp{}),
+		}},
+		problems: []int{http.StatusNotFound, http.StatusConflict},
+		admin:    true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		res, err := resumeImport(request.Context(), &users.ResumeImportReq{ID: chi.URLParam(request, "id")})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writer.Header().Set("Location", importJobsPath+"/"+url.PathEscape(res.Job.ID))
+		writeJSON(writer, http.StatusAccepted, res)
+	})
+	return b
+}
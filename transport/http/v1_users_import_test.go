@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/importstore"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/pwdhasher"
+	"go-users-example/infra/usernotifier"
+	"go-users-example/infra/userstore"
+)
+
+func newImportTestHandler(t *testing.T) http.Handler {
+	log := logger.Logger{}
+	emails, _ := users.NewEmailPolicy(users.Config{})
+	names, _ := users.NewNamePolicy(users.Config{})
+	validator, err := users.NewValidator(emails, names, users.DefaultRules())
+	require.NoError(t, err)
+	jobStore := importstore.NewInMemory()
+	run := users.SetupRunImport(log, usernotifier.NewInMemory(), userstore.NewInMemory(), pwdhasher.NewBcryptWithCost(bcrypt.MinCost),
+		validator, jobStore, users.Config{ImportWorkers: 2}, users.SystemClock)
+
+	return NewBuilder(log, Config{MaxImportSize: 256}).
+		WithAPIKeyAuth(func(ctx context.Context, req *users.AuthenticateAPIKeyReq) (*users.AuthenticateAPIKeyResp, error) {
+			return &users.AuthenticateAPIKeyResp{APIKey: &users.APIKey{ID: "keyid", UserID: "adminid", Scopes: []users.Scope{users.ScopeUsersRead, users.ScopeUsersWrite, users.ScopeUsersAdmin}}}, nil
+		}).
+		WithV1ImportUsers(
+			users.SetupStartImport(context.Background(), log, jobStore, run, users.SystemClock),
+			users.SetupGetImportJob(log, jobStore),
+			users.SetupResumeImport(context.Background(), log, jobStore, run, users.SystemClock),
+		).
+		handler()
+}
+
+func importCall(handler http.Handler, method, target, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://localhost"+target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Authorization", "Bearer key")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestBuilder_WithV1ImportUsers(t *testing.T) {
+	handler := newImportTestHandler(t)
+
+	w := importCall(handler, "POST", "/v1/users:import", "text/csv; charset=utf-8", "first_name,last_name,nick_name,email\n"+
+		"test,test,test-import-1,test-import-1@test.com\n"+
+		"test,test,test-import-2,invalid\n")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	location := w.Header().Get("Location")
+	require.True(t, strings.HasPrefix(location, "/v1/users:import/"))
+
+	var res users.GetImportJobResp
+	require.Eventually(t, func() bool {
+		w = importCall(handler, "GET", location, "", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res.Job.Status == users.ImportCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, res.Job.Created)
+	require.Equal(t, 1, res.Job.Failed)
+	require.Equal(t, "email", res.Job.Errors[0].Violations[0].Field)
+
+	w = importCall(handler, "POST", location+":resume", "", "")
+	require.Equal(t, http.StatusConflict, w.Code, "a completed job can't be resumed")
+	w = importCall(handler, "GET", "/v1/users:import/unknown", "", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBuilder_WithV1ImportUsers_Invalid(t *testing.T) {
+	handler := newImportTestHandler(t)
+
+	w := importCall(handler, "POST", "/v1/users:import", "text/csv", "email,role\n")
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Contains(t, w.Body.String(), "/problems/invalid-import")
+
+	w = importCall(handler, "POST", "/v1/users:import", "application/json", "[]")
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	// the rows are limited by the import size, not the body size
+	w = importCall(handler, "POST", "/v1/users:import?dry_run=true", "application/x-ndjson", strings.Repeat(`{"email": "test@test.com"}`+"\n", 10))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1ConfirmUserMFA will add http endpoint to enable the second factor of a user with a first valid code
+func (b *Builder) WithV1ConfirmUserMFA(confirmMFA users.ConfirmMFA) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/user/mfa/confirm", id: "v1ConfirmUserMFA", summary: "Enable the second factor of a user with a first code",
+		request:       jsonContent(users.ConfirmMFAReq{}),
+		responses:     []response{{status: http.StatusOK, content: jsonContent(users.ConfirmMFAResp{})}},
+		problems:      []int{http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.ConfirmMFAReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err := authorizeUser(request, req.ID); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := confirmMFA(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
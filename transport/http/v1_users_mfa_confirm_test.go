@@ -0,0 +1,35 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1ConfirmUserMFA(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1ConfirmUserMFA(func(ctx context.Context, req *users.ConfirmMFAReq) (*users.ConfirmMFAResp, error) {
+		if req.Code != "123456" {
+			return nil, users.ErrInvalidCode
+		}
+		return &users.ConfirmMFAResp{Enabled: true}, nil
+	}).router
+
+	for code, status := range map[string]int{"123456": http.StatusOK, "000000": http.StatusUnprocessableEntity} {
+		req := httptest.NewRequest("POST", "http://localhost/v1/user/mfa/confirm", strings.NewReader(`{"id": "testid", "code": "`+code+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req = withAPIKey(req, "testid")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+		resp := w.Result()
+
+		require.Equal(t, status, resp.StatusCode)
+	}
+}
@@ -0,0 +1,36 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1EnrollUserMFA will add http endpoint to start the second factor enrolment of a user
+func (b *Builder) WithV1EnrollUserMFA(enrollMFA users.EnrollMFA) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/user/mfa/enroll", id: "v1EnrollUserMFA", summary: "Start the second factor enrolment of a user",
+		request:       jsonContent(users.EnrollMFAReq{}),
+		responses:     []response{{status: http.StatusOK, content: jsonContent(users.EnrollMFAResp{})}},
+		problems:      []int{http.StatusNotFound, http.StatusConflict},
+		authenticated: true,
+		passwordAuth:  true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.EnrollMFAReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err := authorizeUser(request, req.ID); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := enrollMFA(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
@@ -0,0 +1,34 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1EnrollUserMFA(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1EnrollUserMFA(func(ctx context.Context, req *users.EnrollMFAReq) (*users.EnrollMFAResp, error) {
+		require.Equal(t, "testid", req.ID)
+		return &users.EnrollMFAResp{Secret: "SECRET", URI: "otpauth://totp/test"}, nil
+	}).router
+
+	req := httptest.NewRequest("POST", "http://localhost/v1/user/mfa/enroll", strings.NewReader(`{"id": "testid"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "SECRET")
+}
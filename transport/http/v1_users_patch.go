@@ -0,0 +1,184 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/userstore"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// patchDescription and patchContent describe the patches accepted by parsePatchRequest
+const patchDescription = "An absent field is kept, a null or removed field is cleared. The attributes are merged, " +
+	"a null or removed attribute is deleted. A JSON Patch only support add, replace and remove operations on the " +
+	"fields and the attributes."
+
+var patchContent = []content{
+	{contentType: mergePatchContentType, body: users.UpdateReq{}},
+	{contentType: jsonContentType, body: users.UpdateReq{}},
+	{contentType: jsonPatchContentType, body: []jsonPatchOperation{}},
+}
+
+// ifMatch is the parameter of the routes updating a user only if it is still at the version of its ETag
+var ifMatch = parameter{name: "If-Match", in: "header", description: "Update the user only if it is still at the version of this ETag."}
+
+// etagHeader describes the ETag of the routes returning a user
+var etagHeader = map[string]string{"ETag": "The version of the user, to send in If-Match to update only this version."}
+
+// setETag will set the ETag of the user, its version
+func setETag(writer http.ResponseWriter, usr *users.User) {
+	writer.Header().Set("ETag", `"`+strconv.FormatInt(usr.Version, 10)+`"`)
+}
+
+// parseIfMatch will read the version of the user the update is based on, it is 0 if any version can be updated.
+// only a single strong ETag can match a version
+func parseIfMatch(request *http.Request) (int64, error) {
+	etag := strings.TrimSpace(request.Header.Get("If-Match"))
+	if etag == "" || etag == "*" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(etag, `"`), `"`), 10, 64)
+	if err != nil || version <= 0 || !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		return 0, fmt.Errorf("the etag %s doesn't match a version: %w", etag, users.ErrPreconditionFailed)
+	}
+	return version, nil
+}
+
+// preconditionFailed will report the change of the user between the read and the store of an update based on a
+// version as a failed precondition, the version has been changed by a concurrent request
+func preconditionFailed(req *users.UpdateReq, err error) error {
+	if req.Version != 0 && errors.Is(err, userstore.ErrVersionConflict) {
+		return fmt.Errorf("%v: %w", err, users.ErrPreconditionFailed)
+	}
+	return err
+}
+
+// WithV1PatchUser will add http endpoint to partially update a user with a JSON Merge Patch (RFC 7396)
+// or a JSON Patch (RFC 6902), an absent field is kept, a null or removed field is cleared
+func (b *Builder) WithV1PatchUser(updateUser users.Update) *Builder {
+	b.handle(operation{
+		method: http.MethodPatch, path: "/v1/users/{id}", id: "v1PatchUser", summary: "Partially update a user",
+		description:   patchDescription,
+		params:        []parameter{acceptLanguage, ifMatch},
+		request:       patchContent,
+		responses:     []response{{status: http.StatusOK, headers: etagHeader, content: jsonContent(userBody{User: localizedUser{}})}},
+		problems:      []int{http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		req, err := parsePatchRequest(request)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err := authorizeUser(request, req.ID); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := updateUser(request.Context(), req)
+		if err != nil {
+			writeError(b.log, writer, request, preconditionFailed(req, err))
+			return
+		}
+		setETag(writer, res.User)
+		writeJSON(writer, http.StatusOK, userBody{User: localizeUser(writer, request, res.User)})
+	})
+	return b
+}
+
+// parsePatchRequest will read the patch of the user of the path, its content type and its schema are checked by
+// Builder.handle. A JSON Patch is converted to the equivalent merge patch
+func parsePatchRequest(request *http.Request) (*users.UpdateReq, error) {
+	if contentType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type")); contentType == jsonPatchContentType {
+		data, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			return nil, invalidBody("", codeInvalidBody, "the body can't be read")
+		}
+		if data, err = jsonPatchToMergePatch(data); err != nil {
+			return nil, err
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+
+	var req users.UpdateReq
+	if err := decodeBody(request, &req); err != nil {
+		return nil, err
+	}
+	id := chi.URLParam(request, "id")
+	if req.ID != "" && req.ID != id {
+		return nil, invalidBody("id", codeInvalidBody, "the id can't be changed")
+	}
+	req.ID = id
+	var err error
+	if req.Version, err = parseIfMatch(request); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// jsonPatchOperation is an operation of a JSON Patch, only add, replace and remove are supported
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonPatchToMergePatch will convert a JSON Patch to the equivalent merge patch, which is possible as the user
+// only has scalar fields and a flat object of attributes. a removed field is set to null
+func jsonPatchToMergePatch(data []byte) ([]byte, error) {
+	var ops []jsonPatchOperation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, invalidBody("", codeInvalidJSON, "the json patch can't be decoded: "+err.Error())
+	}
+	patch := map[string]interface{}{}
+	attributes := map[string]interface{}{}
+	for i, op := range ops {
+		var value interface{} = json.RawMessage("null")
+		switch op.Op {
+		case "add", "replace":
+			if op.Value == nil {
+				return nil, invalidBody(fmt.Sprintf("%d.value", i), users.CodeRequired, fmt.Sprintf("%s %s: value is missing", op.Op, op.Path))
+			}
+			value = op.Value
+		case "remove":
+		default:
+			return nil, invalidBody(fmt.Sprintf("%d.op", i), codeInvalidBody, fmt.Sprintf("unsupported json patch operation %q", op.Op))
+		}
+
+		tokens := strings.Split(op.Path, "/")
+		for i, token := range tokens {
+			tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		}
+		switch {
+		case len(tokens) == 2 && tokens[0] == "" && tokens[1] != "attributes" && tokens[1] != "id":
+			patch[tokens[1]] = value
+		case len(tokens) == 2 && tokens[0] == "" && tokens[1] == "attributes" && op.Op == "remove":
+			patch["attributes"] = nil
+			attributes = map[string]interface{}{}
+		case len(tokens) == 3 && tokens[0] == "" && tokens[1] == "attributes":
+			if _, removed := patch["attributes"]; removed {
+				return nil, invalidBody(fmt.Sprintf("%d.path", i), codeInvalidBody, fmt.Sprintf("%s %s: the attributes have been removed", op.Op, op.Path))
+			}
+			attributes[tokens[2]] = value
+		default:
+			return nil, invalidBody(fmt.Sprintf("%d.path", i), codeInvalidBody, fmt.Sprintf("unsupported json patch path %q", op.Path))
+		}
+	}
+	if len(attributes) > 0 {
+		patch["attributes"] = attributes
+	}
+	return json.Marshal(patch)
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1PatchUser(t *testing.T) {
+	var got *users.UpdateReq
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1PatchUser(func(ctx context.Context, req *users.UpdateReq) (*users.UpdateResp, error) {
+		got = req
+		return &users.UpdateResp{User: &users.User{ID: req.ID}}, nil
+	}).router
+
+	for name, tc := range map[string]struct {
+		contentType string
+		body        string
+		status      int
+		want        *users.UpdateReq
+	}{
+		"merge patch": {
+			contentType: "application/merge-patch+json",
+			body:        `{"first_name": "test", "nick_name": null, "country": "", "attributes": {"level": null}}`,
+			status:      http.StatusOK,
+			want: &users.UpdateReq{
+				ID:         "testid",
+				FirstName:  users.SetString("test"),
+				NickName:   users.NullString(),
+				Country:    users.SetString(""),
+				Attributes: users.SetAttributes(map[string]interface{}{"level": nil}),
+			},
+		},
+		"json is a merge patch": {
+			contentType: "application/json; charset=utf-8",
+			body:        `{"id": "testid", "attributes": null}`,
+			status:      http.StatusOK,
+			want:        &users.UpdateReq{ID: "testid", Attributes: users.NullAttributes()},
+		},
+		"json patch": {
+			contentType: "application/json-patch+json",
+			body: `[
+				{"op": "replace", "path": "/first_name", "value": "test"},
+				{"op": "remove", "path": "/nick_name"},
+				{"op": "add", "path": "/attributes/department", "value": "sales"},
+				{"op": "remove", "path": "/attributes/level"}
+			]`,
+			status: http.StatusOK,
+			want: &users.UpdateReq{
+				ID:         "testid",
+				FirstName:  users.SetString("test"),
+				NickName:   users.NullString(),
+				Attributes: users.SetAttributes(map[string]interface{}{"department": "sales", "level": nil}),
+			},
+		},
+		"json patch remove attributes": {
+			contentType: "application/json-patch+json",
+			body:        `[{"op": "remove", "path": "/attributes"}]`,
+			status:      http.StatusOK,
+			want:        &users.UpdateReq{ID: "testid", Attributes: users.NullAttributes()},
+		},
+		"unknown field":          {contentType: "application/merge-patch+json", body: `{"unknown": "test"}`, status: http.StatusBadRequest},
+		"change id":              {contentType: "application/merge-patch+json", body: `{"id": "other"}`, status: http.StatusBadRequest},
+		"json patch move":        {contentType: "application/json-patch+json", body: `[{"op": "move", "from": "/first_name", "path": "/last_name"}]`, status: http.StatusBadRequest},
+		"json patch id":          {contentType: "application/json-patch+json", body: `[{"op": "replace", "path": "/id", "value": "other"}]`, status: http.StatusBadRequest},
+		"json patch no value":    {contentType: "application/json-patch+json", body: `[{"op": "add", "path": "/first_name"}]`, status: http.StatusBadRequest},
+		"unsupported media type": {contentType: "text/plain", body: `{}`, status: http.StatusUnsupportedMediaType},
+	} {
+		got = nil
+		req := httptest.NewRequest("PATCH", "http://localhost/v1/users/testid", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", tc.contentType)
+		req = withAPIKey(req, "testid")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+		require.Equal(t, tc.status, w.Result().StatusCode, name)
+		if tc.want != nil {
+			require.Equal(t, tc.want, got, name)
+		}
+	}
+}
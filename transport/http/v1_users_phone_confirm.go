@@ -0,0 +1,36 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1ConfirmUserPhone will add http endpoint to mark the phone of a user as verified with the code sent to it
+func (b *Builder) WithV1ConfirmUserPhone(confirmPhone users.ConfirmPhone) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/user/phone/confirm", id: "v1ConfirmUserPhone", summary: "Mark the phone of a user as verified",
+		params:        []parameter{acceptLanguage},
+		request:       jsonContent(users.ConfirmPhoneReq{}),
+		responses:     []response{{status: http.StatusOK, content: jsonContent(userBody{User: localizedUser{}})}},
+		problems:      []int{http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.ConfirmPhoneReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err := authorizeUser(request, req.ID); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := confirmPhone(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, userBody{User: localizeUser(writer, request, res.User)})
+	})
+	return b
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1ConfirmUserPhone(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1ConfirmUserPhone(func(ctx context.Context, req *users.ConfirmPhoneReq) (*users.ConfirmPhoneResp, error) {
+		if req.Code != "123456" {
+			return nil, users.ErrInvalidCode
+		}
+		verifiedAt := time.Unix(1600000000, 0)
+		return &users.ConfirmPhoneResp{User: &users.User{ID: req.ID, Phone: "+33612345678", PhoneVerifiedAt: &verifiedAt}}, nil
+	}).router
+
+	for code, status := range map[string]int{"123456": http.StatusOK, "000000": http.StatusUnprocessableEntity} {
+		req := httptest.NewRequest("POST", "http://localhost/v1/user/phone/confirm", strings.NewReader(`{"id": "testid", "code": "`+code+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req = withAPIKey(req, "testid")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+		resp := w.Result()
+
+		require.Equal(t, status, resp.StatusCode)
+		if status == http.StatusOK {
+			require.Contains(t, w.Body.String(), `"phone":"+33612345678","phone_verified_at":`)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1VerifyUserPhone will add http endpoint to send a verification code to the phone of a user
+func (b *Builder) WithV1VerifyUserPhone(verifyPhone users.VerifyPhone) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/user/phone/verify", id: "v1VerifyUserPhone", summary: "Send a verification code to the phone of a user",
+		request:       jsonContent(users.VerifyPhoneReq{}),
+		responses:     []response{{status: http.StatusOK, content: jsonContent(users.VerifyPhoneResp{})}},
+		problems:      []int{http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.VerifyPhoneReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err := authorizeUser(request, req.ID); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := verifyPhone(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, res)
+	})
+	return b
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1VerifyUserPhone(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1VerifyUserPhone(func(ctx context.Context, req *users.VerifyPhoneReq) (*users.VerifyPhoneResp, error) {
+		if req.ID != "testid" {
+			return nil, users.ErrNoPhone
+		}
+		return &users.VerifyPhoneResp{Phone: "+33612345678", ExpiresAt: time.Unix(1600000000, 0).UTC()}, nil
+	}).router
+
+	req := httptest.NewRequest("POST", "http://localhost/v1/user/phone/verify", strings.NewReader(`{"id": "testid"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.JSONEq(t, `{"phone": "+33612345678", "expires_at": "2020-09-13T12:26:40Z"}`, w.Body.String())
+
+	req = httptest.NewRequest("POST", "http://localhost/v1/user/phone/verify", strings.NewReader(`{"id": "other"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAPIKey(req, "adminid", users.ScopeUsersAdmin)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusConflict, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), "/problems/phone-missing")
+}
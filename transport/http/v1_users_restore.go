@@ -0,0 +1,36 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV1RestoreUser will add http endpoint to restore a deleted user
+func (b *Builder) WithV1RestoreUser(restoreUser users.Restore) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v1/user/restore", id: "v1RestoreUser", summary: "Restore a deleted user",
+		params:        []parameter{acceptLanguage},
+		request:       jsonContent(users.RestoreReq{}),
+		responses:     []response{{status: http.StatusOK, content: jsonContent(userBody{User: localizedUser{}})}},
+		problems:      []int{http.StatusNotFound, http.StatusConflict},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.RestoreReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err := authorizeUser(request, req.ID); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := restoreUser(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, userBody{User: localizeUser(writer, request, res.User)})
+	})
+	return b
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV1RestoreUser(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1RestoreUser(func(ctx context.Context, req *users.RestoreReq) (*users.RestoreResp, error) {
+		require.NotEmpty(t, req.ID)
+		return &users.RestoreResp{User: &users.User{
+			ID: req.ID,
+		}}, nil
+	}).router
+
+	req := httptest.NewRequest("POST", "http://localhost/v1/user/restore", strings.NewReader(`
+	{"id": "testid"}
+	`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
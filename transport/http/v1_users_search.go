@@ -1,8 +1,9 @@
 package http
 
 import (
-	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"go-users-example/domain/users"
 )
@@ -10,33 +11,67 @@ import (
 // WithV1SearchUser will add http endpoint to search users
 // Note: here pagination is not implemented, so too many users can break the response
 func (b *Builder) WithV1SearchUser(searchUser users.Search) *Builder {
-	b.router.Get("/v1/users", func(writer http.ResponseWriter, request *http.Request) {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v1/users", id: "v1SearchUsers", summary: "Search the users",
+		description: searchDescription,
+		params:      append([]parameter{acceptLanguage}, searchParams...),
+		responses:   []response{{status: http.StatusOK, content: jsonContent(usersBody{Users: []localizedUser{}})}},
+		admin:       true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
 		req, status, err := parseSearchRequest(request)
 		if err != nil {
-			writer.WriteHeader(status)
+			writeStatusProblem(writer, request, status)
 			return
 		}
 		res, err := searchUser(request.Context(), req)
-		switch {
-		case err != nil:
-			b.log.Error().Err(err).Send()
-			writer.WriteHeader(http.StatusInternalServerError)
-			writer.Write([]byte(err.Error()))
-		default:
-			data, _ := json.Marshal(res)
-			_, _ = writer.Write(data)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
 		}
+		writeJSON(writer, http.StatusOK, usersBody{Users: localizeUsers(writer, request, res.Users)})
 	})
 	return b
 }
 
+// searchDescription and searchParams describe the parameters read by parseSearchRequest
+const searchDescription = "The users matching all the parameters are returned, a repeated parameter matches any of its " +
+	"values. The custom attributes are searched with the `attributes.<name>` parameters (ex: attributes.department=sales)."
+
+var searchParams = []parameter{
+	{name: "id", in: "query", array: true},
+	{name: "email", in: "query", array: true},
+	{name: "first_name", in: "query", array: true},
+	{name: "last_name", in: "query", array: true},
+	{name: "nick_name", in: "query", array: true},
+	{name: "country", in: "query", array: true},
+	{name: "phone", in: "query", array: true},
+	{name: "with_deleted", in: "query", typ: "boolean", description: "Include the deleted users."},
+}
+
+// attributeParamPrefix is the prefix of the search parameters of the custom attributes (ex: attributes.department=sales)
+const attributeParamPrefix = "attributes."
+
 func parseSearchRequest(request *http.Request) (*users.SearchReq, int, error) {
+	withDeleted, _ := strconv.ParseBool(request.URL.Query().Get("with_deleted"))
+	var attributes map[string][]string
+	for param, values := range request.URL.Query() {
+		if !strings.HasPrefix(param, attributeParamPrefix) {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string][]string)
+		}
+		attributes[strings.TrimPrefix(param, attributeParamPrefix)] = values
+	}
 	return &users.SearchReq{
-		IDs:       request.URL.Query()["id"],
-		Emails:    request.URL.Query()["email"],
-		FirstName: request.URL.Query()["first_name"],
-		LastName:  request.URL.Query()["last_name"],
-		NickName:  request.URL.Query()["nick_name"],
-		Country:   request.URL.Query()["country"],
+		IDs:         request.URL.Query()["id"],
+		Emails:      request.URL.Query()["email"],
+		FirstName:   request.URL.Query()["first_name"],
+		LastName:    request.URL.Query()["last_name"],
+		NickName:    request.URL.Query()["nick_name"],
+		Country:     request.URL.Query()["country"],
+		Phones:      request.URL.Query()["phone"],
+		Attributes:  attributes,
+		WithDeleted: withDeleted,
 	}, 0, nil
 }
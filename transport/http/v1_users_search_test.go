@@ -21,6 +21,44 @@ func TestBuilder_WithV1SearchUser(t *testing.T) {
 	}).router
 
 	req := httptest.NewRequest("GET", "http://localhost/v1/users?id=test1&id=test2", nil)
+	req = withAPIKey(req, "adminid", users.ScopeUsersAdmin)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestBuilder_WithV1SearchUser_Localized(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1SearchUser(func(ctx context.Context, req *users.SearchReq) (*users.SearchResp, error) {
+		return &users.SearchResp{Users: []*users.User{{ID: "test1", Country: "DE"}}}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v1/users?id=test1", nil)
+	req = withAPIKey(req, "adminid", users.ScopeUsersAdmin)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.NotContains(t, w.Body.String(), "country_name")
+
+	req.Header.Set("Accept-Language", "fr")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "fr", resp.Header.Get("Content-Language"))
+	require.Contains(t, w.Body.String(), `"country":"DE","country_name":"Allemagne"`)
+}
+
+func TestBuilder_WithV1SearchUser_Attributes(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1SearchUser(func(ctx context.Context, req *users.SearchReq) (*users.SearchResp, error) {
+		require.Equal(t, map[string][]string{"department": {"sales", "legal"}, "level": {"2"}}, req.Attributes)
+		return &users.SearchResp{}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v1/users?attributes.department=sales&attributes.department=legal&attributes.level=2", nil)
+	req = withAPIKey(req, "adminid", users.ScopeUsersAdmin)
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -1,10 +1,6 @@
 package http
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io/ioutil"
 	"net/http"
 
 	"go-users-example/domain/users"
@@ -12,38 +8,57 @@ import (
 
 // WithV1UpdateUser will add http endpoint to update new user
 func (b *Builder) WithV1UpdateUser(updateUser users.Update) *Builder {
-	b.router.Put("/v1/user", func(writer http.ResponseWriter, request *http.Request) {
-		req, status, err := parseUpdateRequest(request)
+	b.handle(operation{
+		method: http.MethodPut, path: "/v1/user", id: "v1UpdateUser", summary: "Update a user",
+		description:   "The absent, empty and null fields are kept, use PATCH /v1/users/{id} to clear them.",
+		params:        []parameter{acceptLanguage, ifMatch},
+		request:       jsonContent(users.UpdateReq{}),
+		responses:     []response{{status: http.StatusOK, headers: etagHeader, content: jsonContent(userBody{User: localizedUser{}})}},
+		problems:      []int{http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		req, err := parseUpdateRequest(request)
 		if err != nil {
-			writer.WriteHeader(status)
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err := authorizeUser(request, req.ID); err != nil {
+			writeError(b.log, writer, request, err)
 			return
 		}
 		res, err := updateUser(request.Context(), req)
-		switch {
-		case errors.Is(err, users.ErrInvalidUser):
-			writer.WriteHeader(http.StatusBadRequest)
-			writer.Write([]byte(err.Error()))
-		case err != nil:
-			b.log.Error().Err(err).Send()
-			writer.WriteHeader(http.StatusInternalServerError)
-			writer.Write([]byte(err.Error()))
-		default:
-			data, _ := json.Marshal(res)
-			_, _ = writer.Write(data)
+		if err != nil {
+			writeError(b.log, writer, request, preconditionFailed(req, err))
+			return
 		}
+		setETag(writer, res.User)
+		writeJSON(writer, http.StatusOK, userBody{User: localizeUser(writer, request, res.User)})
 	})
 	return b
 }
 
-func parseUpdateRequest(request *http.Request) (*users.UpdateReq, int, error) {
-	data, err := ioutil.ReadAll(request.Body)
-	if err != nil {
-		return nil, http.StatusBadRequest, fmt.Errorf("can't read body: %w", err)
-	}
+func parseUpdateRequest(request *http.Request) (*users.UpdateReq, error) {
 	var req users.UpdateReq
-	if err := json.Unmarshal(data, &req); err != nil {
-		return nil, http.StatusBadRequest, fmt.Errorf("can't parse json body: %w", err)
+	if err := decodeBody(request, &req); err != nil {
+		return nil, err
 	}
+	keepEmptyFields(&req)
+	var err error
+	if req.Version, err = parseIfMatch(request); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
 
-	return &req, 0, nil
+// keepEmptyFields will unset the empty and null fields of the update, they are kept as they are. use PATCH /v1/users/{id}
+// to clear them
+func keepEmptyFields(req *users.UpdateReq) {
+	for _, field := range []*users.OptionalString{&req.FirstName, &req.LastName, &req.NickName, &req.Email, &req.RawPassword, &req.Country, &req.Phone} {
+		if field.Value == "" {
+			*field = users.OptionalString{}
+		}
+	}
+	if req.Attributes.Null {
+		req.Attributes = users.OptionalAttributes{}
+	}
 }
@@ -18,17 +18,19 @@ func TestBuilder_WithV1UpdateUser(t *testing.T) {
 		require.NotEmpty(t, req.ID)
 		return &users.UpdateResp{User: &users.User{
 			ID:        req.ID,
-			FirstName: req.FirstName,
-			LastName:  req.LastName,
-			NickName:  req.NickName,
-			Password:  req.RawPassword,
-			Email:     req.Email,
+			FirstName: req.FirstName.Value,
+			LastName:  req.LastName.Value,
+			NickName:  req.NickName.Value,
+			Password:  req.RawPassword.Value,
+			Email:     req.Email.Value,
 		}}, nil
 	}).router
 
 	req := httptest.NewRequest("PUT", "http://localhost/v1/user", strings.NewReader(`
 	{"id": "testid", "first_name": "test", "last_name": "test", "email": "test@test.com", "nick_name": "test", "password": "test"}
 	`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAPIKey(req, "testid")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -36,3 +38,23 @@ func TestBuilder_WithV1UpdateUser(t *testing.T) {
 
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 }
+
+func TestBuilder_WithV1UpdateUser_KeepEmpty(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV1UpdateUser(func(ctx context.Context, req *users.UpdateReq) (*users.UpdateResp, error) {
+		require.Equal(t, users.SetString("test"), req.FirstName)
+		require.False(t, req.LastName.Set)
+		require.False(t, req.NickName.Set)
+		require.False(t, req.Attributes.Set)
+		return &users.UpdateResp{User: &users.User{ID: req.ID}}, nil
+	}).router
+
+	req := httptest.NewRequest("PUT", "http://localhost/v1/user", strings.NewReader(`
+	{"id": "testid", "first_name": "test", "last_name": "", "nick_name": null, "attributes": null}
+	`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
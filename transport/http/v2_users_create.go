@@ -0,0 +1,37 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+
+	"go-users-example/domain/users"
+)
+
+// WithV2CreateUser will add http endpoint to create new user, the location of the user is returned
+func (b *Builder) WithV2CreateUser(createUser users.Create) *Builder {
+	b.handle(operation{
+		method: http.MethodPost, path: "/v2/users", id: "v2CreateUser", summary: "Create a user",
+		params:  []parameter{acceptLanguage},
+		request: jsonContent(users.CreateReq{}),
+		responses: []response{{
+			status:  http.StatusCreated,
+			headers: map[string]string{"Location": "The path of the created user."},
+			content: jsonContent(userBody{User: localizedUser{}}),
+		}},
+		problems: []int{http.StatusConflict, http.StatusUnprocessableEntity},
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		var req users.CreateReq
+		if err := decodeBody(request, &req); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := createUser(request.Context(), &req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writer.Header().Set("Location", "/v2/users/"+url.PathEscape(res.User.ID))
+		writeJSON(writer, http.StatusCreated, userBody{User: localizeUser(writer, request, res.User)})
+	})
+	return b
+}
@@ -0,0 +1,34 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV2CreateUser(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV2CreateUser(func(ctx context.Context, req *users.CreateReq) (*users.CreateResp, error) {
+		return &users.CreateResp{User: &users.User{ID: "testid", Email: req.Email}}, nil
+	}).router
+
+	req := httptest.NewRequest("POST", "http://localhost/v2/users", strings.NewReader(`
+	{"first_name": "test", "last_name": "test", "email": "test@test.com", "nick_name": "test", "password": "test"}
+	`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, "/v2/users/testid", resp.Header.Get("Location"))
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.Contains(t, w.Body.String(), `"email":"test@test.com"`)
+}
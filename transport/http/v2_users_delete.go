@@ -0,0 +1,33 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"go-users-example/domain/users"
+)
+
+// WithV2DeleteUser will add http endpoint to delete a user, the deleted user is returned
+func (b *Builder) WithV2DeleteUser(deleteUser users.Delete) *Builder {
+	b.handle(operation{
+		method: http.MethodDelete, path: "/v2/users/{id}", id: "v2DeleteUser", summary: "Delete a user",
+		description:   "The user is soft deleted, it can be restored until it is purged.",
+		params:        []parameter{acceptLanguage},
+		responses:     []response{{status: http.StatusOK, content: jsonContent(userBody{User: localizedUser{}})}},
+		problems:      []int{http.StatusNotFound},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		if err := authorizeUser(request, chi.URLParam(request, "id")); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := deleteUser(request.Context(), &users.DeleteReq{ID: chi.URLParam(request, "id")})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, userBody{User: localizeUser(writer, request, res.User)})
+	})
+	return b
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+func TestBuilder_WithV2DeleteUser(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV2DeleteUser(func(ctx context.Context, req *users.DeleteReq) (*users.DeleteResp, error) {
+		if req.ID != "testid" {
+			return nil, fmt.Errorf("can't delete user: %w", userstore.ErrNotFound)
+		}
+		return &users.DeleteResp{User: &users.User{ID: req.ID}}, nil
+	}).router
+
+	req := httptest.NewRequest("DELETE", "http://localhost/v2/users/testid", nil)
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	req = httptest.NewRequest("DELETE", "http://localhost/v2/users/unknown", nil)
+	req = withAPIKey(req, "adminid", users.ScopeUsersAdmin)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"go-users-example/domain/users"
+)
+
+// WithV2GetUser will add http endpoint to get a user by its id, a deleted user isn't found
+func (b *Builder) WithV2GetUser(searchUser users.Search) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v2/users/{id}", id: "v2GetUser", summary: "Get a user",
+		params:        []parameter{acceptLanguage},
+		responses:     []response{{status: http.StatusOK, headers: etagHeader, content: jsonContent(userBody{User: localizedUser{}})}},
+		problems:      []int{http.StatusNotFound},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		if err := authorizeUser(request, chi.URLParam(request, "id")); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := searchUser(request.Context(), &users.SearchReq{IDs: []string{chi.URLParam(request, "id")}})
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if len(res.Users) != 1 {
+			writeError(b.log, writer, request, fmt.Errorf("can't get user: %w", users.ErrUserNotFound))
+			return
+		}
+		setETag(writer, res.Users[0])
+		writeJSON(writer, http.StatusOK, userBody{User: localizeUser(writer, request, res.Users[0])})
+	})
+	return b
+}
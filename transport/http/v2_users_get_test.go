@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV2GetUser(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV2GetUser(func(ctx context.Context, req *users.SearchReq) (*users.SearchResp, error) {
+		require.Equal(t, &users.SearchReq{IDs: []string{req.IDs[0]}}, req)
+		if req.IDs[0] != "testid" {
+			return &users.SearchResp{}, nil
+		}
+		return &users.SearchResp{Users: []*users.User{{ID: "testid", Country: "FR", Password: "$2a$12$hash"}}}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v2/users/testid", nil)
+	req.Header.Set("Accept-Language", "fr")
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.Equal(t, "fr", resp.Header.Get("Content-Language"))
+	require.Contains(t, w.Body.String(), `"country_name":"France"`)
+	require.NotContains(t, w.Body.String(), "password", "the password hash is never returned")
+
+	req = httptest.NewRequest("GET", "http://localhost/v2/users/testid", nil)
+	req = withAPIKey(req, "testid")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.NotContains(t, w.Body.String(), "password", "the password hash isn't returned without a language either")
+
+	req = httptest.NewRequest("GET", "http://localhost/v2/users/unknown", nil)
+	req = withAPIKey(req, "adminid", users.ScopeUsersAdmin)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp = w.Result()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+}
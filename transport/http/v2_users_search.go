@@ -0,0 +1,36 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV2SearchUser will add http endpoint to search users, with the same parameters as WithV1SearchUser
+// Note: here pagination is not implemented, so too many users can break the response
+func (b *Builder) WithV2SearchUser(searchUser users.Search) *Builder {
+	b.handle(operation{
+		method: http.MethodGet, path: "/v2/users", id: "v2SearchUsers", summary: "Search the users",
+		description: searchDescription,
+		params:      append([]parameter{acceptLanguage}, searchParams...),
+		responses:   []response{{status: http.StatusOK, content: jsonContent(usersBody{Users: []localizedUser{}})}},
+		admin:       true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		req, status, err := parseSearchRequest(request)
+		if err != nil {
+			writeStatusProblem(writer, request, status)
+			return
+		}
+		res, err := searchUser(request.Context(), req)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		usrs := res.Users
+		if usrs == nil {
+			usrs = []*users.User{}
+		}
+		writeJSON(writer, http.StatusOK, usersBody{Users: localizeUsers(writer, request, usrs)})
+	})
+	return b
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+)
+
+func TestBuilder_WithV2SearchUser(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV2SearchUser(func(ctx context.Context, req *users.SearchReq) (*users.SearchResp, error) {
+		require.Equal(t, []string{"test@test.com"}, req.Emails)
+		return &users.SearchResp{}, nil
+	}).router
+
+	req := httptest.NewRequest("GET", "http://localhost/v2/users?email=test@test.com", nil)
+	req = withAPIKey(req, "adminid", users.ScopeUsersAdmin)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.JSONEq(t, `{"users": []}`, w.Body.String())
+}
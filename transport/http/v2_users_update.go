@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http"
+
+	"go-users-example/domain/users"
+)
+
+// WithV2UpdateUser will add http endpoint to partially update a user, see WithV1PatchUser for the accepted patches
+func (b *Builder) WithV2UpdateUser(updateUser users.Update) *Builder {
+	b.handle(operation{
+		method: http.MethodPatch, path: "/v2/users/{id}", id: "v2UpdateUser", summary: "Partially update a user",
+		description:   patchDescription,
+		params:        []parameter{acceptLanguage, ifMatch},
+		request:       patchContent,
+		responses:     []response{{status: http.StatusOK, headers: etagHeader, content: jsonContent(userBody{User: localizedUser{}})}},
+		problems:      []int{http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity},
+		authenticated: true,
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		req, err := parsePatchRequest(request)
+		if err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		if err := authorizeUser(request, req.ID); err != nil {
+			writeError(b.log, writer, request, err)
+			return
+		}
+		res, err := updateUser(request.Context(), req)
+		if err != nil {
+			writeError(b.log, writer, request, preconditionFailed(req, err))
+			return
+		}
+		setETag(writer, res.User)
+		writeJSON(writer, http.StatusOK, userBody{User: localizeUser(writer, request, res.User)})
+	})
+	return b
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go-users-example/domain/users"
+	"go-users-example/infra/logger"
+	"go-users-example/infra/userstore"
+)
+
+func TestBuilder_WithV2UpdateUser(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV2UpdateUser(func(ctx context.Context, req *users.UpdateReq) (*users.UpdateResp, error) {
+		require.Equal(t, &users.UpdateReq{ID: "testid", NickName: users.NullString()}, req)
+		return &users.UpdateResp{User: &users.User{ID: req.ID, Version: 2}}, nil
+	}).router
+
+	req := httptest.NewRequest("PATCH", "http://localhost/v2/users/testid", strings.NewReader(`{"nick_name": null}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req = withAPIKey(req, "testid")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.Equal(t, `"2"`, resp.Header.Get("ETag"))
+}
+
+func TestBuilder_WithV2UpdateUser_IfMatch(t *testing.T) {
+	router := NewBuilder(logger.Logger{}, Config{}).WithV2UpdateUser(func(ctx context.Context, req *users.UpdateReq) (*users.UpdateResp, error) {
+		switch req.Version {
+		case 0, 2:
+			return &users.UpdateResp{User: &users.User{ID: req.ID, Version: 3}}, nil
+		case 3:
+			return nil, fmt.Errorf("can't save new user: %w", userstore.ErrVersionConflict)
+		default:
+			return nil, fmt.Errorf("can't update user: %w", users.ErrPreconditionFailed)
+		}
+	}).router
+
+	for name, tc := range map[string]struct {
+		ifMatch string
+		status  int
+	}{
+		"no etag":              {status: http.StatusOK},
+		"any version":          {ifMatch: "*", status: http.StatusOK},
+		"current version":      {ifMatch: `"2"`, status: http.StatusOK},
+		"previous version":     {ifMatch: `"1"`, status: http.StatusPreconditionFailed},
+		"changed concurrently": {ifMatch: `"3"`, status: http.StatusPreconditionFailed},
+		"weak etag":            {ifMatch: `W/"2"`, status: http.StatusPreconditionFailed},
+		"not a version":        {ifMatch: `"abc"`, status: http.StatusPreconditionFailed},
+	} {
+		req := httptest.NewRequest("PATCH", "http://localhost/v2/users/testid", strings.NewReader(`{"first_name": "test"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		if tc.ifMatch != "" {
+			req.Header.Set("If-Match", tc.ifMatch)
+		}
+		req = withAPIKey(req, "testid")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+		resp := w.Result()
+
+		require.Equal(t, tc.status, resp.StatusCode, name)
+		if tc.status == http.StatusPreconditionFailed {
+			require.Contains(t, w.Body.String(), "/problems/precondition-failed", name)
+		}
+	}
+}